@@ -0,0 +1,315 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+	"github.com/colony-2/devcontainer-go/pkg/devcontainer"
+)
+
+// upResult is the output of up
+type upResult struct {
+	Outcome               string `json:"outcome"`
+	ContainerID           string `json:"containerId"`
+	RemoteUser            string `json:"remoteUser,omitempty"`
+	RemoteWorkspaceFolder string `json:"remoteWorkspaceFolder"`
+}
+
+// containerResult is the output of commands acting on a container
+type containerResult struct {
+	Outcome     string `json:"outcome"`
+	ContainerID string `json:"containerId"`
+}
+
+func up(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	var cf configFlags
+	fs := newFlagSet("up", stderr)
+	cf.register(fs)
+	removeExisting := fs.Bool("remove-existing-container", false, "Remove the dev container if it already exists")
+	expectExisting := fs.Bool("expect-existing-container", false, "Fail if the dev container does not exist")
+	skipPostCreate := fs.Bool("skip-post-create", false, "Do not run onCreateCommand and the lifecycle commands after it")
+	skipPostAttach := fs.Bool("skip-post-attach", false, "Do not run postAttachCommand")
+	skipNonBlocking := fs.Bool("skip-non-blocking-commands", false, "Stop running lifecycle commands after the waitFor command")
+	prebuild := fs.Bool("prebuild", false, "Stop running lifecycle commands after updateContentCommand")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	mgr, err := devcontainer.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	defer mgr.Close()
+	opts := cf.createOptions()
+
+	id, err := mgr.FindContainer(ctx, cf.workspaceFolder, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if id != "" && *removeExisting {
+		if err := mgr.Remove(ctx, id); err != nil {
+			return nil, err
+		}
+		id = ""
+	}
+	if id == "" && *expectExisting {
+		return nil, fmt.Errorf("dev container not found for %s", cf.workspaceFolder)
+	}
+
+	// New containers run every lifecycle command, restarted ones from
+	// postStartCommand and running ones only postAttachCommand
+	startAt := "postAttachCommand"
+	if id == "" {
+		if err := mgr.RunInitializeCommand(ctx, cf.workspaceFolder, stderr, opts...); err != nil {
+			return nil, err
+		}
+		if id, err = mgr.Create(ctx, cf.workspaceFolder, opts...); err != nil {
+			return nil, err
+		}
+		startAt = ""
+	}
+	status, err := mgr.GetStatus(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if status != api.StatusRunning {
+		if err := mgr.Start(ctx, id); err != nil {
+			return nil, err
+		}
+		if startAt != "" {
+			startAt = "postStartCommand"
+		}
+	}
+
+	if !*skipPostCreate {
+		err := mgr.RunUserCommands(ctx, id, cf.workspaceFolder, devcontainer.UserCommandOptions{
+			StartAt:         startAt,
+			Prebuild:        *prebuild,
+			SkipNonBlocking: *skipNonBlocking,
+			SkipPostAttach:  *skipPostAttach,
+			Output:          stderr,
+		}, opts...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := mgr.ReadConfiguration(ctx, cf.workspaceFolder, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return upResult{
+		Outcome:               "success",
+		ContainerID:           id,
+		RemoteUser:            remoteUser(config),
+		RemoteWorkspaceFolder: config.Workspace.WorkspaceFolder,
+	}, nil
+}
+
+func build(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	var cf configFlags
+	fs := newFlagSet("build", stderr)
+	cf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	mgr, err := devcontainer.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	defer mgr.Close()
+	image, err := mgr.Build(ctx, cf.workspaceFolder, cf.createOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"outcome": "success", "imageName": []string{image}}, nil
+}
+
+func execCommand(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	var cf containerFlags
+	var remoteEnv stringList
+	fs := newFlagSet("exec", stderr)
+	cf.register(fs)
+	fs.Var(&remoteEnv, "remote-env", "Remote environment variable NAME=VALUE (repeatable)")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() == 0 {
+		return nil, fmt.Errorf("no command given")
+	}
+
+	mgr, err := devcontainer.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	defer mgr.Close()
+	id, err := cf.find(ctx, mgr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Run like the lifecycle commands: as the remote user in the workspace
+	config, err := mgr.ReadConfiguration(ctx, cf.workspaceFolder, cf.createOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	opts := devcontainer.ExecOptions{
+		User:       remoteUser(config),
+		WorkingDir: config.Workspace.WorkspaceFolder,
+		Env:        map[string]string{},
+		Stdout:     stdout,
+		Stderr:     stderr,
+	}
+	if env, ok := config.MergedConfiguration["remoteEnv"].(map[string]interface{}); ok {
+		for k, v := range env {
+			if s, ok := v.(string); ok {
+				opts.Env[k] = s
+			}
+		}
+	}
+	for _, kv := range remoteEnv {
+		k, v, ok := strings.Cut(kv, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid remote-env %q, expected NAME=VALUE", kv)
+		}
+		opts.Env[k] = v
+	}
+
+	_, err = mgr.ExecWithOptions(ctx, id, opts, fs.Args())
+	var execErr *devcontainer.ExecError
+	if errors.As(err, &execErr) {
+		return nil, &exitError{code: execErr.ExitCode}
+	}
+	return nil, err
+}
+
+// remoteUser is the user commands run as in the container: remoteUser,
+// falling back to containerUser
+func remoteUser(config *devcontainer.ReadConfigurationResult) string {
+	user, _ := config.MergedConfiguration["remoteUser"].(string)
+	if user == "" {
+		user, _ = config.MergedConfiguration["containerUser"].(string)
+	}
+	return user
+}
+
+func runUserCommands(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	var cf containerFlags
+	fs := newFlagSet("run-user-commands", stderr)
+	cf.register(fs)
+	skipPostAttach := fs.Bool("skip-post-attach", false, "Do not run postAttachCommand")
+	skipNonBlocking := fs.Bool("skip-non-blocking-commands", false, "Stop running lifecycle commands after the waitFor command")
+	prebuild := fs.Bool("prebuild", false, "Stop running lifecycle commands after updateContentCommand")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	mgr, err := devcontainer.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	defer mgr.Close()
+	id, err := cf.find(ctx, mgr)
+	if err != nil {
+		return nil, err
+	}
+	err = mgr.RunUserCommands(ctx, id, cf.workspaceFolder, devcontainer.UserCommandOptions{
+		Prebuild:        *prebuild,
+		SkipNonBlocking: *skipNonBlocking,
+		SkipPostAttach:  *skipPostAttach,
+		Output:          stderr,
+	}, cf.createOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{"outcome": "success"}, nil
+}
+
+func stop(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	return withContainer(ctx, "stop", args, stderr, func(mgr *devcontainer.Manager, id string) error {
+		return mgr.Stop(ctx, id)
+	})
+}
+
+func down(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	return withContainer(ctx, "down", args, stderr, func(mgr *devcontainer.Manager, id string) error {
+		return mgr.Remove(ctx, id)
+	})
+}
+
+// withContainer runs action on the selected container
+func withContainer(ctx context.Context, name string, args []string, stderr io.Writer, action func(*devcontainer.Manager, string) error) (interface{}, error) {
+	var cf containerFlags
+	fs := newFlagSet(name, stderr)
+	cf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	mgr, err := devcontainer.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	defer mgr.Close()
+	id, err := cf.find(ctx, mgr)
+	if err != nil {
+		return nil, err
+	}
+	if err := action(mgr, id); err != nil {
+		return nil, err
+	}
+	return containerResult{Outcome: "success", ContainerID: id}, nil
+}
+
+func attach(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	var cf containerFlags
+	fs := newFlagSet("attach", stderr)
+	cf.register(fs)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	mgr, err := devcontainer.NewManager()
+	if err != nil {
+		return nil, err
+	}
+	defer mgr.Close()
+	id, err := cf.find(ctx, mgr)
+	if err != nil {
+		return nil, err
+	}
+	return nil, mgr.AttachInteractive(ctx, id)
+}
+
+func readConfiguration(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error) {
+	var cf configFlags
+	fs := newFlagSet("read-configuration", stderr)
+	cf.register(fs)
+	includeMerged := fs.Bool("include-merged-configuration", false, "Include the configuration merged with the image metadata")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Image metadata needs Docker; the plain configuration does not
+	mgr := &devcontainer.Manager{}
+	if *includeMerged {
+		var err error
+		if mgr, err = devcontainer.NewManager(); err != nil {
+			return nil, err
+		}
+		defer mgr.Close()
+	}
+	result, err := mgr.ReadConfiguration(ctx, cf.workspaceFolder, cf.createOptions()...)
+	if err != nil {
+		return nil, err
+	}
+	if !*includeMerged {
+		result.MergedConfiguration = nil
+	}
+	return result, nil
+}
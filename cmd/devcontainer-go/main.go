@@ -0,0 +1,171 @@
+// Command devcontainer-go works with dev containers from the command line.
+// Its subcommands and flags mirror the reference devcontainer CLI: results
+// are printed as a single line of JSON on stdout, progress and command
+// output go to stderr.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+	"github.com/colony-2/devcontainer-go/pkg/devcontainer"
+)
+
+// command is a subcommand of devcontainer-go. run returns the result to
+// print as JSON, or nil if the command wrote its own output.
+type command struct {
+	summary string
+	run     func(ctx context.Context, args []string, stdout, stderr io.Writer) (interface{}, error)
+}
+
+var commands = map[string]command{
+	"up":                 {"Create and run a dev container", up},
+	"build":              {"Build the image of a dev container", build},
+	"exec":               {"Execute a command in a dev container", execCommand},
+	"run-user-commands":  {"Run the lifecycle commands in a dev container", runUserCommands},
+	"stop":               {"Stop a dev container", stop},
+	"down":               {"Stop and remove a dev container", down},
+	"read-configuration": {"Print the resolved configuration", readConfiguration},
+	"attach":             {"Attach a terminal to a dev container", attach},
+}
+
+// exitError makes the process exit with a code without printing an error
+type exitError struct {
+	code int
+}
+
+func (e *exitError) Error() string {
+	return fmt.Sprintf("exit code %d", e.code)
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	stop()
+	os.Exit(code)
+}
+
+// run runs a subcommand and returns the process exit code
+func run(ctx context.Context, args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] == "-h" || args[0] == "--help" || args[0] == "help" {
+		usage(stderr)
+		return 0
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "unknown command %q\n\n", args[0])
+		usage(stderr)
+		return 2
+	}
+
+	result, err := cmd.run(ctx, args[1:], stdout, stderr)
+	var exit *exitError
+	switch {
+	case errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &exit):
+		return exit.code
+	case err != nil:
+		writeJSON(stdout, map[string]string{"outcome": "error", "message": err.Error()})
+		return 1
+	case result != nil:
+		if err := writeJSON(stdout, result); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	return 0
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "Usage: devcontainer-go <command> [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-20s %s\n", name, commands[name].summary)
+	}
+}
+
+// configFlags are the flags selecting a workspace and its configuration
+type configFlags struct {
+	workspaceFolder string
+	config          string
+	profiles        stringList
+}
+
+func (f *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.workspaceFolder, "workspace-folder", ".", "Workspace folder path")
+	fs.StringVar(&f.config, "config", "", "devcontainer.json path; defaults to the workspace's default configuration")
+	fs.Var(&f.profiles, "profile", "Configuration profile to apply (repeatable)")
+}
+
+// createOptions returns the options selecting the configuration
+func (f *configFlags) createOptions() []api.CreateOption {
+	var opts []api.CreateOption
+	if f.config != "" {
+		opts = append(opts, api.WithConfigPath(f.config))
+	}
+	if len(f.profiles) > 0 {
+		opts = append(opts, api.WithProfiles(f.profiles...))
+	}
+	return opts
+}
+
+// containerFlags select an existing dev container, by id or by workspace
+type containerFlags struct {
+	configFlags
+	containerID string
+}
+
+func (f *containerFlags) register(fs *flag.FlagSet) {
+	f.configFlags.register(fs)
+	fs.StringVar(&f.containerID, "container-id", "", "Id of the container; defaults to the container of the workspace")
+}
+
+// find returns the selected container
+func (f *containerFlags) find(ctx context.Context, mgr *devcontainer.Manager) (string, error) {
+	if f.containerID != "" {
+		return f.containerID, nil
+	}
+	id, err := mgr.FindContainer(ctx, f.workspaceFolder, f.createOptions()...)
+	if err != nil {
+		return "", err
+	}
+	if id == "" {
+		return "", fmt.Errorf("dev container not found for %s", f.workspaceFolder)
+	}
+	return id, nil
+}
+
+// stringList is a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string     { return strings.Join(*l, ",") }
+func (l *stringList) Set(v string) error { *l = append(*l, v); return nil }
+
+func newFlagSet(name string, stderr io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet("devcontainer-go "+name, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	return fs
+}
+
+// writeJSON prints v as a single line of JSON
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/devcontainer"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, ".devcontainer"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"image": "alpine:3.19", "containerEnv": {"HOME_DIR": "${localWorkspaceFolder}"}}`
+	if err := os.WriteFile(filepath.Join(dir, ".devcontainer", "devcontainer.json"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".devcontainer", "devcontainer.ci.json"), []byte(`{"remoteUser": "ci"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("read-configuration", func(t *testing.T) {
+		var stdout, stderr strings.Builder
+		code := run(context.Background(), []string{"read-configuration", "--workspace-folder", dir, "--profile", "ci"}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("exit code %d: %s%s", code, stdout.String(), stderr.String())
+		}
+		if strings.Count(stdout.String(), "\n") != 1 {
+			t.Errorf("expected a single line of JSON, got %q", stdout.String())
+		}
+		var out struct {
+			Configuration       map[string]interface{} `json:"configuration"`
+			MergedConfiguration map[string]interface{} `json:"mergedConfiguration"`
+		}
+		if err := json.Unmarshal([]byte(stdout.String()), &out); err != nil {
+			t.Fatal(err)
+		}
+		if out.Configuration["remoteUser"] != "ci" || out.Configuration["containerEnv"].(map[string]interface{})["HOME_DIR"] != dir {
+			t.Errorf("unexpected configuration %v", out.Configuration)
+		}
+		if out.MergedConfiguration != nil {
+			t.Error("expected no merged configuration without --include-merged-configuration")
+		}
+	})
+
+	t.Run("error outcome", func(t *testing.T) {
+		var stdout, stderr strings.Builder
+		code := run(context.Background(), []string{"read-configuration", "--workspace-folder", dir, "--profile", "missing"}, &stdout, &stderr)
+		var out map[string]string
+		if err := json.Unmarshal([]byte(stdout.String()), &out); err != nil {
+			t.Fatal(err)
+		}
+		if code != 1 || out["outcome"] != "error" || !strings.Contains(out["message"], "missing") {
+			t.Errorf("unexpected result %d %v", code, out)
+		}
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		var stdout, stderr strings.Builder
+		if code := run(context.Background(), nil, &stdout, &stderr); code != 0 {
+			t.Errorf("expected exit code 0, got %d", code)
+		}
+		for name := range commands {
+			if !strings.Contains(stderr.String(), name) {
+				t.Errorf("expected usage to list %s", name)
+			}
+		}
+		if code := run(context.Background(), []string{"rebuild"}, &stdout, &stderr); code != 2 {
+			t.Errorf("expected exit code 2 for an unknown command, got %d", code)
+		}
+	})
+}
+
+func TestRemoteUser(t *testing.T) {
+	tests := []struct {
+		merged map[string]interface{}
+		want   string
+	}{
+		{map[string]interface{}{"remoteUser": "vscode", "containerUser": "root"}, "vscode"},
+		{map[string]interface{}{"containerUser": "root"}, "root"},
+		{nil, ""},
+	}
+	for _, tt := range tests {
+		config := &devcontainer.ReadConfigurationResult{MergedConfiguration: tt.merged}
+		if got := remoteUser(config); got != tt.want {
+			t.Errorf("remoteUser(%v) = %q, expected %q", tt.merged, got, tt.want)
+		}
+	}
+}
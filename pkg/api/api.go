@@ -2,26 +2,82 @@ package api
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"time"
 )
 
 // Status represents the current status of a container.
 type Status string
 
 const (
-	StatusNone    Status = "none"    // No container exists
-	StatusStopped Status = "stopped" // Container exists but is not running
-	StatusRunning Status = "running" // Container is running
-	StatusError   Status = "error"   // Container is in error state
+	StatusNone       Status = "none"       // No container exists
+	StatusCreated    Status = "created"    // Container exists but was never started
+	StatusStopped    Status = "stopped"    // Container exists but is not running
+	StatusRunning    Status = "running"    // Container is running
+	StatusPaused     Status = "paused"     // Container processes are paused
+	StatusRestarting Status = "restarting" // Container is being restarted by its restart policy
+	StatusError      Status = "error"      // Container is in error state
 )
 
 // Info contains information about a container.
 type Info struct {
 	ID      string            `json:"id"`
+	Name    string            `json:"name,omitempty"`
 	Status  Status            `json:"status"`
 	Image   string            `json:"image,omitempty"`
 	Created string            `json:"created,omitempty"`
 	Ports   map[string]string `json:"ports,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Mounts  []Mount           `json:"mounts,omitempty"`
+
+	StartedAt  string `json:"startedAt,omitempty"`
+	FinishedAt string `json:"finishedAt,omitempty"`
+	ExitCode   int    `json:"exitCode"`
+	OOMKilled  bool   `json:"oomKilled,omitempty"`
+	Health     string `json:"health,omitempty"`     // starting, healthy or unhealthy; empty without a healthcheck
+	RemoteUser string `json:"remoteUser,omitempty"` // User commands and copies run as
+
+	// Read from the labels the container was created with
+	WorkspaceFolder string `json:"workspaceFolder,omitempty"` // Local workspace folder
+	ConfigPath      string `json:"configPath,omitempty"`      // devcontainer.json used
+	DevContainerID  string `json:"devcontainerId,omitempty"`
+	ConfigHash      string `json:"configHash,omitempty"` // Hash of the configuration used
+	Version         string `json:"version,omitempty"`    // Version of the library that created it
+}
+
+// ListFilter selects the containers returned by Manager.List. Empty fields
+// match any container.
+type ListFilter struct {
+	WorkspaceFolder string
+	ConfigPath      string
+	DevContainerID  string
+	Status          Status
+
+	// Labels the containers must have; an empty value matches any value
+	Labels map[string]string
+}
+
+// Stats is a resource usage reading of a container. CPU and memory are
+// computed like docker stats does.
+type Stats struct {
+	Time time.Time `json:"time"`
+
+	// CPUPercent is the share of the host CPU time used since the previous
+	// reading, where each online CPU counts for 100%
+	CPUPercent float64 `json:"cpuPercent"`
+
+	MemoryUsage   uint64  `json:"memoryUsage"` // Bytes, without the inactive page cache
+	MemoryLimit   uint64  `json:"memoryLimit"`
+	MemoryPercent float64 `json:"memoryPercent"`
+
+	NetworkRx  uint64 `json:"networkRx"` // Bytes received on all interfaces
+	NetworkTx  uint64 `json:"networkTx"`
+	BlockRead  uint64 `json:"blockRead"` // Bytes read from block devices
+	BlockWrite uint64 `json:"blockWrite"`
+	PIDs       uint64 `json:"pids"`
 }
 
 // Mount represents a container mount configuration
@@ -32,10 +88,62 @@ type Mount struct {
 	ReadOnly bool   // read-only flag
 }
 
+// CreateOptions holds the optional settings of Manager.Create.
+type CreateOptions struct {
+	// ConfigPath is the devcontainer.json to use. Relative paths resolve
+	// against the node path. If empty, the default configuration of the
+	// node path is used.
+	ConfigPath string
+
+	// Profiles are the named configuration profiles applied on top of the
+	// configuration, in order.
+	Profiles []string
+
+	// RemoteWorkspace copies the workspace into a volume instead of bind
+	// mounting it, for daemons that cannot see the local file system. It
+	// is implied when the daemon is remote.
+	RemoteWorkspace bool
+}
+
+// CreateOption configures Manager.Create.
+type CreateOption func(*CreateOptions)
+
+// WithConfigPath selects the devcontainer.json used by Manager.Create.
+func WithConfigPath(path string) CreateOption {
+	return func(o *CreateOptions) {
+		o.ConfigPath = path
+	}
+}
+
+// WithProfiles applies named configuration profiles on top of the
+// devcontainer.json used by Manager.Create. Repeated use appends.
+func WithProfiles(names ...string) CreateOption {
+	return func(o *CreateOptions) {
+		o.Profiles = append(o.Profiles, names...)
+	}
+}
+
+// WithRemoteWorkspace makes Manager.Create copy the workspace into a volume
+// instead of bind mounting it.
+func WithRemoteWorkspace() CreateOption {
+	return func(o *CreateOptions) {
+		o.RemoteWorkspace = true
+	}
+}
+
+// ApplyCreateOptions returns the CreateOptions set by opts.
+func ApplyCreateOptions(opts ...CreateOption) CreateOptions {
+	var o CreateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
 // Manager provides container lifecycle operations.
 type Manager interface {
 	// Create creates a new container for the specified node.
-	Create(ctx context.Context, nodePath string) (containerID string, err error)
+	Create(ctx context.Context, nodePath string, opts ...CreateOption) (containerID string, err error)
 
 	// Start starts an existing container.
 	Start(ctx context.Context, containerID string) error
@@ -55,6 +163,14 @@ type Manager interface {
 	// GetStatus returns the current status of a container.
 	GetStatus(ctx context.Context, containerID string) (Status, error)
 
+	// List returns the containers created by the manager that match filter,
+	// most recent first.
+	List(ctx context.Context, filter ListFilter) ([]Info, error)
+
+	// FindByWorkspace returns the containers created for a local workspace
+	// folder, most recent first.
+	FindByWorkspace(ctx context.Context, workspaceFolder string) ([]Info, error)
+
 	// Exec executes a command in a running container.
 	Exec(ctx context.Context, containerID string, command []string) (output string, err error)
 
@@ -63,8 +179,96 @@ type Manager interface {
 
 	// ConfigureMounts configures custom mount points for containers.
 	ConfigureMounts(mounts []Mount) error
+
+	// CopyTo copies a host file or directory, or the content of a reader,
+	// to containerPath in a container.
+	CopyTo(ctx context.Context, containerID string, src CopySource, containerPath string, opts ...CopyOption) error
+
+	// CopyFrom returns the content of a file in a container.
+	CopyFrom(ctx context.Context, containerID, containerPath string, opts ...CopyOption) (io.ReadCloser, error)
+
+	// CopyFromToDir copies a file or directory of a container into hostDir.
+	CopyFromToDir(ctx context.Context, containerID, containerPath, hostDir string, opts ...CopyOption) error
+
+	// Stats returns a resource usage reading of a running container.
+	Stats(ctx context.Context, containerID string) (*Stats, error)
+
+	// StatsStream sends a reading about every second until ctx is done. The
+	// readings channel is closed when the stream ends; a failure other than
+	// ctx being done is sent on the error channel first.
+	StatsStream(ctx context.Context, containerID string) (<-chan Stats, <-chan error)
+}
+
+// CopySource is what CopyTo copies: a host path, or the content of a single
+// file read from Reader if HostPath is empty.
+type CopySource struct {
+	HostPath string
+	Reader   io.Reader
+	Mode     fs.FileMode // Permissions of the file written from Reader; 0644 if zero
+}
+
+// FromHostPath copies a host file or directory.
+func FromHostPath(path string) CopySource {
+	return CopySource{HostPath: path}
 }
 
+// FromReader copies the content of r as a single file.
+func FromReader(r io.Reader) CopySource {
+	return CopySource{Reader: r}
+}
+
+// CopyOptions configures CopyTo, CopyFrom and CopyFromToDir.
+type CopyOptions struct {
+	// Owner is the user[:group] that owns the copied files in the
+	// container. If empty, CopyTo uses the remote user of the dev
+	// container.
+	Owner string
+
+	// MaxSize limits the bytes of file content copied; 0 means no limit.
+	// Exceeding it fails with ErrCopyTooLarge.
+	MaxSize int64
+
+	// FollowSymlinks copies the targets of symlinks to files instead of the
+	// links themselves.
+	FollowSymlinks bool
+}
+
+// CopyOption configures a copy.
+type CopyOption func(*CopyOptions)
+
+// WithOwner sets the user[:group] owning files copied into a container.
+func WithOwner(owner string) CopyOption {
+	return func(o *CopyOptions) {
+		o.Owner = owner
+	}
+}
+
+// WithMaxSize limits the bytes of file content a copy transfers.
+func WithMaxSize(bytes int64) CopyOption {
+	return func(o *CopyOptions) {
+		o.MaxSize = bytes
+	}
+}
+
+// WithFollowSymlinks copies the targets of symlinks to files.
+func WithFollowSymlinks() CopyOption {
+	return func(o *CopyOptions) {
+		o.FollowSymlinks = true
+	}
+}
+
+// ApplyCopyOptions returns the CopyOptions set by opts.
+func ApplyCopyOptions(opts ...CopyOption) CopyOptions {
+	var o CopyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// ErrCopyTooLarge is returned when a copy exceeds its size limit.
+var ErrCopyTooLarge = errors.New("copy exceeds size limit")
+
 // TerminalConnection represents a terminal connection to a container.
 type TerminalConnection interface {
 	// Read reads data from the terminal.
@@ -123,7 +327,7 @@ func newInternalManager() (Manager, error) {
 type stubManager struct{}
 
 // Create creates a new container for the specified node
-func (m *stubManager) Create(ctx context.Context, nodePath string) (containerID string, err error) {
+func (m *stubManager) Create(ctx context.Context, nodePath string, opts ...CreateOption) (containerID string, err error) {
 	return "", fmt.Errorf("container creation not implemented")
 }
 
@@ -157,6 +361,16 @@ func (m *stubManager) GetStatus(ctx context.Context, containerID string) (Status
 	return StatusNone, fmt.Errorf("container status not implemented")
 }
 
+// List lists containers
+func (m *stubManager) List(ctx context.Context, filter ListFilter) ([]Info, error) {
+	return nil, fmt.Errorf("container list not implemented")
+}
+
+// FindByWorkspace finds the containers of a workspace
+func (m *stubManager) FindByWorkspace(ctx context.Context, workspaceFolder string) ([]Info, error) {
+	return nil, fmt.Errorf("container lookup not implemented")
+}
+
 // Exec executes a command in a running container
 func (m *stubManager) Exec(ctx context.Context, containerID string, command []string) (output string, err error) {
 	return "", fmt.Errorf("container exec not implemented")
@@ -171,3 +385,31 @@ func (m *stubManager) AttachWebSocket(ctx context.Context, containerID string) (
 func (m *stubManager) ConfigureMounts(mounts []Mount) error {
 	return fmt.Errorf("container mount configuration not implemented")
 }
+
+// CopyTo copies into a container
+func (m *stubManager) CopyTo(ctx context.Context, containerID string, src CopySource, containerPath string, opts ...CopyOption) error {
+	return fmt.Errorf("copy to container not implemented")
+}
+
+// CopyFrom copies out of a container
+func (m *stubManager) CopyFrom(ctx context.Context, containerID, containerPath string, opts ...CopyOption) (io.ReadCloser, error) {
+	return nil, fmt.Errorf("copy from container not implemented")
+}
+
+// CopyFromToDir copies out of a container into a directory
+func (m *stubManager) CopyFromToDir(ctx context.Context, containerID, containerPath, hostDir string, opts ...CopyOption) error {
+	return fmt.Errorf("copy from container not implemented")
+}
+
+// Stats returns a resource usage reading
+func (m *stubManager) Stats(ctx context.Context, containerID string) (*Stats, error) {
+	return nil, fmt.Errorf("container stats not implemented")
+}
+
+// StatsStream streams resource usage readings
+func (m *stubManager) StatsStream(ctx context.Context, containerID string) (<-chan Stats, <-chan error) {
+	readings, errs := make(chan Stats), make(chan error, 1)
+	errs <- fmt.Errorf("container stats not implemented")
+	close(readings)
+	return readings, errs
+}
@@ -0,0 +1,633 @@
+package devcontainer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// CLIEngine is an Engine that runs a Docker compatible command line tool,
+// for environments where the binary works but the API socket cannot be
+// reached. Containers are created from DockerRunConfig.ToDockerRunArgs, so
+// runArgs are honored.
+type CLIEngine struct {
+	// Binary is the tool to run, e.g. "docker", "podman" or "nerdctl", or a
+	// path to it
+	Binary string
+
+	// Env is the environment of the tool, e.g. DOCKER_HOST; the environment
+	// of the process if nil
+	Env []string
+}
+
+// NewCLIEngine returns an engine running binary, "docker" if empty
+func NewCLIEngine(binary string) *CLIEngine {
+	if binary == "" {
+		binary = "docker"
+	}
+	return &CLIEngine{Binary: binary}
+}
+
+// CLIError reports a failed invocation of the command line tool
+type CLIError struct {
+	Args     []string
+	ExitCode int
+	Stderr   string
+}
+
+func (e *CLIError) Error() string {
+	msg := strings.TrimSpace(e.Stderr)
+	if msg == "" {
+		msg = fmt.Sprintf("exit code %d", e.ExitCode)
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(e.Args, " "), msg)
+}
+
+// run runs the tool and returns its standard output. A non-zero exit code
+// is reported as a *CLIError.
+func (e *CLIEngine) run(ctx context.Context, stdin io.Reader, args ...string) (string, error) {
+	var stdout bytes.Buffer
+	err := e.stream(ctx, stdin, &stdout, nil, args...)
+	return stdout.String(), err
+}
+
+// stream runs the tool with args, writing its output to stdout and stderr as
+// it is produced. A nil stderr is kept for the *CLIError of a failure.
+func (e *CLIEngine) stream(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args ...string) error {
+	cmd := exec.CommandContext(ctx, e.Binary, args...)
+	cmd.Env = e.Env
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	var captured bytes.Buffer
+	cmd.Stderr = &captured
+	if stderr != nil {
+		cmd.Stderr = stderr
+	}
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return err
+		}
+		return &CLIError{Args: append([]string{e.Binary}, args...), ExitCode: exitErr.ExitCode(), Stderr: captured.String()}
+	}
+	return nil
+}
+
+// CreateContainer implements Engine
+func (e *CLIEngine) CreateContainer(ctx context.Context, config *DockerRunConfig) (string, error) {
+	out, err := e.run(ctx, nil, createArgs(config)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to create container: %w", err)
+	}
+	return lastLine(out), nil
+}
+
+// createArgs turns the docker run arguments of config into create arguments
+// for a long-running interactive container
+func createArgs(config *DockerRunConfig) []string {
+	run := config.ToDockerRunArgs()
+	args := []string{"create", "-it"}
+	for i := 3; i < len(run); i++ {
+		// The workspace mount uses --mount syntax
+		if run[i] == "-v" && i+1 < len(run) && strings.Contains(run[i+1], "=") {
+			args = append(args, "--mount", run[i+1])
+			i++
+			continue
+		}
+		args = append(args, run[i])
+	}
+	return args
+}
+
+// StartContainer implements Engine
+func (e *CLIEngine) StartContainer(ctx context.Context, containerID string) error {
+	if _, err := e.run(ctx, nil, "start", containerID); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+	return nil
+}
+
+// StopContainer implements Engine
+func (e *CLIEngine) StopContainer(ctx context.Context, containerID string) error {
+	if _, err := e.run(ctx, nil, "stop", "-t", "10", containerID); err != nil {
+		return fmt.Errorf("failed to stop container: %w", err)
+	}
+	return nil
+}
+
+// RemoveContainer implements Engine
+func (e *CLIEngine) RemoveContainer(ctx context.Context, containerID string) error {
+	if _, err := e.run(ctx, nil, "rm", "-f", containerID); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	return nil
+}
+
+// cliInspect is the part of `inspect --format json` output CLIEngine reads
+type cliInspect struct {
+	ID      string `json:"Id"`
+	Name    string `json:"Name"`
+	Created string `json:"Created"`
+	Config  struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+		User   string            `json:"User"`
+		Tty    bool              `json:"Tty"`
+	} `json:"Config"`
+	State struct {
+		Status     string `json:"Status"`
+		StartedAt  string `json:"StartedAt"`
+		FinishedAt string `json:"FinishedAt"`
+		ExitCode   int    `json:"ExitCode"`
+		OOMKilled  bool   `json:"OOMKilled"`
+		Health     *struct {
+			Status string `json:"Status"`
+		} `json:"Health"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Ports map[string][]struct {
+			HostIP   string `json:"HostIp"`
+			HostPort string `json:"HostPort"`
+		} `json:"Ports"`
+	} `json:"NetworkSettings"`
+	Mounts []struct {
+		Type        string `json:"Type"`
+		Name        string `json:"Name"`
+		Source      string `json:"Source"`
+		Destination string `json:"Destination"`
+		RW          bool   `json:"RW"`
+	} `json:"Mounts"`
+}
+
+// InspectContainer implements Engine
+func (e *CLIEngine) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	out, err := e.run(ctx, nil, "inspect", "--format", "json", containerID)
+	var cliErr *CLIError
+	if errors.As(err, &cliErr) && strings.Contains(strings.ToLower(cliErr.Stderr), "no such") {
+		return nil, fmt.Errorf("failed to inspect container: %w: %s", ErrNoSuchContainer, containerID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	// Docker prints an object, Podman and nerdctl an array
+	var inspect cliInspect
+	data := []byte(strings.TrimSpace(out))
+	if bytes.HasPrefix(data, []byte("[")) {
+		var list []cliInspect
+		if err := json.Unmarshal(data, &list); err != nil {
+			return nil, fmt.Errorf("failed to parse inspect output: %w", err)
+		}
+		if len(list) == 0 {
+			return nil, fmt.Errorf("failed to inspect container: %w: %s", ErrNoSuchContainer, containerID)
+		}
+		inspect = list[0]
+	} else if err := json.Unmarshal(data, &inspect); err != nil {
+		return nil, fmt.Errorf("failed to parse inspect output: %w", err)
+	}
+
+	details := &ContainerDetails{
+		ID:        inspect.ID,
+		Name:      strings.TrimPrefix(inspect.Name, "/"),
+		Image:     inspect.Config.Image,
+		Status:    strings.ToLower(inspect.State.Status),
+		Labels:    inspect.Config.Labels,
+		User:      inspect.Config.User,
+		Tty:       inspect.Config.Tty,
+		ExitCode:  inspect.State.ExitCode,
+		OOMKilled: inspect.State.OOMKilled,
+	}
+	details.Created, _ = time.Parse(time.RFC3339Nano, inspect.Created)
+	details.StartedAt, _ = time.Parse(time.RFC3339Nano, inspect.State.StartedAt)
+	details.FinishedAt, _ = time.Parse(time.RFC3339Nano, inspect.State.FinishedAt)
+	if inspect.State.Health != nil {
+		details.Health = inspect.State.Health.Status
+	}
+	for port, bindings := range inspect.NetworkSettings.Ports {
+		if len(bindings) > 0 {
+			if details.Ports == nil {
+				details.Ports = map[string]string{}
+			}
+			details.Ports[port] = portBinding(bindings[0].HostIP, bindings[0].HostPort)
+		}
+	}
+	for _, mp := range inspect.Mounts {
+		source := mp.Source
+		if mp.Type == "volume" {
+			source = mp.Name
+		}
+		details.Mounts = append(details.Mounts, Mount{Type: mp.Type, Source: source, Target: mp.Destination, ReadOnly: !mp.RW})
+	}
+	return details, nil
+}
+
+// FindContainers implements Engine
+func (e *CLIEngine) FindContainers(ctx context.Context, labels map[string]string) ([]string, error) {
+	args := []string{"ps", "-a", "--no-trunc", "--format", "{{.ID}}"}
+	for _, k := range sortedKeys(labels) {
+		args = append(args, "--filter", "label="+labelFilter(k, labels[k]))
+	}
+	out, err := e.run(ctx, nil, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	return strings.Fields(out), nil
+}
+
+// cliEvent is an event printed by `events --format {{json .}}`: Docker
+// nests the attributes under Actor, Podman does not and names the action
+// Status
+type cliEvent struct {
+	ID     string `json:"id"`
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Status string `json:"status"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+	Attributes map[string]string `json:"Attributes"`
+	TimeNano   int64             `json:"timeNano"`
+	Time       json.RawMessage   `json:"time"` // Seconds, or an RFC 3339 string for Podman
+}
+
+func (e cliEvent) event() Event {
+	id, action, attributes := e.Actor.ID, e.Action, e.Actor.Attributes
+	if id == "" {
+		id = e.ID
+	}
+	if action == "" {
+		action = e.Status
+	}
+	if attributes == nil {
+		attributes = e.Attributes
+	}
+
+	t := time.Unix(0, e.TimeNano)
+	if e.TimeNano == 0 {
+		var seconds int64
+		var text string
+		if json.Unmarshal(e.Time, &seconds) == nil {
+			t = time.Unix(seconds, 0)
+		} else if json.Unmarshal(e.Time, &text) == nil {
+			t, _ = time.Parse(time.RFC3339Nano, text)
+		}
+	}
+	return engineEvent(id, action, attributes, t)
+}
+
+// ContainerEvents implements Engine with a long-running `events` command
+func (e *CLIEngine) ContainerEvents(ctx context.Context, containerID string, labels map[string]string) (<-chan Event, <-chan error) {
+	args := []string{"events", "--format", "{{json .}}", "--filter", "type=container"}
+	if containerID != "" {
+		args = append(args, "--filter", "container="+containerID)
+	}
+	for _, k := range sortedKeys(labels) {
+		args = append(args, "--filter", "label="+labelFilter(k, labels[k]))
+	}
+
+	out := make(chan Event)
+	errs := make(chan error, 1)
+	cmd := exec.CommandContext(ctx, e.Binary, args...)
+	cmd.Env = e.Env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		errs <- fmt.Errorf("failed to stream events: %w", err)
+		close(out)
+		return out, errs
+	}
+
+	go func() {
+		defer close(out)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			var ev cliEvent
+			if err := json.Unmarshal(scanner.Bytes(), &ev); err != nil {
+				continue // Not an event, e.g. a warning
+			}
+			select {
+			case out <- ev.event():
+			case <-ctx.Done():
+			}
+		}
+		err := cmd.Wait()
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			err = errors.New("event stream ended")
+		}
+		errs <- fmt.Errorf("failed to stream events: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}()
+	return out, errs
+}
+
+// ContainerStats implements Engine with `stats`, parsing the values it
+// displays
+func (e *CLIEngine) ContainerStats(ctx context.Context, containerID string, stream bool) (<-chan api.Stats, <-chan error) {
+	args := []string{"stats", "--format", "{{json .}}"}
+	if !stream {
+		args = append(args, "--no-stream")
+	}
+	args = append(args, containerID)
+
+	out := make(chan api.Stats)
+	errs := make(chan error, 1)
+	cmd := exec.CommandContext(ctx, e.Binary, args...)
+	cmd.Env = e.Env
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		errs <- fmt.Errorf("failed to get container stats: %w", err)
+		close(out)
+		close(errs)
+		return out, errs
+	}
+
+	go func() {
+		defer close(errs)
+		defer close(out)
+		scanner := bufio.NewScanner(stdout)
+		for scanner.Scan() {
+			// Skip terminal control sequences before the JSON
+			line := scanner.Bytes()
+			start := bytes.IndexByte(line, '{')
+			var reading cliStats
+			if start < 0 || json.Unmarshal(line[start:], &reading) != nil {
+				continue
+			}
+			stats := reading.stats()
+			stats.Time = time.Now()
+			select {
+			case out <- stats:
+			case <-ctx.Done():
+			}
+		}
+		if err := cmd.Wait(); err != nil && ctx.Err() == nil {
+			errs <- fmt.Errorf("failed to get container stats: %w: %s", err, strings.TrimSpace(stderr.String()))
+		}
+	}()
+	return out, errs
+}
+
+// ExecAs implements Engine
+func (e *CLIEngine) ExecAs(ctx context.Context, containerID string, opts ExecOptions, command []string) (string, error) {
+	args := []string{"exec"}
+	if opts.User != "" {
+		args = append(args, "-u", opts.User)
+	}
+	if opts.WorkingDir != "" {
+		args = append(args, "-w", opts.WorkingDir)
+	}
+	for _, k := range sortedKeys(opts.Env) {
+		args = append(args, "-e", k+"="+opts.Env[k])
+	}
+	args = append(append(args, containerID), command...)
+
+	var out bytes.Buffer
+	var stdout io.Writer = &out
+	if opts.Stdout != nil {
+		stdout = opts.Stdout
+	}
+	err := e.stream(ctx, nil, stdout, opts.Stderr, args...)
+	var cliErr *CLIError
+	if errors.As(err, &cliErr) {
+		return "", &ExecError{ExitCode: cliErr.ExitCode, Stderr: cliErr.Stderr}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to exec: %w", err)
+	}
+	return out.String(), nil
+}
+
+// GetContainerLogs implements Engine. Standard error follows standard output.
+func (e *CLIEngine) GetContainerLogs(ctx context.Context, containerID string, tail int) (string, error) {
+	// One writer for both streams makes them share a pipe, keeping their order
+	var logs bytes.Buffer
+	if err := e.ContainerLogs(ctx, containerID, LogOptions{Tail: tail}, &logs, &logs); err != nil {
+		return "", err
+	}
+	return logs.String(), nil
+}
+
+// ContainerLogs implements Engine; the tool separates the streams itself
+func (e *CLIEngine) ContainerLogs(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error {
+	args := []string{"logs"}
+	if opts.Follow {
+		args = append(args, "--follow")
+	}
+	if opts.Timestamps {
+		args = append(args, "--timestamps")
+	}
+	if since := logTime(opts.Since); since != "" {
+		args = append(args, "--since", since)
+	}
+	if until := logTime(opts.Until); until != "" {
+		args = append(args, "--until", until)
+	}
+	tail := "all"
+	if opts.Tail > 0 {
+		tail = strconv.Itoa(opts.Tail)
+	}
+	args = append(args, "--tail", tail, containerID)
+
+	cmd := exec.CommandContext(ctx, e.Binary, args...)
+	cmd.Env = e.Env
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to get container logs: %w", err)
+	}
+	return nil
+}
+
+// CopyToContainer implements Engine
+func (e *CLIEngine) CopyToContainer(ctx context.Context, containerID, dstDir string, archive io.Reader) error {
+	if _, err := e.run(ctx, archive, "cp", "-a", "-", containerID+":"+dstDir); err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+	return nil
+}
+
+// CopyFromContainer implements Engine. The archive is streamed; errors of
+// the tool are reported by Close.
+func (e *CLIEngine) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, e.Binary, "cp", containerID+":"+srcPath, "-")
+	cmd.Env = e.Env
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	return &cmdReader{ReadCloser: stdout, cmd: cmd, stderr: stderr}, nil
+}
+
+// cmdReader is the output of a running command; Close waits for it
+type cmdReader struct {
+	io.ReadCloser
+	cmd    *exec.Cmd
+	stderr *bytes.Buffer
+}
+
+func (r *cmdReader) Close() error {
+	// Drain the output so the command can exit
+	io.Copy(io.Discard, r.ReadCloser)
+	if err := r.cmd.Wait(); err != nil {
+		return fmt.Errorf("failed to copy from container: %w: %s", err, strings.TrimSpace(r.stderr.String()))
+	}
+	return nil
+}
+
+// ValidateImage implements Engine
+func (e *CLIEngine) ValidateImage(ctx context.Context, imageName string) error {
+	if _, err := e.run(ctx, nil, "image", "inspect", imageName); err == nil {
+		return nil // Image exists locally
+	}
+	if _, err := e.run(ctx, nil, "pull", imageName); err != nil {
+		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
+	}
+	return nil
+}
+
+// GetImageMetadata implements Engine
+func (e *CLIEngine) GetImageMetadata(ctx context.Context, imageName string) ([]*DevContainer, error) {
+	out, err := e.run(ctx, nil, "image", "inspect", "--format", "{{json .Config.Labels}}", imageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(strings.TrimSpace(out)), &labels); err != nil {
+		return nil, fmt.Errorf("failed to parse labels of image %s: %w", imageName, err)
+	}
+	return ParseImageMetadata(labels[LabelMetadata])
+}
+
+// BuildImage implements Engine. The context is sent as a tar stream on
+// standard input, like DockerClient does.
+func (e *CLIEngine) BuildImage(ctx context.Context, config *ImageBuildConfig) error {
+	var buildContext io.ReadCloser
+	var dockerfile string
+	var err error
+	if config.ContextFS != nil {
+		buildContext, dockerfile, err = tarBuildContextFS(config.ContextFS, config.ContextDir, config.Dockerfile)
+	} else {
+		buildContext, dockerfile, err = tarBuildContext(config.ContextDir, config.Dockerfile)
+	}
+	if err != nil {
+		return err
+	}
+	defer buildContext.Close()
+
+	args := []string{"build", "-f", dockerfile, "-t", config.Tag}
+	if config.Target != "" {
+		args = append(args, "--target", config.Target)
+	}
+	for _, k := range sortedKeys(config.Args) {
+		args = append(args, "--build-arg", k+"="+config.Args[k])
+	}
+	for _, image := range config.CacheFrom {
+		args = append(args, "--cache-from", image)
+	}
+	for _, k := range sortedKeys(config.Labels) {
+		args = append(args, "--label", k+"="+config.Labels[k])
+	}
+	if _, err := e.run(ctx, buildContext, append(args, "-")...); err != nil {
+		return fmt.Errorf("failed to build image %s: %w", config.Tag, err)
+	}
+	return nil
+}
+
+// CreateVolume implements Engine
+func (e *CLIEngine) CreateVolume(ctx context.Context, name string) error {
+	return e.CreateLabeledVolume(ctx, name, nil)
+}
+
+// CreateLabeledVolume implements Engine
+func (e *CLIEngine) CreateLabeledVolume(ctx context.Context, name string, labels map[string]string) error {
+	args := []string{"volume", "create"}
+	for _, k := range sortedKeys(labels) {
+		args = append(args, "--label", k+"="+labels[k])
+	}
+	if _, err := e.run(ctx, nil, append(args, name)...); err != nil {
+		return fmt.Errorf("failed to create volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// VolumeExists implements Engine
+func (e *CLIEngine) VolumeExists(ctx context.Context, name string) (bool, error) {
+	out, err := e.run(ctx, nil, "volume", "ls", "-q", "--filter", "name="+name)
+	if err != nil {
+		return false, fmt.Errorf("failed to list volumes: %w", err)
+	}
+	// The name filter also matches substrings
+	for _, line := range strings.Split(out, "\n") {
+		if strings.TrimSpace(line) == name {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// RemoveVolume implements Engine
+func (e *CLIEngine) RemoveVolume(ctx context.Context, name string) error {
+	if _, err := e.run(ctx, nil, "volume", "rm", "-f", name); err != nil {
+		return fmt.Errorf("failed to remove volume %s: %w", name, err)
+	}
+	return nil
+}
+
+// CreateNetwork implements Engine
+func (e *CLIEngine) CreateNetwork(ctx context.Context, name string) (string, error) {
+	out, err := e.run(ctx, nil, "network", "create", name)
+	if err != nil {
+		return "", fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+	return lastLine(out), nil
+}
+
+// RemoveNetwork implements Engine
+func (e *CLIEngine) RemoveNetwork(ctx context.Context, name string) error {
+	if _, err := e.run(ctx, nil, "network", "rm", name); err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", name, err)
+	}
+	return nil
+}
+
+// Close implements Engine
+func (e *CLIEngine) Close() error {
+	return nil
+}
+
+// lastLine returns the last non-empty line of out, e.g. the ID printed
+// after pull progress
+func lastLine(out string) string {
+	lines := strings.Fields(out)
+	if len(lines) == 0 {
+		return ""
+	}
+	return lines[len(lines)-1]
+}
@@ -0,0 +1,258 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeCLI is a shell script standing in for docker: it logs its arguments,
+// one invocation per line, and answers a few commands with canned output
+const fakeCLI = `#!/bin/sh
+echo "$*" >> "$(dirname "$0")/calls"
+case "$1" in
+create) echo 0123abcd ;;
+inspect) echo '[{"Id":"0123abcd","Name":"/dev","Created":"2024-01-01T00:00:00.5Z","Config":{"Image":"alpine:3","Labels":{"devcontainer.local_folder":"/src"},"User":"dev"},"State":{"Status":"running","StartedAt":"2024-01-01T00:00:01Z","FinishedAt":"0001-01-01T00:00:00Z","Health":{"Status":"healthy"}},"NetworkSettings":{"Ports":{"8080/tcp":[{"HostIp":"0.0.0.0","HostPort":"32768"}],"9000/tcp":null}},"Mounts":[{"Type":"volume","Name":"cache","Source":"/var/lib/docker/volumes/cache/_data","Destination":"/cache","RW":true},{"Type":"bind","Source":"/src","Destination":"/workspaces/src","RW":false}]}]' ;;
+ps) printf '0123abcd\n4567ef01\n' ;;
+exec)
+	case "$*" in *" fail") echo "boom" >&2; exit 3 ;; esac
+	echo "ran $*" ;;
+logs) echo "out"; echo "err" >&2 ;;
+stats) printf '\033[2J\033[H{"BlockIO":"0B / 4.1kB","CPUPerc":"12.50%%","Container":"0123abcd","MemPerc":"1.95%%","MemUsage":"150MiB / 7.5GiB","NetIO":"1.2kB / 648B","PIDs":"7"}\n' ;;
+image)
+	if [ "$2" = "inspect" ] && [ "$3" = "--format" ]; then
+		echo '{"devcontainer.metadata":"[{\"remoteUser\":\"vscode\"}]"}'
+	elif [ "$3" = "missing:1" ]; then exit 1
+	fi ;;
+build) cat > "$(dirname "$0")/context.tar" ;;
+network) echo net-1234 ;;
+volume) [ "$2" = "ls" ] && printf 'ws-app-cache\nws-app\n' ;;
+events)
+	echo '{"status":"start","id":"0123abcd","Type":"container","Action":"start","Actor":{"ID":"0123abcd","Attributes":{"name":"dev"}},"time":1704067200,"timeNano":1704067200000000000}'
+	echo 'WARNING: not an event'
+	echo '{"ID":"0123abcd","Image":"alpine:3","Name":"dev","Status":"died","Time":"2024-01-01T00:00:01Z","Type":"container","Attributes":{"image":"alpine:3"}}' ;;
+esac
+`
+
+func newFakeCLI(t *testing.T) (*CLIEngine, func() []string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake CLI is a shell script")
+	}
+	dir := t.TempDir()
+	bin := filepath.Join(dir, "docker")
+	if err := os.WriteFile(bin, []byte(fakeCLI), 0755); err != nil {
+		t.Fatal(err)
+	}
+	calls := func() []string {
+		data, err := os.ReadFile(filepath.Join(dir, "calls"))
+		if err != nil {
+			return nil
+		}
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+	return NewCLIEngine(bin), calls
+}
+
+func TestCLIEngineContainers(t *testing.T) {
+	ctx := context.Background()
+	engine, calls := newFakeCLI(t)
+
+	id, err := engine.CreateContainer(ctx, &DockerRunConfig{
+		Image:          "alpine:3",
+		Name:           "dev",
+		WorkspaceMount: "type=bind,source=/src,target=/workspace",
+		Labels:         map[string]string{"a": "1"},
+		RunArgs:        []string{"--cap-add=SYS_PTRACE"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "0123abcd" {
+		t.Errorf("expected id from output, got %q", id)
+	}
+	create := calls()[0]
+	for _, want := range []string{"create -it ", "--mount type=bind,source=/src,target=/workspace", "--label a=1", "--cap-add=SYS_PTRACE", "alpine:3"} {
+		if !strings.Contains(create, want) {
+			t.Errorf("expected %q in %q", want, create)
+		}
+	}
+	if strings.Contains(create, "--rm") {
+		t.Errorf("create must not remove the container on exit: %q", create)
+	}
+
+	details, err := engine.InspectContainer(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.Name != "dev" || details.Status != "running" || details.Image != "alpine:3" ||
+		details.Labels[LabelLocalFolder] != "/src" || details.Created.Nanosecond() != 5e8 {
+		t.Errorf("unexpected details %+v", details)
+	}
+	if details.User != "dev" || details.Health != "healthy" || details.StartedAt.Sub(details.Created) != 500*time.Millisecond ||
+		!details.FinishedAt.IsZero() || !reflect.DeepEqual(details.Ports, map[string]string{"8080/tcp": "0.0.0.0:32768"}) {
+		t.Errorf("unexpected state %+v", details)
+	}
+	wantMounts := []Mount{
+		{Type: "volume", Source: "cache", Target: "/cache"},
+		{Type: "bind", Source: "/src", Target: "/workspaces/src", ReadOnly: true},
+	}
+	if !reflect.DeepEqual(details.Mounts, wantMounts) {
+		t.Errorf("expected mounts %+v, got %+v", wantMounts, details.Mounts)
+	}
+
+	ids, err := engine.FindContainers(ctx, map[string]string{"b": "2", "a": "1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(ids, []string{"0123abcd", "4567ef01"}) {
+		t.Errorf("unexpected ids %v", ids)
+	}
+
+	for _, err := range []error{
+		engine.StartContainer(ctx, id),
+		engine.StopContainer(ctx, id),
+		engine.RemoveContainer(ctx, id),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := []string{
+		"inspect --format json 0123abcd",
+		"ps -a --no-trunc --format {{.ID}} --filter label=a=1 --filter label=b=2",
+		"start 0123abcd",
+		"stop -t 10 0123abcd",
+		"rm -f 0123abcd",
+	}
+	if got := calls()[1:]; !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected calls:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestCLIEngineExec(t *testing.T) {
+	ctx := context.Background()
+	engine, calls := newFakeCLI(t)
+
+	opts := ExecOptions{User: "vscode", WorkingDir: "/workspace", Env: map[string]string{"A": "1"}}
+	out, err := engine.ExecAs(ctx, "c1", opts, []string{"echo", "hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ran exec -u vscode -w /workspace -e A=1 c1 echo hi\n"; out != want {
+		t.Errorf("expected %q, got %q", want, out)
+	}
+
+	_, err = engine.ExecAs(ctx, "c1", ExecOptions{User: "root", WorkingDir: "/"}, []string{"fail"})
+	var execErr *ExecError
+	if !errors.As(err, &execErr) || execErr.ExitCode != 3 || strings.TrimSpace(execErr.Stderr) != "boom" {
+		t.Errorf("expected exec error with exit code 3, got %v", err)
+	}
+
+	// Streamed output goes to the writers as it is produced
+	var stdout, stderr strings.Builder
+	out, err = engine.ExecAs(ctx, "c1", ExecOptions{Stdout: &stdout, Stderr: &stderr}, []string{"fail"})
+	if !errors.As(err, &execErr) || execErr.ExitCode != 3 || execErr.Stderr != "" {
+		t.Errorf("expected exec error with exit code 3 and no captured stderr, got %v", err)
+	}
+	if out != "" || stdout.String() != "" || stderr.String() != "boom\n" {
+		t.Errorf("unexpected streamed output %q %q %q", out, stdout.String(), stderr.String())
+	}
+	if _, err := engine.ExecAs(ctx, "c1", ExecOptions{Stdout: &stdout}, []string{"echo", "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	if want := "ran exec c1 echo hi\n"; stdout.String() != want {
+		t.Errorf("expected %q, got %q", want, stdout.String())
+	}
+
+	logs, err := engine.GetContainerLogs(ctx, "c1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs != "out\nerr\n" {
+		t.Errorf("expected both streams, got %q", logs)
+	}
+	if got := calls()[len(calls())-1]; got != "logs --tail all c1" {
+		t.Errorf("unexpected logs call %q", got)
+	}
+}
+
+func TestCLIEngineImages(t *testing.T) {
+	ctx := context.Background()
+	engine, calls := newFakeCLI(t)
+
+	if err := engine.ValidateImage(ctx, "alpine:3"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.ValidateImage(ctx, "missing:1"); err != nil {
+		t.Fatal(err)
+	}
+	metadata, err := engine.GetImageMetadata(ctx, "alpine:3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(metadata) != 1 || metadata[0].RemoteUser == nil || *metadata[0].RemoteUser != "vscode" {
+		t.Errorf("unexpected metadata %+v", metadata)
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte("FROM alpine:3\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = engine.BuildImage(ctx, &ImageBuildConfig{
+		ContextDir: dir,
+		Dockerfile: "Dockerfile",
+		Tag:        "dev:latest",
+		Target:     "dev",
+		Args:       map[string]string{"V": "1"},
+		Labels:     map[string]string{"l": "x"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(filepath.Dir(engine.Binary), "context.tar")); err != nil || info.Size() == 0 {
+		t.Errorf("expected the build context on stdin: %v", err)
+	}
+
+	want := []string{
+		"image inspect alpine:3",
+		"image inspect missing:1",
+		"pull missing:1",
+		"image inspect --format {{json .Config.Labels}} alpine:3",
+		"build -f Dockerfile -t dev:latest --target dev --build-arg V=1 --label l=x -",
+	}
+	if got := calls(); !reflect.DeepEqual(got, want) {
+		t.Errorf("unexpected calls:\n%s", strings.Join(got, "\n"))
+	}
+}
+
+func TestCLIEngineVolumeExists(t *testing.T) {
+	ctx := context.Background()
+	engine, calls := newFakeCLI(t)
+
+	// Only whole names match
+	for name, want := range map[string]bool{"ws-app": true, "ws": false} {
+		if got, err := engine.VolumeExists(ctx, name); err != nil || got != want {
+			t.Errorf("VolumeExists(%q) = %v, %v, expected %v", name, got, err, want)
+		}
+	}
+	if c := calls(); len(c) != 2 || !strings.HasPrefix(c[0], "volume ls -q --filter name=") {
+		t.Errorf("unexpected calls %v", c)
+	}
+}
+
+func TestCLIEngineErrors(t *testing.T) {
+	engine := NewCLIEngine(filepath.Join(t.TempDir(), "missing"))
+	if err := engine.StartContainer(context.Background(), "c1"); err == nil {
+		t.Error("expected error for missing binary")
+	}
+	if NewCLIEngine("").Binary != "docker" {
+		t.Error("expected docker by default")
+	}
+}
@@ -11,44 +11,50 @@ import (
 func TestParseAppPorts(t *testing.T) {
 	tests := []struct {
 		name     string
-		appPort  interface{}
+		appPort  string
 		expected []string
+		wantErr  bool
 	}{
 		{
 			name:     "single number port",
-			appPort:  float64(8080),
+			appPort:  `8080`,
 			expected: []string{"8080:8080"},
 		},
 		{
 			name:     "port mapping string",
-			appPort:  "3000:3001",
+			appPort:  `"3000:3001"`,
 			expected: []string{"3000:3001"},
 		},
 		{
 			name:     "array of mixed ports",
-			appPort:  []interface{}{float64(8080), "9000:9001", float64(3000)},
+			appPort:  `[8080, "9000:9001", 3000]`,
 			expected: []string{"8080:8080", "9000:9001", "3000:3000"},
 		},
 		{
 			name:     "empty array",
-			appPort:  []interface{}{},
+			appPort:  `[]`,
 			expected: nil,
 		},
 		{
 			name:     "nil port",
-			appPort:  nil,
+			appPort:  `null`,
 			expected: nil,
 		},
 		{
-			name:     "invalid type",
-			appPort:  map[string]string{"invalid": "type"},
-			expected: nil,
+			name:    "invalid type",
+			appPort: `{"invalid": "type"}`,
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := parseAppPorts(tt.appPort)
+			var ports PortList
+			err := json.Unmarshal([]byte(tt.appPort), &ports)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			result := parseAppPorts(ports)
 			if !reflect.DeepEqual(result, tt.expected) {
 				t.Errorf("parseAppPorts() = %v, want %v", result, tt.expected)
 			}
@@ -59,34 +65,38 @@ func TestParseAppPorts(t *testing.T) {
 func TestFormatForwardPort(t *testing.T) {
 	tests := []struct {
 		name     string
-		port     interface{}
+		port     string
 		expected string
+		wantErr  bool
 	}{
 		{
 			name:     "number port",
-			port:     float64(8080),
+			port:     `8080`,
 			expected: "8080:8080",
 		},
 		{
 			name:     "string port",
-			port:     "3000:3001",
+			port:     `"3000:3001"`,
 			expected: "3000:3001",
 		},
 		{
-			name:     "invalid type",
-			port:     []string{"invalid"},
-			expected: "",
-		},
-		{
-			name:     "nil",
-			port:     nil,
-			expected: "",
+			name:    "invalid type",
+			port:    `["invalid"]`,
+			wantErr: true,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			result := formatForwardPort(tt.port)
+			var port Port
+			err := json.Unmarshal([]byte(tt.port), &port)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unmarshal error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			result := formatForwardPort(port)
 			if result != tt.expected {
 				t.Errorf("formatForwardPort() = %v, want %v", result, tt.expected)
 			}
@@ -320,10 +330,10 @@ func TestBuildDockerRunCommandEdgeCases(t *testing.T) {
 					Image: "nginx:latest",
 				},
 				DevContainerCommon: DevContainerCommon{
-					ForwardPorts: []interface{}{
-						float64(80),
-						"8080:80",
-						float64(443),
+					ForwardPorts: []Port{
+						PortNumber(80),
+						PortString("8080:80"),
+						PortNumber(443),
 					},
 				},
 			},
@@ -341,10 +351,10 @@ func TestBuildDockerRunCommandEdgeCases(t *testing.T) {
 					Image: "node:18",
 				},
 				DevContainerCommon: DevContainerCommon{
-					ForwardPorts: []interface{}{float64(3000), "3000:3000"},
+					ForwardPorts: []Port{PortNumber(3000), PortString("3000:3000")},
 				},
 				NonComposeBase: &NonComposeBase{
-					AppPort: float64(3000),
+					AppPort: PortList{PortNumber(3000)},
 				},
 			},
 			check: func(t *testing.T, config *DockerRunConfig) {
@@ -355,16 +365,11 @@ func TestBuildDockerRunCommandEdgeCases(t *testing.T) {
 			},
 		},
 		{
-			name: "container with invalid forward port",
+			name: "container without forward ports",
 			devContainer: &DevContainer{
 				ImageContainer: &ImageContainer{
 					Image: "alpine:latest",
 				},
-				DevContainerCommon: DevContainerCommon{
-					ForwardPorts: []interface{}{
-						map[string]string{"invalid": "port"},
-					},
-				},
 			},
 			check: func(t *testing.T, config *DockerRunConfig) {
 				if len(config.Ports) != 0 {
@@ -0,0 +1,367 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// maxSymlinkHops bounds how many symlinks CopyFrom follows
+const maxSymlinkHops = 8
+
+// CopyTo copies a host file or directory, or the content of a reader, to
+// the absolute containerPath in a container. Files are owned by root unless
+// an owner is given.
+func (c *DockerClient) CopyTo(ctx context.Context, containerID string, src api.CopySource, containerPath string, opts ...api.CopyOption) error {
+	return copyTo(ctx, c, containerID, src, containerPath, api.ApplyCopyOptions(opts...))
+}
+
+// CopyFrom returns the content of a file in a container, following symlinks
+func (c *DockerClient) CopyFrom(ctx context.Context, containerID, containerPath string, opts ...api.CopyOption) (io.ReadCloser, error) {
+	return copyFrom(ctx, c, containerID, containerPath, api.ApplyCopyOptions(opts...))
+}
+
+// CopyFromToDir copies a file or directory of a container into hostDir
+func (c *DockerClient) CopyFromToDir(ctx context.Context, containerID, containerPath, hostDir string, opts ...api.CopyOption) error {
+	return copyFromToDir(ctx, c, containerID, containerPath, hostDir, api.ApplyCopyOptions(opts...))
+}
+
+// CopyTo copies a host file or directory, or the content of a reader, to
+// the absolute containerPath in a container. Files are owned by the remote
+// user of the dev container unless an owner is given.
+func (m *Manager) CopyTo(ctx context.Context, containerID string, src api.CopySource, containerPath string, opts ...api.CopyOption) error {
+	options := api.ApplyCopyOptions(opts...)
+	if options.Owner == "" {
+		user, err := m.containerRemoteUser(ctx, containerID)
+		if err != nil {
+			return err
+		}
+		options.Owner = user
+	}
+	return copyTo(ctx, m.docker, containerID, src, containerPath, options)
+}
+
+// CopyFrom returns the content of a file in a container, following symlinks
+func (m *Manager) CopyFrom(ctx context.Context, containerID, containerPath string, opts ...api.CopyOption) (io.ReadCloser, error) {
+	return copyFrom(ctx, m.docker, containerID, containerPath, api.ApplyCopyOptions(opts...))
+}
+
+// CopyFromToDir copies a file or directory of a container into hostDir
+func (m *Manager) CopyFromToDir(ctx context.Context, containerID, containerPath, hostDir string, opts ...api.CopyOption) error {
+	return copyFromToDir(ctx, m.docker, containerID, containerPath, hostDir, api.ApplyCopyOptions(opts...))
+}
+
+// containerRemoteUser returns the remote user of the dev container a
+// container was created for; "" if it is unknown
+func (m *Manager) containerRemoteUser(ctx context.Context, containerID string) (string, error) {
+	details, err := m.docker.InspectContainer(ctx, containerID)
+	if err != nil {
+		return "", err
+	}
+	return m.detailsRemoteUser(ctx, details)
+}
+
+// detailsRemoteUser returns the remote user of an inspected container from
+// the devcontainer.metadata label it was created with, or else from the
+// label of its image if that is still present
+func (m *Manager) detailsRemoteUser(ctx context.Context, details *ContainerDetails) (string, error) {
+	metadata, err := ParseImageMetadata(details.Labels[LabelMetadata])
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve remote user: %w", err)
+	}
+	if metadata == nil && details.Image != "" {
+		// Images are not pulled, one that is gone leaves the user unknown
+		if metadata, err = m.docker.GetImageMetadata(ctx, details.Image); err != nil {
+			return "", nil
+		}
+	}
+	return remoteUser(MergeImageMetadata(metadata, nil)), nil
+}
+
+// copyTo streams src to the container as an archive rooted at /, so missing
+// parent directories are created and existing ones are left alone
+func copyTo(ctx context.Context, engine Engine, containerID string, src api.CopySource, containerPath string, opts api.CopyOptions) error {
+	if !path.IsAbs(containerPath) || path.Clean(containerPath) == "/" {
+		return fmt.Errorf("invalid container path %q: must be absolute and not /", containerPath)
+	}
+	name := strings.TrimPrefix(path.Clean(containerPath), "/")
+	owner, err := lookupContainerUser(ctx, engine, containerID, opts.Owner)
+	if err != nil {
+		return err
+	}
+
+	if src.HostPath == "" {
+		if src.Reader == nil {
+			return fmt.Errorf("nothing to copy: no host path or reader")
+		}
+		content, err := readLimited(src.Reader, opts.MaxSize)
+		if err != nil {
+			return err
+		}
+		mode := src.Mode.Perm()
+		if mode == 0 {
+			mode = 0644
+		}
+		var buf bytes.Buffer
+		tw := tar.NewWriter(&buf)
+		err = tw.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     name,
+			Mode:     int64(mode),
+			Size:     int64(len(content)),
+			ModTime:  time.Now(),
+			Uid:      owner.UID,
+			Gid:      owner.GID,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to archive content: %w", err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return fmt.Errorf("failed to archive content: %w", err)
+		}
+		if err := tw.Close(); err != nil {
+			return fmt.Errorf("failed to archive content: %w", err)
+		}
+		return engine.CopyToContainer(ctx, containerID, "/", &buf)
+	}
+
+	// Check the size before anything is copied
+	if opts.MaxSize > 0 {
+		var total int64
+		err := walkHostPath(src.HostPath, opts.FollowSymlinks, func(rel, file string, info fs.FileInfo, link string) error {
+			if info.Mode().IsRegular() {
+				total += info.Size()
+			}
+			if total > opts.MaxSize {
+				return fmt.Errorf("%w: %s is larger than %d bytes", api.ErrCopyTooLarge, src.HostPath, opts.MaxSize)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := walkHostPath(src.HostPath, opts.FollowSymlinks, func(rel, file string, info fs.FileInfo, link string) error {
+			return archiveHostFile(tw, path.Join(name, rel), file, info, link, owner)
+		})
+		if err == nil {
+			err = tw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	err = engine.CopyToContainer(ctx, containerID, "/", pr)
+	pr.CloseWithError(err) // Stops the writer if the copy failed early
+	return err
+}
+
+// walkHostPath calls fn for the host path and, if it is a directory, every
+// path below it. rel is slash-separated and relative to the host path, file
+// the path to read and link the target of a symlink that is kept as such.
+// With follow, symlinks to regular files are replaced by their targets.
+func walkHostPath(hostPath string, follow bool, fn func(rel, file string, info fs.FileInfo, link string) error) error {
+	visit := func(rel, file string) error {
+		info, err := os.Lstat(file)
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			if target, err := os.Stat(file); follow && err == nil && target.Mode().IsRegular() {
+				info = target
+			} else if link, err = os.Readlink(file); err != nil {
+				return err
+			}
+		}
+		return fn(rel, file, info, link)
+	}
+
+	root, err := os.Lstat(hostPath)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", hostPath, err)
+	}
+	if !root.IsDir() {
+		return visit(".", hostPath)
+	}
+	err = filepath.WalkDir(hostPath, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(hostPath, file)
+		if err != nil {
+			return err
+		}
+		return visit(filepath.ToSlash(rel), file)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %w", hostPath, err)
+	}
+	return nil
+}
+
+// archiveHostFile writes a host path to a tar stream under name
+func archiveHostFile(tw *tar.Writer, name, file string, info fs.FileInfo, link string, owner containerOwner) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(info.Mode().Perm()),
+		ModTime: info.ModTime(),
+		Uid:     owner.UID,
+		Gid:     owner.GID,
+	}
+	switch {
+	case link != "":
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = link
+	case info.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case info.Mode().IsRegular():
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = info.Size()
+	default:
+		return nil // Devices, sockets and pipes are not copied
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if hdr.Typeflag != tar.TypeReg {
+		return nil
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.CopyN(tw, f, hdr.Size)
+	return err
+}
+
+// readLimited reads r, failing if it has more than max bytes
+func readLimited(r io.Reader, max int64) ([]byte, error) {
+	if max <= 0 {
+		return io.ReadAll(r)
+	}
+	content, err := io.ReadAll(io.LimitReader(r, max+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > max {
+		return nil, fmt.Errorf("%w: content is larger than %d bytes", api.ErrCopyTooLarge, max)
+	}
+	return content, nil
+}
+
+// copyFrom returns the content of a container file, following symlinks
+func copyFrom(ctx context.Context, engine Engine, containerID, containerPath string, opts api.CopyOptions) (io.ReadCloser, error) {
+	for hop := 0; hop <= maxSymlinkHops; hop++ {
+		reader, err := engine.CopyFromContainer(ctx, containerID, containerPath)
+		if err != nil {
+			return nil, err
+		}
+		tr := tar.NewReader(reader)
+		hdr, err := tr.Next()
+		if err != nil {
+			reader.Close()
+			return nil, fmt.Errorf("failed to copy %s: %w", containerPath, err)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeReg:
+			if opts.MaxSize > 0 && hdr.Size > opts.MaxSize {
+				reader.Close()
+				return nil, fmt.Errorf("%w: %s is %d bytes, more than %d", api.ErrCopyTooLarge, containerPath, hdr.Size, opts.MaxSize)
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{tr, reader}, nil
+		case tar.TypeSymlink:
+			reader.Close()
+			if path.IsAbs(hdr.Linkname) {
+				containerPath = hdr.Linkname
+			} else {
+				containerPath = path.Join(path.Dir(containerPath), hdr.Linkname)
+			}
+		default:
+			reader.Close()
+			return nil, fmt.Errorf("failed to copy %s: not a regular file", containerPath)
+		}
+	}
+	return nil, fmt.Errorf("failed to copy %s: too many levels of symbolic links", containerPath)
+}
+
+// copyFromToDir extracts a container path into hostDir. Entries that would
+// land outside of hostDir, directly or through symlinks, are refused.
+func copyFromToDir(ctx context.Context, engine Engine, containerID, containerPath, hostDir string, opts api.CopyOptions) error {
+	if err := os.MkdirAll(hostDir, 0755); err != nil {
+		return fmt.Errorf("failed to create %s: %w", hostDir, err)
+	}
+	root, err := filepath.EvalSymlinks(hostDir)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", hostDir, err)
+	}
+
+	reader, err := engine.CopyFromContainer(ctx, containerID, containerPath)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var total int64
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", containerPath, err)
+		}
+		name := path.Clean(hdr.Name)
+		if path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("failed to copy %s: invalid archive entry %s", containerPath, hdr.Name)
+		}
+
+		var content io.Reader = tr
+		if hdr.Typeflag == tar.TypeSymlink && opts.FollowSymlinks {
+			// The entry is named after the container path it came from
+			source := path.Join(path.Dir(path.Clean(containerPath)), name)
+			if target, err := copyFrom(ctx, engine, containerID, source, api.CopyOptions{}); err == nil {
+				data, err := readLimited(target, 0)
+				target.Close()
+				if err != nil {
+					return err
+				}
+				resolved := *hdr
+				resolved.Typeflag, resolved.Linkname, resolved.Mode, resolved.Size = tar.TypeReg, "", 0644, int64(len(data))
+				hdr, content = &resolved, bytes.NewReader(data)
+			}
+		}
+		if hdr.Typeflag == tar.TypeReg {
+			total += hdr.Size
+			if opts.MaxSize > 0 && total > opts.MaxSize {
+				return fmt.Errorf("%w: %s is larger than %d bytes", api.ErrCopyTooLarge, containerPath, opts.MaxSize)
+			}
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if err := checkInside(root, filepath.Dir(target)); err != nil {
+			return err
+		}
+		if err := writeArchiveEntry(target, hdr, content); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+	}
+}
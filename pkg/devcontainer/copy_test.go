@@ -0,0 +1,167 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func TestCopyTo(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine, id := newFakeContainer(t, `{"image": "img", "remoteUser": "dev"}`, "img")
+	if err := engine.WriteFile(id, "/etc/passwd", []byte("root:x:0:0::/root:/bin/sh\ndev:x:1000:1000::/home/dev:/bin/sh\nsvc:x:2000:2000::/:/bin/sh\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	src := t.TempDir()
+	writeFile(t, filepath.Join(src, "a.txt"), "alpha")
+	writeFile(t, filepath.Join(src, "sub", "b.txt"), "beta")
+	if err := os.Symlink("a.txt", filepath.Join(src, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := mgr.CopyTo(ctx, id, api.FromHostPath(src), "/home/dev/data"); err != nil {
+		t.Fatal(err)
+	}
+	b := engine.File(id, "/home/dev/data/sub/b.txt")
+	if b == nil || string(b.Content) != "beta" || b.Header.Uid != 1000 {
+		t.Errorf("expected b.txt owned by the remote user, got %+v", b)
+	}
+	if link := engine.File(id, "/home/dev/data/link"); link == nil || link.Header.Typeflag != tar.TypeSymlink || link.Header.Linkname != "a.txt" {
+		t.Errorf("expected symlink to be kept, got %+v", link)
+	}
+
+	// Following symlinks copies the target; an explicit owner wins
+	err := mgr.CopyTo(ctx, id, api.FromHostPath(filepath.Join(src, "link")), "/srv/a.txt", api.WithFollowSymlinks(), api.WithOwner("svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a := engine.File(id, "/srv/a.txt"); a == nil || a.Header.Typeflag != tar.TypeReg || string(a.Content) != "alpha" || a.Header.Uid != 2000 {
+		t.Errorf("expected followed file owned by svc, got %+v", a)
+	}
+
+	err = mgr.CopyTo(ctx, id, api.CopySource{Reader: strings.NewReader("#!/bin/sh\n"), Mode: 0755}, "/usr/local/bin/hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hello := engine.File(id, "/usr/local/bin/hello"); hello == nil || hello.Header.Mode != 0755 || string(hello.Content) != "#!/bin/sh\n" {
+		t.Errorf("unexpected file from reader %+v", hello)
+	}
+
+	// Size limits fail before anything is copied
+	err = mgr.CopyTo(ctx, id, api.FromHostPath(src), "/big", api.WithMaxSize(8))
+	if !errors.Is(err, api.ErrCopyTooLarge) {
+		t.Errorf("expected ErrCopyTooLarge, got %v", err)
+	}
+	if engine.File(id, "/big/a.txt") != nil {
+		t.Error("expected nothing to be copied over the limit")
+	}
+	err = mgr.CopyTo(ctx, id, api.FromReader(strings.NewReader("0123456789")), "/big.txt", api.WithMaxSize(9))
+	if !errors.Is(err, api.ErrCopyTooLarge) {
+		t.Errorf("expected ErrCopyTooLarge for reader, got %v", err)
+	}
+
+	if err := mgr.CopyTo(ctx, id, api.FromHostPath(src), "relative"); err == nil {
+		t.Error("expected error for relative container path")
+	}
+}
+
+func TestCopyFrom(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine, id := newFakeContainer(t, `{"image": "img", "remoteUser": "dev"}`, "img")
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: "out/", Mode: 0755})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "out/report.txt", Mode: 0600, Size: 6})
+	tw.Write([]byte("report"))
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "out/latest", Linkname: "report.txt"})
+	tw.WriteHeader(&tar.Header{Typeflag: tar.TypeSymlink, Name: "out/escape", Linkname: "/etc"})
+	tw.Close()
+	if err := engine.CopyToContainer(ctx, id, "/tmp", &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := mgr.CopyFrom(ctx, id, "/tmp/out/latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(reader)
+	reader.Close()
+	if string(data) != "report" {
+		t.Errorf("expected content through the symlink, got %q", data)
+	}
+	if _, err := mgr.CopyFrom(ctx, id, "/tmp/out/report.txt", api.WithMaxSize(3)); !errors.Is(err, api.ErrCopyTooLarge) {
+		t.Errorf("expected ErrCopyTooLarge, got %v", err)
+	}
+	if _, err := mgr.CopyFrom(ctx, id, "/tmp/out"); err == nil {
+		t.Error("expected error for a directory")
+	}
+
+	dst := t.TempDir()
+	if err := mgr.CopyFromToDir(ctx, id, "/tmp/out", dst); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(filepath.Join(dst, "out", "report.txt")); err != nil || string(data) != "report" {
+		t.Errorf("expected extracted file, got %q: %v", data, err)
+	}
+	if link, err := os.Readlink(filepath.Join(dst, "out", "escape")); err != nil || link != "/etc" {
+		t.Errorf("expected symlink to be kept, got %q: %v", link, err)
+	}
+
+	followed := t.TempDir()
+	if err := mgr.CopyFromToDir(ctx, id, "/tmp/out", followed, api.WithFollowSymlinks()); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Lstat(filepath.Join(followed, "out", "latest")); err != nil || !info.Mode().IsRegular() {
+		t.Errorf("expected followed symlink to be a file, got %v: %v", info, err)
+	}
+	if err := mgr.CopyFromToDir(ctx, id, "/tmp/out", t.TempDir(), api.WithMaxSize(4)); !errors.Is(err, api.ErrCopyTooLarge) {
+		t.Errorf("expected ErrCopyTooLarge, got %v", err)
+	}
+}
+
+func TestCopyFromToDirRefusesEscapes(t *testing.T) {
+	ctx := context.Background()
+	_, engine, id := newFakeContainer(t, `{"image": "img", "remoteUser": "dev"}`, "img")
+
+	// A symlink to outside followed by a file written through it
+	outside := t.TempDir()
+	archive := tarOf(t,
+		&tar.Header{Typeflag: tar.TypeDir, Name: "evil/", Mode: 0755},
+		&tar.Header{Typeflag: tar.TypeSymlink, Name: "evil/out", Linkname: outside},
+		&tar.Header{Typeflag: tar.TypeReg, Name: "evil/out/pwned", Mode: 0644},
+	)
+	if err := engine.CopyToContainer(ctx, id, "/", archive); err != nil {
+		t.Fatal(err)
+	}
+	dst := t.TempDir()
+	err := copyFromToDir(ctx, engine, id, "/evil", dst, api.CopyOptions{})
+	if err == nil || !strings.Contains(err.Error(), "outside") {
+		t.Errorf("expected refusal, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(outside, "pwned")); err == nil {
+		t.Error("file was written outside of the destination")
+	}
+}
+
+func tarOf(t *testing.T, headers ...*tar.Header) io.Reader {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, hdr := range headers {
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tw.Close()
+	return &buf
+}
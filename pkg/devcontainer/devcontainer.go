@@ -4,8 +4,10 @@ package devcontainer
 import (
     "encoding/json"
     "fmt"
+    "io/fs"
     "os"
     "path/filepath"
+    "reflect"
     "strconv"
     "strings"
     "regexp"
@@ -28,6 +30,19 @@ type DevContainer struct {
 	
 	// NonComposeBase fields
 	NonComposeBase   *NonComposeBase  `json:"-"`
+	
+	// ConfigFilePath is the path the configuration was loaded from, if any
+	ConfigFilePath   string           `json:"-"`
+	
+	// Profiles lists the profiles applied by ApplyProfiles, in order
+	Profiles         []string         `json:"-"`
+	
+	// AdditionalProperties holds properties not modeled above, such as
+	// "extends" or "$schema", so that they survive a round-trip
+	AdditionalProperties map[string]json.RawMessage `json:"-"`
+	
+	// appPortScalar records that appPort was written as a single port
+	appPortScalar bool
 }
 
 // DevContainerCommon contains common fields for all container types
@@ -42,26 +57,29 @@ type DevContainerCommon struct {
 	
 	// Environment
 	ContainerEnv    map[string]string `json:"containerEnv,omitempty"`
-	RemoteEnv       map[string]string `json:"remoteEnv,omitempty"`
+	RemoteEnv       map[string]*string `json:"remoteEnv,omitempty"` // nil values unset the variable
 	
 	// User configuration
 	ContainerUser   *string           `json:"containerUser,omitempty"`
 	RemoteUser      *string           `json:"remoteUser,omitempty"`
 	
 	// Ports and networking
-	ForwardPorts    interface{}       `json:"forwardPorts,omitempty"`
-	AppPort         interface{}       `json:"appPort,omitempty"`
+	ForwardPorts    []Port            `json:"forwardPorts,omitempty"`
+	AppPort         PortList          `json:"appPort,omitempty"`
+	PortsAttributes map[string]PortAttributes `json:"portsAttributes,omitempty"`
+	OtherPortsAttributes *PortAttributes `json:"otherPortsAttributes,omitempty"`
 	
 	// Commands
-	OnCreateCommand      interface{}    `json:"onCreateCommand,omitempty"`
-	UpdateContentCommand interface{}    `json:"updateContentCommand,omitempty"`
-	PostCreateCommand    interface{}    `json:"postCreateCommand,omitempty"`
-	PostStartCommand     interface{}    `json:"postStartCommand,omitempty"`
-	PostAttachCommand    interface{}    `json:"postAttachCommand,omitempty"`
-	InitializeCommand    interface{}    `json:"initializeCommand,omitempty"`
+	OnCreateCommand      *LifecycleCommand `json:"onCreateCommand,omitempty"`
+	UpdateContentCommand *LifecycleCommand `json:"updateContentCommand,omitempty"`
+	PostCreateCommand    *LifecycleCommand `json:"postCreateCommand,omitempty"`
+	PostStartCommand     *LifecycleCommand `json:"postStartCommand,omitempty"`
+	PostAttachCommand    *LifecycleCommand `json:"postAttachCommand,omitempty"`
+	InitializeCommand    *LifecycleCommand `json:"initializeCommand,omitempty"`
+	WaitFor              string            `json:"waitFor,omitempty"`
 	
 	// Mounts and volumes
-	Mounts          []interface{} `json:"mounts,omitempty"`
+	Mounts          []MountEntry      `json:"mounts,omitempty"`
 	
 	// Security
 	CapAdd          []string          `json:"capAdd,omitempty"`
@@ -71,6 +89,11 @@ type DevContainerCommon struct {
 	
 	// Features
 	Features        *DevContainerCommonFeatures `json:"features,omitempty"`
+	OverrideFeatureInstallOrder []string `json:"overrideFeatureInstallOrder,omitempty"`
+	
+	// Host requirements and secrets
+	HostRequirements *DevContainerCommonHostRequirements `json:"hostRequirements,omitempty"`
+	Secrets         map[string]Secret `json:"secrets,omitempty"`
 	
 	// Extensions
 	Customizations  map[string]interface{} `json:"customizations,omitempty"`
@@ -101,39 +124,12 @@ type DevContainerCommonHostRequirements struct {
 	CPUs     string `json:"cpus,omitempty"`
 	Memory   string `json:"memory,omitempty"`
 	Storage  string `json:"storage,omitempty"`
-	Gpu      string `json:"gpu,omitempty"`
-}
+	Gpu      string `json:"gpu,omitempty"` // "true", "false" or "optional"
+	GpuDetails *HostGPURequirements `json:"-"` // Object form of gpu
+	AdditionalProperties map[string]json.RawMessage `json:"-"`
 
-// UnmarshalJSON implements custom JSON unmarshaling for DevContainerCommonFeatures
-func (f *DevContainerCommonFeatures) UnmarshalJSON(data []byte) error {
-	// First unmarshal known fields
-	type Alias DevContainerCommonFeatures
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(f),
-	}
-	if err := json.Unmarshal(data, &aux); err != nil {
-		return err
-	}
-	
-	// Then unmarshal everything to get additional properties
-	var raw map[string]interface{}
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return err
-	}
-	
-	// Remove known fields
-	delete(raw, "fish")
-	delete(raw, "gradle")
-	delete(raw, "maven")
-	
-	// Store the rest as additional properties
-	if len(raw) > 0 {
-		f.AdditionalProperties = raw
-	}
-	
-	return nil
+	// cpusString records that cpus was written as a string
+	cpusString bool
 }
 
 // ComposeContainer represents Docker Compose configuration
@@ -147,7 +143,7 @@ type NonComposeBase struct {
 	RunArgs         []string    `json:"runArgs,omitempty"`
 	WorkspaceFolder *string     `json:"workspaceFolder,omitempty"`
 	WorkspaceMount  *string     `json:"workspaceMount,omitempty"`
-	AppPort         interface{} `json:"appPort,omitempty"`
+	AppPort         PortList    `json:"appPort,omitempty"`
 }
 
 // Build represents build configuration
@@ -156,7 +152,12 @@ type Build struct {
 	Context    string            `json:"context,omitempty"`
 	Args       map[string]string `json:"args,omitempty"`
 	Target     string            `json:"target,omitempty"`
-	CacheFrom  []string          `json:"cacheFrom,omitempty"`
+	CacheFrom  StringList        `json:"cacheFrom,omitempty"`
+	Options    []string          `json:"options,omitempty"`
+	AdditionalProperties map[string]json.RawMessage `json:"-"`
+
+	// cacheFromScalar records that cacheFrom was written as a string
+	cacheFromScalar bool
 }
 
 // Mount types
@@ -181,41 +182,33 @@ func LoadDevContainer(path string) (*DevContainer, error) {
 		return nil, fmt.Errorf("failed to read devcontainer.json: %w", err)
 	}
 	
-	var dc DevContainer
-	if err := json.Unmarshal(data, &dc); err != nil {
-		return nil, fmt.Errorf("failed to parse devcontainer.json: %w", err)
+	dc, err := ParseDevContainer(data)
+	if err != nil {
+		return nil, err
 	}
+	dc.ConfigFilePath = absPath(path)
 	
-	// Also parse raw JSON to get runArgs if present
-	var raw map[string]interface{}
-	if err := json.Unmarshal(data, &raw); err == nil {
-		// Initialize NonComposeBase if needed
-		if runArgs, ok := raw["runArgs"].([]interface{}); ok {
-			if dc.NonComposeBase == nil {
-				dc.NonComposeBase = &NonComposeBase{}
-			}
-			dc.NonComposeBase.RunArgs = make([]string, 0, len(runArgs))
-			for _, arg := range runArgs {
-				if s, ok := arg.(string); ok {
-					dc.NonComposeBase.RunArgs = append(dc.NonComposeBase.RunArgs, s)
-				}
-			}
-		}
-		
-		// Set container type based on what's present
-		if dc.Image != "" {
-			dc.ImageContainer = &ImageContainer{
-				Image: dc.Image,
-			}
-		}
-		
-		// Set ComposeContainer if dockerComposeFile is present
-		if dc.DockerComposeFile != nil {
-			dc.ComposeContainer = &ComposeContainer{
-				DockerComposeFile: dc.DockerComposeFile,
-				Service:          dc.Service,
-			}
-		}
+	return dc, nil
+}
+
+// LoadDevContainerFS loads a devcontainer.json from a file system, e.g. a
+// GitFS for a revision that is not checked out. name is a slash-separated
+// path within fsys; ConfigFilePath is left empty since the file has no
+// location on the local disk.
+func LoadDevContainerFS(fsys fs.FS, name string) (*DevContainer, error) {
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devcontainer.json: %w", err)
+	}
+	return ParseDevContainer(data)
+}
+
+// ParseDevContainer parses devcontainer.json content. Comments and trailing
+// commas are allowed.
+func ParseDevContainer(data []byte) (*DevContainer, error) {
+	var dc DevContainer
+	if err := json.Unmarshal(stripJSONC(data), &dc); err != nil {
+		return nil, fmt.Errorf("failed to parse devcontainer.json: %w", err)
 	}
 	
 	return &dc, nil
@@ -239,28 +232,56 @@ type DockerRunConfig struct {
 	Name            string
 	Command         []string
 	RunArgs         []string // Additional run arguments
+	Labels          map[string]string
 }
 
 // Mount represents a Docker mount
 type Mount struct {
 	Type     string `json:"type"`
-	Source   string `json:"source"`
+	Source   string `json:"source,omitempty"`
 	Target   string `json:"target"`
 	ReadOnly bool   `json:"readonly,omitempty"`
+	
+	// AdditionalProperties holds unmodeled keys such as "consistency"
+	AdditionalProperties map[string]json.RawMessage `json:"-"`
+	
+	// readOnlyKey remembers the spelling used in the source document
+	readOnlyKey string
+}
+
+// MarshalJSON implements custom JSON marshaling for Mount
+func (m Mount) MarshalJSON() ([]byte, error) {
+	type Alias Mount
+	extra := m.AdditionalProperties
+	if m.ReadOnly && m.readOnlyKey != "" && m.readOnlyKey != "readonly" {
+		extra = make(map[string]json.RawMessage, len(m.AdditionalProperties)+1)
+		for k, v := range m.AdditionalProperties {
+			extra[k] = v
+		}
+		extra[m.readOnlyKey] = json.RawMessage("true")
+		m.ReadOnly = false
+	}
+	return marshalWithExtra(Alias(m), extra)
 }
 
 // UnmarshalJSON implements custom JSON unmarshaling for Mount
 func (m *Mount) UnmarshalJSON(data []byte) error {
 	type Alias Mount
-	aux := &struct {
-		*Alias
-	}{
-		Alias: (*Alias)(m),
-	}
-	
-	if err := json.Unmarshal(data, &aux); err != nil {
+	*m = Mount{}
+	raw, err := unmarshalWithRaw(data, (*Alias)(m))
+	if err != nil {
 		return err
 	}
+	if _, ok := raw["readonly"]; ok {
+		m.readOnlyKey = "readonly"
+	} else if v, ok := raw["readOnly"]; ok {
+		if err := json.Unmarshal(v, &m.ReadOnly); err != nil {
+			return fmt.Errorf("invalid readOnly value: %w", err)
+		}
+		m.readOnlyKey = "readOnly"
+	}
+	delete(raw, "readOnly")
+	m.AdditionalProperties = extraProperties(raw, reflect.TypeOf(Alias{}))
 	
 	// Validate required fields
 	if m.Type == "" {
@@ -288,29 +309,25 @@ func (m *Mount) UnmarshalJSON(data []byte) error {
 func BuildDockerRunCommand(dc *DevContainer, workspaceFolder string) (*DockerRunConfig, error) {
     // Expand variables in the devcontainer before building
     vars := GetStandardVariables(workspaceFolder)
+    vars["devcontainerId"] = dc.DevContainerID(workspaceFolder)
     ExpandVariables(dc, vars)
 
     // Resolve ${localEnv:VAR[:default]} in mounts; fail if any unresolved without default
     var missing []string
     for i, mount := range dc.Mounts {
-        if s, ok := mount.(string); ok {
-            resolved, miss := resolveLocalEnvVars(s)
-            if len(miss) > 0 {
-                missing = append(missing, miss...)
-            }
-            dc.Mounts[i] = resolved
-        } else if m, ok := mount.(map[string]interface{}); ok {
-            if src, ok2 := m["source"].(string); ok2 {
-                resolved, miss := resolveLocalEnvVars(src)
-                if len(miss) > 0 { missing = append(missing, miss...) }
-                m["source"] = resolved
-            }
-            if tgt, ok2 := m["target"].(string); ok2 {
-                resolved, miss := resolveLocalEnvVars(tgt)
-                if len(miss) > 0 { missing = append(missing, miss...) }
-                m["target"] = resolved
-            }
+        if mount.Object == nil {
+            resolved, miss := resolveLocalEnvVars(mount.Value)
+            missing = append(missing, miss...)
+            dc.Mounts[i].Value = resolved
+            continue
         }
+        m := *mount.Object
+        var miss []string
+        m.Source, miss = resolveLocalEnvVars(m.Source)
+        missing = append(missing, miss...)
+        m.Target, miss = resolveLocalEnvVars(m.Target)
+        missing = append(missing, miss...)
+        dc.Mounts[i].Object = &m
     }
     if len(missing) > 0 {
         return nil, fmt.Errorf("unresolved localEnv variables in devcontainer mounts: %s", strings.Join(uniqueStrings(missing), ", "))
@@ -336,22 +353,8 @@ func BuildDockerRunCommand(dc *DevContainer, workspaceFolder string) (*DockerRun
 		return nil, fmt.Errorf("no image specified")
 	}
 	
-	// Set workspace folder
-	if dc.NonComposeBase != nil && dc.NonComposeBase.WorkspaceFolder != nil {
-		config.WorkspaceFolder = *dc.NonComposeBase.WorkspaceFolder
-	} else if config.WorkspaceFolder == "" {
-		config.WorkspaceFolder = "/workspaces/" + filepath.Base(workspaceFolder)
-	}
-	
-	// Handle workspace mount
-	if dc.NonComposeBase != nil && dc.NonComposeBase.WorkspaceMount != nil {
-		config.WorkspaceMount = *dc.NonComposeBase.WorkspaceMount
-	} else if dc.WorkspaceMount != "" {
-		config.WorkspaceMount = dc.WorkspaceMount
-	} else {
-		absPath, _ := filepath.Abs(workspaceFolder)
-		config.WorkspaceMount = fmt.Sprintf("type=bind,source=%s,target=%s", absPath, config.WorkspaceFolder)
-	}
+	// Set workspace folder and mount
+	config.WorkspaceFolder, config.WorkspaceMount = containerWorkspace(dc, workspaceFolder)
 	
 	// Handle environment variables
 	for k, v := range dc.ContainerEnv {
@@ -396,16 +399,8 @@ func BuildDockerRunCommand(dc *DevContainer, workspaceFolder string) (*DockerRun
 	
 	// Handle mounts (can be strings or objects)
 	for _, mount := range dc.Mounts {
-		switch m := mount.(type) {
-		case string:
-			// String format: "source=...,target=...,type=...,readonly"
-			config.Mounts = append(config.Mounts, m)
-		case map[string]interface{}:
-			// Object format: convert to string
-			mountStr := buildMountStringFromMap(m)
-			if mountStr != "" {
-				config.Mounts = append(config.Mounts, mountStr)
-			}
+		if mountStr := mount.MountArg(); mountStr != "" {
+			config.Mounts = append(config.Mounts, mountStr)
 		}
 	}
 	
@@ -434,9 +429,31 @@ func BuildDockerRunCommand(dc *DevContainer, workspaceFolder string) (*DockerRun
 		config.RunArgs = dc.NonComposeBase.RunArgs
 	}
 	
+	// Label the container so it can be found again for the workspace
+	config.Labels = ResourceLabels(dc, workspaceFolder)
+	
 	return config, nil
 }
 
+// containerWorkspace returns the workspace folder inside the container and
+// the mount providing it for the local workspace folder
+func containerWorkspace(dc *DevContainer, workspaceFolder string) (string, string) {
+	folder := dc.WorkspaceFolder
+	if dc.NonComposeBase != nil && dc.NonComposeBase.WorkspaceFolder != nil {
+		folder = *dc.NonComposeBase.WorkspaceFolder
+	} else if folder == "" {
+		folder = "/workspaces/" + filepath.Base(workspaceFolder)
+	}
+	
+	if dc.NonComposeBase != nil && dc.NonComposeBase.WorkspaceMount != nil {
+		return folder, *dc.NonComposeBase.WorkspaceMount
+	}
+	if dc.WorkspaceMount != "" {
+		return folder, dc.WorkspaceMount
+	}
+	return folder, fmt.Sprintf("type=bind,source=%s,target=%s", absPath(workspaceFolder), folder)
+}
+
 // ToDockerRunArgs converts the config to docker run arguments
 func (c *DockerRunConfig) ToDockerRunArgs() []string {
 	args := []string{"run", "--rm", "-it"}
@@ -466,6 +483,11 @@ func (c *DockerRunConfig) ToDockerRunArgs() []string {
 		args = append(args, "-p", port)
 	}
 	
+	// Add labels
+	for _, k := range sortedKeys(c.Labels) {
+		args = append(args, "--label", fmt.Sprintf("%s=%s", k, c.Labels[k]))
+	}
+	
 	// Add additional run args first
 	if c.RunArgs != nil {
 		args = append(args, c.RunArgs...)
@@ -555,85 +577,23 @@ func (c *DockerRunConfig) Validate() error {
 
 // Helper functions
 
-func parseForwardPorts(ports interface{}) []string {
+func parseForwardPorts(ports []Port) []string {
 	var result []string
-	
-	switch v := ports.(type) {
-	case []interface{}:
-		for _, port := range v {
-			switch p := port.(type) {
-			case float64:
-				result = append(result, fmt.Sprintf("%d:%d", int(p), int(p)))
-			case string:
-				result = append(result, p)
-			}
-		}
+	for _, port := range ports {
+		result = append(result, formatForwardPort(port))
 	}
-	
 	return result
 }
 
-func parseAppPorts(ports interface{}) []string {
-	var result []string
-	
-	switch v := ports.(type) {
-	case float64:
-		result = append(result, fmt.Sprintf("%d:%d", int(v), int(v)))
-	case string:
-		result = append(result, v)
-	case []interface{}:
-		for _, port := range v {
-			switch p := port.(type) {
-			case float64:
-				result = append(result, fmt.Sprintf("%d:%d", int(p), int(p)))
-			case string:
-				result = append(result, p)
-			}
-		}
-	}
-	
-	return result
+func parseAppPorts(ports PortList) []string {
+	return parseForwardPorts(ports)
 }
 
-func formatForwardPort(port interface{}) string {
-	switch p := port.(type) {
-	case float64:
-		return fmt.Sprintf("%d:%d", int(p), int(p))
-	case int:
-		return fmt.Sprintf("%d:%d", p, p)
-	case string:
-		return p
-	default:
-		return ""
-	}
-}
-
-func parseMounts(mounts interface{}) []Mount {
-	var result []Mount
-	
-	switch v := mounts.(type) {
-	case []interface{}:
-		for _, mount := range v {
-			if m, ok := mount.(map[string]interface{}); ok {
-				mountObj := Mount{}
-				if t, ok := m["type"].(string); ok {
-					mountObj.Type = t
-				}
-				if s, ok := m["source"].(string); ok {
-					mountObj.Source = s
-				}
-				if t, ok := m["target"].(string); ok {
-					mountObj.Target = t
-				}
-				if r, ok := m["readOnly"].(bool); ok {
-					mountObj.ReadOnly = r
-				}
-				result = append(result, mountObj)
-			}
-		}
+func formatForwardPort(port Port) string {
+	if port.IsNumber() {
+		return fmt.Sprintf("%d:%d", port.Number, port.Number)
 	}
-	
-	return result
+	return port.Value
 }
 
 func contains(slice []string, item string) bool {
@@ -848,6 +808,7 @@ type LifecycleCommand struct {
 	Args     []string                          // For array commands
 	Commands map[string]*LifecycleCommand      // For object commands (nested commands)
 	Object   map[string]interface{}            // Raw object data
+	Sequence []*LifecycleCommand               // For merged commands run in order
 }
 
 // ParseLifecycleCommand parses an interface{} into a LifecycleCommand
@@ -882,6 +843,8 @@ func ParseLifecycleCommand(cmd interface{}) (*LifecycleCommand, error) {
 				result.Commands[name] = nestedCmd
 			}
 		}
+	case *LifecycleCommand:
+		return v, nil
 	default:
 		return nil, fmt.Errorf("unsupported command type: %T", cmd)
 	}
@@ -915,6 +878,15 @@ func (lc *LifecycleCommand) ToShellCommand() string {
 	case "object":
 		// For object commands, return a comment indicating multiple commands
 		return "# Multiple commands:"
+	case "sequence":
+		// Run each contributed command in order
+		var steps []string
+		for _, step := range lc.Sequence {
+			if shellCmd := step.ToShellCommand(); shellCmd != "" {
+				steps = append(steps, shellCmd)
+			}
+		}
+		return strings.Join(steps, "\n")
 	default:
 		return ""
 	}
@@ -964,39 +936,16 @@ func ProcessLifecycleCommands(dc *DevContainer) (map[string]*LifecycleCommand, e
 	commands := make(map[string]*LifecycleCommand)
 	
 	// Process each lifecycle command
-	if dc.InitializeCommand != nil {
-		if cmd, err := ParseLifecycleCommand(dc.InitializeCommand); err == nil && cmd != nil {
-			commands["initializeCommand"] = cmd
-		}
-	}
-	
-	if dc.OnCreateCommand != nil {
-		if cmd, err := ParseLifecycleCommand(dc.OnCreateCommand); err == nil && cmd != nil {
-			commands["onCreateCommand"] = cmd
-		}
-	}
-	
-	if dc.UpdateContentCommand != nil {
-		if cmd, err := ParseLifecycleCommand(dc.UpdateContentCommand); err == nil && cmd != nil {
-			commands["updateContentCommand"] = cmd
-		}
-	}
-	
-	if dc.PostCreateCommand != nil {
-		if cmd, err := ParseLifecycleCommand(dc.PostCreateCommand); err == nil && cmd != nil {
-			commands["postCreateCommand"] = cmd
-		}
-	}
-	
-	if dc.PostStartCommand != nil {
-		if cmd, err := ParseLifecycleCommand(dc.PostStartCommand); err == nil && cmd != nil {
-			commands["postStartCommand"] = cmd
-		}
-	}
-	
-	if dc.PostAttachCommand != nil {
-		if cmd, err := ParseLifecycleCommand(dc.PostAttachCommand); err == nil && cmd != nil {
-			commands["postAttachCommand"] = cmd
+	for name, cmd := range map[string]*LifecycleCommand{
+		"initializeCommand":    dc.InitializeCommand,
+		"onCreateCommand":      dc.OnCreateCommand,
+		"updateContentCommand": dc.UpdateContentCommand,
+		"postCreateCommand":    dc.PostCreateCommand,
+		"postStartCommand":     dc.PostStartCommand,
+		"postAttachCommand":    dc.PostAttachCommand,
+	} {
+		if cmd != nil {
+			commands[name] = cmd
 		}
 	}
 	
@@ -1063,68 +1012,24 @@ func GetLifecycleScript(dc *DevContainer, phase string) (string, error) {
 
 // ExpandVariables expands variables in a DevContainer's command strings
 func ExpandVariables(dc *DevContainer, vars map[string]string) {
-	// Helper function to expand variables in interface{}
-	var expandInterface func(cmd interface{}) interface{}
-	expandInterface = func(cmd interface{}) interface{} {
-		switch v := cmd.(type) {
-		case string:
-			return expandVariableString(v, vars)
-		case []interface{}:
-			result := make([]interface{}, len(v))
-			for i, item := range v {
-				if s, ok := item.(string); ok {
-					result[i] = expandVariableString(s, vars)
-				} else {
-					result[i] = item
-				}
-			}
-			return result
-		case map[string]interface{}:
-			result := make(map[string]interface{})
-			for k, val := range v {
-				result[k] = expandInterface(val)
-			}
-			return result
-		default:
-			return cmd
-		}
-	}
-	
 	// Expand variables in all commands
-	if dc.InitializeCommand != nil {
-		dc.InitializeCommand = expandInterface(dc.InitializeCommand)
-	}
-	if dc.OnCreateCommand != nil {
-		dc.OnCreateCommand = expandInterface(dc.OnCreateCommand)
-	}
-	if dc.UpdateContentCommand != nil {
-		dc.UpdateContentCommand = expandInterface(dc.UpdateContentCommand)
-	}
-	if dc.PostCreateCommand != nil {
-		dc.PostCreateCommand = expandInterface(dc.PostCreateCommand)
-	}
-	if dc.PostStartCommand != nil {
-		dc.PostStartCommand = expandInterface(dc.PostStartCommand)
-	}
-	if dc.PostAttachCommand != nil {
-		dc.PostAttachCommand = expandInterface(dc.PostAttachCommand)
-	}
+	dc.InitializeCommand = dc.InitializeCommand.expand(vars)
+	dc.OnCreateCommand = dc.OnCreateCommand.expand(vars)
+	dc.UpdateContentCommand = dc.UpdateContentCommand.expand(vars)
+	dc.PostCreateCommand = dc.PostCreateCommand.expand(vars)
+	dc.PostStartCommand = dc.PostStartCommand.expand(vars)
+	dc.PostAttachCommand = dc.PostAttachCommand.expand(vars)
 	
 	// Expand variables in mounts
 	for i, mount := range dc.Mounts {
-		switch m := mount.(type) {
-		case string:
-			// Expand variables in string mount
-			dc.Mounts[i] = expandVariableString(m, vars)
-		case map[string]interface{}:
-			// Expand variables in object mount
-			if source, ok := m["source"].(string); ok {
-				m["source"] = expandVariableString(source, vars)
-			}
-			if target, ok := m["target"].(string); ok {
-				m["target"] = expandVariableString(target, vars)
-			}
+		if mount.Object == nil {
+			dc.Mounts[i].Value = expandVariableString(mount.Value, vars)
+			continue
 		}
+		m := *mount.Object
+		m.Source = expandVariableString(m.Source, vars)
+		m.Target = expandVariableString(m.Target, vars)
+		dc.Mounts[i].Object = &m
 	}
 	
 	// Expand variables in NonComposeBase fields
@@ -1153,6 +1058,34 @@ func ExpandVariables(dc *DevContainer, vars map[string]string) {
 	}
 }
 
+// expand returns a copy of the command with variables expanded
+func (lc *LifecycleCommand) expand(vars map[string]string) *LifecycleCommand {
+	if lc == nil {
+		return nil
+	}
+	result := &LifecycleCommand{Type: lc.Type, Object: lc.Object}
+	switch lc.Type {
+	case CommandTypeString:
+		result.Command = expandVariableString(lc.Command, vars)
+	case CommandTypeArray:
+		result.Args = make([]string, len(lc.Args))
+		for i, arg := range lc.Args {
+			result.Args[i] = expandVariableString(arg, vars)
+		}
+	case CommandTypeObject:
+		result.Commands = make(map[string]*LifecycleCommand, len(lc.Commands))
+		for name, cmd := range lc.Commands {
+			result.Commands[name] = cmd.expand(vars)
+		}
+	case CommandTypeSequence:
+		result.Sequence = make([]*LifecycleCommand, len(lc.Sequence))
+		for i, step := range lc.Sequence {
+			result.Sequence[i] = step.expand(vars)
+		}
+	}
+	return result
+}
+
 // expandVariableString expands variables in a string
 func expandVariableString(s string, vars map[string]string) string {
     result := s
@@ -1219,7 +1152,8 @@ func HostRequirementsCheck(req *DevContainerCommonHostRequirements) error {
 	return nil
 }
 
-// MergeDevContainers merges multiple DevContainers
+// MergeDevContainers merges override on top of base following the
+// devcontainer spec merge rules (see MergeDevContainersWithTrace)
 func MergeDevContainers(base, override *DevContainer) *DevContainer {
 	if base == nil {
 		return override
@@ -1228,149 +1162,7 @@ func MergeDevContainers(base, override *DevContainer) *DevContainer {
 		return base
 	}
 	
-	// Deep copy base
-	result := *base
-	
-	// Merge basic fields
-	if override.Image != "" {
-		result.Image = override.Image
-	}
-	if override.ImageContainer != nil {
-		result.ImageContainer = override.ImageContainer
-	}
-	if override.Name != nil {
-		result.Name = override.Name
-	}
-	
-	// Merge environment variables
-	if len(override.ContainerEnv) > 0 {
-		if result.ContainerEnv == nil {
-			result.ContainerEnv = make(map[string]string)
-		}
-		for k, v := range override.ContainerEnv {
-			result.ContainerEnv[k] = v
-		}
-	}
-	
-	// Override arrays (not merge)
-	if override.ForwardPorts != nil {
-		result.ForwardPorts = override.ForwardPorts
-	}
-	if len(override.CapAdd) > 0 {
-		result.CapAdd = override.CapAdd
-	}
-	if len(override.SecurityOpt) > 0 {
-		result.SecurityOpt = override.SecurityOpt
-	}
-	
-	// Merge features
-	if base.Features != nil || override.Features != nil {
-		result.Features = mergeFeatures(base.Features, override.Features)
-	}
-	
-	// Merge mounts
-	if len(override.Mounts) > 0 {
-		result.Mounts = override.Mounts
-	}
-	
-	// Merge NonComposeBase
-	if override.NonComposeBase != nil {
-		if result.NonComposeBase == nil {
-			result.NonComposeBase = override.NonComposeBase
-		} else {
-			// Merge individual fields
-			if override.NonComposeBase.WorkspaceFolder != nil {
-				result.NonComposeBase.WorkspaceFolder = override.NonComposeBase.WorkspaceFolder
-			}
-			if override.NonComposeBase.WorkspaceMount != nil {
-				result.NonComposeBase.WorkspaceMount = override.NonComposeBase.WorkspaceMount
-			}
-			if override.NonComposeBase.AppPort != nil {
-				result.NonComposeBase.AppPort = override.NonComposeBase.AppPort
-			}
-			// Always override RunArgs (even if empty)
-			result.NonComposeBase.RunArgs = override.NonComposeBase.RunArgs
-		}
-	}
-	
-	// Merge lifecycle commands
-	if override.InitializeCommand != nil {
-		result.InitializeCommand = override.InitializeCommand
-	}
-	if override.OnCreateCommand != nil {
-		result.OnCreateCommand = override.OnCreateCommand
-	}
-	if override.UpdateContentCommand != nil {
-		result.UpdateContentCommand = override.UpdateContentCommand
-	}
-	if override.PostCreateCommand != nil {
-		result.PostCreateCommand = override.PostCreateCommand
-	}
-	if override.PostStartCommand != nil {
-		result.PostStartCommand = override.PostStartCommand
-	}
-	if override.PostAttachCommand != nil {
-		result.PostAttachCommand = override.PostAttachCommand
-	}
-	
-	return &result
-}
-
-// LoadDevContainerWithExtends loads a devcontainer.json with extends support
-func LoadDevContainerWithExtends(path string, resolver interface{}) (*DevContainer, error) {
-	// Load the main config
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read devcontainer.json: %w", err)
-	}
-	
-	// Parse to check for extends
-	var raw map[string]interface{}
-	if err := json.Unmarshal(data, &raw); err != nil {
-		return nil, fmt.Errorf("failed to parse devcontainer.json: %w", err)
-	}
-	
-	// Load the main config
-	dc, err := LoadDevContainer(path)
-	if err != nil {
-		return nil, err
-	}
-	
-	// Check for extends field
-	if extendsValue, ok := raw["extends"]; ok {
-		if extendsPath, ok := extendsValue.(string); ok {
-			// Resolve the extends path
-			baseConfigPath := ""
-			
-			if strings.HasPrefix(extendsPath, "file://") {
-				// Handle file:// prefix
-				baseDir := strings.TrimPrefix(extendsPath, "file://")
-				baseConfigPath = filepath.Join(baseDir, ".devcontainer", "devcontainer.json")
-			} else if strings.HasSuffix(extendsPath, ".json") {
-				// Direct path to JSON file
-				if filepath.IsAbs(extendsPath) {
-					baseConfigPath = extendsPath
-				} else {
-					baseConfigPath = filepath.Join(filepath.Dir(path), extendsPath)
-				}
-			} else {
-				// Relative directory path
-				baseDir := filepath.Join(filepath.Dir(path), extendsPath)
-				baseConfigPath = filepath.Join(baseDir, ".devcontainer", "devcontainer.json")
-			}
-			
-			// Load base config
-			baseConfig, err := LoadDevContainerWithExtends(baseConfigPath, resolver)
-			if err != nil {
-				return nil, fmt.Errorf("failed to load extends config: %w", err)
-			}
-			
-			// Merge configs (override takes precedence)
-			dc = MergeDevContainers(baseConfig, dc)
-		}
-	}
-	
-	return dc, nil
+	return mergeConfigs(base, override)
 }
 
 // GetStandardVariables returns standard devcontainer variables
@@ -1445,30 +1237,6 @@ func mergeRemoteEnv(base, override map[string]*string) map[string]*string {
 	return result
 }
 
-// buildMountStringFromMap builds a mount string from a map
-func buildMountStringFromMap(m map[string]interface{}) string {
-	mountType := "bind"
-	if t, ok := m["type"].(string); ok {
-		mountType = t
-	}
-	
-	result := fmt.Sprintf("type=%s", mountType)
-	
-	if source, ok := m["source"].(string); ok {
-		result += fmt.Sprintf(",source=%s", source)
-	}
-	
-	if target, ok := m["target"].(string); ok {
-		result += fmt.Sprintf(",target=%s", target)
-	}
-	
-	if readOnly, ok := m["readonly"].(bool); ok && readOnly {
-		result += ",readonly"
-	}
-	
-	return result
-}
-
 // validateDockerRunFlags validates docker run flags
 func validateDockerRunFlags(flags []string) error {
 	// Basic validation
@@ -1507,3 +1275,51 @@ func validateDockerRunFlags(flags []string) error {
 	}
 	return nil
 }
+
+// imageName returns the image a configuration runs, if any
+func (dc *DevContainer) imageName() string {
+	if dc.ImageContainer != nil && dc.ImageContainer.Image != "" {
+		return dc.ImageContainer.Image
+	}
+	return dc.Image
+}
+
+// dockerfilePath returns the Dockerfile a configuration builds, if any
+func (dc *DevContainer) dockerfilePath() string {
+	if dc.Build.Dockerfile != "" {
+		return dc.Build.Dockerfile
+	}
+	if dc.DockerfileContainer != "" {
+		return dc.DockerfileContainer
+	}
+	return dc.DockerFile
+}
+
+// configDir returns the directory relative paths in the configuration
+// resolve against
+func (dc *DevContainer) configDir(workspaceFolder string) string {
+	if dc.ConfigFilePath != "" {
+		return filepath.Dir(dc.ConfigFilePath)
+	}
+	return filepath.Join(workspaceFolder, ".devcontainer")
+}
+
+// resolveConfigPath resolves path against dir unless it is absolute
+func resolveConfigPath(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// ResolvedRemoteEnv returns the remote environment with unset (null)
+// variables removed
+func (dc *DevContainer) ResolvedRemoteEnv() map[string]string {
+	result := make(map[string]string, len(dc.RemoteEnv))
+	for k, v := range dc.RemoteEnv {
+		if v != nil {
+			result[k] = *v
+		}
+	}
+	return result
+}
@@ -0,0 +1,64 @@
+package devcontainer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"math/big"
+	"path/filepath"
+	"strings"
+)
+
+// Identifying labels used by the reference devcontainer CLI
+const (
+	LabelLocalFolder = "devcontainer.local_folder"
+	LabelConfigFile  = "devcontainer.config_file"
+)
+
+// devcontainerIDLength is the padded length of a base-32 encoded SHA-256 digest
+const devcontainerIDLength = 52
+
+// IDLabels returns the identifying labels for a workspace and config file.
+// The config file label is omitted when configFile is empty.
+func IDLabels(localFolder, configFile string) map[string]string {
+	labels := map[string]string{
+		LabelLocalFolder: absPath(localFolder),
+	}
+	if configFile != "" {
+		labels[LabelConfigFile] = absPath(configFile)
+	}
+	return labels
+}
+
+// ComputeDevContainerID computes ${devcontainerId} from identifying labels.
+// It matches the reference CLI: the labels are serialized as JSON with sorted
+// keys, hashed with SHA-256 and rendered in base 32, left-padded to 52 chars.
+func ComputeDevContainerID(labels map[string]string) string {
+	// encoding/json sorts map keys; disable HTML escaping to match JSON.stringify
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(labels)
+	data := bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+
+	sum := sha256.Sum256(data)
+	id := new(big.Int).SetBytes(sum[:]).Text(32)
+	if len(id) < devcontainerIDLength {
+		id = strings.Repeat("0", devcontainerIDLength-len(id)) + id
+	}
+	return id
+}
+
+// DevContainerID returns the stable ${devcontainerId} for this configuration
+// when used with the given local workspace folder.
+func (dc *DevContainer) DevContainerID(localFolder string) string {
+	return ComputeDevContainerID(IDLabels(localFolder, dc.ConfigFilePath))
+}
+
+// absPath returns the absolute form of path, or path itself on failure
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
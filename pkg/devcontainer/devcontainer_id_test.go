@@ -0,0 +1,66 @@
+package devcontainer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestComputeDevContainerID(t *testing.T) {
+	labels := map[string]string{
+		LabelLocalFolder: "/home/user/project",
+		LabelConfigFile:  "/home/user/project/.devcontainer/devcontainer.json",
+	}
+
+	// Value produced by the reference CLI's getDevContainerId for the same labels
+	expected := "0ns9efvs2cg80a2avksvk7nqv06jrab7n2918j79h49700ucligl"
+	if got := ComputeDevContainerID(labels); got != expected {
+		t.Errorf("expected id %s, got %s", expected, got)
+	}
+
+	other := ComputeDevContainerID(map[string]string{LabelLocalFolder: "/home/user/other"})
+	if len(other) != 52 {
+		t.Errorf("expected id length 52, got %d", len(other))
+	}
+	if other == expected {
+		t.Error("expected different labels to produce different ids")
+	}
+}
+
+func TestDevContainerIDVariable(t *testing.T) {
+	tmpDir := t.TempDir()
+	configPath := filepath.Join(tmpDir, ".devcontainer", "devcontainer.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	config := `{
+		"image": "alpine:latest",
+		"mounts": ["source=cache-${devcontainerId},target=/cache,type=volume"]
+	}`
+	if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err := LoadDevContainer(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := dc.DevContainerID(tmpDir)
+	if id != ComputeDevContainerID(IDLabels(tmpDir, configPath)) {
+		t.Errorf("expected id to be derived from folder and config labels")
+	}
+
+	runConfig, err := BuildDockerRunCommand(dc, tmpDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "source=cache-" + id + ",target=/cache,type=volume"
+	if len(runConfig.Mounts) != 1 || runConfig.Mounts[0] != expected {
+		t.Errorf("expected mount %s, got %v", expected, runConfig.Mounts)
+	}
+
+	mgr := &Manager{devContainer: dc}
+	if mgr.DevContainerID(tmpDir) != id {
+		t.Error("expected manager to report the same id")
+	}
+}
@@ -0,0 +1,127 @@
+package devcontainer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+)
+
+// DevContainerConfig is a devcontainer.json found by DiscoverDevContainers
+type DevContainerConfig struct {
+	// Name is the folder of a config in .devcontainer/<name>/devcontainer.json,
+	// or "" for the default config of a folder
+	Name string `json:"name,omitempty"`
+
+	// Path is the absolute path of the devcontainer.json
+	Path string `json:"path"`
+
+	// WorkspaceFolder is the folder the configuration applies to, i.e. the
+	// folder containing .devcontainer or .devcontainer.json
+	WorkspaceFolder string `json:"workspaceFolder"`
+}
+
+// DiscoverDevContainers returns every devcontainer configuration that
+// applies to dir. The folder itself is searched first, then, when dir is
+// inside a git repository, each parent up to the repository root. Within a
+// folder the default locations come first, in the order FindDevContainerFile
+// checks them, followed by .devcontainer/<name>/devcontainer.json sorted by
+// name.
+func DiscoverDevContainers(dir string) ([]DevContainerConfig, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	folders := []string{dir}
+	if root := findRepoRoot(dir); root != "" {
+		for folder := dir; folder != root; {
+			folder = filepath.Dir(folder)
+			folders = append(folders, folder)
+		}
+	}
+
+	var configs []DevContainerConfig
+	for _, folder := range folders {
+		found, err := folderDevContainers(os.DirFS(folder), ".")
+		if err != nil {
+			return nil, err
+		}
+		for _, config := range found {
+			config.Path = filepath.Join(folder, filepath.FromSlash(config.Path))
+			config.WorkspaceFolder = folder
+			configs = append(configs, config)
+		}
+	}
+	return configs, nil
+}
+
+// DiscoverDevContainersFS is DiscoverDevContainers for a file system whose
+// root is the repository root, such as a GitFS. dir and the returned paths
+// are slash-separated paths within fsys.
+func DiscoverDevContainersFS(fsys fs.FS, dir string) ([]DevContainerConfig, error) {
+	dir = path.Clean(dir)
+	if !fs.ValidPath(dir) {
+		return nil, fmt.Errorf("invalid path %q", dir)
+	}
+
+	var configs []DevContainerConfig
+	for folder := dir; ; folder = path.Dir(folder) {
+		found, err := folderDevContainers(fsys, folder)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, found...)
+		if folder == "." {
+			return configs, nil
+		}
+	}
+}
+
+// folderDevContainers returns the configurations declared in one folder
+func folderDevContainers(fsys fs.FS, folder string) ([]DevContainerConfig, error) {
+	var configs []DevContainerConfig
+	for _, name := range []string{
+		path.Join(folder, ".devcontainer", "devcontainer.json"),
+		path.Join(folder, ".devcontainer.json"),
+		path.Join(folder, ".devcontainer", ".devcontainer.json"),
+	} {
+		if isFileFS(fsys, name) {
+			configs = append(configs, DevContainerConfig{Path: name, WorkspaceFolder: folder})
+		}
+	}
+
+	entries, err := fs.ReadDir(fsys, path.Join(folder, ".devcontainer"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read .devcontainer folder: %w", err)
+	}
+	for _, entry := range entries {
+		name := path.Join(folder, ".devcontainer", entry.Name(), "devcontainer.json")
+		if entry.IsDir() && isFileFS(fsys, name) {
+			configs = append(configs, DevContainerConfig{Name: entry.Name(), Path: name, WorkspaceFolder: folder})
+		}
+	}
+	return configs, nil
+}
+
+// findRepoRoot returns the closest folder at or above dir that contains a
+// .git entry, or "" if there is none
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func isFileFS(fsys fs.FS, name string) bool {
+	info, err := fs.Stat(fsys, name)
+	return err == nil && !info.IsDir()
+}
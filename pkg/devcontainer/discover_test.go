@@ -0,0 +1,106 @@
+package devcontainer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func writeConfig(t *testing.T, path, content string) {
+	t.Helper()
+	writeFile(t, path, content)
+}
+
+// writeFile writes content to path, creating its folder
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDiscoverDevContainers(t *testing.T) {
+	repo, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	service := filepath.Join(repo, "services", "api")
+	writeConfig(t, filepath.Join(repo, ".devcontainer", "devcontainer.json"), `{"image": "alpine:3.19"}`)
+	writeConfig(t, filepath.Join(repo, ".devcontainer", "python", "devcontainer.json"), `{"image": "python:3.12"}`)
+	writeConfig(t, filepath.Join(repo, ".devcontainer", "go", "devcontainer.json"), `{"image": "golang:1.22"}`)
+	writeFile(t, filepath.Join(repo, ".devcontainer", "empty", "README.md"), "not a config")
+	writeConfig(t, filepath.Join(service, ".devcontainer.json"), `{"image": "node:20"}`)
+
+	configs, err := DiscoverDevContainers(service)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DevContainerConfig{
+		{Path: filepath.Join(service, ".devcontainer.json"), WorkspaceFolder: service},
+		{Path: filepath.Join(repo, ".devcontainer", "devcontainer.json"), WorkspaceFolder: repo},
+		{Name: "go", Path: filepath.Join(repo, ".devcontainer", "go", "devcontainer.json"), WorkspaceFolder: repo},
+		{Name: "python", Path: filepath.Join(repo, ".devcontainer", "python", "devcontainer.json"), WorkspaceFolder: repo},
+	}
+	if !reflect.DeepEqual(configs, want) {
+		t.Errorf("unexpected configs\nwant: %+v\ngot:  %+v", want, configs)
+	}
+}
+
+func TestDiscoverDevContainersOutsideRepository(t *testing.T) {
+	parent := t.TempDir()
+	writeConfig(t, filepath.Join(parent, ".devcontainer.json"), `{"image": "alpine:3.19"}`)
+	dir := filepath.Join(parent, "project")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	configs, err := DiscoverDevContainers(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 0 {
+		t.Errorf("expected parents not to be searched outside a repository, got %+v", configs)
+	}
+}
+
+func TestManagerConfigSelection(t *testing.T) {
+	dir := t.TempDir()
+	defaultPath := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	namedPath := filepath.Join(dir, ".devcontainer", "go", "devcontainer.json")
+	writeConfig(t, defaultPath, `{"image": "alpine:3.19"}`)
+	writeConfig(t, namedPath, `{"image": "golang:1.22"}`)
+
+	mgr := &Manager{}
+	dc, err := mgr.loadDevContainer(context.Background(), dir, api.ApplyCreateOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.ConfigFilePath != defaultPath {
+		t.Errorf("expected default config, got %s", dc.ConfigFilePath)
+	}
+
+	dc, err = mgr.loadDevContainer(context.Background(), dir, api.ApplyCreateOptions(api.WithConfigPath(".devcontainer/go/devcontainer.json")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.ConfigFilePath != namedPath || dc.imageName() != "golang:1.22" {
+		t.Errorf("expected selected config, got %s", dc.ConfigFilePath)
+	}
+	if mgr.DevContainerID(dir, api.WithConfigPath(namedPath)) == mgr.DevContainerID(dir) {
+		t.Error("expected configs to have different ids")
+	}
+
+	if _, err := mgr.loadDevContainer(context.Background(), dir, api.ApplyCreateOptions(api.WithConfigPath("missing.json"))); err == nil {
+		t.Error("expected error for a missing selected config")
+	}
+}
@@ -2,16 +2,25 @@ package devcontainer
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/colony-2/devcontainer-go/pkg/api"
+	cerrdefs "github.com/containerd/errdefs"
+	"github.com/docker/docker/api/types/build"
 	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/events"
+	"github.com/docker/docker/api/types/filters"
 	"github.com/docker/docker/api/types/image"
 	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/api/types/network"
 	"github.com/docker/docker/api/types/strslice"
 	"github.com/docker/docker/api/types/volume"
 	"github.com/docker/docker/client"
@@ -20,13 +29,26 @@ import (
 
 // DockerClient provides Docker operations using the Docker SDK
 type DockerClient struct {
-	client *client.Client
+	client   *client.Client
+	engine   EngineType     // Engine behind the socket, detected on connect
+	rootless bool           // Whether the daemon runs rootless
+	endpoint DockerEndpoint // Daemon connected to and why
 }
 
-// NewDockerClient creates a new Docker client using the SDK
+// NewDockerClient creates a new Docker client using the SDK. A Docker
+// context selected with DOCKER_CONTEXT or docker context use is connected
+// to exclusively; otherwise DOCKER_HOST and the usual sockets are probed.
 func NewDockerClient() (*DockerClient, error) {
+	endpoint, err := ResolveDockerEndpoint()
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve Docker context: %w", err)
+	}
+	if endpoint != nil {
+		return connectEndpoint(endpoint)
+	}
+
 	var connectionAttempts []func() (*client.Client, error)
-	
+
 	// On macOS, prioritize Docker Desktop locations
 	if runtime.GOOS == "darwin" {
 		connectionAttempts = []func() (*client.Client, error){
@@ -106,10 +128,13 @@ func NewDockerClient() (*DockerClient, error) {
 			},
 		}
 	}
-	
+
+	// Podman serves the Docker API on its own sockets
+	connectionAttempts = append(connectionAttempts, podmanSocketAttempts()...)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
-	
+
 	var lastErr error
 	for _, attempt := range connectionAttempts {
 		cli, err := attempt()
@@ -117,17 +142,19 @@ func NewDockerClient() (*DockerClient, error) {
 			lastErr = err
 			continue
 		}
-		
+
 		// Test connection
 		_, err = cli.Ping(ctx)
 		if err == nil {
-			return &DockerClient{client: cli}, nil
+			dc := &DockerClient{client: cli, endpoint: probedEndpoint(cli)}
+			dc.detectEngine(ctx)
+			return dc, nil
 		}
-		
+
 		cli.Close()
 		lastErr = err
 	}
-	
+
 	return nil, fmt.Errorf("failed to connect to Docker daemon: %w", lastErr)
 }
 
@@ -148,7 +175,7 @@ func (c *DockerClient) RunContainer(ctx context.Context, config *DockerRunConfig
 	if err != nil {
 		return err
 	}
-	
+
 	// Start container
 	return c.StartContainer(ctx, containerID)
 }
@@ -160,7 +187,7 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 	for k, v := range config.Environment {
 		envSlice = append(envSlice, fmt.Sprintf("%s=%s", k, v))
 	}
-	
+
 	// Convert our config to Docker SDK types
 	containerConfig := &container.Config{
 		Image:        config.Image,
@@ -168,6 +195,7 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 		Env:          envSlice,
 		WorkingDir:   config.WorkspaceFolder,
 		User:         config.User,
+		Labels:       config.Labels,
 		AttachStdin:  true,
 		AttachStdout: true,
 		AttachStderr: true,
@@ -175,25 +203,25 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 		OpenStdin:    true,
 		StdinOnce:    false,
 	}
-	
+
 	// Convert Init bool to *bool
 	var initPtr *bool
 	if config.Init {
 		initPtr = &config.Init
 	}
-	
+
 	// Convert port bindings
 	hostConfig := &container.HostConfig{
 		Privileged: config.Privileged,
 		Init:       initPtr,
 	}
-	
+
 	// Parse and add mounts
 	for _, mountStr := range config.Mounts {
 		// Parse mount string (e.g., "type=bind,source=/host/path,target=/container/path,readonly")
 		mountParts := make(map[string]string)
 		mountReadOnly := false
-		
+
 		for _, part := range strings.Split(mountStr, ",") {
 			if part == "readonly" || part == "ro" {
 				mountReadOnly = true
@@ -208,7 +236,7 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 				mountParts[kv[0]] = kv[1]
 			}
 		}
-		
+
 		mountType := mount.TypeBind
 		switch mountParts["type"] {
 		case "volume":
@@ -216,43 +244,43 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 		case "tmpfs":
 			mountType = mount.TypeTmpfs
 		}
-		
+
 		dockerMount := mount.Mount{
 			Type:     mountType,
 			Source:   mountParts["source"],
 			Target:   mountParts["target"],
 			ReadOnly: mountReadOnly,
 		}
-		
+
 		// Check for empty target and fail fast
 		if dockerMount.Target == "" {
 			return "", fmt.Errorf("mount target is empty for mount string: %s", mountStr)
 		}
-		
+
 		hostConfig.Mounts = append(hostConfig.Mounts, dockerMount)
 	}
-	
+
 	// Add capabilities
 	if len(config.CapAdd) > 0 {
 		hostConfig.CapAdd = strslice.StrSlice(config.CapAdd)
 	} else if len(config.Capabilities) > 0 {
 		hostConfig.CapAdd = strslice.StrSlice(config.Capabilities)
 	}
-	
+
 	// Add security options
 	if len(config.SecurityOpt) > 0 {
 		hostConfig.SecurityOpt = config.SecurityOpt
 	} else if len(config.SecurityOpts) > 0 {
 		hostConfig.SecurityOpt = config.SecurityOpts
 	}
-	
+
 	// Add the workspace mount if specified
 	if config.WorkspaceMount != "" && config.WorkspaceMount != "none" {
-		
+
 		// Parse workspace mount
 		mountParts := make(map[string]string)
 		mountReadOnly := false
-		
+
 		for _, part := range strings.Split(config.WorkspaceMount, ",") {
 			if part == "readonly" || part == "ro" {
 				mountReadOnly = true
@@ -263,7 +291,7 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 				mountParts[kv[0]] = kv[1]
 			}
 		}
-		
+
 		mountType := mount.TypeBind
 		switch mountParts["type"] {
 		case "volume":
@@ -271,7 +299,7 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 		case "tmpfs":
 			mountType = mount.TypeTmpfs
 		}
-		
+
 		hostConfig.Mounts = append(hostConfig.Mounts, mount.Mount{
 			Type:     mountType,
 			Source:   mountParts["source"],
@@ -279,13 +307,16 @@ func (c *DockerClient) CreateContainer(ctx context.Context, config *DockerRunCon
 			ReadOnly: mountReadOnly,
 		})
 	}
-	
-	
+
+	if c.engine == EnginePodman {
+		adaptHostConfigForPodman(hostConfig, c.rootless)
+	}
+
 	resp, err := c.client.ContainerCreate(ctx, containerConfig, hostConfig, nil, nil, config.Name)
 	if err != nil {
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
-	
+
 	return resp.ID, nil
 }
 
@@ -295,7 +326,7 @@ func (c *DockerClient) StartContainer(ctx context.Context, containerID string) e
 	if err != nil {
 		return fmt.Errorf("failed to start container: %w", err)
 	}
-	
+
 	return nil
 }
 
@@ -308,10 +339,28 @@ func (c *DockerClient) StopContainer(ctx context.Context, containerID string) er
 	if err != nil {
 		return fmt.Errorf("failed to stop container: %w", err)
 	}
-	
+
 	return nil
 }
 
+// FindContainers returns the IDs of the containers, running or not, that
+// carry all of the given labels, most recently created first
+func (c *DockerClient) FindContainers(ctx context.Context, labels map[string]string) ([]string, error) {
+	args := filters.NewArgs()
+	for _, k := range sortedKeys(labels) {
+		args.Add("label", labelFilter(k, labels[k]))
+	}
+	list, err := c.client.ContainerList(ctx, container.ListOptions{All: true, Filters: args})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list containers: %w", err)
+	}
+	ids := make([]string, len(list))
+	for i, ctr := range list {
+		ids[i] = ctr.ID
+	}
+	return ids, nil
+}
+
 // RemoveContainer removes a container
 func (c *DockerClient) RemoveContainer(ctx context.Context, containerID string) error {
 	err := c.client.ContainerRemove(ctx, containerID, container.RemoveOptions{
@@ -320,46 +369,86 @@ func (c *DockerClient) RemoveContainer(ctx context.Context, containerID string)
 	if err != nil {
 		return fmt.Errorf("failed to remove container: %w", err)
 	}
-	
+
 	return nil
 }
 
+// ExecError reports a command that exited with a non-zero code
+type ExecError struct {
+	ExitCode int
+	Stderr   string
+}
+
+func (e *ExecError) Error() string {
+	return fmt.Sprintf("exec failed with exit code %d: %s", e.ExitCode, e.Stderr)
+}
+
 // ExecInContainer executes a command in a running container
 func (c *DockerClient) ExecInContainer(ctx context.Context, containerID string, command []string) (string, error) {
+	return c.ExecAs(ctx, containerID, ExecOptions{}, command)
+}
+
+// ExecOptions are the optional settings of ExecAs
+type ExecOptions struct {
+	User       string            // User to run as; the container user if empty
+	WorkingDir string            // Working directory; the container's if empty
+	Env        map[string]string // Additional environment variables
+
+	// Stdout and Stderr receive the output as it is produced. ExecAs then
+	// returns no output, and an *ExecError carries no standard error.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// ExecAs executes a command in a running container with options. A non-zero
+// exit code is reported as an *ExecError.
+func (c *DockerClient) ExecAs(ctx context.Context, containerID string, opts ExecOptions, command []string) (string, error) {
 	execConfig := container.ExecOptions{
 		Cmd:          command,
+		User:         opts.User,
+		WorkingDir:   opts.WorkingDir,
 		AttachStdout: true,
 		AttachStderr: true,
 	}
-	
+	for _, k := range sortedKeys(opts.Env) {
+		execConfig.Env = append(execConfig.Env, k+"="+opts.Env[k])
+	}
+
 	execResp, err := c.client.ContainerExecCreate(ctx, containerID, execConfig)
 	if err != nil {
 		return "", fmt.Errorf("failed to create exec: %w", err)
 	}
-	
+
 	resp, err := c.client.ContainerExecAttach(ctx, execResp.ID, container.ExecStartOptions{})
 	if err != nil {
 		return "", fmt.Errorf("failed to attach exec: %w", err)
 	}
 	defer resp.Close()
-	
+
 	// Read output - Docker multiplexes stdout/stderr with headers
 	var stdout, stderr strings.Builder
-	_, err = stdcopy.StdCopy(&stdout, &stderr, resp.Reader)
+	var outW, errW io.Writer = &stdout, &stderr
+	if opts.Stdout != nil {
+		outW = opts.Stdout
+	}
+	if opts.Stderr != nil {
+		errW = opts.Stderr
+	}
+	_, err = stdcopy.StdCopy(outW, errW, resp.Reader)
 	if err != nil {
 		return "", fmt.Errorf("failed to read exec output: %w", err)
 	}
-	
+
 	// Check exec exit code
 	inspectResp, err := c.client.ContainerExecInspect(ctx, execResp.ID)
 	if err != nil {
 		return "", fmt.Errorf("failed to inspect exec: %w", err)
 	}
-	
+
 	if inspectResp.ExitCode != 0 {
-		return "", fmt.Errorf("exec failed with exit code %d: %s", inspectResp.ExitCode, stderr.String())
+		return "", &ExecError{ExitCode: inspectResp.ExitCode, Stderr: stderr.String()}
 	}
-	
+
 	return stdout.String(), nil
 }
 
@@ -369,33 +458,109 @@ func (c *DockerClient) GetContainerStatus(ctx context.Context, containerID strin
 	if err != nil {
 		return "", fmt.Errorf("failed to inspect container: %w", err)
 	}
-	
+
 	return resp.State.Status, nil
 }
 
-// WaitForContainer waits for a container to reach a specific status
-func (c *DockerClient) WaitForContainer(ctx context.Context, containerID string, desiredStatus string, timeout time.Duration) error {
-	deadline := time.Now().Add(timeout)
-	
-	for time.Now().Before(deadline) {
-		status, err := c.GetContainerStatus(ctx, containerID)
-		if err != nil {
-			return err
+// InspectContainer returns the state of a container
+func (c *DockerClient) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	resp, err := c.client.ContainerInspect(ctx, containerID)
+	if cerrdefs.IsNotFound(err) {
+		return nil, fmt.Errorf("failed to inspect container: %w: %s", ErrNoSuchContainer, containerID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+
+	details := &ContainerDetails{
+		ID:   resp.ID,
+		Name: strings.TrimPrefix(resp.Name, "/"),
+	}
+	if resp.Config != nil {
+		details.Image = resp.Config.Image
+		details.Labels = resp.Config.Labels
+		details.User = resp.Config.User
+		details.Tty = resp.Config.Tty
+	}
+	if resp.State != nil {
+		details.Status = resp.State.Status
+		details.StartedAt, _ = time.Parse(time.RFC3339Nano, resp.State.StartedAt)
+		details.FinishedAt, _ = time.Parse(time.RFC3339Nano, resp.State.FinishedAt)
+		details.ExitCode = resp.State.ExitCode
+		details.OOMKilled = resp.State.OOMKilled
+		if resp.State.Health != nil {
+			details.Health = resp.State.Health.Status
 		}
-		
-		if status == desiredStatus {
-			return nil
+	}
+	details.Created, _ = time.Parse(time.RFC3339Nano, resp.Created)
+
+	// Only the first binding of a port is kept, usually the IPv4 one
+	if resp.NetworkSettings != nil {
+		for port, bindings := range resp.NetworkSettings.Ports {
+			if len(bindings) > 0 {
+				if details.Ports == nil {
+					details.Ports = map[string]string{}
+				}
+				details.Ports[string(port)] = portBinding(bindings[0].HostIP, bindings[0].HostPort)
+			}
 		}
-		
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		case <-time.After(500 * time.Millisecond):
-			// Continue checking
+	}
+	for _, mp := range resp.Mounts {
+		source := mp.Source
+		if mp.Type == mount.TypeVolume {
+			source = mp.Name
 		}
+		details.Mounts = append(details.Mounts, Mount{
+			Type:     string(mp.Type),
+			Source:   source,
+			Target:   mp.Destination,
+			ReadOnly: !mp.RW,
+		})
 	}
-	
-	return fmt.Errorf("timeout waiting for container to reach status %s", desiredStatus)
+	return details, nil
+}
+
+// WaitForContainer waits for a container to reach a specific status, see
+// Manager.WaitForContainer
+func (c *DockerClient) WaitForContainer(ctx context.Context, containerID string, desiredStatus string, timeout time.Duration) error {
+	return NewManagerWithEngine(c).WaitForContainer(ctx, containerID, mapDockerStatus(desiredStatus), timeout)
+}
+
+// ContainerEvents streams container events from the Docker event API
+func (c *DockerClient) ContainerEvents(ctx context.Context, containerID string, labels map[string]string) (<-chan Event, <-chan error) {
+	args := filters.NewArgs(filters.Arg("type", string(events.ContainerEventType)))
+	if containerID != "" {
+		args.Add("container", containerID)
+	}
+	for _, k := range sortedKeys(labels) {
+		args.Add("label", labelFilter(k, labels[k]))
+	}
+	messages, streamErrs := c.client.Events(ctx, events.ListOptions{Filters: args})
+
+	out := make(chan Event)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(out)
+		for {
+			select {
+			case msg := <-messages:
+				e := engineEvent(msg.Actor.ID, string(msg.Action), msg.Actor.Attributes, time.Unix(0, msg.TimeNano))
+				select {
+				case out <- e:
+				case <-ctx.Done():
+					return
+				}
+			case err := <-streamErrs:
+				if ctx.Err() == nil {
+					errs <- fmt.Errorf("failed to stream events: %w", err)
+				}
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
 }
 
 // ValidateImage checks if a Docker image exists locally or can be pulled
@@ -405,78 +570,266 @@ func (c *DockerClient) ValidateImage(ctx context.Context, imageName string) erro
 	if err == nil {
 		return nil // Image exists locally
 	}
-	
+
 	// If not found locally, try to pull it
 	reader, err := c.client.ImagePull(ctx, imageName, image.PullOptions{})
 	if err != nil {
 		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
 	}
 	defer reader.Close()
-	
+
 	// Consume the output to ensure pull completes
 	_, err = io.Copy(io.Discard, reader)
 	if err != nil {
 		return fmt.Errorf("failed to pull image %s: %w", imageName, err)
 	}
-	
+
 	return nil
 }
 
 // CreateVolume creates a Docker volume
 func (c *DockerClient) CreateVolume(ctx context.Context, name string) error {
+	return c.CreateLabeledVolume(ctx, name, nil)
+}
+
+// CreateLabeledVolume creates a Docker volume with labels
+func (c *DockerClient) CreateLabeledVolume(ctx context.Context, name string, labels map[string]string) error {
 	_, err := c.client.VolumeCreate(ctx, volume.CreateOptions{
-		Name: name,
+		Name:   name,
+		Labels: labels,
 	})
 	if err != nil {
 		return fmt.Errorf("failed to create volume %s: %w", name, err)
 	}
-	
+
 	return nil
 }
 
+// VolumeExists reports whether a Docker volume exists
+func (c *DockerClient) VolumeExists(ctx context.Context, name string) (bool, error) {
+	_, err := c.client.VolumeInspect(ctx, name)
+	if cerrdefs.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to inspect volume %s: %w", name, err)
+	}
+
+	return true, nil
+}
+
 // RemoveVolume removes a Docker volume
 func (c *DockerClient) RemoveVolume(ctx context.Context, name string) error {
 	err := c.client.VolumeRemove(ctx, name, true)
 	if err != nil {
 		return fmt.Errorf("failed to remove volume %s: %w", name, err)
 	}
-	
+
+	return nil
+}
+
+// CreateNetwork creates a Docker network and returns its ID
+func (c *DockerClient) CreateNetwork(ctx context.Context, name string) (string, error) {
+	resp, err := c.client.NetworkCreate(ctx, name, network.CreateOptions{})
+	if err != nil {
+		return "", fmt.Errorf("failed to create network %s: %w", name, err)
+	}
+
+	return resp.ID, nil
+}
+
+// RemoveNetwork removes a Docker network
+func (c *DockerClient) RemoveNetwork(ctx context.Context, name string) error {
+	if err := c.client.NetworkRemove(ctx, name); err != nil {
+		return fmt.Errorf("failed to remove network %s: %w", name, err)
+	}
+
 	return nil
 }
 
+// ContainerStats streams readings from the Docker stats API. A single
+// reading waits for a second sample, so its CPU percentage is meaningful.
+func (c *DockerClient) ContainerStats(ctx context.Context, containerID string, stream bool) (<-chan api.Stats, <-chan error) {
+	out := make(chan api.Stats)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		resp, err := c.client.ContainerStats(ctx, containerID, stream)
+		if err != nil {
+			if ctx.Err() == nil {
+				errs <- fmt.Errorf("failed to get container stats: %w", err)
+			}
+			return
+		}
+		defer resp.Body.Close()
+
+		decoder := json.NewDecoder(resp.Body)
+		for {
+			var reading container.StatsResponse
+			if err := decoder.Decode(&reading); err != nil {
+				if err != io.EOF && ctx.Err() == nil {
+					errs <- fmt.Errorf("failed to read container stats: %w", err)
+				}
+				return
+			}
+			select {
+			case out <- statsFromResponse(&reading):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
 // GetContainerLogs gets logs from a container
 func (c *DockerClient) GetContainerLogs(ctx context.Context, containerID string, tail int) (string, error) {
+	// A single buffer keeps the streams interleaved
+	var logs strings.Builder
+	if err := c.ContainerLogs(ctx, containerID, LogOptions{Tail: tail}, &logs, &logs); err != nil {
+		return "", err
+	}
+	return logs.String(), nil
+}
+
+// ContainerLogs streams the logs of a container, demultiplexing them unless
+// the container has a TTY
+func (c *DockerClient) ContainerLogs(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error {
+	resp, err := c.client.ContainerInspect(ctx, containerID)
+	if err != nil {
+		return fmt.Errorf("failed to inspect container: %w", err)
+	}
+
 	options := container.LogsOptions{
 		ShowStdout: true,
 		ShowStderr: true,
-		Tail:       fmt.Sprintf("%d", tail),
+		Follow:     opts.Follow,
+		Since:      logTime(opts.Since),
+		Until:      logTime(opts.Until),
+		Timestamps: opts.Timestamps,
+		Tail:       "all",
 	}
-	
-	if tail <= 0 {
-		options.Tail = "all"
+	if opts.Tail > 0 {
+		options.Tail = strconv.Itoa(opts.Tail)
 	}
-	
+
 	reader, err := c.client.ContainerLogs(ctx, containerID, options)
 	if err != nil {
-		return "", fmt.Errorf("failed to get container logs: %w", err)
+		return fmt.Errorf("failed to get container logs: %w", err)
 	}
 	defer reader.Close()
-	
-	logs, err := io.ReadAll(reader)
+
+	if err := copyLogs(reader, resp.Config != nil && resp.Config.Tty, stdout, stderr); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("failed to read container logs: %w", err)
+	}
+	return nil
+}
+
+// CopyToContainer extracts a tar archive below dstDir in a container. The
+// owners recorded in the archive are kept.
+func (c *DockerClient) CopyToContainer(ctx context.Context, containerID, dstDir string, archive io.Reader) error {
+	err := c.client.CopyToContainer(ctx, containerID, dstDir, archive, container.CopyToContainerOptions{
+		CopyUIDGID: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+
+	return nil
+}
+
+// CopyFromContainer returns a tar archive of srcPath in a container
+func (c *DockerClient) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, error) {
+	reader, _, err := c.client.CopyFromContainer(ctx, containerID, srcPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+
+	return reader, nil
+}
+
+// GetImageMetadata returns the devcontainer.metadata entries of an image
+func (c *DockerClient) GetImageMetadata(ctx context.Context, imageName string) ([]*DevContainer, error) {
+	inspect, _, err := c.client.ImageInspectWithRaw(ctx, imageName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect image %s: %w", imageName, err)
+	}
+
+	if inspect.Config == nil {
+		return nil, nil
+	}
+	label, ok := inspect.Config.Labels[LabelMetadata]
+	if !ok {
+		return nil, nil
+	}
+
+	return ParseImageMetadata(label)
+}
+
+// ImageBuildConfig represents an image build configuration
+type ImageBuildConfig struct {
+	ContextFS  fs.FS             // File system holding the context; nil for the local disk
+	ContextDir string            // Build context directory, a slash-separated path within ContextFS if set
+	Dockerfile string            // Dockerfile path, absolute or relative to ContextDir
+	Tag        string            // Tag for the built image
+	Target     string            // Target build stage
+	Args       map[string]string // Build arguments
+	CacheFrom  []string          // Images to use as cache sources
+	Labels     map[string]string // Labels to set on the image
+}
+
+// BuildImage builds an image from a Dockerfile
+func (c *DockerClient) BuildImage(ctx context.Context, config *ImageBuildConfig) error {
+	var buildContext io.ReadCloser
+	var dockerfile string
+	var err error
+	if config.ContextFS != nil {
+		buildContext, dockerfile, err = tarBuildContextFS(config.ContextFS, config.ContextDir, config.Dockerfile)
+	} else {
+		buildContext, dockerfile, err = tarBuildContext(config.ContextDir, config.Dockerfile)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to read container logs: %w", err)
-	}
-	
-	// Strip Docker log headers (8 bytes per line)
-	lines := strings.Split(string(logs), "\n")
-	var cleanedLines []string
-	for _, line := range lines {
-		if len(line) > 8 {
-			cleanedLines = append(cleanedLines, line[8:])
-		} else if line != "" {
-			cleanedLines = append(cleanedLines, line)
+		return err
+	}
+	defer buildContext.Close()
+
+	buildArgs := make(map[string]*string, len(config.Args))
+	for k, v := range config.Args {
+		value := v
+		buildArgs[k] = &value
+	}
+
+	resp, err := c.client.ImageBuild(ctx, buildContext, build.ImageBuildOptions{
+		Tags:        []string{config.Tag},
+		Dockerfile:  dockerfile,
+		Target:      config.Target,
+		BuildArgs:   buildArgs,
+		CacheFrom:   config.CacheFrom,
+		Labels:      config.Labels,
+		Remove:      true,
+		ForceRemove: true,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build image %s: %w", config.Tag, err)
+	}
+	defer resp.Body.Close()
+
+	// The build output is a JSON stream; errors are reported in-band
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := decoder.Decode(&msg); err == io.EOF {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read build output: %w", err)
+		}
+		if msg.Error != "" {
+			return fmt.Errorf("failed to build image %s: %s", config.Tag, msg.Error)
 		}
 	}
-	
-	return strings.Join(cleanedLines, "\n"), nil
-}
\ No newline at end of file
+
+	return nil
+}
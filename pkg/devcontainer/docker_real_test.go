@@ -53,17 +53,17 @@ func TestRealDockerExecution(t *testing.T) {
 					Image: "ubuntu:22.04",
 				},
 				DevContainerCommon: DevContainerCommon{
-					Mounts: []interface{}{
-						map[string]interface{}{
-							"type":   "volume",
-							"source": "test-cache",
-							"target": "/cache",
-						},
-						map[string]interface{}{
-							"type":   "bind",
-							"source": tmpDir,
-							"target": "/data",
-						},
+					Mounts: []MountEntry{
+						MountObject(Mount{
+							Type:   "volume",
+							Source: "test-cache",
+							Target: "/cache",
+						}),
+						MountObject(Mount{
+							Type:   "bind",
+							Source: tmpDir,
+							Target: "/data",
+						}),
 					},
 				},
 			},
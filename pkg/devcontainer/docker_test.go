@@ -66,10 +66,10 @@ func TestBuildDockerRunCommand(t *testing.T) {
 						"FLASK_ENV": "development",
 						"PORT":      "5000",
 					},
-					ForwardPorts: []interface{}{8080.0, "3000:3001"},
+					ForwardPorts: []Port{PortNumber(8080), PortString("3000:3001")},
 				},
 				NonComposeBase: &NonComposeBase{
-					AppPort: []interface{}{5000.0},
+					AppPort: PortList{PortNumber(5000)},
 				},
 			},
 			workspaceRoot: "/workspace",
@@ -124,17 +124,17 @@ func TestBuildDockerRunCommand(t *testing.T) {
 					Image: "golang:1.21",
 				},
 				DevContainerCommon: DevContainerCommon{
-					Mounts: []interface{}{
-						map[string]interface{}{
-							"type":   "bind",
-							"source": "/host/cache",
-							"target": "/go/pkg/mod",
-						},
-						map[string]interface{}{
-							"type":   "volume",
-							"source": "go-build-cache",
-							"target": "/root/.cache/go-build",
-						},
+					Mounts: []MountEntry{
+						MountObject(Mount{
+							Type:   "bind",
+							Source: "/host/cache",
+							Target: "/go/pkg/mod",
+						}),
+						MountObject(Mount{
+							Type:   "volume",
+							Source: "go-build-cache",
+							Target: "/root/.cache/go-build",
+						}),
 					},
 				},
 				NonComposeBase: &NonComposeBase{},
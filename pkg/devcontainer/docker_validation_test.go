@@ -106,18 +106,18 @@ func TestBuildDockerRunCommandValidation(t *testing.T) {
 					ContainerEnv: map[string]string{
 						"PYTHONPATH": "/app",
 					},
-					ForwardPorts: []interface{}{float64(8000), "5432:5432"},
-					Mounts: []interface{}{
-						map[string]interface{}{
-							"type":   "volume",
-							"source": "pip-cache",
-							"target": "/root/.cache/pip",
-						},
-						map[string]interface{}{
-							"type":   "bind",
-							"source": "/host/data",
-							"target": "/container/data",
-						},
+					ForwardPorts: []Port{PortNumber(8000), PortString("5432:5432")},
+					Mounts: []MountEntry{
+						MountObject(Mount{
+							Type:   "volume",
+							Source: "pip-cache",
+							Target: "/root/.cache/pip",
+						}),
+						MountObject(Mount{
+							Type:   "bind",
+							Source: "/host/data",
+							Target: "/container/data",
+						}),
 					},
 				},
 			},
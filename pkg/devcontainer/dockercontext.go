@@ -0,0 +1,214 @@
+package devcontainer
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/client"
+)
+
+// DockerEndpoint is the daemon a DockerClient connects to and why it was
+// chosen, for diagnostics
+type DockerEndpoint struct {
+	Host    string `json:"host"`
+	Context string `json:"context,omitempty"` // Docker context the host comes from, if any
+	Reason  string `json:"reason"`
+
+	tlsConfig *tls.Config // TLS material from the context store
+}
+
+// dockerContextMeta is the meta.json of a context in the context store
+type dockerContextMeta struct {
+	Name      string `json:"Name"`
+	Endpoints map[string]struct {
+		Host          string `json:"Host"`
+		SkipTLSVerify bool   `json:"SkipTLSVerify"`
+	} `json:"Endpoints"`
+}
+
+// ResolveDockerEndpoint resolves the Docker context selected like the docker
+// CLI does: DOCKER_HOST takes precedence over any context, then DOCKER_CONTEXT,
+// then currentContext in config.json. It returns nil if no context other
+// than the default one is selected, in which case the usual sockets apply.
+func ResolveDockerEndpoint() (*DockerEndpoint, error) {
+	if os.Getenv("DOCKER_HOST") != "" {
+		return nil, nil
+	}
+	configDir, err := dockerConfigDir()
+	if err != nil {
+		return nil, err
+	}
+
+	name, reason := os.Getenv("DOCKER_CONTEXT"), "DOCKER_CONTEXT environment variable"
+	if name == "" {
+		var config struct {
+			CurrentContext string `json:"currentContext"`
+		}
+		data, err := os.ReadFile(filepath.Join(configDir, "config.json"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read docker config: %w", err)
+		}
+		if len(data) > 0 {
+			if err := json.Unmarshal(data, &config); err != nil {
+				return nil, fmt.Errorf("failed to parse docker config: %w", err)
+			}
+		}
+		name, reason = config.CurrentContext, "current context in "+filepath.Join(configDir, "config.json")
+	}
+	if name == "" || name == "default" {
+		return nil, nil
+	}
+
+	endpoint, err := loadDockerContext(configDir, name)
+	if err != nil {
+		return nil, err
+	}
+	endpoint.Reason = reason
+	return endpoint, nil
+}
+
+// dockerConfigDir returns the docker CLI configuration directory, honoring
+// DOCKER_CONFIG
+func dockerConfigDir() (string, error) {
+	if dir := os.Getenv("DOCKER_CONFIG"); dir != "" {
+		return dir, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find docker config: %w", err)
+	}
+	return filepath.Join(home, ".docker"), nil
+}
+
+// loadDockerContext reads the docker endpoint of a context and its TLS
+// material from the context store, where both are kept in directories named
+// after the SHA-256 digest of the context name
+func loadDockerContext(configDir, name string) (*DockerEndpoint, error) {
+	sum := sha256.Sum256([]byte(name))
+	digest := hex.EncodeToString(sum[:])
+
+	data, err := os.ReadFile(filepath.Join(configDir, "contexts", "meta", digest, "meta.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("docker context %q not found", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read docker context %q: %w", name, err)
+	}
+	var meta dockerContextMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return nil, fmt.Errorf("failed to parse docker context %q: %w", name, err)
+	}
+	docker, ok := meta.Endpoints["docker"]
+	if !ok || docker.Host == "" {
+		return nil, fmt.Errorf("docker context %q has no docker endpoint", name)
+	}
+	if strings.HasPrefix(docker.Host, "ssh://") {
+		return nil, fmt.Errorf("docker context %q uses an ssh host, which is not supported", name)
+	}
+
+	endpoint := &DockerEndpoint{Host: docker.Host, Context: name}
+	tlsConfig, err := loadContextTLS(filepath.Join(configDir, "contexts", "tls", digest, "docker"), docker.SkipTLSVerify)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS material of docker context %q: %w", name, err)
+	}
+	endpoint.tlsConfig = tlsConfig
+	return endpoint, nil
+}
+
+// loadContextTLS loads ca.pem, cert.pem and key.pem from dir. It returns nil
+// if the context has none of them and does not skip verification.
+func loadContextTLS(dir string, skipVerify bool) (*tls.Config, error) {
+	read := func(name string) ([]byte, error) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return data, err
+	}
+	ca, err := read("ca.pem")
+	if err != nil {
+		return nil, err
+	}
+	cert, err := read("cert.pem")
+	if err != nil {
+		return nil, err
+	}
+	key, err := read("key.pem")
+	if err != nil {
+		return nil, err
+	}
+	if ca == nil && cert == nil && key == nil && !skipVerify {
+		return nil, nil
+	}
+
+	config := &tls.Config{MinVersion: tls.VersionTLS12, InsecureSkipVerify: skipVerify}
+	if ca != nil {
+		config.RootCAs = x509.NewCertPool()
+		if !config.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("no certificates in ca.pem")
+		}
+	}
+	if cert != nil || key != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{pair}
+	}
+	return config, nil
+}
+
+// newClient connects to the endpoint
+func (e *DockerEndpoint) newClient() (*client.Client, error) {
+	var opts []client.Opt
+	if e.tlsConfig != nil {
+		// Before the host, which configures the transport's dialer
+		opts = append(opts, client.WithHTTPClient(&http.Client{
+			Transport: &http.Transport{TLSClientConfig: e.tlsConfig},
+		}))
+	}
+	opts = append(opts, client.WithHost(e.Host), client.WithAPIVersionNegotiation())
+	return client.NewClientWithOpts(opts...)
+}
+
+// Endpoint returns the daemon the client is connected to and why
+func (c *DockerClient) Endpoint() DockerEndpoint {
+	return c.endpoint
+}
+
+// probedEndpoint describes a connection found by probing the usual sockets
+func probedEndpoint(cli *client.Client) DockerEndpoint {
+	host := cli.DaemonHost()
+	if env := os.Getenv("DOCKER_HOST"); env != "" && env == host {
+		return DockerEndpoint{Host: host, Reason: "DOCKER_HOST environment variable"}
+	}
+	return DockerEndpoint{Host: host, Reason: "first reachable default socket"}
+}
+
+// connectEndpoint connects to the endpoint of a Docker context
+func connectEndpoint(endpoint *DockerEndpoint) (*DockerClient, error) {
+	cli, err := endpoint.newClient()
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Docker context %q: %w", endpoint.Context, err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := cli.Ping(ctx); err != nil {
+		cli.Close()
+		return nil, fmt.Errorf("failed to connect to Docker daemon at %s (%s): %w", endpoint.Host, endpoint.Reason, err)
+	}
+	dc := &DockerClient{client: cli, endpoint: *endpoint}
+	dc.detectEngine(ctx)
+	return dc, nil
+}
@@ -0,0 +1,130 @@
+package devcontainer
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDockerContext adds a context to the context store in configDir
+func writeDockerContext(t *testing.T, configDir, name, host string, tlsFiles map[string][]byte) {
+	t.Helper()
+	sum := sha256.Sum256([]byte(name))
+	digest := hex.EncodeToString(sum[:])
+	writeFile(t, filepath.Join(configDir, "contexts", "meta", digest, "meta.json"),
+		`{"Name":"`+name+`","Metadata":{},"Endpoints":{"docker":{"Host":"`+host+`","SkipTLSVerify":false}}}`)
+	for file, data := range tlsFiles {
+		writeFile(t, filepath.Join(configDir, "contexts", "tls", digest, "docker", file), string(data))
+	}
+}
+
+// selfSignedPEM returns a self-signed certificate and its key
+func selfSignedPEM(t *testing.T) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "docker"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestResolveDockerEndpoint(t *testing.T) {
+	configDir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", configDir)
+	t.Setenv("DOCKER_HOST", "")
+	t.Setenv("DOCKER_CONTEXT", "")
+
+	// No config: the usual sockets apply
+	if endpoint, err := ResolveDockerEndpoint(); err != nil || endpoint != nil {
+		t.Fatalf("expected no endpoint, got %+v: %v", endpoint, err)
+	}
+
+	cert, key := selfSignedPEM(t)
+	writeDockerContext(t, configDir, "remote", "tcp://10.0.0.2:2376", map[string][]byte{"ca.pem": cert, "cert.pem": cert, "key.pem": key})
+	writeDockerContext(t, configDir, "plain", "unix:///tmp/plain.sock", nil)
+	writeConfig(t, filepath.Join(configDir, "config.json"), `{"currentContext": "remote"}`)
+
+	endpoint, err := ResolveDockerEndpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Host != "tcp://10.0.0.2:2376" || endpoint.Context != "remote" || !strings.Contains(endpoint.Reason, "config.json") {
+		t.Errorf("unexpected endpoint %+v", endpoint)
+	}
+	if endpoint.tlsConfig == nil || endpoint.tlsConfig.RootCAs == nil || len(endpoint.tlsConfig.Certificates) != 1 {
+		t.Errorf("expected TLS material from the context store, got %+v", endpoint.tlsConfig)
+	}
+	if _, err := endpoint.newClient(); err != nil {
+		t.Errorf("expected a TLS client: %v", err)
+	}
+
+	// DOCKER_CONTEXT overrides the current context
+	t.Setenv("DOCKER_CONTEXT", "plain")
+	endpoint, err = ResolveDockerEndpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if endpoint.Host != "unix:///tmp/plain.sock" || endpoint.Reason != "DOCKER_CONTEXT environment variable" || endpoint.tlsConfig != nil {
+		t.Errorf("unexpected endpoint %+v", endpoint)
+	}
+
+	t.Setenv("DOCKER_CONTEXT", "missing")
+	if _, err := ResolveDockerEndpoint(); err == nil || !strings.Contains(err.Error(), `"missing" not found`) {
+		t.Errorf("expected missing context error, got %v", err)
+	}
+
+	t.Setenv("DOCKER_CONTEXT", "default")
+	if endpoint, err := ResolveDockerEndpoint(); err != nil || endpoint != nil {
+		t.Errorf("expected default context to use the usual sockets, got %+v: %v", endpoint, err)
+	}
+
+	// DOCKER_HOST takes precedence over contexts
+	t.Setenv("DOCKER_CONTEXT", "plain")
+	t.Setenv("DOCKER_HOST", "tcp://127.0.0.1:2375")
+	if endpoint, err := ResolveDockerEndpoint(); err != nil || endpoint != nil {
+		t.Errorf("expected DOCKER_HOST to win, got %+v: %v", endpoint, err)
+	}
+}
+
+func TestLoadContextTLSErrors(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "ca.pem"), []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadContextTLS(dir, false); err == nil {
+		t.Error("expected error for invalid ca.pem")
+	}
+
+	config, err := loadContextTLS(t.TempDir(), true)
+	if err != nil || config == nil || !config.InsecureSkipVerify {
+		t.Errorf("expected config skipping verification, got %+v: %v", config, err)
+	}
+}
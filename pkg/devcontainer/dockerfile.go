@@ -0,0 +1,315 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// dockerfileStage is a FROM instruction of a Dockerfile
+type dockerfileStage struct {
+	Image string
+	Alias string
+}
+
+var reDockerfileVar = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::-([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// DockerfileBaseImage returns the base image of the target stage of a
+// Dockerfile, following references to earlier stages. The last stage is used
+// when target is empty. Build args override ARG defaults declared before the
+// first FROM. Returns an empty string for scratch or unresolvable images.
+func DockerfileBaseImage(content, target string, buildArgs map[string]string) string {
+	args := map[string]string{}
+	var stages []dockerfileStage
+
+	for _, line := range dockerfileInstructions(content) {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			// Only global ARGs (before the first FROM) apply to FROM lines
+			if len(stages) > 0 {
+				continue
+			}
+			kv := strings.SplitN(fields[1], "=", 2)
+			if len(kv) == 2 {
+				args[kv[0]] = strings.Trim(kv[1], `"'`)
+			} else if _, ok := args[kv[0]]; !ok {
+				args[kv[0]] = ""
+			}
+		case "FROM":
+			var stage dockerfileStage
+			rest := fields[1:]
+			for len(rest) > 0 && strings.HasPrefix(rest[0], "--") {
+				rest = rest[1:]
+			}
+			if len(rest) == 0 {
+				continue
+			}
+			stage.Image = rest[0]
+			if len(rest) >= 3 && strings.EqualFold(rest[1], "AS") {
+				stage.Alias = rest[2]
+			}
+			stages = append(stages, stage)
+		}
+	}
+	for k, v := range buildArgs {
+		args[k] = v
+	}
+
+	if len(stages) == 0 {
+		return ""
+	}
+	index := len(stages) - 1
+	if target != "" {
+		index = -1
+		for i, stage := range stages {
+			if strings.EqualFold(stage.Alias, target) {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			return ""
+		}
+	}
+
+	for index >= 0 {
+		image := expandDockerfileVars(stages[index].Image, args)
+		// Follow references to earlier stages
+		next := -1
+		for i := 0; i < index; i++ {
+			if stages[i].Alias != "" && strings.EqualFold(stages[i].Alias, image) {
+				next = i
+			}
+		}
+		if next < 0 {
+			if strings.EqualFold(image, "scratch") || strings.Contains(image, "$") {
+				return ""
+			}
+			return image
+		}
+		index = next
+	}
+	return ""
+}
+
+// dockerfileInstructions splits Dockerfile content into instructions,
+// joining continuation lines and dropping comments
+func dockerfileInstructions(content string) []string {
+	var result []string
+	var current strings.Builder
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(strings.TrimSuffix(line, "\r"))
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			current.WriteString(strings.TrimSuffix(line, "\\"))
+			current.WriteString(" ")
+			continue
+		}
+		current.WriteString(line)
+		if s := strings.TrimSpace(current.String()); s != "" {
+			result = append(result, s)
+		}
+		current.Reset()
+	}
+	if s := strings.TrimSpace(current.String()); s != "" {
+		result = append(result, s)
+	}
+	return result
+}
+
+// expandDockerfileVars substitutes $VAR, ${VAR} and ${VAR:-default}
+func expandDockerfileVars(s string, args map[string]string) string {
+	return reDockerfileVar.ReplaceAllStringFunc(s, func(m string) string {
+		sub := reDockerfileVar.FindStringSubmatch(m)
+		name := sub[1]
+		if name == "" {
+			name = sub[3]
+		}
+		if val, ok := args[name]; ok && val != "" {
+			return val
+		}
+		if sub[2] != "" {
+			return sub[2]
+		}
+		if _, ok := args[name]; ok {
+			return ""
+		}
+		return m
+	})
+}
+
+// dockerfileInContext is the name used for a Dockerfile that lives outside
+// of its build context
+const dockerfileInContext = ".devcontainer-go.Dockerfile"
+
+// tarBuildContext creates a tar stream of contextDir for an image build.
+// It returns the Dockerfile path to use inside the archive. The caller must
+// close the stream.
+func tarBuildContext(contextDir, dockerfile string) (io.ReadCloser, string, error) {
+	if !filepath.IsAbs(dockerfile) {
+		dockerfile = filepath.Join(contextDir, dockerfile)
+	}
+	fsys := localFS{FS: os.DirFS(contextDir), dir: contextDir}
+
+	name, err := filepath.Rel(contextDir, dockerfile)
+	if err != nil || strings.HasPrefix(name, "..") {
+		// Dockerfile is outside of the context, add it to the archive
+		content, err := os.ReadFile(dockerfile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read Dockerfile: %w", err)
+		}
+		return archiveBuildContext(fsys, ".", "", content)
+	}
+	name = filepath.ToSlash(name)
+	reader, _, err := archiveBuildContext(fsys, ".", name, nil)
+	return reader, name, err
+}
+
+// tarBuildContextFS is tarBuildContext for a context inside a file system.
+// contextDir is a slash-separated path within fsys and dockerfile is
+// relative to it; a Dockerfile outside of the context is read from fsys too.
+func tarBuildContextFS(fsys fs.FS, contextDir, dockerfile string) (io.ReadCloser, string, error) {
+	contextDir = path.Clean(contextDir)
+	dockerfile = path.Join(contextDir, dockerfile)
+
+	inside := strings.HasPrefix(dockerfile, contextDir+"/")
+	if contextDir == "." {
+		inside = dockerfile != ".." && !strings.HasPrefix(dockerfile, "../")
+	}
+	if !inside {
+		content, err := fs.ReadFile(fsys, dockerfile)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to read Dockerfile: %w", err)
+		}
+		return archiveBuildContext(fsys, contextDir, "", content)
+	}
+	if contextDir != "." {
+		dockerfile = strings.TrimPrefix(dockerfile, contextDir+"/")
+	}
+	reader, _, err := archiveBuildContext(fsys, contextDir, dockerfile, nil)
+	return reader, dockerfile, err
+}
+
+// readLinkFS is implemented by file systems that can report symlink targets
+type readLinkFS interface {
+	ReadLink(name string) (string, error)
+}
+
+// localFS is os.DirFS with support for reading symlinks
+type localFS struct {
+	fs.FS
+	dir string
+}
+
+// ReadLink implements readLinkFS
+func (f localFS) ReadLink(name string) (string, error) {
+	return os.Readlink(filepath.Join(f.dir, filepath.FromSlash(name)))
+}
+
+// archiveBuildContext streams the tree at root of fsys as a tar archive,
+// leaving out the paths excluded by its .dockerignore. keep is the
+// Dockerfile in the context, sent even if excluded like docker does. A
+// non-nil dockerfile is added as dockerfileInContext and that name is
+// returned. Closing the stream stops the archiving.
+func archiveBuildContext(fsys fs.FS, root, keep string, dockerfile []byte) (io.ReadCloser, string, error) {
+	ignore, err := loadDockerignore(fsys, root)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read %s: %w", dockerignoreFileName, err)
+	}
+	for _, name := range []string{keep, dockerignoreFileName} {
+		if name != "" {
+			ignore.addDockerignore([]byte("!" + name))
+		}
+	}
+
+	name := ""
+	if dockerfile != nil {
+		name = dockerfileInContext
+	}
+	pr, pw := io.Pipe()
+	go func() {
+		tw := tar.NewWriter(pw)
+		err := writeBuildContext(tw, fsys, root, ignore)
+		if err == nil && dockerfile != nil {
+			hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(dockerfile))}
+			if err = tw.WriteHeader(hdr); err == nil {
+				_, err = tw.Write(dockerfile)
+			}
+		}
+		if err == nil {
+			err = tw.Close()
+		}
+		if err != nil {
+			err = fmt.Errorf("failed to archive build context: %w", err)
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr, name, nil
+}
+
+// writeBuildContext writes the paths below root of fsys that ignore does not
+// exclude
+func writeBuildContext(tw *tar.Writer, fsys fs.FS, root string, ignore *ignoreMatcher) error {
+	return fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name == root {
+			return nil
+		}
+		rel := name
+		if root != "." {
+			rel = strings.TrimPrefix(name, root+"/")
+		}
+		if ignore.excluded(rel) {
+			if d.IsDir() && !ignore.reincludes(rel) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		link := ""
+		if info.Mode()&fs.ModeSymlink != 0 {
+			lfs, ok := fsys.(readLinkFS)
+			if !ok {
+				return fmt.Errorf("cannot read symlink %s", name)
+			}
+			if link, err = lfs.ReadLink(name); err != nil {
+				return err
+			}
+		}
+		hdr, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		hdr.Name = rel
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := fsys.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
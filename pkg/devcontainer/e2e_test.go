@@ -41,12 +41,12 @@ func TestE2EMountsActuallyWork(t *testing.T) {
 			Image: "alpine:latest",
 		},
 		DevContainerCommon: DevContainerCommon{
-			Mounts: []interface{}{
-				map[string]interface{}{
-					"type":   "bind",
-					"source": tmpDir,
-					"target": "/test-mount",
-				},
+			Mounts: []MountEntry{
+				MountObject(Mount{
+					Type:   "bind",
+					Source: tmpDir,
+					Target: "/test-mount",
+				}),
 			},
 		},
 	}
@@ -116,12 +116,12 @@ func TestE2EVolumePersistence(t *testing.T) {
 			Image: "alpine:latest",
 		},
 		DevContainerCommon: DevContainerCommon{
-			Mounts: []interface{}{
-				map[string]interface{}{
-					"type":   "volume",
-					"source": volumeName,
-					"target": "/data",
-				},
+			Mounts: []MountEntry{
+				MountObject(Mount{
+					Type:   "volume",
+					Source: volumeName,
+					Target: "/data",
+				}),
 			},
 		},
 	}
@@ -188,9 +188,9 @@ func TestE2ELifecycleCommandsActualExecution(t *testing.T) {
 	// Generate the lifecycle script
 	script, err := GetLifecycleScript(&DevContainer{
 		DevContainerCommon: DevContainerCommon{
-			OnCreateCommand:      "echo 'Step 1: onCreate' >> /tmp/execution-log.txt",
-			UpdateContentCommand: "echo 'Step 2: updateContent' >> /tmp/execution-log.txt", 
-			PostCreateCommand:    "echo 'Step 3: postCreate' >> /tmp/execution-log.txt",
+			OnCreateCommand:      StringCommand("echo 'Step 1: onCreate' >> /tmp/execution-log.txt"),
+			UpdateContentCommand: StringCommand("echo 'Step 2: updateContent' >> /tmp/execution-log.txt"), 
+			PostCreateCommand:    StringCommand("echo 'Step 3: postCreate' >> /tmp/execution-log.txt"),
 		},
 	}, "create")
 	
@@ -210,12 +210,12 @@ func TestE2ELifecycleCommandsActualExecution(t *testing.T) {
 			Image: "alpine:latest",
 		},
 		DevContainerCommon: DevContainerCommon{
-			Mounts: []interface{}{
-				map[string]interface{}{
-					"type":   "bind",
-					"source": tmpDir,
-					"target": "/scripts",
-				},
+			Mounts: []MountEntry{
+				MountObject(Mount{
+					Type:   "bind",
+					Source: tmpDir,
+					Target: "/scripts",
+				}),
 			},
 		},
 	}
@@ -344,10 +344,10 @@ func TestE2EPortForwarding(t *testing.T) {
 			Image: "nginx:alpine",
 		},
 		DevContainerCommon: DevContainerCommon{
-			ForwardPorts: []interface{}{float64(18080)},
+			ForwardPorts: []Port{PortNumber(18080)},
 		},
 		NonComposeBase: &NonComposeBase{
-			AppPort: []interface{}{float64(80)},
+			AppPort: PortList{PortNumber(80)},
 		},
 	}
 
@@ -493,12 +493,8 @@ func TestE2EMergeLogicRealFiles(t *testing.T) {
 	}
 
 	// Check ports (should be overridden)
-	if ports, ok := dc.ForwardPorts.([]interface{}); ok {
-		if len(ports) != 2 {
-			t.Errorf("Expected 2 forward ports, got %d", len(ports))
-		}
-	} else {
-		t.Errorf("ForwardPorts is not a slice, got %T", dc.ForwardPorts)
+	if len(dc.ForwardPorts) != 2 {
+		t.Errorf("Expected 2 forward ports, got %d", len(dc.ForwardPorts))
 	}
 
 	// Check mounts (should be overridden)
@@ -507,8 +503,8 @@ func TestE2EMergeLogicRealFiles(t *testing.T) {
 	}
 
 	// Check variable expansion in mount
-	if dc.Mounts[0].Source == nil || !strings.Contains(*dc.Mounts[0].Source, projectDir) {
-		t.Errorf("Variable expansion failed in mount source: %v", dc.Mounts[0].Source)
+	if !strings.Contains(dc.Mounts[0].MountArg(), projectDir) {
+		t.Errorf("Variable expansion failed in mount source: %v", dc.Mounts[0].MountArg())
 	}
 
 	// Verify the final Docker command includes all merged settings
@@ -0,0 +1,494 @@
+package devcontainer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Document is the source of a devcontainer.json that can be edited in
+// place. Edits only touch the text of the values they change, so comments,
+// property order and formatting elsewhere in the file are preserved.
+//
+// Values are addressed with JSON pointers (RFC 6901), e.g. "/forwardPorts"
+// or JSONPointer("features", "ghcr.io/devcontainers/features/go:1").
+type Document struct {
+	data []byte
+	root *jsonNode
+}
+
+// ParseDocument parses devcontainer.json content for editing. Comments and
+// trailing commas are allowed.
+func ParseDocument(data []byte) (*Document, error) {
+	root, err := parseJSONC(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse devcontainer.json: %w", err)
+	}
+	return &Document{data: append([]byte(nil), data...), root: root}, nil
+}
+
+// LoadDocument reads a devcontainer.json file for editing
+func LoadDocument(path string) (*Document, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devcontainer.json: %w", err)
+	}
+	return ParseDocument(data)
+}
+
+// JSONPointer builds a JSON pointer from unescaped property names and
+// array indexes
+func JSONPointer(tokens ...string) string {
+	var pointer string
+	for _, token := range tokens {
+		pointer = joinPointer(pointer, token)
+	}
+	return pointer
+}
+
+// Bytes returns the current source of the document
+func (d *Document) Bytes() []byte {
+	return append([]byte(nil), d.data...)
+}
+
+// DevContainer parses the current source of the document
+func (d *Document) DevContainer() (*DevContainer, error) {
+	return ParseDevContainer(d.data)
+}
+
+// Save writes the document to path, keeping the mode of an existing file
+func (d *Document) Save(path string) error {
+	mode := os.FileMode(0644)
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+	if err := os.WriteFile(path, d.data, mode); err != nil {
+		return fmt.Errorf("failed to write devcontainer.json: %w", err)
+	}
+	return nil
+}
+
+// Get returns the value at pointer as encoding/json would decode it
+func (d *Document) Get(pointer string) (interface{}, bool) {
+	node, ok := d.root.resolve(pointer)
+	if !ok {
+		return nil, false
+	}
+	return node.interfaceValue(), true
+}
+
+// Set sets the value at pointer. Missing objects along the way are created
+// and "-" as an array index appends to the array.
+func (d *Document) Set(pointer string, value interface{}) error {
+	tokens := splitPointer(pointer)
+	path := []*jsonNode{d.root}
+	for _, token := range tokens {
+		next := path[len(path)-1].child(token)
+		if next == nil {
+			break
+		}
+		path = append(path, next)
+	}
+	if len(path) == len(tokens)+1 {
+		if err := d.replace(path, value); err != nil {
+			return fmt.Errorf("failed to set %s: %w", pointer, err)
+		}
+		return nil
+	}
+
+	// Wrap the value in the objects and arrays that do not exist yet
+	missing := tokens[len(path)-1:]
+	for i := len(missing) - 1; i > 0; i-- {
+		if missing[i] == "-" {
+			value = []interface{}{value}
+		} else {
+			value = map[string]interface{}{missing[i]: value}
+		}
+	}
+	parent := path[len(path)-1]
+	var err error
+	switch {
+	case parent.Kind == jsonObject:
+		err = d.insert(parent, &missing[0], value)
+	case parent.Kind == jsonArray && (missing[0] == "-" || missing[0] == strconv.Itoa(len(parent.Children))):
+		err = d.insert(parent, nil, value)
+	case parent.Kind == jsonArray:
+		err = fmt.Errorf("array index %s out of range", missing[0])
+	default:
+		err = fmt.Errorf("%s is not an object or array", JSONPointer(tokens[:len(path)-1]...))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set %s: %w", pointer, err)
+	}
+	return nil
+}
+
+// Append appends a value to the array at pointer, creating the array if
+// it does not exist
+func (d *Document) Append(pointer string, value interface{}) error {
+	node, ok := d.root.resolve(pointer)
+	if !ok {
+		return d.Set(pointer, []interface{}{value})
+	}
+	if node.Kind != jsonArray {
+		return fmt.Errorf("failed to append to %s: not an array", pointer)
+	}
+	if err := d.insert(node, nil, value); err != nil {
+		return fmt.Errorf("failed to append to %s: %w", pointer, err)
+	}
+	return nil
+}
+
+// Delete removes the value at pointer together with its separator and any
+// comment on the same line. Deleting a missing value is not an error.
+func (d *Document) Delete(pointer string) error {
+	tokens := splitPointer(pointer)
+	if len(tokens) == 0 {
+		return fmt.Errorf("failed to delete %s: cannot delete the document", pointer)
+	}
+	parent, ok := d.root.resolve(JSONPointer(tokens[:len(tokens)-1]...))
+	if !ok {
+		return nil
+	}
+	node := parent.child(tokens[len(tokens)-1])
+	if node == nil {
+		return nil
+	}
+	index := 0
+	for i, c := range parent.Children {
+		if c == node {
+			index = i
+		}
+	}
+
+	start := nodeStart(node)
+	end := node.End
+	last := index == len(parent.Children)-1
+	if d.multiline(parent) {
+		// Remove the whole line when the value is the only thing on it
+		ls := lineStart(d.data, start)
+		if isBlank(d.data[ls:start]) {
+			start = ls
+		}
+		end = d.skipInline(end)
+		if end < len(d.data) && d.data[end] == ',' {
+			end = d.skipInline(end + 1)
+			last = false
+		}
+		end = d.skipLineComment(end)
+		if start == ls && end < len(d.data) && d.data[end] == '\n' {
+			end++
+		}
+	} else if !last {
+		end = nodeStart(parent.Children[index+1])
+	}
+
+	edits := []textEdit{{start, end, ""}}
+	if last && index > 0 {
+		// Drop the separator after the previous value
+		prev := parent.Children[index-1].End
+		if comma := d.skipInline(prev); comma < len(d.data) && d.data[comma] == ',' {
+			if d.multiline(parent) {
+				edits = append(edits, textEdit{comma, comma + 1, ""})
+			} else {
+				edits[0].start = prev
+			}
+		}
+	}
+	if err := d.apply(edits); err != nil {
+		return fmt.Errorf("failed to delete %s: %w", pointer, err)
+	}
+	return nil
+}
+
+// replace replaces the last node of path with value
+func (d *Document) replace(path []*jsonNode, value interface{}) error {
+	node := path[len(path)-1]
+	multiline := true
+	if len(path) > 1 {
+		multiline = d.multiline(path[len(path)-2])
+	}
+	text, err := d.format(value, lineIndent(d.data, nodeStart(node)), multiline)
+	if err != nil {
+		return err
+	}
+	return d.apply([]textEdit{{node.Start, node.End, text}})
+}
+
+// insert adds a member (key != nil) or element to the end of a container
+func (d *Document) insert(container *jsonNode, key *string, value interface{}) error {
+	multiline := len(container.Children) == 0 || d.multiline(container)
+	indent := lineIndent(d.data, container.Start) + d.indentUnit()
+	if len(container.Children) > 0 {
+		indent = lineIndent(d.data, nodeStart(container.Children[0]))
+	}
+	text, err := d.format(value, indent, multiline)
+	if err != nil {
+		return err
+	}
+	if key != nil {
+		name, err := json.Marshal(*key)
+		if err != nil {
+			return err
+		}
+		text = string(name) + ": " + text
+	}
+
+	if len(container.Children) == 0 {
+		interior := d.data[container.Start+1 : container.End-1]
+		if isBlank(interior) {
+			closing := "\n" + lineIndent(d.data, container.Start)
+			return d.apply([]textEdit{{container.Start + 1, container.End - 1, "\n" + indent + text + closing}})
+		}
+		// Keep comments inside the empty container after the new value
+		return d.apply([]textEdit{{container.Start + 1, container.Start + 1, "\n" + indent + text}})
+	}
+
+	last := container.Children[len(container.Children)-1]
+	comma := d.skipInline(last.End)
+	trailingComma := comma < len(d.data) && d.data[comma] == ','
+	if !multiline {
+		if trailingComma {
+			return d.apply([]textEdit{{comma + 1, comma + 1, " " + text}})
+		}
+		return d.apply([]textEdit{{last.End, last.End, ", " + text}})
+	}
+
+	at := last.End
+	if trailingComma {
+		at = comma + 1
+		text += ","
+	}
+	at = d.skipLineComment(d.skipInline(at))
+	edits := []textEdit{{at, at, "\n" + indent + text}}
+	if !trailingComma {
+		edits = append(edits, textEdit{last.End, last.End, ","})
+	}
+	return d.apply(edits)
+}
+
+// format encodes value for insertion at a line with the given indentation
+func (d *Document) format(value interface{}, indent string, multiline bool) (string, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if multiline {
+		enc.SetIndent(indent, d.indentUnit())
+	}
+	if err := enc.Encode(value); err != nil {
+		return "", fmt.Errorf("failed to encode value: %w", err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// indentUnit returns the indentation the document uses per level
+func (d *Document) indentUnit() string {
+	if d.root.Kind == jsonObject && len(d.root.Children) > 0 && d.multiline(d.root) {
+		if unit := lineIndent(d.data, nodeStart(d.root.Children[0])); unit != "" {
+			return strings.TrimPrefix(unit, lineIndent(d.data, d.root.Start))
+		}
+	}
+	return "  "
+}
+
+// multiline reports whether a container puts its values on separate lines
+func (d *Document) multiline(container *jsonNode) bool {
+	if len(container.Children) == 0 {
+		return bytes.IndexByte(d.data[container.Start:container.End], '\n') >= 0
+	}
+	return bytes.IndexByte(d.data[container.Start:nodeStart(container.Children[0])], '\n') >= 0
+}
+
+// skipInline returns the offset of the next byte at or after pos that is
+// not a space or tab
+func (d *Document) skipInline(pos int) int {
+	for pos < len(d.data) && (d.data[pos] == ' ' || d.data[pos] == '\t') {
+		pos++
+	}
+	return pos
+}
+
+// skipLineComment returns the end of a comment starting at pos on the
+// current line, or pos if there is none
+func (d *Document) skipLineComment(pos int) int {
+	rest := d.data[pos:]
+	switch {
+	case bytes.HasPrefix(rest, []byte("//")):
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			return pos + i
+		}
+		return len(d.data)
+	case bytes.HasPrefix(rest, []byte("/*")):
+		if i := bytes.Index(rest, []byte("*/")); i >= 0 && bytes.IndexByte(rest[:i], '\n') < 0 {
+			return d.skipInline(pos + i + 2)
+		}
+	}
+	return pos
+}
+
+// textEdit replaces the bytes in [start, end) with text
+type textEdit struct {
+	start, end int
+	text       string
+}
+
+// apply applies non-overlapping edits and re-parses the document
+func (d *Document) apply(edits []textEdit) error {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].start > edits[j].start })
+	data := d.data
+	for _, e := range edits {
+		data = append(data[:e.start:e.start], append([]byte(e.text), data[e.end:]...)...)
+	}
+	root, err := parseJSONC(data)
+	if err != nil {
+		return fmt.Errorf("edit produced invalid JSON: %w", err)
+	}
+	d.data, d.root = data, root
+	return nil
+}
+
+// nodeStart returns the offset of a member's name, or of the value itself
+func nodeStart(n *jsonNode) int {
+	if n.KeyStart >= 0 {
+		return n.KeyStart
+	}
+	return n.Start
+}
+
+func lineStart(data []byte, offset int) int {
+	return bytes.LastIndexByte(data[:offset], '\n') + 1
+}
+
+// lineIndent returns the leading whitespace of the line containing offset
+func lineIndent(data []byte, offset int) string {
+	start := lineStart(data, offset)
+	end := start
+	for end < len(data) && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+func isBlank(data []byte) bool {
+	return len(bytes.TrimSpace(data)) == 0
+}
+
+// SaveDevContainer writes a configuration to path. If the file exists, only
+// the values whose decoded form differs from its current content are
+// rewritten so that comments and formatting are kept; otherwise a new
+// indented file is created.
+func SaveDevContainer(path string, dc *DevContainer) error {
+	data, err := json.Marshal(dc)
+	if err != nil {
+		return fmt.Errorf("failed to encode devcontainer.json: %w", err)
+	}
+
+	existing, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		var buf bytes.Buffer
+		if err := json.Indent(&buf, data, "", "  "); err != nil {
+			return fmt.Errorf("failed to encode devcontainer.json: %w", err)
+		}
+		buf.WriteByte('\n')
+		if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+			return fmt.Errorf("failed to write devcontainer.json: %w", err)
+		}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read devcontainer.json: %w", err)
+	}
+
+	doc, err := ParseDocument(existing)
+	if err != nil {
+		return err
+	}
+	want, err := parseJSONC(data)
+	if err != nil {
+		return fmt.Errorf("failed to encode devcontainer.json: %w", err)
+	}
+	if err := doc.update("", want, data, decodedDocument(existing)); err != nil {
+		return err
+	}
+	return doc.Save(path)
+}
+
+// decodedDocument returns a configuration as encoded after decoding it, so
+// that values written in another accepted form, such as a string cacheFrom,
+// compare equal to their encoding. It is nil if the configuration does not
+// decode.
+func decodedDocument(data []byte) *jsonNode {
+	dc, err := ParseDevContainer(data)
+	if err != nil {
+		return nil
+	}
+	encoded, err := json.Marshal(dc)
+	if err != nil {
+		return nil
+	}
+	root, err := parseJSONC(encoded)
+	if err != nil {
+		return nil
+	}
+	return root
+}
+
+// update edits the value at pointer until it equals want, recursing into
+// objects so that unchanged members keep their formatting. Values whose
+// decoded form in decoded already equals want are left as written, as are
+// members the model drops when decoding.
+func (d *Document) update(pointer string, want *jsonNode, source []byte, decoded *jsonNode) error {
+	var current *jsonNode
+	if decoded != nil {
+		if n, ok := decoded.resolve(pointer); ok {
+			current = n
+			if reflect.DeepEqual(n.interfaceValue(), want.interfaceValue()) {
+				return nil
+			}
+		}
+	}
+	have, ok := d.root.resolve(pointer)
+	if !ok {
+		return d.Set(pointer, json.RawMessage(source[want.Start:want.End]))
+	}
+	if have.Kind == jsonObject && want.Kind == jsonObject {
+		var stale []string
+		for _, c := range have.Children {
+			dropped := decoded != nil && (current == nil || current.member(c.Key) == nil)
+			if want.member(c.Key) == nil && !dropped {
+				stale = append(stale, c.Key)
+			}
+		}
+		for _, key := range stale {
+			if err := d.Delete(joinPointer(pointer, key)); err != nil {
+				return err
+			}
+		}
+		for _, c := range want.Children {
+			if err := d.update(joinPointer(pointer, c.Key), c, source, decoded); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	haveValue, wantValue := have.interfaceValue(), want.interfaceValue()
+	if reflect.DeepEqual(haveValue, wantValue) {
+		return nil
+	}
+	if have.Kind == jsonArray && want.Kind == jsonArray && len(have.Children) < len(want.Children) &&
+		reflect.DeepEqual(haveValue, wantValue.([]interface{})[:len(have.Children)]) {
+		for _, c := range want.Children[len(have.Children):] {
+			if err := d.Append(pointer, json.RawMessage(source[c.Start:c.End])); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return d.Set(pointer, json.RawMessage(source[want.Start:want.End]))
+}
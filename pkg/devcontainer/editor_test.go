@@ -0,0 +1,217 @@
+package devcontainer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const editorSource = `{
+  // Development image
+  "image": "mcr.microsoft.com/devcontainers/go:1.22",
+  "forwardPorts": [
+    3000 // web
+  ],
+  "features": {
+    "ghcr.io/devcontainers/features/node:1": {} // for the frontend
+  },
+  /* Lifecycle */
+  "postCreateCommand": "make setup",
+  "runArgs": ["--init"]
+}
+`
+
+func TestDocumentEdits(t *testing.T) {
+	doc, err := ParseDocument([]byte(editorSource))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := doc.Set("/image", "mcr.microsoft.com/devcontainers/go:1.23"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Append("/forwardPorts", 5432); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Set(JSONPointer("features", "ghcr.io/devcontainers/features/docker-in-docker:2"), map[string]interface{}{"moby": false}); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Append("/runArgs", "--privileged"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Delete("/postCreateCommand"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Append("/mounts", "source=cache,target=/cache,type=volume"); err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Set("/customizations/vscode/extensions/-", "golang.go"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `{
+  // Development image
+  "image": "mcr.microsoft.com/devcontainers/go:1.23",
+  "forwardPorts": [
+    3000, // web
+    5432
+  ],
+  "features": {
+    "ghcr.io/devcontainers/features/node:1": {}, // for the frontend
+    "ghcr.io/devcontainers/features/docker-in-docker:2": {
+      "moby": false
+    }
+  },
+  /* Lifecycle */
+  "runArgs": ["--init", "--privileged"],
+  "mounts": [
+    "source=cache,target=/cache,type=volume"
+  ],
+  "customizations": {
+    "vscode": {
+      "extensions": [
+        "golang.go"
+      ]
+    }
+  }
+}
+`
+	if got := string(doc.Bytes()); got != want {
+		t.Errorf("unexpected document\nwant:\n%s\ngot:\n%s", want, got)
+	}
+
+	dc, err := doc.DevContainer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dc.ForwardPorts) != 2 || dc.PostCreateCommand != nil || len(dc.Mounts) != 1 {
+		t.Errorf("unexpected config %+v", dc)
+	}
+}
+
+func TestDocumentDelete(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		pointer string
+		want    string
+	}{
+		{"last member", "{\n  \"a\": 1,\n  \"b\": 2 // two\n}", "/b", "{\n  \"a\": 1\n}"},
+		{"first member", "{\n  \"a\": 1, // one\n  // about b\n  \"b\": 2\n}", "/a", "{\n  // about b\n  \"b\": 2\n}"},
+		{"inline array element", `{"a": [1, 2, 3]}`, "/a/1", `{"a": [1, 3]}`},
+		{"inline last element", `{"a": [1, 2, 3]}`, "/a/2", `{"a": [1, 2]}`},
+		{"missing", `{"a": 1}`, "/b", `{"a": 1}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := ParseDocument([]byte(tt.source))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := doc.Delete(tt.pointer); err != nil {
+				t.Fatal(err)
+			}
+			if got := string(doc.Bytes()); got != tt.want {
+				t.Errorf("want:\n%s\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestDocumentErrors(t *testing.T) {
+	doc, err := ParseDocument([]byte(`{"image": "alpine", "runArgs": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := doc.Append("/image", "x"); err == nil {
+		t.Error("expected error appending to a string")
+	}
+	if err := doc.Set("/runArgs/3", "x"); err == nil {
+		t.Error("expected error for out of range index")
+	}
+	if err := doc.Set("/image/tag", "x"); err == nil {
+		t.Error("expected error setting a property of a string")
+	}
+}
+
+func TestSaveDevContainer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	if err := os.WriteFile(path, []byte(editorSource), 0600); err != nil {
+		t.Fatal(err)
+	}
+	dc, err := LoadDevContainer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc.ForwardPorts = append(dc.ForwardPorts, PortNumber(8080))
+	dc.PostCreateCommand = nil
+	dc.RemoteUser = strPtr("vscode")
+
+	if err := SaveDevContainer(path, dc); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  // Development image
+  "image": "mcr.microsoft.com/devcontainers/go:1.22",
+  "forwardPorts": [
+    3000, // web
+    8080
+  ],
+  "features": {
+    "ghcr.io/devcontainers/features/node:1": {} // for the frontend
+  },
+  /* Lifecycle */
+  "runArgs": ["--init"],
+  "remoteUser": "vscode"
+}
+`
+	if string(got) != want {
+		t.Errorf("unexpected file\nwant:\n%s\ngot:\n%s", want, got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected file mode to be kept, got %v", info.Mode())
+	}
+
+	// Values in another accepted form are kept while they do not change
+	alternate := `{
+  "build": {"dockerfile": "Dockerfile", "cacheFrom": "img:1"},
+  "hostRequirements": {"cpus": "2", "gpu": "true"},
+  "remoteUser": "root"
+}
+`
+	if err := os.WriteFile(path, []byte(alternate), 0600); err != nil {
+		t.Fatal(err)
+	}
+	edited, err := LoadDevContainer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edited.RemoteUser = strPtr("vscode")
+	if err := SaveDevContainer(path, edited); err != nil {
+		t.Fatal(err)
+	}
+	if got, err = os.ReadFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if want := strings.Replace(alternate, `"root"`, `"vscode"`, 1); string(got) != want {
+		t.Errorf("unexpected file\nwant:\n%s\ngot:\n%s", want, got)
+	}
+
+	// Saving to a new file writes the whole configuration
+	newPath := filepath.Join(t.TempDir(), "new.json")
+	if err := SaveDevContainer(newPath, dc); err != nil {
+		t.Fatal(err)
+	}
+	saved, err := LoadDevContainer(newPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.imageName() != dc.imageName() || len(saved.ForwardPorts) != 2 || *saved.RemoteUser != "vscode" {
+		t.Errorf("unexpected saved config %+v", saved)
+	}
+}
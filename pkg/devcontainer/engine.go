@@ -0,0 +1,99 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// ErrNoSuchContainer is wrapped by the error InspectContainer returns for a
+// container that does not exist
+var ErrNoSuchContainer = errors.New("no such container")
+
+// Engine is the container engine Manager drives. DockerClient implements it
+// against the Docker API, CLIEngine through a Docker compatible command line
+// tool and FakeEngine in memory for tests.
+type Engine interface {
+	// Containers. FindContainers matches containers having all labels; an
+	// empty label value matches any value.
+	CreateContainer(ctx context.Context, config *DockerRunConfig) (string, error)
+	StartContainer(ctx context.Context, containerID string) error
+	StopContainer(ctx context.Context, containerID string) error
+	RemoveContainer(ctx context.Context, containerID string) error
+	InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error)
+	FindContainers(ctx context.Context, labels map[string]string) ([]string, error)
+	ExecAs(ctx context.Context, containerID string, opts ExecOptions, command []string) (string, error)
+	GetContainerLogs(ctx context.Context, containerID string, tail int) (string, error)
+	ContainerLogs(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error
+
+	// ContainerEvents streams the events of the containers having labels,
+	// or of one container if containerID is set, until ctx is done. The
+	// event channel is closed when ctx is done or the stream fails; a
+	// failure other than ctx being done is sent on the error channel first.
+	ContainerEvents(ctx context.Context, containerID string, labels map[string]string) (<-chan Event, <-chan error)
+
+	// ContainerStats sends one resource usage reading, or a reading about
+	// every second until ctx is done if stream is set. Both channels are
+	// closed when the readings end, after a failure other than ctx being
+	// done is sent on the error channel.
+	ContainerStats(ctx context.Context, containerID string, stream bool) (<-chan api.Stats, <-chan error)
+
+	// Files, as tar archives. Entries are extracted below dstDir keeping
+	// their owner; the archive of srcPath has its base name as root.
+	CopyToContainer(ctx context.Context, containerID, dstDir string, archive io.Reader) error
+	CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, error)
+
+	// Images
+	ValidateImage(ctx context.Context, imageName string) error
+	GetImageMetadata(ctx context.Context, imageName string) ([]*DevContainer, error)
+	BuildImage(ctx context.Context, config *ImageBuildConfig) error
+
+	// Volumes and networks
+	CreateVolume(ctx context.Context, name string) error
+	CreateLabeledVolume(ctx context.Context, name string, labels map[string]string) error
+	VolumeExists(ctx context.Context, name string) (bool, error)
+	RemoveVolume(ctx context.Context, name string) error
+	CreateNetwork(ctx context.Context, name string) (string, error)
+	RemoveNetwork(ctx context.Context, name string) error
+
+	Close() error
+}
+
+// ContainerDetails is the inspected state of a container
+type ContainerDetails struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Image   string            `json:"image"`
+	Status  string            `json:"status"` // Engine state, e.g. created, running or exited
+	Labels  map[string]string `json:"labels,omitempty"`
+	Created time.Time         `json:"created"`
+
+	User   string            `json:"user,omitempty"`   // User the container runs as
+	Tty    bool              `json:"tty,omitempty"`    // Output is a single raw stream
+	Ports  map[string]string `json:"ports,omitempty"`  // Published ports, e.g. "8080/tcp" to "0.0.0.0:32768"
+	Mounts []Mount           `json:"mounts,omitempty"` // Volume mounts have the volume name as source
+
+	StartedAt  time.Time `json:"startedAt"`
+	FinishedAt time.Time `json:"finishedAt"`
+	ExitCode   int       `json:"exitCode"`
+	OOMKilled  bool      `json:"oomKilled"`
+	Health     string    `json:"health,omitempty"` // starting, healthy or unhealthy; empty without a healthcheck
+}
+
+// portBinding returns the "host:port" a container port is published on
+func portBinding(hostIP, hostPort string) string {
+	if hostIP == "" {
+		hostIP = "0.0.0.0"
+	}
+	return net.JoinHostPort(hostIP, hostPort)
+}
+
+var (
+	_ Engine = (*DockerClient)(nil)
+	_ Engine = (*CLIEngine)(nil)
+	_ Engine = (*FakeEngine)(nil)
+)
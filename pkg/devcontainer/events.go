@@ -0,0 +1,271 @@
+package devcontainer
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// EventType is the kind of an Event
+type EventType string
+
+const (
+	EventContainer EventType = "container" // Reported by the engine; Action is the engine action
+	EventPull      EventType = "pull"      // Image pulled if missing before a create or build
+	EventBuild     EventType = "build"     // Dockerfile build
+	EventCreate    EventType = "create"    // Container creation
+	EventLifecycle EventType = "lifecycle" // Lifecycle command; Phase names it
+	EventAttach    EventType = "attach"    // Interactive terminal; finished when detached
+)
+
+// Actions of the events reported by Manager itself
+const (
+	ActionStarted  = "started"
+	ActionFinished = "finished"
+	ActionFailed   = "failed"
+)
+
+// Event is a state change of a container or a step of a Manager operation
+type Event struct {
+	Type EventType `json:"type"`
+
+	// Action is started, finished or failed for Manager events and the
+	// engine action, e.g. create, start, die, destroy or
+	// "health_status: healthy", for container events
+	Action string `json:"action"`
+
+	ContainerID string `json:"containerId,omitempty"`
+	Image       string `json:"image,omitempty"`
+	Phase       string `json:"phase,omitempty"` // Lifecycle command of lifecycle events
+
+	// Labels are the IDLabels of the workspace for Manager events and the
+	// attributes (labels, name, image, exitCode, ...) of container events
+	Labels map[string]string `json:"labels,omitempty"`
+
+	Err  error     `json:"-"` // Failure of a failed step or of the engine event stream
+	Time time.Time `json:"time"`
+}
+
+// EventFilter selects the events of Manager.Events. Empty fields match any
+// event.
+type EventFilter struct {
+	ContainerID     string
+	WorkspaceFolder string // Local workspace folder of the container or operation
+	Types           []EventType
+}
+
+func (f EventFilter) match(e Event) bool {
+	if f.ContainerID != "" && e.ContainerID != f.ContainerID {
+		return false
+	}
+	if f.WorkspaceFolder != "" && e.Labels[LabelLocalFolder] != absPath(f.WorkspaceFolder) {
+		return false
+	}
+	return f.wants(e.Type)
+}
+
+func (f EventFilter) wants(t EventType) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, want := range f.Types {
+		if want == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Events returns the events matching filter until ctx is done: the container
+// events of the engine for containers created by a Manager, or for any
+// container if filter.ContainerID is set, merged with the
+// steps of the operations of m (pulls, builds, creates, lifecycle commands
+// and attaches). Events are queued, so a slow reader delays but never drops
+// them. An engine stream failure is sent as a container event with Action
+// failed, after which only Manager events follow.
+func (m *Manager) Events(ctx context.Context, filter EventFilter) <-chan Event {
+	out := make(chan Event)
+	own := m.events.subscribe(ctx, filter.match)
+
+	var engine <-chan Event
+	var errs <-chan error
+	if filter.wants(EventContainer) {
+		// A container given by ID needs no label, match filters the rest
+		var labels map[string]string
+		if filter.ContainerID == "" {
+			labels = map[string]string{LabelLocalFolder: ""}
+			if filter.WorkspaceFolder != "" {
+				labels[LabelLocalFolder] = absPath(filter.WorkspaceFolder)
+			}
+		}
+		engine, errs = m.docker.ContainerEvents(ctx, filter.ContainerID, labels)
+	}
+
+	go func() {
+		defer close(out)
+		for {
+			var e Event
+			select {
+			case ev, ok := <-own:
+				if !ok {
+					return // ctx is done
+				}
+				e = ev
+			case ev, ok := <-engine:
+				if !ok {
+					engine = nil
+					continue
+				}
+				e = ev
+			case err := <-errs:
+				errs = nil
+				if ctx.Err() != nil {
+					continue
+				}
+				e = Event{Type: EventContainer, Action: ActionFailed, ContainerID: filter.ContainerID, Err: err, Time: time.Now()}
+			}
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// WaitForContainer waits until a container reaches status and then until
+// probes, e.g. HealthProbe(), succeed, all within timeout. The status is
+// re-checked with the backoff of a Probe and at once whenever an event of
+// the container arrives.
+func (m *Manager) WaitForContainer(ctx context.Context, containerID string, status api.Status, timeout time.Duration, probes ...Probe) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	events := m.Events(ctx, EventFilter{ContainerID: containerID, Types: []EventType{EventContainer}})
+	probe := StatusProbe(status)
+	probe.Timeout = timeout
+	if err := m.waitProbe(ctx, containerID, probe, events); err != nil {
+		return err
+	}
+	return m.WaitReady(ctx, containerID, probes...)
+}
+
+// emit publishes an event of m
+func (m *Manager) emit(e Event) {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	m.events.publish(e)
+}
+
+// step runs fn between started and finished or failed events based on e
+func (m *Manager) step(e Event, fn func() error) error {
+	e.Action = ActionStarted
+	m.emit(e)
+	err := fn()
+	e.Action, e.Err = ActionFinished, err
+	if err != nil {
+		e.Action = ActionFailed
+	}
+	m.emit(e)
+	return err
+}
+
+// eventBus delivers published events to its subscribers. The zero value is
+// ready to use.
+type eventBus struct {
+	mu   sync.Mutex
+	subs map[*eventQueue]bool
+}
+
+// subscribe returns the events published until ctx is done that match; the
+// channel is closed once ctx is done
+func (b *eventBus) subscribe(ctx context.Context, match func(Event) bool) <-chan Event {
+	q := &eventQueue{match: match, notify: make(chan struct{}, 1)}
+	b.mu.Lock()
+	if b.subs == nil {
+		b.subs = map[*eventQueue]bool{}
+	}
+	b.subs[q] = true
+	b.mu.Unlock()
+
+	out := make(chan Event)
+	go func() {
+		defer close(out)
+		defer func() {
+			b.mu.Lock()
+			delete(b.subs, q)
+			b.mu.Unlock()
+		}()
+		q.forward(ctx, out)
+	}()
+	return out
+}
+
+// publish queues e for the subscribers it matches, without blocking
+func (b *eventBus) publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for q := range b.subs {
+		if q.match == nil || q.match(e) {
+			q.push(e)
+		}
+	}
+}
+
+// eventQueue is an unbounded queue of the events of a subscriber, so that
+// publishers never wait for slow readers
+type eventQueue struct {
+	match  func(Event) bool
+	mu     sync.Mutex
+	events []Event
+	notify chan struct{}
+}
+
+func (q *eventQueue) push(e Event) {
+	q.mu.Lock()
+	q.events = append(q.events, e)
+	q.mu.Unlock()
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+// forward sends the queued events to out, in order, until ctx is done
+func (q *eventQueue) forward(ctx context.Context, out chan<- Event) {
+	for {
+		select {
+		case <-q.notify:
+		case <-ctx.Done():
+			return
+		}
+		q.mu.Lock()
+		events := q.events
+		q.events = nil
+		q.mu.Unlock()
+		for _, e := range events {
+			select {
+			case out <- e:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// engineEvent converts the action and attributes of an engine container
+// event; the attributes include the labels of the container
+func engineEvent(id, action string, attributes map[string]string, t time.Time) Event {
+	return Event{
+		Type:        EventContainer,
+		Action:      strings.TrimSpace(action),
+		ContainerID: id,
+		Image:       attributes["image"],
+		Labels:      attributes,
+		Time:        t,
+	}
+}
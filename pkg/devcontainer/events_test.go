@@ -0,0 +1,235 @@
+package devcontainer
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func TestManagerEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	config := `{"image": "alpine:3.20", "postCreateCommand": "make"}`
+	dir, other := writeWorkspace(t, config), writeWorkspace(t, config)
+	mgr, _ := newFakeManager("alpine:3.20")
+	events := mgr.Events(ctx, EventFilter{WorkspaceFolder: dir})
+
+	// Events of another workspace are filtered out
+	if _, err := mgr.Create(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	id, err := mgr.Create(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.RunUserCommands(ctx, id, dir, UserCommandOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// Engine and Manager events are merged in any order, each in order
+	var own, container []string
+	for len(container) < 4 {
+		select {
+		case e := <-events:
+			if e.Labels[LabelLocalFolder] != dir {
+				t.Errorf("unexpected event %+v", e)
+			}
+			if e.Type == EventContainer {
+				container = append(container, e.Action)
+				if e.ContainerID != id {
+					t.Errorf("unexpected container event %+v", e)
+				}
+				continue
+			}
+			own = append(own, strings.TrimSuffix(string(e.Type)+" "+e.Phase, " ")+" "+e.Action)
+		case <-ctx.Done():
+			t.Fatalf("timed out with events %v and %v", own, container)
+		}
+	}
+	for len(own) < 6 {
+		select {
+		case e := <-events:
+			own = append(own, strings.TrimSuffix(string(e.Type)+" "+e.Phase, " ")+" "+e.Action)
+		case <-ctx.Done():
+			t.Fatalf("timed out with events %v", own)
+		}
+	}
+
+	wantOwn := []string{
+		"pull started", "pull finished",
+		"create started", "create finished",
+		"lifecycle postCreateCommand started", "lifecycle postCreateCommand finished",
+	}
+	if !reflect.DeepEqual(own, wantOwn) {
+		t.Errorf("expected %v, got %v", wantOwn, own)
+	}
+	if want := []string{"create", "start", "die", "stop"}; !reflect.DeepEqual(container, want) {
+		t.Errorf("expected %v, got %v", want, container)
+	}
+}
+
+func TestManagerEventsFailedStep(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	dir := writeWorkspace(t, `{"image": "missing:1"}`)
+	mgr, _ := newFakeManager()
+	events := mgr.Events(ctx, EventFilter{Types: []EventType{EventPull}})
+	if _, err := mgr.Create(ctx, dir); err == nil {
+		t.Fatal("expected a missing image to fail")
+	}
+	for _, action := range []string{ActionStarted, ActionFailed} {
+		select {
+		case e := <-events:
+			if e.Type != EventPull || e.Action != action || e.Image != "missing:1" || (action == ActionFailed) != (e.Err != nil) {
+				t.Errorf("unexpected event %+v", e)
+			}
+		case <-ctx.Done():
+			t.Fatal("timed out")
+		}
+	}
+}
+
+func TestManagerWaitForContainer(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine, id := newFakeContainer(t, `{"image": "alpine:3.20"}`, "alpine:3.20")
+
+	err := mgr.WaitForContainer(ctx, id, api.StatusRunning, 50*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "status running not ready after 50ms") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- mgr.WaitForContainer(ctx, id, api.StatusRunning, 10*time.Second)
+	}()
+	time.Sleep(20 * time.Millisecond)
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("expected the container to be running: %v", err)
+	}
+
+	// Probes run once the status is reached
+	err = mgr.WaitForContainer(ctx, id, api.StatusRunning, time.Second, HealthProbe())
+	if err == nil || !strings.Contains(err.Error(), "no healthcheck") {
+		t.Errorf("expected a missing healthcheck to fail, got %v", err)
+	}
+	engine.UpdateContainer(id, func(c *FakeContainer) { c.Health = "healthy" })
+	if err := mgr.WaitForContainer(ctx, id, api.StatusRunning, time.Second, HealthProbe()); err != nil {
+		t.Errorf("expected the container to be healthy: %v", err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := mgr.WaitForContainer(canceled, id, api.StatusStopped, time.Second); err != context.Canceled {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func TestManagerWaitForUnlabeledContainer(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine := newFakeManager("alpine:3.20")
+	id, err := engine.CreateContainer(ctx, &DockerRunConfig{Image: "alpine:3.20"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.StartContainer(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stop is seen through its event, before the re-check after 100ms
+	// and 200ms of backoff
+	go func() {
+		time.Sleep(150 * time.Millisecond)
+		engine.StopContainer(ctx, id)
+	}()
+	start := time.Now()
+	if err := mgr.WaitForContainer(ctx, id, api.StatusStopped, 2*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("expected the stop event to end the wait, took %v", elapsed)
+	}
+}
+
+// inspectRecorder records when containers are inspected and stops the
+// container at the given inspection
+type inspectRecorder struct {
+	*FakeEngine
+	stopAt int
+	times  []time.Time
+}
+
+func (e *inspectRecorder) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	e.times = append(e.times, time.Now())
+	if len(e.times) == e.stopAt {
+		e.FakeEngine.StopContainer(ctx, containerID)
+	}
+	return e.FakeEngine.InspectContainer(ctx, containerID)
+}
+
+func TestWaitForContainerBacksOff(t *testing.T) {
+	ctx := context.Background()
+	engine := &inspectRecorder{FakeEngine: NewFakeEngine(), stopAt: 4}
+	engine.AddImage("alpine:3.20", nil)
+	mgr := NewManagerWithEngine(engine)
+	id := mustCreate(t, mgr, writeWorkspace(t, `{"image": "alpine:3.20"}`))
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without events the status is re-checked after a growing delay
+	if err := mgr.WaitForContainer(ctx, id, api.StatusStopped, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if len(engine.times) != 4 {
+		t.Fatalf("expected the fourth check to succeed, got %d checks", len(engine.times))
+	}
+	var previous time.Duration
+	for i := 1; i < len(engine.times); i++ {
+		interval := engine.times[i].Sub(engine.times[i-1])
+		if interval < (Backoff{}).delay(i-1) || interval <= previous {
+			t.Errorf("expected check %d after at least %s and more than %s, got %s", i, (Backoff{}).delay(i-1), previous, interval)
+		}
+		previous = interval
+	}
+}
+
+func TestCLIEngineContainerEvents(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	engine, calls := newFakeCLI(t)
+
+	events, errs := engine.ContainerEvents(ctx, "0123abcd", map[string]string{LabelLocalFolder: ""})
+	var got []Event
+	for e := range events {
+		got = append(got, e)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %+v", got)
+	}
+	if got[0].ContainerID != "0123abcd" || got[0].Action != "start" || got[0].Labels["name"] != "dev" || got[0].Time.Unix() != 1704067200 {
+		t.Errorf("unexpected Docker event %+v", got[0])
+	}
+	if got[1].ContainerID != "0123abcd" || got[1].Action != "died" || got[1].Image != "alpine:3" || got[1].Time.Unix() != 1704067201 {
+		t.Errorf("unexpected Podman event %+v", got[1])
+	}
+	if err := <-errs; err == nil || !strings.Contains(err.Error(), "event stream ended") {
+		t.Errorf("expected the end of the stream to be reported, got %v", err)
+	}
+	want := "events --format {{json .}} --filter type=container --filter container=0123abcd --filter label=" + LabelLocalFolder
+	if c := calls(); len(c) != 1 || c[0] != want {
+		t.Errorf("expected %q, got %v", want, c)
+	}
+}
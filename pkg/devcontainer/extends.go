@@ -0,0 +1,559 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Limits of remote extends configurations
+const (
+	extendsTimeout = 30 * time.Second // Time allowed for each request with the default client
+	maxExtendsSize = 10 << 20         // Largest response read
+)
+
+// extendsClient is the HTTP client used by resolvers without their own
+var extendsClient = &http.Client{Timeout: extendsTimeout}
+
+// ExtendsResolver resolves the configurations referenced by "extends"
+type ExtendsResolver interface {
+	// Resolve locates the configuration referenced by ref from the
+	// configuration at location from (empty for the root configuration).
+	// It returns a canonical location, used for relative references and
+	// cycle detection, and the configuration content.
+	Resolve(ctx context.Context, from, ref string) (location string, data []byte, err error)
+}
+
+// ExtendsCycleError reports a configuration that (indirectly) extends itself
+type ExtendsCycleError struct {
+	Chain []string // Locations from the first occurrence back to itself
+}
+
+func (e *ExtendsCycleError) Error() string {
+	return fmt.Sprintf("extends cycle detected: %s", strings.Join(e.Chain, " -> "))
+}
+
+// LoadDevContainerWithExtends loads a devcontainer.json with extends support.
+// "extends" may be a single reference or an array of references; bases are
+// merged in order and the extending configuration is applied last, using the
+// same rules as MergeDevContainers. A nil resolver uses SchemeResolver.
+func LoadDevContainerWithExtends(path string, resolver ExtendsResolver) (*DevContainer, error) {
+	return LoadDevContainerWithExtendsContext(context.Background(), path, resolver)
+}
+
+// LoadDevContainerWithExtendsContext is LoadDevContainerWithExtends with a
+// context for fetching remote configurations
+func LoadDevContainerWithExtendsContext(ctx context.Context, path string, resolver ExtendsResolver) (*DevContainer, error) {
+	layers, err := ResolveExtends(ctx, path, resolver)
+	if err != nil {
+		return nil, err
+	}
+	dc, _ := MergeDevContainersWithTrace(layers...)
+	return dc, nil
+}
+
+// LoadDevContainerWithExtendsFS is LoadDevContainerWithExtends for a
+// configuration inside a file system. Local references resolve within fsys;
+// a nil resolver uses a SchemeResolver whose File resolver is an FSResolver
+// for fsys, so http(s):// and oci:// references still work.
+func LoadDevContainerWithExtendsFS(ctx context.Context, fsys fs.FS, name string, resolver ExtendsResolver) (*DevContainer, error) {
+	if resolver == nil {
+		resolver = &SchemeResolver{File: &FSResolver{FS: fsys}}
+	}
+	return LoadDevContainerWithExtendsContext(ctx, name, resolver)
+}
+
+// ResolveExtends loads a configuration and everything it extends, returning
+// the layers in merge order: bases first, the configuration at path last.
+// A base reached several times is merged once, at its first position.
+// Remote configurations cannot extend local ones.
+func ResolveExtends(ctx context.Context, path string, resolver ExtendsResolver) ([]MergeLayer, error) {
+	if resolver == nil {
+		resolver = &SchemeResolver{}
+	}
+	r := &extendsResolution{resolver: resolver, merged: map[string]bool{}}
+	if err := r.resolve(ctx, "", path, nil); err != nil {
+		return nil, err
+	}
+	return r.layers, nil
+}
+
+// extendsResolution collects the layers of a configuration
+type extendsResolution struct {
+	resolver ExtendsResolver
+	layers   []MergeLayer
+	merged   map[string]bool // Locations of the layers
+}
+
+// resolve appends the layers of ref, depth first, to the layers
+func (r *extendsResolution) resolve(ctx context.Context, from, ref string, chain []string) error {
+	location, data, err := r.resolver.Resolve(ctx, from, ref)
+	if err != nil {
+		if from == "" {
+			return fmt.Errorf("failed to read devcontainer.json: %w", err)
+		}
+		return fmt.Errorf("failed to load extends config %q from %s: %w", ref, from, err)
+	}
+	if isRemoteLocation(from) && !isRemoteLocation(location) {
+		return fmt.Errorf("remote config %s cannot extend local config %q", from, ref)
+	}
+
+	for i, seen := range chain {
+		if seen == location {
+			cycle := append(append([]string{}, chain[i:]...), location)
+			return &ExtendsCycleError{Chain: cycle}
+		}
+	}
+	chain = append(chain, location)
+	if r.merged[location] {
+		return nil
+	}
+
+	dc, err := ParseDevContainer(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", location, err)
+	}
+	if filepath.IsAbs(location) {
+		dc.ConfigFilePath = location
+	}
+
+	bases, err := extendsRefs(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", location, err)
+	}
+	for _, base := range bases {
+		if err := r.resolve(ctx, location, base, chain); err != nil {
+			return err
+		}
+	}
+
+	r.merged[location] = true
+	r.layers = append(r.layers, MergeLayer{Source: location, Config: dc})
+	return nil
+}
+
+// extendsRefs returns the references of the "extends" property
+func extendsRefs(data []byte) ([]string, error) {
+	var raw struct {
+		Extends interface{} `json:"extends"`
+	}
+	if err := json.Unmarshal(stripJSONC(data), &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse devcontainer.json: %w", err)
+	}
+
+	switch v := raw.Extends.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return []string{v}, nil
+	case []interface{}:
+		refs := make([]string, 0, len(v))
+		for _, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("extends entries must be strings, got %T", item)
+			}
+			refs = append(refs, s)
+		}
+		return refs, nil
+	default:
+		return nil, fmt.Errorf("extends must be a string or an array of strings, got %T", v)
+	}
+}
+
+// SchemeResolver dispatches references by scheme: oci:// references go to
+// OCI, http(s):// references and references relative to a remote
+// configuration go to HTTP, everything else to File. Nil resolvers use the
+// zero value of the respective default implementation. Remote configurations
+// are refused local references.
+type SchemeResolver struct {
+	File ExtendsResolver
+	HTTP ExtendsResolver
+	OCI  ExtendsResolver
+}
+
+// Resolve implements ExtendsResolver
+func (r *SchemeResolver) Resolve(ctx context.Context, from, ref string) (string, []byte, error) {
+	switch {
+	case strings.HasPrefix(ref, "oci://"):
+		return orResolver(r.OCI, &OCIResolver{}).Resolve(ctx, from, ref)
+	case isHTTPURL(ref), isHTTPURL(from) && !strings.Contains(ref, "://"):
+		return orResolver(r.HTTP, &HTTPResolver{}).Resolve(ctx, from, ref)
+	case isRemoteLocation(from):
+		return "", nil, fmt.Errorf("remote config %s cannot extend local config %q", from, ref)
+	default:
+		return orResolver(r.File, &FileResolver{}).Resolve(ctx, from, ref)
+	}
+}
+
+func orResolver(r, fallback ExtendsResolver) ExtendsResolver {
+	if r != nil {
+		return r
+	}
+	return fallback
+}
+
+func isHTTPURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+// isRemoteLocation reports whether a location was fetched over the network
+func isRemoteLocation(location string) bool {
+	return isHTTPURL(location) || strings.HasPrefix(location, "oci://")
+}
+
+// readResponse reads a response body of at most maxExtendsSize bytes
+func readResponse(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxExtendsSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxExtendsSize {
+		return nil, fmt.Errorf("response exceeds %d bytes", maxExtendsSize)
+	}
+	return data, nil
+}
+
+// FileResolver resolves references on the local filesystem. References may be
+// plain paths or file:// URLs, relative to the directory of the referencing
+// configuration. A reference to a directory resolves to its
+// .devcontainer/devcontainer.json or .devcontainer.json.
+type FileResolver struct{}
+
+// Resolve implements ExtendsResolver
+func (r *FileResolver) Resolve(ctx context.Context, from, ref string) (string, []byte, error) {
+	if strings.HasPrefix(ref, "file://") {
+		u, err := url.Parse(ref)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid file URL %q: %w", ref, err)
+		}
+		// file://relative/path keeps the host as the first path segment
+		ref = filepath.FromSlash(u.Host + u.Path)
+	}
+
+	p := ref
+	if !filepath.IsAbs(p) && from != "" {
+		p = filepath.Join(filepath.Dir(from), p)
+	}
+	p = absPath(p)
+
+	info, err := os.Stat(p)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		found := ""
+		for _, candidate := range []string{
+			filepath.Join(p, ".devcontainer", "devcontainer.json"),
+			filepath.Join(p, ".devcontainer.json"),
+			filepath.Join(p, "devcontainer.json"),
+		} {
+			if _, err := os.Stat(candidate); err == nil {
+				found = candidate
+				break
+			}
+		}
+		if found == "" {
+			return "", nil, fmt.Errorf("no devcontainer.json found in %s", p)
+		}
+		p = found
+	}
+
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return "", nil, err
+	}
+	return p, data, nil
+}
+
+// FSResolver resolves references within an fs.FS. Locations are
+// slash-separated paths relative to the root of the file system.
+type FSResolver struct {
+	FS fs.FS
+}
+
+// Resolve implements ExtendsResolver
+func (r *FSResolver) Resolve(ctx context.Context, from, ref string) (string, []byte, error) {
+	ref = strings.TrimPrefix(ref, "file://")
+	p := ref
+	if !path.IsAbs(p) && from != "" {
+		p = path.Join(path.Dir(from), p)
+	}
+	p = strings.TrimPrefix(path.Clean(p), "/")
+	if !fs.ValidPath(p) {
+		return "", nil, fmt.Errorf("invalid path %q", ref)
+	}
+
+	info, err := fs.Stat(r.FS, p)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.IsDir() {
+		found := ""
+		for _, candidate := range []string{
+			path.Join(p, ".devcontainer", "devcontainer.json"),
+			path.Join(p, ".devcontainer.json"),
+			path.Join(p, "devcontainer.json"),
+		} {
+			if _, err := fs.Stat(r.FS, candidate); err == nil {
+				found = candidate
+				break
+			}
+		}
+		if found == "" {
+			return "", nil, fmt.Errorf("no devcontainer.json found in %s", p)
+		}
+		p = found
+	}
+
+	data, err := fs.ReadFile(r.FS, p)
+	if err != nil {
+		return "", nil, err
+	}
+	return p, data, nil
+}
+
+// HTTPResolver resolves http(s):// references. Relative references from a
+// remote configuration resolve against its URL.
+type HTTPResolver struct {
+	Client *http.Client // Defaults to a client with a 30s timeout
+}
+
+// Resolve implements ExtendsResolver
+func (r *HTTPResolver) Resolve(ctx context.Context, from, ref string) (string, []byte, error) {
+	target, err := url.Parse(ref)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid URL %q: %w", ref, err)
+	}
+	if !target.IsAbs() && isHTTPURL(from) {
+		base, err := url.Parse(from)
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid URL %q: %w", from, err)
+		}
+		target = base.ResolveReference(target)
+	}
+
+	client := r.Client
+	if client == nil {
+		client = extendsClient
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.String(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", nil, fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	data, err := readResponse(resp.Body)
+	if err != nil {
+		return "", nil, err
+	}
+	return target.String(), data, nil
+}
+
+// OCIResolver resolves oci://registry/repository[:tag|@digest] references
+// by pulling the configuration artifact from an OCI registry. The artifact's
+// first JSON layer is used, or the devcontainer.json inside a tar layer.
+type OCIResolver struct {
+	Client    *http.Client // Defaults to a client with a 30s timeout
+	PlainHTTP bool         // Use http:// instead of https:// for the registry
+}
+
+// Resolve implements ExtendsResolver
+func (r *OCIResolver) Resolve(ctx context.Context, from, ref string) (string, []byte, error) {
+	registry, repository, reference, err := parseOCIRef(strings.TrimPrefix(ref, "oci://"))
+	if err != nil {
+		return "", nil, err
+	}
+
+	scheme := "https"
+	if r.PlainHTTP {
+		scheme = "http"
+	}
+	base := fmt.Sprintf("%s://%s/v2/%s", scheme, registry, repository)
+
+	var manifest struct {
+		Layers []struct {
+			MediaType   string            `json:"mediaType"`
+			Digest      string            `json:"digest"`
+			Annotations map[string]string `json:"annotations"`
+		} `json:"layers"`
+	}
+	data, err := r.get(ctx, base+"/manifests/"+reference, "application/vnd.oci.image.manifest.v1+json")
+	if err != nil {
+		return "", nil, err
+	}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return "", nil, fmt.Errorf("failed to parse manifest of %s: %w", ref, err)
+	}
+
+	for _, layer := range manifest.Layers {
+		isTar := strings.HasSuffix(layer.MediaType, "tar")
+		isJSON := strings.Contains(layer.MediaType, "json") ||
+			strings.HasSuffix(layer.Annotations["org.opencontainers.image.title"], ".json")
+		if !isTar && !isJSON {
+			continue
+		}
+		blob, err := r.get(ctx, base+"/blobs/"+layer.Digest, "")
+		if err != nil {
+			return "", nil, err
+		}
+		if isTar {
+			if blob, err = devcontainerFromTar(blob); err != nil {
+				return "", nil, fmt.Errorf("%s: %w", ref, err)
+			}
+		}
+		return "oci://" + registry + "/" + repository + ociSeparator(reference) + reference, blob, nil
+	}
+	return "", nil, fmt.Errorf("no devcontainer configuration layer in %s", ref)
+}
+
+// get fetches a registry URL, following the anonymous bearer token flow
+func (r *OCIResolver) get(ctx context.Context, target, accept string) ([]byte, error) {
+	client := r.Client
+	if client == nil {
+		client = extendsClient
+	}
+
+	do := func(token string) (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return client.Do(req)
+	}
+
+	resp, err := do("")
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := r.token(ctx, client, challenge)
+		if err != nil {
+			return nil, err
+		}
+		if resp, err = do(token); err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return nil, fmt.Errorf("GET %s: %s", target, resp.Status)
+	}
+	return readResponse(resp.Body)
+}
+
+// token requests an anonymous bearer token for a WWW-Authenticate challenge
+func (r *OCIResolver) token(ctx context.Context, client *http.Client, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported registry authentication: %q", challenge)
+	}
+	params := map[string]string{}
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+		if len(kv) == 2 {
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+	}
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid registry token realm in %q", challenge)
+	}
+	query := realm.Query()
+	for _, key := range []string{"service", "scope"} {
+		if params[key] != "" {
+			query.Set(key, params[key])
+		}
+	}
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("GET %s: %s", realm, resp.Status)
+	}
+	var body struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxExtendsSize)).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to parse registry token: %w", err)
+	}
+	return lastString(body.AccessToken, body.Token), nil
+}
+
+// parseOCIRef splits registry/repository[:tag|@digest]
+func parseOCIRef(ref string) (registry, repository, reference string, err error) {
+	slash := strings.Index(ref, "/")
+	if slash <= 0 {
+		return "", "", "", fmt.Errorf("invalid OCI reference %q", ref)
+	}
+	registry, repository = ref[:slash], ref[slash+1:]
+
+	reference = "latest"
+	if at := strings.Index(repository, "@"); at >= 0 {
+		repository, reference = repository[:at], repository[at+1:]
+	} else if colon := strings.LastIndex(repository, ":"); colon >= 0 {
+		repository, reference = repository[:colon], repository[colon+1:]
+	}
+	if repository == "" || reference == "" {
+		return "", "", "", fmt.Errorf("invalid OCI reference %q", ref)
+	}
+	return registry, repository, reference, nil
+}
+
+func ociSeparator(reference string) string {
+	if strings.Contains(reference, ":") {
+		return "@"
+	}
+	return ":"
+}
+
+// devcontainerFromTar extracts devcontainer.json from a tar layer
+func devcontainerFromTar(data []byte) ([]byte, error) {
+	tr := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil, fmt.Errorf("no devcontainer.json in layer")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read layer: %w", err)
+		}
+		switch path.Base(hdr.Name) {
+		case "devcontainer.json", ".devcontainer.json":
+			return io.ReadAll(tr)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestExtendsMultipleBases(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) string {
+		p := filepath.Join(tmpDir, name)
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+
+	write("a.json", `{"image": "ubuntu:22.04", "containerEnv": {"FROM": "a"}, "postCreateCommand": "echo a"}`)
+	write("b.json", `{"containerEnv": {"FROM": "b"}, "postCreateCommand": "echo b", "capAdd": ["SYS_PTRACE"]}`)
+	project := write("devcontainer.json", `{"extends": ["./a.json", "file://b.json"], "postCreateCommand": "echo project"}`)
+
+	layers, err := ResolveExtends(context.Background(), project, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sources []string
+	for _, layer := range layers {
+		sources = append(sources, filepath.Base(layer.Source))
+	}
+	if !reflect.DeepEqual(sources, []string{"a.json", "b.json", "devcontainer.json"}) {
+		t.Errorf("unexpected merge order %v", sources)
+	}
+
+	dc, err := LoadDevContainerWithExtends(project, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.imageName() != "ubuntu:22.04" {
+		t.Error("expected image from first base")
+	}
+	if dc.ContainerEnv["FROM"] != "b" {
+		t.Errorf("expected later base to win, got %s", dc.ContainerEnv["FROM"])
+	}
+	if script := dc.PostCreateCommand.ToShellCommand(); script != "echo a\necho b\necho project" {
+		t.Errorf("expected accumulated commands, got %q", script)
+	}
+	if dc.ConfigFilePath != project {
+		t.Errorf("expected config path of extending config, got %s", dc.ConfigFilePath)
+	}
+}
+
+func TestExtendsCycleDetection(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a/devcontainer.json": {Data: []byte(`{"extends": "../b/devcontainer.json"}`)},
+		"b/devcontainer.json": {Data: []byte(`{"extends": "../c"}`)},
+		"c/devcontainer.json": {Data: []byte(`{"extends": "../a/devcontainer.json"}`)},
+	}
+
+	_, err := LoadDevContainerWithExtends("a/devcontainer.json", &FSResolver{FS: fsys})
+	var cycle *ExtendsCycleError
+	if !errors.As(err, &cycle) {
+		t.Fatalf("expected cycle error, got %v", err)
+	}
+	expected := []string{"a/devcontainer.json", "b/devcontainer.json", "c/devcontainer.json", "a/devcontainer.json"}
+	if !reflect.DeepEqual(cycle.Chain, expected) {
+		t.Errorf("expected chain %v, got %v", expected, cycle.Chain)
+	}
+	if !strings.Contains(err.Error(), "a/devcontainer.json -> b/devcontainer.json") {
+		t.Errorf("expected chain in error message, got %v", err)
+	}
+
+	// The same base reached twice without a cycle is merged once
+	fsys = fstest.MapFS{
+		"base.json":  {Data: []byte(`{"image": "alpine:3", "postCreateCommand": "echo base"}`)},
+		"left.json":  {Data: []byte(`{"extends": "base.json", "postCreateCommand": "echo left"}`)},
+		"right.json": {Data: []byte(`{"extends": "./base.json", "postCreateCommand": "echo right"}`)},
+		"main.json":  {Data: []byte(`{"extends": ["left.json", "right.json"]}`)},
+	}
+	layers, err := ResolveExtends(context.Background(), "main.json", &FSResolver{FS: fsys})
+	if err != nil {
+		t.Fatalf("expected diamond extends to load, got %v", err)
+	}
+	var sources []string
+	for _, layer := range layers {
+		sources = append(sources, layer.Source)
+	}
+	if want := []string{"base.json", "left.json", "right.json", "main.json"}; !reflect.DeepEqual(sources, want) {
+		t.Errorf("expected layers %v, got %v", want, sources)
+	}
+	dc, _ := MergeDevContainersWithTrace(layers...)
+	if script := dc.PostCreateCommand.ToShellCommand(); script != "echo base\necho left\necho right" {
+		t.Errorf("expected the base command once, got %q", script)
+	}
+}
+
+func TestHTTPExtendsResolver(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/configs/base.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"image": "node:18", "extends": "common.json"}`))
+	})
+	mux.HandleFunc("/configs/common.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"remoteUser": "node"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	project := filepath.Join(tmpDir, "devcontainer.json")
+	config := `{"extends": "` + server.URL + `/configs/base.json", "name": "web"}`
+	if err := os.WriteFile(project, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	dc, err := LoadDevContainerWithExtends(project, &SchemeResolver{HTTP: &HTTPResolver{Client: server.Client()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.imageName() != "node:18" || dc.RemoteUser == nil || *dc.RemoteUser != "node" {
+		t.Errorf("expected values from remote bases, got image %s", dc.imageName())
+	}
+}
+
+func TestHTTPExtendsResolverLimits(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "secret.json")
+	if err := os.WriteFile(secret, []byte(`{"image": "local"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/local.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"extends": "file://` + filepath.ToSlash(secret) + `"}`))
+	})
+	mux.HandleFunc("/large.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(bytes.Repeat([]byte(" "), maxExtendsSize+1))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	resolver := &SchemeResolver{
+		File: &FSResolver{FS: fstest.MapFS{}},
+		HTTP: &HTTPResolver{Client: server.Client()},
+	}
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"/local.json", "cannot extend local config"},
+		{"/large.json", "response exceeds"},
+	}
+	for _, tt := range tests {
+		fsys := fstest.MapFS{"devcontainer.json": {Data: []byte(`{"extends": "` + server.URL + tt.ref + `"}`)}}
+		resolver.File = &FSResolver{FS: fsys}
+		if _, err := LoadDevContainerWithExtends("devcontainer.json", resolver); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected %q, got %v", tt.ref, tt.want, err)
+		}
+	}
+
+	// Custom resolvers are held to the same rule
+	remote := resolverFunc(func(ctx context.Context, from, ref string) (string, []byte, error) {
+		if from == "" {
+			return "https://example.com/devcontainer.json", []byte(`{"extends": "base.json"}`), nil
+		}
+		return "base.json", []byte(`{}`), nil
+	})
+	if _, err := ResolveExtends(context.Background(), "devcontainer.json", remote); err == nil || !strings.Contains(err.Error(), "cannot extend local config") {
+		t.Errorf("expected a local base of a remote config to fail, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, _, err := (&HTTPResolver{Client: server.Client()}).Resolve(ctx, "", server.URL+"/local.json")
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+// resolverFunc adapts a function to ExtendsResolver
+type resolverFunc func(ctx context.Context, from, ref string) (string, []byte, error)
+
+func (f resolverFunc) Resolve(ctx context.Context, from, ref string) (string, []byte, error) {
+	return f(ctx, from, ref)
+}
+
+func TestOCIExtendsResolver(t *testing.T) {
+	var layer bytes.Buffer
+	tw := tar.NewWriter(&layer)
+	content := []byte(`{"image": "python:3.12", "containerEnv": {"SOURCE": "oci"}}`)
+	tw.WriteHeader(&tar.Header{Name: "devcontainer.json", Mode: 0644, Size: int64(len(content))})
+	tw.Write(content)
+	tw.Close()
+
+	mux := http.NewServeMux()
+	var serverURL string
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("scope") != "repository:org/base:pull" {
+			http.Error(w, "bad scope", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"token": "anon"}`))
+	})
+	mux.HandleFunc("/v2/org/base/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer anon" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="`+serverURL+`/token",service="test",scope="repository:org/base:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v2/org/base/manifests/1.0":
+			w.Write([]byte(`{"layers": [{"mediaType": "application/vnd.devcontainers.layer.v1+tar", "digest": "sha256:abc"}]}`))
+		case "/v2/org/base/blobs/sha256:abc":
+			w.Write(layer.Bytes())
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	serverURL = server.URL
+
+	fsys := fstest.MapFS{
+		"devcontainer.json": {Data: []byte(`{"extends": "oci://` + strings.TrimPrefix(server.URL, "http://") + `/org/base:1.0"}`)},
+	}
+	resolver := &SchemeResolver{
+		File: &FSResolver{FS: fsys},
+		OCI:  &OCIResolver{Client: server.Client(), PlainHTTP: true},
+	}
+	dc, err := LoadDevContainerWithExtends("devcontainer.json", resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.imageName() != "python:3.12" || dc.ContainerEnv["SOURCE"] != "oci" {
+		t.Errorf("expected config from OCI artifact, got image %s env %v", dc.imageName(), dc.ContainerEnv)
+	}
+}
@@ -0,0 +1,680 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// FakeEngine is an in-memory Engine for unit tests. Containers only move
+// between states, exec commands are answered by ExecFunc and images must be
+// added with AddImage (or built) before they can be used. IDs and creation
+// times are deterministic: containers get IDs fake-1, fake-2, ... and are
+// created one second apart starting at FakeEpoch; a container starts one
+// second after it was created or last stopped and stops one second after it
+// started. It is safe for concurrent use.
+type FakeEngine struct {
+	// ExecFunc answers commands run in containers; by default they succeed
+	// without output. A non-zero exit code is returned as an *ExecError.
+	ExecFunc func(containerID string, opts ExecOptions, command []string) (stdout string, exitCode int)
+
+	mu         sync.Mutex
+	nextID     int
+	containers map[string]*FakeContainer
+	files      map[string]map[string]*FakeFile // By container, then absolute path
+	images     map[string]map[string]string
+	imageFiles map[string]map[string]*FakeFile // Copied into containers of the image
+	volumes    map[string]map[string]string    // Labels by name
+	networks   map[string]string
+	execs      []FakeExec
+	builds     []ImageBuildConfig
+	events     eventBus
+}
+
+// FakeEpoch is the creation time of the first container of a FakeEngine
+var FakeEpoch = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// FakeContainer is a container of a FakeEngine
+type FakeContainer struct {
+	ContainerDetails
+	Config DockerRunConfig // Configuration the container was created with
+	Logs   string          // Output returned by GetContainerLogs
+	Stats  api.Stats       // Reading returned by ContainerStats
+}
+
+// FakeFile is a file, directory or symlink in a FakeEngine container
+type FakeFile struct {
+	Header  tar.Header
+	Content []byte
+}
+
+func newFakeFile(name string, content []byte) *FakeFile {
+	name = path.Clean("/" + name)
+	return &FakeFile{
+		Header:  tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(content)), ModTime: FakeEpoch},
+		Content: append([]byte(nil), content...),
+	}
+}
+
+// FakeExec records a command run in a FakeEngine container
+type FakeExec struct {
+	ContainerID string
+	Options     ExecOptions
+	Command     []string
+}
+
+// NewFakeEngine returns an empty FakeEngine
+func NewFakeEngine() *FakeEngine {
+	return &FakeEngine{
+		containers: map[string]*FakeContainer{},
+		files:      map[string]map[string]*FakeFile{},
+		images:     map[string]map[string]string{},
+		imageFiles: map[string]map[string]*FakeFile{},
+		volumes:    map[string]map[string]string{},
+		networks:   map[string]string{},
+	}
+}
+
+// AddImage makes an image available with the given labels
+func (f *FakeEngine) AddImage(name string, labels map[string]string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.images[name] = mergeMaps(labels, nil)
+}
+
+// AddImageFile adds a regular file owned by root to an image, e.g.
+// /etc/passwd for user lookups; containers created later start with it
+func (f *FakeEngine) AddImageFile(image, name string, content []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.imageFiles[image] == nil {
+		f.imageFiles[image] = map[string]*FakeFile{}
+	}
+	file := newFakeFile(name, content)
+	f.imageFiles[image][file.Header.Name] = file
+}
+
+// Container returns a copy of a container, or nil if it does not exist
+func (f *FakeEngine) Container(containerID string) *FakeContainer {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil
+	}
+	clone := *c
+	return &clone
+}
+
+// SetLogs sets the output GetContainerLogs returns for a container
+func (f *FakeEngine) SetLogs(containerID, logs string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(containerID)
+	if err != nil {
+		return err
+	}
+	c.Logs = logs
+	return nil
+}
+
+// WriteFile writes a regular file owned by root to a container
+func (f *FakeEngine) WriteFile(containerID, name string, content []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.container(containerID); err != nil {
+		return err
+	}
+	file := newFakeFile(name, content)
+	f.files[containerID][file.Header.Name] = file
+	return nil
+}
+
+// RemoveFile removes a path and everything below it from a container
+func (f *FakeEngine) RemoveFile(containerID, name string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name = path.Clean("/" + name)
+	for file := range f.files[containerID] {
+		if file == name || strings.HasPrefix(file, name+"/") {
+			delete(f.files[containerID], file)
+		}
+	}
+}
+
+// File returns a copy of a file of a container, or nil if it does not exist
+func (f *FakeEngine) File(containerID, name string) *FakeFile {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	file, ok := f.files[containerID][path.Clean("/"+name)]
+	if !ok {
+		return nil
+	}
+	clone := *file
+	clone.Content = append([]byte(nil), file.Content...)
+	return &clone
+}
+
+// Execs returns the commands run in containers, in order
+func (f *FakeEngine) Execs() []FakeExec {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeExec(nil), f.execs...)
+}
+
+// Builds returns the image builds, in order
+func (f *FakeEngine) Builds() []ImageBuildConfig {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]ImageBuildConfig(nil), f.builds...)
+}
+
+// Volumes returns the names of the volumes, sorted
+func (f *FakeEngine) Volumes() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedKeys(f.volumes)
+}
+
+// VolumeLabels returns the labels of a volume
+func (f *FakeEngine) VolumeLabels(name string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return mergeMaps(f.volumes[name], nil)
+}
+
+// Networks returns the names of the networks, sorted
+func (f *FakeEngine) Networks() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return sortedKeys(f.networks)
+}
+
+// CreateContainer implements Engine
+func (f *FakeEngine) CreateContainer(ctx context.Context, config *DockerRunConfig) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.images[config.Image]; !ok {
+		return "", fmt.Errorf("failed to create container: no such image: %s", config.Image)
+	}
+	for _, c := range f.containers {
+		if config.Name != "" && c.Name == config.Name {
+			return "", fmt.Errorf("failed to create container: name %q is already in use", config.Name)
+		}
+	}
+
+	f.nextID++
+	id := fmt.Sprintf("fake-%d", f.nextID)
+	f.containers[id] = &FakeContainer{
+		ContainerDetails: ContainerDetails{
+			ID:      id,
+			Name:    config.Name,
+			Image:   config.Image,
+			Status:  "created",
+			Labels:  mergeMaps(config.Labels, nil),
+			User:    config.User,
+			Tty:     true,
+			Created: FakeEpoch.Add(time.Duration(f.nextID-1) * time.Second),
+		},
+		Config: *config,
+	}
+	f.containers[id].Mounts = fakeMounts(config)
+	f.publish(f.containers[id], "create")
+	f.files[id] = map[string]*FakeFile{}
+	for name, file := range f.imageFiles[config.Image] {
+		f.files[id][name] = file
+	}
+	return id, nil
+}
+
+// StartContainer implements Engine
+func (f *FakeEngine) StartContainer(ctx context.Context, containerID string) error {
+	return f.setStatus(containerID, "running", "failed to start container")
+}
+
+// StopContainer implements Engine
+func (f *FakeEngine) StopContainer(ctx context.Context, containerID string) error {
+	return f.setStatus(containerID, "exited", "failed to stop container")
+}
+
+func (f *FakeEngine) setStatus(containerID, status, action string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(containerID)
+	if err != nil {
+		return fmt.Errorf("%s: %w", action, err)
+	}
+	switch {
+	case status == "running" && c.Status != "running":
+		defer f.publish(c, "start")
+		c.StartedAt = c.Created
+		if c.FinishedAt.After(c.StartedAt) {
+			c.StartedAt = c.FinishedAt
+		}
+		c.StartedAt = c.StartedAt.Add(time.Second)
+		c.FinishedAt = time.Time{}
+		c.ExitCode = 0
+	case status == "exited" && c.Status == "running":
+		c.FinishedAt = c.StartedAt.Add(time.Second)
+		defer f.publish(c, "stop")
+		defer f.publish(c, "die")
+	}
+	c.Status = status
+	return nil
+}
+
+// UpdateContainer changes the state of a container, e.g. its Health or
+// ExitCode
+func (f *FakeEngine) UpdateContainer(containerID string, update func(*FakeContainer)) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(containerID)
+	if err != nil {
+		return err
+	}
+	health := c.Health
+	update(c)
+	if c.Health != health && c.Health != "" {
+		f.publish(c, "health_status: "+c.Health)
+	}
+	return nil
+}
+
+// fakeMounts returns the mounts of a container created with config, parsed
+// from their --mount syntax
+func fakeMounts(config *DockerRunConfig) []Mount {
+	var mounts []Mount
+	for _, arg := range append([]string{config.WorkspaceMount}, config.Mounts...) {
+		var mount Mount
+		for _, part := range strings.Split(arg, ",") {
+			key, value, _ := strings.Cut(part, "=")
+			switch key {
+			case "type":
+				mount.Type = value
+			case "source", "src":
+				mount.Source = value
+			case "target", "dst", "destination":
+				mount.Target = value
+			case "readonly", "ro":
+				mount.ReadOnly = value == "" || value == "true" || value == "1"
+			}
+		}
+		if mount.Target != "" {
+			mounts = append(mounts, mount)
+		}
+	}
+	return mounts
+}
+
+// RemoveContainer implements Engine. Like DockerClient it removes running
+// containers too.
+func (f *FakeEngine) RemoveContainer(ctx context.Context, containerID string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.container(containerID); err != nil {
+		return fmt.Errorf("failed to remove container: %w", err)
+	}
+	f.publish(f.containers[containerID], "destroy")
+	delete(f.containers, containerID)
+	delete(f.files, containerID)
+	return nil
+}
+
+// InspectContainer implements Engine
+func (f *FakeEngine) InspectContainer(ctx context.Context, containerID string) (*ContainerDetails, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(containerID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect container: %w", err)
+	}
+	details := c.ContainerDetails
+	details.Labels = mergeMaps(c.Labels, nil)
+	return &details, nil
+}
+
+// FindContainers implements Engine
+func (f *FakeEngine) FindContainers(ctx context.Context, labels map[string]string) ([]string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var found []*FakeContainer
+	for _, c := range f.containers {
+		if hasLabels(c.Labels, labels) {
+			found = append(found, c)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool { return found[i].Created.After(found[j].Created) })
+
+	ids := make([]string, len(found))
+	for i, c := range found {
+		ids[i] = c.ID
+	}
+	return ids, nil
+}
+
+// ExecAs implements Engine
+func (f *FakeEngine) ExecAs(ctx context.Context, containerID string, opts ExecOptions, command []string) (string, error) {
+	f.mu.Lock()
+	c, err := f.container(containerID)
+	if err == nil && c.Status != "running" {
+		err = fmt.Errorf("container %s is not running", containerID)
+	}
+	if err != nil {
+		f.mu.Unlock()
+		return "", fmt.Errorf("failed to create exec: %w", err)
+	}
+	f.execs = append(f.execs, FakeExec{ContainerID: containerID, Options: opts, Command: append([]string(nil), command...)})
+	handler := f.ExecFunc
+	f.mu.Unlock()
+
+	if handler == nil {
+		return "", nil
+	}
+	stdout, code := handler(containerID, opts, command)
+	if opts.Stdout != nil {
+		io.WriteString(opts.Stdout, stdout)
+		stdout = ""
+	}
+	if code != 0 {
+		return "", &ExecError{ExitCode: code}
+	}
+	return stdout, nil
+}
+
+// GetContainerLogs implements Engine
+func (f *FakeEngine) GetContainerLogs(ctx context.Context, containerID string, tail int) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	c, err := f.container(containerID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get container logs: %w", err)
+	}
+	lines := strings.SplitAfter(c.Logs, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if tail > 0 && tail < len(lines) {
+		lines = lines[len(lines)-tail:]
+	}
+	return strings.Join(lines, ""), nil
+}
+
+// ContainerLogs implements Engine. Logs go to stdout, as for a container
+// with a TTY, with timestamps of FakeEpoch; Since and Until are ignored.
+// Following a running container waits for it to stop.
+func (f *FakeEngine) ContainerLogs(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error {
+	logs, err := f.GetContainerLogs(ctx, containerID, opts.Tail)
+	if err != nil {
+		return err
+	}
+	if opts.Timestamps {
+		lines := strings.SplitAfter(logs, "\n")
+		for i, line := range lines {
+			if line != "" {
+				lines[i] = FakeEpoch.Format(time.RFC3339Nano) + " " + line
+			}
+		}
+		logs = strings.Join(lines, "")
+	}
+	if _, err := io.WriteString(stdout, logs); err != nil {
+		return err
+	}
+	if !opts.Follow {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	events, _ := f.ContainerEvents(ctx, containerID, nil)
+	if c := f.Container(containerID); c == nil || c.Status != "running" {
+		return nil
+	}
+	for e := range events {
+		if e.Action == "die" || e.Action == "destroy" {
+			return nil
+		}
+	}
+	return nil
+}
+
+// CopyToContainer implements Engine. Like Docker it works on containers
+// that are not running.
+func (f *FakeEngine) CopyToContainer(ctx context.Context, containerID, dstDir string, archive io.Reader) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.container(containerID); err != nil {
+		return fmt.Errorf("failed to copy to container: %w", err)
+	}
+	tr := tar.NewReader(archive)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy to container: %w", err)
+		}
+		content, err := io.ReadAll(tr)
+		if err != nil {
+			return fmt.Errorf("failed to copy to container: %w", err)
+		}
+		name := path.Join("/", dstDir, hdr.Name)
+		file := &FakeFile{Header: *hdr, Content: content}
+		file.Header.Name = name
+		f.files[containerID][name] = file
+	}
+}
+
+// CopyFromContainer implements Engine
+func (f *FakeEngine) CopyFromContainer(ctx context.Context, containerID, srcPath string) (io.ReadCloser, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, err := f.container(containerID); err != nil {
+		return nil, fmt.Errorf("failed to copy from container: %w", err)
+	}
+	srcPath = path.Clean("/" + srcPath)
+	var names []string
+	for name := range f.files[containerID] {
+		if name == srcPath || strings.HasPrefix(name, srcPath+"/") || srcPath == "/" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("failed to copy from container: no such file: %s", srcPath)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	base := path.Base(srcPath)
+	if names[0] != srcPath {
+		// Directories only implied by their files
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: base + "/", Mode: 0755})
+	}
+	for _, name := range names {
+		file := f.files[containerID][name]
+		hdr := file.Header
+		hdr.Name = path.Join(base, strings.TrimPrefix(name, srcPath))
+		if hdr.Typeflag == tar.TypeDir {
+			hdr.Name += "/"
+		}
+		if err := tw.WriteHeader(&hdr); err != nil {
+			return nil, fmt.Errorf("failed to copy from container: %w", err)
+		}
+		tw.Write(file.Content)
+	}
+	tw.Close()
+	return io.NopCloser(&buf), nil
+}
+
+// ValidateImage implements Engine. Images are never pulled, they must have
+// been added with AddImage or built.
+func (f *FakeEngine) ValidateImage(ctx context.Context, imageName string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.images[imageName]; !ok {
+		return fmt.Errorf("failed to pull image %s: not found", imageName)
+	}
+	return nil
+}
+
+// GetImageMetadata implements Engine
+func (f *FakeEngine) GetImageMetadata(ctx context.Context, imageName string) ([]*DevContainer, error) {
+	f.mu.Lock()
+	labels, ok := f.images[imageName]
+	f.mu.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("failed to inspect image %s: not found", imageName)
+	}
+	return ParseImageMetadata(labels[LabelMetadata])
+}
+
+// BuildImage implements Engine. The image is tagged with the build labels;
+// the Dockerfile is not run.
+func (f *FakeEngine) BuildImage(ctx context.Context, config *ImageBuildConfig) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.builds = append(f.builds, *config)
+	f.images[config.Tag] = mergeMaps(config.Labels, nil)
+	return nil
+}
+
+// CreateVolume implements Engine
+func (f *FakeEngine) CreateVolume(ctx context.Context, name string) error {
+	return f.CreateLabeledVolume(ctx, name, nil)
+}
+
+// CreateLabeledVolume implements Engine
+func (f *FakeEngine) CreateLabeledVolume(ctx context.Context, name string, labels map[string]string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.volumes[name] = mergeMaps(labels, nil)
+	return nil
+}
+
+// VolumeExists implements Engine
+func (f *FakeEngine) VolumeExists(ctx context.Context, name string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.volumes[name]
+	return ok, nil
+}
+
+// RemoveVolume implements Engine
+func (f *FakeEngine) RemoveVolume(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.volumes[name]; !ok {
+		return fmt.Errorf("failed to remove volume %s: no such volume", name)
+	}
+	delete(f.volumes, name)
+	return nil
+}
+
+// CreateNetwork implements Engine
+func (f *FakeEngine) CreateNetwork(ctx context.Context, name string) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.networks[name]; ok {
+		return "", fmt.Errorf("failed to create network %s: already exists", name)
+	}
+	f.networks[name] = "network-" + name
+	return f.networks[name], nil
+}
+
+// RemoveNetwork implements Engine
+func (f *FakeEngine) RemoveNetwork(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.networks[name]; !ok {
+		return fmt.Errorf("failed to remove network %s: no such network", name)
+	}
+	delete(f.networks, name)
+	return nil
+}
+
+// Close implements Engine
+func (f *FakeEngine) Close() error {
+	return nil
+}
+
+// ContainerStats implements Engine. Streams send the current Stats of the
+// container as fast as they are read.
+func (f *FakeEngine) ContainerStats(ctx context.Context, containerID string, stream bool) (<-chan api.Stats, <-chan error) {
+	out := make(chan api.Stats)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(errs)
+		defer close(out)
+		for {
+			c := f.Container(containerID)
+			if c == nil {
+				errs <- fmt.Errorf("failed to get container stats: no such container: %s", containerID)
+				return
+			}
+			select {
+			case out <- c.Stats:
+			case <-ctx.Done():
+				return
+			}
+			if !stream {
+				return
+			}
+		}
+	}()
+	return out, errs
+}
+
+// ContainerEvents implements Engine. Containers report create, start, die,
+// stop and destroy, and health_status when UpdateContainer changes Health.
+func (f *FakeEngine) ContainerEvents(ctx context.Context, containerID string, labels map[string]string) (<-chan Event, <-chan error) {
+	events := f.events.subscribe(ctx, func(e Event) bool {
+		return (containerID == "" || e.ContainerID == containerID) && hasLabels(e.Labels, labels)
+	})
+	return events, make(chan error)
+}
+
+// publish reports an action of a container; f.mu must be held
+func (f *FakeEngine) publish(c *FakeContainer, action string) {
+	attributes := mergeMaps(c.Labels, map[string]string{"name": c.Name, "image": c.Image})
+	if action == "die" {
+		attributes["exitCode"] = strconv.Itoa(c.ExitCode)
+	}
+	t := c.Created
+	for _, at := range []time.Time{c.StartedAt, c.FinishedAt} {
+		if at.After(t) {
+			t = at
+		}
+	}
+	f.events.publish(engineEvent(c.ID, action, attributes, t))
+}
+
+// container returns a container; f.mu must be held
+func (f *FakeEngine) container(containerID string) (*FakeContainer, error) {
+	c, ok := f.containers[containerID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNoSuchContainer, containerID)
+	}
+	return c, nil
+}
+
+// hasLabels reports whether labels contains every label of want; an empty
+// value in want matches any value
+func hasLabels(labels, want map[string]string) bool {
+	for k, v := range want {
+		if got, ok := labels[k]; !ok || (v != "" && got != v) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,186 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func TestManagerWithFakeEngine(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".devcontainer", "devcontainer.json"), `{
+		"image": "golang:1.22",
+		"remoteEnv": {"CI": "true"},
+		"postCreateCommand": "go mod download",
+		"postStartCommand": ["make", "serve"]
+	}`)
+
+	engine := NewFakeEngine()
+	engine.AddImage("golang:1.22", map[string]string{LabelMetadata: `[{"remoteUser": "gopher", "onCreateCommand": "echo image"}]`})
+	mgr := NewManagerWithEngine(engine)
+
+	id, err := mgr.Create(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "fake-1" {
+		t.Errorf("expected deterministic id, got %s", id)
+	}
+	if c := engine.Container(id); c.Labels[LabelLocalFolder] != dir || c.Created != FakeEpoch {
+		t.Errorf("unexpected container %+v", c.ContainerDetails)
+	}
+	if found, err := mgr.FindContainer(ctx, dir); err != nil || found != id {
+		t.Errorf("expected to find %s, got %q: %v", id, found, err)
+	}
+	if status, _ := mgr.GetStatus(ctx, id); status != api.StatusCreated {
+		t.Errorf("expected created container to map to %s, got %s", api.StatusCreated, status)
+	}
+
+	if err := mgr.RunUserCommands(ctx, id, dir, UserCommandOptions{}); err == nil {
+		t.Error("expected exec in a stopped container to fail")
+	}
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.RunUserCommands(ctx, id, dir, UserCommandOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	var commands []string
+	for _, exec := range engine.Execs() {
+		commands = append(commands, strings.Join(exec.Command, " "))
+		if exec.Options.User != "gopher" || exec.Options.Env["CI"] != "true" || exec.Options.WorkingDir != "/workspaces/"+filepath.Base(dir) {
+			t.Errorf("unexpected exec options %+v", exec.Options)
+		}
+	}
+	want := []string{"/bin/sh -c echo image", "/bin/sh -c go mod download", "make serve"}
+	if !reflect.DeepEqual(commands, want) {
+		t.Errorf("expected lifecycle commands %v, got %v", want, commands)
+	}
+
+	engine.ExecFunc = func(containerID string, opts ExecOptions, command []string) (string, int) {
+		if command[0] == "false" {
+			return "", 1
+		}
+		return strings.Join(command, " ") + "\n", 0
+	}
+	if out, err := mgr.Exec(ctx, id, []string{"echo", "hi"}); err != nil || out != "echo hi\n" {
+		t.Errorf("unexpected exec result %q: %v", out, err)
+	}
+	var execErr *ExecError
+	if _, err := mgr.Exec(ctx, id, []string{"false"}); !errors.As(err, &execErr) || execErr.ExitCode != 1 {
+		t.Errorf("expected exit code 1, got %v", err)
+	}
+
+	if err := mgr.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if status, _ := mgr.GetStatus(ctx, id); status != api.StatusStopped {
+		t.Errorf("expected stopped, got %s", status)
+	}
+	if err := mgr.Remove(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.GetStatus(ctx, id); err == nil {
+		t.Error("expected removed container to be gone")
+	}
+}
+
+func TestFakeEngineBuildAndResources(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".devcontainer", "devcontainer.json"), `{"build": {"dockerfile": "Dockerfile"}}`)
+	writeFile(t, filepath.Join(dir, ".devcontainer", "Dockerfile"), "FROM alpine:3.19\n")
+
+	engine := NewFakeEngine()
+	mgr := NewManagerWithEngine(engine)
+	if _, err := mgr.Build(ctx, dir); err == nil {
+		t.Error("expected missing base image to fail")
+	}
+	engine.AddImage("alpine:3.19", nil)
+	image, err := mgr.Build(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if builds := engine.Builds(); len(builds) != 1 || builds[0].Tag != image || builds[0].Labels[LabelMetadata] == "" || builds[0].Labels[LabelDevContainerID] == "" {
+		t.Errorf("unexpected builds %+v", builds)
+	}
+	if err := engine.ValidateImage(ctx, image); err != nil {
+		t.Errorf("expected built image to exist: %v", err)
+	}
+
+	id, err := engine.CreateContainer(ctx, &DockerRunConfig{Image: image, Name: "dev"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.CreateContainer(ctx, &DockerRunConfig{Image: image, Name: "dev"}); err == nil {
+		t.Error("expected duplicate name to fail")
+	}
+	if err := engine.SetLogs(id, "one\ntwo\nthree\n"); err != nil {
+		t.Fatal(err)
+	}
+	if logs, _ := engine.GetContainerLogs(ctx, id, 2); logs != "two\nthree\n" {
+		t.Errorf("unexpected logs %q", logs)
+	}
+
+	if err := engine.CreateVolume(ctx, "cache"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.CreateNetwork(ctx, "dev"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := engine.CreateNetwork(ctx, "dev"); err == nil {
+		t.Error("expected duplicate network to fail")
+	}
+	if !reflect.DeepEqual(engine.Volumes(), []string{"cache"}) || !reflect.DeepEqual(engine.Networks(), []string{"dev"}) {
+		t.Errorf("unexpected resources %v %v", engine.Volumes(), engine.Networks())
+	}
+	if err := engine.RemoveVolume(ctx, "cache"); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.RemoveNetwork(ctx, "missing"); err == nil {
+		t.Error("expected removing a missing network to fail")
+	}
+}
+
+// writeWorkspace returns a new workspace folder whose devcontainer.json is
+// config
+func writeWorkspace(t *testing.T, config string) string {
+	t.Helper()
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".devcontainer", "devcontainer.json"), config)
+	return dir
+}
+
+// newFakeManager returns a manager on a FakeEngine having images
+func newFakeManager(images ...string) (*Manager, *FakeEngine) {
+	engine := NewFakeEngine()
+	for _, image := range images {
+		engine.AddImage(image, nil)
+	}
+	return NewManagerWithEngine(engine), engine
+}
+
+// mustCreate creates the container of the workspace folder dir
+func mustCreate(t *testing.T, mgr *Manager, dir string, opts ...api.CreateOption) string {
+	t.Helper()
+	id, err := mgr.Create(context.Background(), dir, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+// newFakeContainer creates the container of a new workspace folder whose
+// devcontainer.json is config on a FakeEngine having image, and returns the
+// manager, the engine and the container ID
+func newFakeContainer(t *testing.T, config, image string) (*Manager, *FakeEngine, string) {
+	t.Helper()
+	mgr, engine := newFakeManager(image)
+	return mgr, engine, mustCreate(t, mgr, writeWorkspace(t, config))
+}
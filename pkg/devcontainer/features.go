@@ -0,0 +1,161 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// featureFileName is the metadata file of a Feature
+const featureFileName = "devcontainer-feature.json"
+
+// Feature is the metadata of a Feature, read from its
+// devcontainer-feature.json
+type Feature struct {
+	ID            string                   `json:"id"`
+	Version       string                   `json:"version,omitempty"`
+	Name          string                   `json:"name,omitempty"`
+	Description   string                   `json:"description,omitempty"`
+	Options       map[string]FeatureOption `json:"options,omitempty"`
+	InstallsAfter []string                 `json:"installsAfter,omitempty"`
+	DependsOn     map[string]interface{}   `json:"dependsOn,omitempty"`
+
+	// Path is the folder of the Feature: absolute for DiscoverFeatures and
+	// LocalFeatures, slash-separated within the file system for their FS
+	// variants
+	Path string `json:"-"`
+}
+
+// FeatureOption is an option of a Feature
+type FeatureOption struct {
+	Type        string        `json:"type"`
+	Default     interface{}   `json:"default,omitempty"`
+	Description string        `json:"description,omitempty"`
+	Proposals   []interface{} `json:"proposals,omitempty"`
+	Enum        []interface{} `json:"enum,omitempty"`
+}
+
+// DiscoverFeatures returns the Features in dir and its subfolders, such as
+// the src folder of a Feature collection, sorted by path. .git and
+// node_modules folders are skipped.
+func DiscoverFeatures(dir string) ([]Feature, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	features, err := DiscoverFeaturesFS(os.DirFS(dir), ".")
+	if err != nil {
+		return nil, err
+	}
+	for i := range features {
+		features[i].Path = filepath.Join(dir, filepath.FromSlash(features[i].Path))
+	}
+	return features, nil
+}
+
+// DiscoverFeaturesFS is DiscoverFeatures for a folder of a file system, such
+// as a GitFS. dir and the returned paths are slash-separated paths within
+// fsys.
+func DiscoverFeaturesFS(fsys fs.FS, dir string) ([]Feature, error) {
+	dir = path.Clean(dir)
+	if !fs.ValidPath(dir) {
+		return nil, fmt.Errorf("invalid path %q", dir)
+	}
+
+	var features []Feature
+	err := fs.WalkDir(fsys, dir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && name != dir && (d.Name() == ".git" || d.Name() == "node_modules") {
+			return fs.SkipDir
+		}
+		if d.IsDir() || d.Name() != featureFileName {
+			return nil
+		}
+		feature, err := loadFeatureFS(fsys, path.Dir(name))
+		if err != nil {
+			return err
+		}
+		features = append(features, *feature)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover features: %w", err)
+	}
+	return features, nil
+}
+
+// LocalFeatures returns the local Features a configuration declares, those
+// referenced by a path inside its folder such as "./my-feature", sorted by
+// reference
+func LocalFeatures(dc *DevContainer) ([]Feature, error) {
+	if dc.ConfigFilePath == "" {
+		return nil, fmt.Errorf("configuration has no file path")
+	}
+	root := filepath.Dir(dc.ConfigFilePath)
+	features, err := LocalFeaturesFS(os.DirFS(root), filepath.Base(dc.ConfigFilePath), dc)
+	if err != nil {
+		return nil, err
+	}
+	for i := range features {
+		features[i].Path = filepath.Join(root, filepath.FromSlash(features[i].Path))
+	}
+	return features, nil
+}
+
+// LocalFeaturesFS is LocalFeatures for a configuration at the
+// slash-separated path configPath within fsys
+func LocalFeaturesFS(fsys fs.FS, configPath string, dc *DevContainer) ([]Feature, error) {
+	if dc.Features == nil {
+		return nil, nil
+	}
+	var refs []string
+	for ref := range dc.Features.AdditionalProperties {
+		if strings.HasPrefix(ref, "./") {
+			refs = append(refs, ref)
+		}
+	}
+	sort.Strings(refs)
+
+	var features []Feature
+	for _, ref := range refs {
+		dir := path.Join(path.Dir(configPath), ref)
+		if !fs.ValidPath(dir) {
+			return nil, fmt.Errorf("invalid feature path %q", ref)
+		}
+		feature, err := loadFeatureFS(fsys, dir)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load feature %q: %w", ref, err)
+		}
+		features = append(features, *feature)
+	}
+	return features, nil
+}
+
+// loadFeatureFS reads the devcontainer-feature.json in dir of fsys
+func loadFeatureFS(fsys fs.FS, dir string) (*Feature, error) {
+	name := path.Join(dir, featureFileName)
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("no %s in %s", featureFileName, dir)
+		}
+		return nil, err
+	}
+	var feature Feature
+	if err := json.Unmarshal(stripJSONC(data), &feature); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if feature.ID == "" {
+		return nil, fmt.Errorf("%s: missing id", name)
+	}
+	feature.Path = dir
+	return &feature, nil
+}
@@ -1,7 +1,12 @@
 package devcontainer
 
 import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 func TestFeatureHandling(t *testing.T) {
@@ -91,4 +96,85 @@ func TestFeaturesInDockerBuild(t *testing.T) {
 	if config.Image != "mcr.microsoft.com/devcontainers/base:ubuntu" {
 		t.Error("image should be preserved even with features")
 	}
-}
\ No newline at end of file
+}
+
+func TestDiscoverFeaturesFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"src/node/devcontainer-feature.json": {Data: []byte(`{
+			// Installs Node.js
+			"id": "node",
+			"version": "1.2.0",
+			"options": {"version": {"type": "string", "default": "lts", "proposals": ["lts", "20"]}},
+			"installsAfter": ["ghcr.io/devcontainers/features/common-utils"]
+		}`)},
+		"src/go/devcontainer-feature.json":               {Data: []byte(`{"id": "go", "version": "1.0.0"}`)},
+		"src/go/install.sh":                              {Data: []byte("#!/bin/sh\n")},
+		"node_modules/dep/devcontainer-feature.json":     {Data: []byte(`{"id": "dep"}`)},
+		".devcontainer/devcontainer.json":                {Data: []byte(`{"image": "alpine", "features": {"./local": {}, "ghcr.io/devcontainers/features/go:1": {}}}`)},
+		".devcontainer/local/devcontainer-feature.json":  {Data: []byte(`{"id": "local", "name": "Local"}`)},
+		".devcontainer/unused/devcontainer-feature.json": {Data: []byte(`{"name": "no id"}`)},
+	}
+
+	features, err := DiscoverFeaturesFS(fsys, "src")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found []string
+	for _, f := range features {
+		found = append(found, f.ID+"@"+f.Path)
+	}
+	if want := []string{"go@src/go", "node@src/node"}; !reflect.DeepEqual(found, want) {
+		t.Errorf("expected %v, got %v", want, found)
+	}
+	if node := features[1]; node.Options["version"].Default != "lts" || len(node.InstallsAfter) != 1 {
+		t.Errorf("unexpected metadata %+v", node)
+	}
+	if _, err := DiscoverFeaturesFS(fsys, "."); err == nil || !strings.Contains(err.Error(), "missing id") {
+		t.Errorf("expected a feature without id to fail, got %v", err)
+	}
+
+	dc, err := LoadDevContainerFS(fsys, ".devcontainer/devcontainer.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	local, err := LocalFeaturesFS(fsys, ".devcontainer/devcontainer.json", dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(local) != 1 || local[0].ID != "local" || local[0].Path != ".devcontainer/local" {
+		t.Errorf("unexpected local features %+v", local)
+	}
+}
+
+func TestLocalFeatures(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	for name, content := range map[string]string{
+		config: `{"image": "alpine", "features": {"./tools": {"version": "2"}}}`,
+		filepath.Join(dir, ".devcontainer", "tools", "devcontainer-feature.json"): `{"id": "tools"}`,
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dc, err := LoadDevContainer(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	features, err := LocalFeatures(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(features) != 1 || features[0].Path != filepath.Join(dir, ".devcontainer", "tools") {
+		t.Errorf("unexpected local features %+v", features)
+	}
+
+	dc.Features.AdditionalProperties["./missing"] = map[string]interface{}{}
+	if _, err := LocalFeatures(dc); err == nil || !strings.Contains(err.Error(), "./missing") {
+		t.Errorf("expected a missing local feature to fail, got %v", err)
+	}
+}
@@ -0,0 +1,327 @@
+package devcontainer
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"os/exec"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// GitFS is a read-only fs.FS over the tree of a revision in a local git
+// repository. The revision does not need to be checked out: the tree is
+// listed once and file contents are read when opened, by a single
+// `git cat-file --batch` process that Close stops. Symlinks are reported with
+// fs.ModeSymlink and opening one returns its target.
+type GitFS struct {
+	repo    string
+	tree    string
+	entries map[string]*gitEntry
+
+	mu    sync.Mutex
+	batch *gitBatch // Started on the first read
+}
+
+// gitEntry is a file or directory of a GitFS
+type gitEntry struct {
+	name     string
+	mode     fs.FileMode
+	object   string
+	size     int64
+	children []*gitEntry // Directory entries, sorted by name
+}
+
+// NewGitFS returns the tree of revision (any commit-ish, e.g. a branch, tag
+// or commit id) in the repository at repo
+func NewGitFS(repo, revision string) (*GitFS, error) {
+	out, err := runGit(repo, "rev-parse", "--verify", "--end-of-options", revision+"^{tree}")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve revision %s: %w", revision, err)
+	}
+	tree := strings.TrimSpace(string(out))
+
+	// -t lists each tree before its contents, so parents always exist
+	out, err = runGit(repo, "ls-tree", "-r", "-t", "-l", "-z", tree)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list revision %s: %w", revision, err)
+	}
+
+	g := &GitFS{
+		repo:    repo,
+		tree:    tree,
+		entries: map[string]*gitEntry{".": {name: ".", mode: fs.ModeDir | 0755}},
+	}
+	for _, record := range strings.Split(string(out), "\x00") {
+		if record == "" {
+			continue
+		}
+		meta, name, ok := strings.Cut(record, "\t")
+		fields := strings.Fields(meta)
+		if !ok || len(fields) != 4 {
+			return nil, fmt.Errorf("unexpected git ls-tree output %q", record)
+		}
+
+		var mode fs.FileMode
+		switch fields[0] {
+		case "040000":
+			mode = fs.ModeDir | 0755
+		case "100755":
+			mode = 0755
+		case "120000":
+			mode = fs.ModeSymlink | 0777
+		case "160000":
+			// Submodule contents are not part of the tree
+			continue
+		default:
+			mode = 0644
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+
+		entry := &gitEntry{name: path.Base(name), mode: mode, object: fields[2], size: size}
+		g.entries[name] = entry
+		if parent, ok := g.entries[path.Dir(name)]; ok {
+			parent.children = append(parent.children, entry)
+		}
+	}
+	for _, entry := range g.entries {
+		sort.Slice(entry.children, func(i, j int) bool { return entry.children[i].name < entry.children[j].name })
+	}
+	return g, nil
+}
+
+// Tree returns the id of the tree the file system represents
+func (g *GitFS) Tree() string {
+	return g.tree
+}
+
+// Open implements fs.FS
+func (g *GitFS) Open(name string) (fs.File, error) {
+	entry, err := g.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if entry.mode.IsDir() {
+		return &gitDir{entry: entry}, nil
+	}
+	data, err := g.blob(entry)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return &gitFile{entry: entry, Reader: bytes.NewReader(data)}, nil
+}
+
+// Stat implements fs.StatFS
+func (g *GitFS) Stat(name string) (fs.FileInfo, error) {
+	entry, err := g.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return gitFileInfo{entry}, nil
+}
+
+// ReadDir implements fs.ReadDirFS
+func (g *GitFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entry, err := g.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.mode.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fmt.Errorf("not a directory")}
+	}
+	return dirEntries(entry.children), nil
+}
+
+// ReadLink returns the target of a symlink
+func (g *GitFS) ReadLink(name string) (string, error) {
+	entry, err := g.lookup("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	if entry.mode&fs.ModeSymlink == 0 {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: fs.ErrInvalid}
+	}
+	data, err := g.blob(entry)
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return string(data), nil
+}
+
+func (g *GitFS) lookup(op, name string) (*gitEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	entry, ok := g.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return entry, nil
+}
+
+// Close stops the git process reading file contents. The file system stays
+// usable; a later read starts a new process.
+func (g *GitFS) Close() error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.batch == nil {
+		return nil
+	}
+	err := g.batch.close()
+	g.batch = nil
+	return err
+}
+
+func (g *GitFS) blob(entry *gitEntry) ([]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.batch == nil {
+		batch, err := startGitBatch(g.repo)
+		if err != nil {
+			return nil, err
+		}
+		g.batch = batch
+	}
+	data, err := g.batch.read(entry.object)
+	if err != nil {
+		// The output may be out of step with the requests, start over
+		g.batch.close()
+		g.batch = nil
+	}
+	return data, err
+}
+
+// gitBatch is a `git cat-file --batch` process, which prints the objects
+// whose names it reads
+type gitBatch struct {
+	cmd *exec.Cmd
+	in  io.WriteCloser
+	out *bufio.Reader
+}
+
+func startGitBatch(repo string) (*gitBatch, error) {
+	cmd := exec.Command("git", "-C", repo, "cat-file", "--batch")
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return &gitBatch{cmd: cmd, in: in, out: bufio.NewReader(out)}, nil
+}
+
+// read returns the content of an object. The output of each object is a
+// "<id> <type> <size>" line, the content and a newline.
+func (b *gitBatch) read(object string) ([]byte, error) {
+	if _, err := io.WriteString(b.in, object+"\n"); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	header, err := b.out.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("git cat-file: %s", strings.TrimSpace(header))
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file: unexpected header %q", strings.TrimSpace(header))
+	}
+	data := make([]byte, size+1)
+	if _, err := io.ReadFull(b.out, data); err != nil {
+		return nil, fmt.Errorf("git cat-file: %w", err)
+	}
+	return data[:size], nil
+}
+
+func (b *gitBatch) close() error {
+	b.in.Close()
+	if err := b.cmd.Wait(); err != nil {
+		return fmt.Errorf("git cat-file: %w", err)
+	}
+	return nil
+}
+
+// runGit runs a git command in repo and returns its standard output
+func runGit(repo string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", repo}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", args[0], err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// gitFileInfo implements fs.FileInfo and fs.DirEntry for a gitEntry
+type gitFileInfo struct {
+	entry *gitEntry
+}
+
+func (i gitFileInfo) Name() string               { return i.entry.name }
+func (i gitFileInfo) Size() int64                { return i.entry.size }
+func (i gitFileInfo) Mode() fs.FileMode          { return i.entry.mode }
+func (i gitFileInfo) ModTime() time.Time         { return time.Time{} }
+func (i gitFileInfo) IsDir() bool                { return i.entry.mode.IsDir() }
+func (i gitFileInfo) Sys() interface{}           { return nil }
+func (i gitFileInfo) Type() fs.FileMode          { return i.entry.mode.Type() }
+func (i gitFileInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+func dirEntries(entries []*gitEntry) []fs.DirEntry {
+	list := make([]fs.DirEntry, len(entries))
+	for i, e := range entries {
+		list[i] = gitFileInfo{e}
+	}
+	return list
+}
+
+// gitFile is an open file of a GitFS
+type gitFile struct {
+	entry *gitEntry
+	*bytes.Reader
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) { return gitFileInfo{f.entry}, nil }
+func (f *gitFile) Close() error               { return nil }
+
+// gitDir is an open directory of a GitFS
+type gitDir struct {
+	entry  *gitEntry
+	offset int
+}
+
+func (d *gitDir) Stat() (fs.FileInfo, error) { return gitFileInfo{d.entry}, nil }
+func (d *gitDir) Close() error               { return nil }
+
+func (d *gitDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.name, Err: fmt.Errorf("is a directory")}
+}
+
+// ReadDir implements fs.ReadDirFile
+func (d *gitDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entry.children[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return dirEntries(rest), nil
+}
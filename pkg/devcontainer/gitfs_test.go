@@ -0,0 +1,193 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// gitRepo creates a repository with two commits and returns its path and
+// the id of the first commit
+func gitRepo(t *testing.T) (string, string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is required")
+	}
+	repo := t.TempDir()
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", repo, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+		out, err := cmd.CombinedOutput()
+		if err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init", "-q")
+	writeConfig(t, filepath.Join(repo, ".devcontainer", "base.json"), `{"remoteUser": "vscode", "forwardPorts": [3000]}`)
+	writeConfig(t, filepath.Join(repo, ".devcontainer", "devcontainer.json"), `{
+		// Built from the repository Dockerfile
+		"extends": "./base.json",
+		"build": {"dockerfile": "../docker/Dockerfile", "context": ".."}
+	}`)
+	writeFile(t, filepath.Join(repo, "docker", "Dockerfile"), "FROM alpine:3.19\n")
+	writeConfig(t, filepath.Join(repo, "services", "api", ".devcontainer", "go", "devcontainer.json"), `{"image": "golang:1.22"}`)
+	if err := os.WriteFile(filepath.Join(repo, "docker", "entrypoint.sh"), []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("docker/Dockerfile", filepath.Join(repo, "Dockerfile")); err != nil {
+		t.Fatal(err)
+	}
+	git("add", "-A")
+	git("commit", "-q", "-m", "first")
+	first := git("rev-parse", "HEAD")
+
+	writeConfig(t, filepath.Join(repo, ".devcontainer", "base.json"), `{"remoteUser": "root"}`)
+	git("commit", "-q", "-am", "second")
+	return repo, first
+}
+
+func TestGitFS(t *testing.T) {
+	repo, first := gitRepo(t)
+
+	fsys, err := NewGitFS(repo, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := fstest.TestFS(fsys, ".devcontainer/devcontainer.json", "docker/Dockerfile", "docker/entrypoint.sh", "services/api/.devcontainer/go/devcontainer.json"); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := fsys.Stat("docker/entrypoint.sh"); err != nil || info.Mode().Perm() != 0755 {
+		t.Errorf("expected executable mode, got %v", info)
+	}
+	if target, err := fsys.ReadLink("Dockerfile"); err != nil || target != "docker/Dockerfile" {
+		t.Errorf("unexpected symlink target %q: %v", target, err)
+	}
+
+	// Contents are read by one git process until Close
+	batch := fsys.batch
+	if data, err := fs.ReadFile(fsys, "docker/Dockerfile"); err != nil || string(data) != "FROM alpine:3.19\n" {
+		t.Errorf("unexpected content %q: %v", data, err)
+	}
+	if batch == nil || fsys.batch != batch {
+		t.Error("expected reads to share one git process")
+	}
+	if err := fsys.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := fs.ReadFile(fsys, "docker/entrypoint.sh"); err != nil {
+		t.Errorf("expected reads after Close to work, got %v", err)
+	}
+	defer fsys.Close()
+
+	// The first commit is read although the working tree has moved on
+	dc, err := LoadDevContainerWithExtendsFS(context.Background(), fsys, ".devcontainer/devcontainer.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.RemoteUser == nil || *dc.RemoteUser != "vscode" || len(dc.ForwardPorts) != 1 {
+		t.Errorf("expected base config from the first commit, got %+v", dc.DevContainerCommon)
+	}
+	if _, err := LoadDevContainerFS(fsys, "missing.json"); err == nil {
+		t.Error("expected error for a missing file")
+	}
+
+	head, err := NewGitFS(repo, "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer head.Close()
+	if head.Tree() == fsys.Tree() {
+		t.Error("expected revisions to have different trees")
+	}
+	if _, err := NewGitFS(repo, "no-such-branch"); err == nil {
+		t.Error("expected error for an unknown revision")
+	}
+}
+
+func TestDiscoverDevContainersFS(t *testing.T) {
+	repo, first := gitRepo(t)
+	fsys, err := NewGitFS(repo, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsys.Close()
+
+	configs, err := DiscoverDevContainersFS(fsys, "services/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, c := range configs {
+		paths = append(paths, c.Name+"="+c.Path+"@"+c.WorkspaceFolder)
+	}
+	want := "go=services/api/.devcontainer/go/devcontainer.json@services/api,=.devcontainer/devcontainer.json@."
+	if strings.Join(paths, ",") != want {
+		t.Errorf("unexpected configs %v", paths)
+	}
+}
+
+func TestTarBuildContextFS(t *testing.T) {
+	repo, first := gitRepo(t)
+	fsys, err := NewGitFS(repo, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fsys.Close()
+
+	tests := []struct {
+		name       string
+		contextDir string
+		dockerfile string
+		wantFile   string
+		wantNames  []string
+	}{
+		{"repository root", ".", "docker/Dockerfile", "docker/Dockerfile", nil},
+		{"dockerfile in context", "docker", "Dockerfile", "Dockerfile", []string{"Dockerfile", "entrypoint.sh"}},
+		{"dockerfile outside context", "services", "../docker/Dockerfile", dockerfileInContext, []string{dockerfileInContext, "api", "api/.devcontainer", "api/.devcontainer/go", "api/.devcontainer/go/devcontainer.json"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reader, dockerfile, err := tarBuildContextFS(fsys, tt.contextDir, tt.dockerfile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if dockerfile != tt.wantFile {
+				t.Errorf("expected Dockerfile %s, got %s", tt.wantFile, dockerfile)
+			}
+
+			var names []string
+			links := map[string]string{}
+			tr := tar.NewReader(reader)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					t.Fatal(err)
+				}
+				names = append(names, hdr.Name)
+				if hdr.Linkname != "" {
+					links[hdr.Name] = hdr.Linkname
+				}
+			}
+			sort.Strings(names)
+			if tt.wantNames != nil && strings.Join(names, ",") != strings.Join(tt.wantNames, ",") {
+				t.Errorf("unexpected archive entries %v", names)
+			}
+			if tt.contextDir == "." && links["Dockerfile"] != "docker/Dockerfile" {
+				t.Errorf("expected symlink to be archived, got %v", links)
+			}
+		})
+	}
+}
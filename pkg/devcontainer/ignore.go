@@ -0,0 +1,204 @@
+package devcontainer
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io/fs"
+	"path"
+	"strings"
+)
+
+// ignoreFileName is the ignore file read in every directory of a workspace
+const ignoreFileName = ".gitignore"
+
+// dockerignoreFileName is the ignore file at the root of a build context
+const dockerignoreFileName = ".dockerignore"
+
+// ignoreRule is a pattern of an ignore file
+type ignoreRule struct {
+	dir      string // Slash-separated directory of the ignore file, "." for the root
+	pattern  string
+	negate   bool // Re-includes matching paths
+	dirOnly  bool // Only matches directories
+	anchored bool // Matches the path relative to dir instead of the base name
+}
+
+// ignoreMatcher decides which paths of a tree are ignored, following the
+// .gitignore rules: later patterns override earlier ones, patterns of a
+// nested file override those of its parents, and nothing below an ignored
+// directory is re-included.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// load adds the rules of the ignore file in dir of fsys, if there is one
+func (m *ignoreMatcher) load(fsys fs.FS, dir string) error {
+	data, err := fs.ReadFile(fsys, path.Join(dir, ignoreFileName))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	m.add(dir, data)
+	return nil
+}
+
+// add adds the rules of an ignore file in dir
+func (m *ignoreMatcher) add(dir string, data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{dir: dir}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:] // Escaped leading ! or #
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimSuffix(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			rule.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// loadDockerignore returns the rules of the .dockerignore file in dir of
+// fsys, if there is one
+func loadDockerignore(fsys fs.FS, dir string) (*ignoreMatcher, error) {
+	m := &ignoreMatcher{}
+	data, err := fs.ReadFile(fsys, path.Join(dir, dockerignoreFileName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	m.addDockerignore(data)
+	return m, nil
+}
+
+// addDockerignore adds the rules of a .dockerignore file. Unlike .gitignore
+// patterns, they are all relative to the root of the build context.
+func (m *ignoreMatcher) addDockerignore(data []byte) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{dir: ".", anchored: true}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = strings.TrimSpace(line[1:])
+		}
+		line = path.Clean(strings.TrimPrefix(line, "/"))
+		if line == "." {
+			continue
+		}
+		rule.pattern = line
+		m.rules = append(m.rules, rule)
+	}
+}
+
+// excluded reports whether the .dockerignore rules exclude the
+// slash-separated path name. Like docker, a pattern matching a parent
+// directory matches the path too, so negated patterns can re-include paths
+// below an excluded directory.
+func (m *ignoreMatcher) excluded(name string) bool {
+	excluded := false
+	for _, rule := range m.rules {
+		for p := name; ; p = path.Dir(p) {
+			if matchGlob(rule.pattern, p) {
+				excluded = !rule.negate
+				break
+			}
+			if !strings.Contains(p, "/") {
+				break
+			}
+		}
+	}
+	return excluded
+}
+
+// reincludes reports whether a negated rule may match a path below the
+// directory dir
+func (m *ignoreMatcher) reincludes(dir string) bool {
+	dirs := strings.Split(dir, "/")
+	for _, rule := range m.rules {
+		if !rule.negate {
+			continue
+		}
+		if strings.Contains(rule.pattern, "**") {
+			return true
+		}
+		pattern := strings.Split(rule.pattern, "/")
+		if len(pattern) > len(dirs) && matchSegments(pattern[:len(dirs)], dirs) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignored reports whether the slash-separated path name, relative to the
+// root of the tree, is ignored
+func (m *ignoreMatcher) ignored(name string, isDir bool) bool {
+	ignored := false
+	for _, rule := range m.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel := name
+		if rule.dir != "." {
+			if !strings.HasPrefix(name, rule.dir+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, rule.dir+"/")
+		}
+		target := rel
+		if !rule.anchored {
+			target = path.Base(rel)
+		}
+		if matchGlob(rule.pattern, target) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
+
+// matchGlob matches a slash-separated name against a pattern whose segments
+// are path.Match patterns or ** for any number of segments
+func matchGlob(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, err := path.Match(pattern[0], name[0]); err != nil || !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,124 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	m := &ignoreMatcher{}
+	m.add(".", []byte(`# build output
+*.log
+!keep.log
+/dist
+node_modules/
+docs/**/*.tmp
+\#hash
+`))
+	m.add("sub", []byte("local.txt\n"))
+
+	tests := []struct {
+		name    string
+		isDir   bool
+		ignored bool
+	}{
+		{"app.log", false, true},
+		{"nested/deep/app.log", false, true},
+		{"keep.log", false, false},
+		{"dist", true, true},
+		{"src/dist", true, false},
+		{"node_modules", true, true},
+		{"pkg/node_modules", true, true},
+		{"node_modules", false, false},
+		{"docs/a/b/x.tmp", false, true},
+		{"docs/x.tmp", false, true},
+		{"x.tmp", false, false},
+		{"#hash", false, true},
+		{"sub/local.txt", false, true},
+		{"local.txt", false, false},
+		{"main.go", false, false},
+	}
+	for _, tt := range tests {
+		if got := m.ignored(tt.name, tt.isDir); got != tt.ignored {
+			t.Errorf("ignored(%q, %v) = %v, want %v", tt.name, tt.isDir, got, tt.ignored)
+		}
+	}
+}
+
+func TestDockerignore(t *testing.T) {
+	m := &ignoreMatcher{}
+	m.addDockerignore([]byte(`# dependencies
+node_modules
+!node_modules/keep
+/.git/
+*.md
+!README.md
+build/**/*.o
+`))
+
+	tests := []struct {
+		name     string
+		excluded bool
+	}{
+		{"node_modules", true},
+		{"node_modules/lib/index.js", true},
+		{"node_modules/keep", false},
+		{"node_modules/keep/index.js", false},
+		{"src/node_modules", false},
+		{".git/config", true},
+		{"CHANGES.md", true},
+		{"docs/guide.md", false},
+		{"README.md", false},
+		{"build/a/b/x.o", true},
+		{"main.go", false},
+	}
+	for _, tt := range tests {
+		if got := m.excluded(tt.name); got != tt.excluded {
+			t.Errorf("excluded(%q) = %v, want %v", tt.name, got, tt.excluded)
+		}
+	}
+	if !m.reincludes("node_modules") || m.reincludes(".git") {
+		t.Error("expected only node_modules to have re-included paths")
+	}
+}
+
+func TestTarBuildContextDockerignore(t *testing.T) {
+	fsys := fstest.MapFS{
+		"app/.dockerignore":         {Data: []byte(".git\nnode_modules\nDockerfile\n*.log\n")},
+		"app/Dockerfile":            {Data: []byte("FROM alpine\n")},
+		"app/main.go":               {Data: []byte("package main\n")},
+		"app/debug.log":             {Data: []byte("log\n")},
+		"app/.git/HEAD":             {Data: []byte("ref: refs/heads/main\n")},
+		"app/node_modules/x/x.js":   {Data: []byte("x\n")},
+		"app/src/node_modules/y.js": {Data: []byte("y\n")},
+	}
+	reader, dockerfile, err := tarBuildContextFS(fsys, "app", "Dockerfile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	var names []string
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, hdr.Name)
+	}
+	sort.Strings(names)
+
+	// The Dockerfile and .dockerignore are sent even if excluded
+	want := ".dockerignore,Dockerfile,main.go,src,src/node_modules,src/node_modules/y.js"
+	if dockerfile != "Dockerfile" || strings.Join(names, ",") != want {
+		t.Errorf("unexpected archive %s with entries %v", dockerfile, names)
+	}
+}
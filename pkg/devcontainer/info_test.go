@@ -0,0 +1,103 @@
+package devcontainer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func TestMapDockerStatus(t *testing.T) {
+	tests := map[string]api.Status{
+		"created":    api.StatusCreated,
+		"configured": api.StatusCreated,
+		"running":    api.StatusRunning,
+		"paused":     api.StatusPaused,
+		"restarting": api.StatusRestarting,
+		"exited":     api.StatusStopped,
+		"Stopped":    api.StatusStopped,
+		"dead":       api.StatusError,
+		"removing":   api.StatusNone,
+	}
+	for state, want := range tests {
+		if got := mapDockerStatus(state); got != want {
+			t.Errorf("mapDockerStatus(%q) = %s, want %s", state, got, want)
+		}
+	}
+}
+
+func TestManagerGetInfo(t *testing.T) {
+	ctx := context.Background()
+	dir := writeWorkspace(t, `{
+		"image": "alpine:3.20",
+		"containerUser": "app",
+		"mounts": ["type=volume,source=cache,target=/cache"]
+	}`)
+	mgr, engine := newFakeManager()
+	engine.AddImage("alpine:3.20", map[string]string{LabelMetadata: `[{"remoteUser": "dev"}]`})
+	id := mustCreate(t, mgr, dir)
+	info, err := mgr.GetInfo(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != api.StatusCreated || info.Created != "2024-01-01T00:00:00Z" || info.StartedAt != "" || info.RemoteUser != "dev" {
+		t.Errorf("unexpected info %+v", info)
+	}
+	wantMounts := []api.Mount{
+		{Type: "bind", Source: dir, Target: "/workspaces/" + filepath.Base(dir)},
+		{Type: "volume", Source: "cache", Target: "/cache"},
+	}
+	if !reflect.DeepEqual(info.Mounts, wantMounts) {
+		t.Errorf("expected mounts %+v, got %+v", wantMounts, info.Mounts)
+	}
+
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.UpdateContainer(id, func(c *FakeContainer) {
+		c.Health = "healthy"
+		c.Ports = map[string]string{"8080/tcp": "0.0.0.0:32768"}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := engine.UpdateContainer(id, func(c *FakeContainer) {
+		c.ExitCode = 137
+		c.OOMKilled = true
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = mgr.GetInfo(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Status != api.StatusStopped || info.StartedAt != "2024-01-01T00:00:01Z" || info.FinishedAt != "2024-01-01T00:00:02Z" ||
+		info.ExitCode != 137 || !info.OOMKilled || info.Health != "healthy" || info.Ports["8080/tcp"] != "0.0.0.0:32768" {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	// The remote user is the one the container was created with, whatever
+	// the configuration says now
+	writeConfig(t, filepath.Join(dir, ".devcontainer", "devcontainer.json"), `{"image": "alpine:3.20", "remoteUser": "other"}`)
+	if info, err := mgr.GetInfo(ctx, id); err != nil || info.RemoteUser != "dev" {
+		t.Errorf("expected remote user dev, got %+v: %v", info, err)
+	}
+
+	// Without the metadata label it comes from the image, and without
+	// either it is the container user
+	engine.UpdateContainer(id, func(c *FakeContainer) {
+		delete(c.Labels, LabelMetadata)
+	})
+	if info, err := mgr.GetInfo(ctx, id); err != nil || info.RemoteUser != "dev" {
+		t.Errorf("expected remote user dev from the image, got %+v: %v", info, err)
+	}
+	engine.AddImage("alpine:3.20", nil)
+	if info, err := mgr.GetInfo(ctx, id); err != nil || info.RemoteUser != "app" {
+		t.Errorf("expected remote user app, got %+v: %v", info, err)
+	}
+}
@@ -56,13 +56,13 @@ func TestIntegrationRealContainers(t *testing.T) {
 						"NODE_ENV": "development",
 						"PORT":     "3000",
 					},
-					ForwardPorts: []interface{}{float64(3000), float64(9229)},
-					Mounts: []interface{}{
-						map[string]interface{}{
-							"type":   "volume",
-							"source": "node_modules",
-							"target": "/workspace/node_modules",
-						},
+					ForwardPorts: []Port{PortNumber(3000), PortNumber(9229)},
+					Mounts: []MountEntry{
+						MountObject(Mount{
+							Type:   "volume",
+							Source: "node_modules",
+							Target: "/workspace/node_modules",
+						}),
 					},
 				},
 			},
@@ -0,0 +1,389 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// JSON value kinds of a jsonNode
+const (
+	jsonObject = iota
+	jsonArray
+	jsonString
+	jsonNumber
+	jsonBool
+	jsonNull
+)
+
+// jsonNode is a JSON value together with its location in the source
+// document. Comments and trailing commas (JSONC) are accepted.
+type jsonNode struct {
+	Kind     int
+	Start    int         // Offset of the first byte of the value
+	End      int         // Offset just past the value
+	Key      string      // Member name, for object members
+	KeyStart int         // Offset of the member name, -1 if not a member
+	Children []*jsonNode // Object members or array elements, in order
+	Value    interface{} // Decoded value of scalars
+}
+
+// JSONSyntaxError reports malformed JSON(C) input
+type JSONSyntaxError struct {
+	Offset int
+	Msg    string
+}
+
+func (e *JSONSyntaxError) Error() string {
+	return fmt.Sprintf("invalid JSON at offset %d: %s", e.Offset, e.Msg)
+}
+
+// parseJSONC parses a JSON document that may contain comments and trailing
+// commas
+func parseJSONC(data []byte) (*jsonNode, error) {
+	p := &jsoncParser{data: data}
+	p.skip()
+	node, err := p.value()
+	if err != nil {
+		return nil, err
+	}
+	p.skip()
+	if p.pos < len(p.data) {
+		return nil, p.errorf("unexpected %q after top-level value", p.data[p.pos])
+	}
+	return node, nil
+}
+
+type jsoncParser struct {
+	data []byte
+	pos  int
+}
+
+func (p *jsoncParser) errorf(format string, args ...interface{}) error {
+	return &JSONSyntaxError{Offset: p.pos, Msg: fmt.Sprintf(format, args...)}
+}
+
+// skip advances past whitespace and comments
+func (p *jsoncParser) skip() {
+	for p.pos < len(p.data) {
+		switch c := p.data[p.pos]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			p.pos++
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '/':
+			for p.pos < len(p.data) && p.data[p.pos] != '\n' {
+				p.pos++
+			}
+		case c == '/' && p.pos+1 < len(p.data) && p.data[p.pos+1] == '*':
+			end := strings.Index(string(p.data[p.pos+2:]), "*/")
+			if end < 0 {
+				p.pos = len(p.data)
+				return
+			}
+			p.pos += end + 4
+		default:
+			return
+		}
+	}
+}
+
+func (p *jsoncParser) value() (*jsonNode, error) {
+	if p.pos >= len(p.data) {
+		return nil, p.errorf("unexpected end of input")
+	}
+	node := &jsonNode{Start: p.pos, KeyStart: -1}
+	switch c := p.data[p.pos]; {
+	case c == '{':
+		node.Kind = jsonObject
+		if err := p.object(node); err != nil {
+			return nil, err
+		}
+	case c == '[':
+		node.Kind = jsonArray
+		if err := p.array(node); err != nil {
+			return nil, err
+		}
+	case c == '"':
+		s, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		node.Kind, node.Value = jsonString, s
+	case c == '-' || (c >= '0' && c <= '9'):
+		start := p.pos
+		for p.pos < len(p.data) && strings.IndexByte("+-.eE0123456789", p.data[p.pos]) >= 0 {
+			p.pos++
+		}
+		n, err := strconv.ParseFloat(string(p.data[start:p.pos]), 64)
+		if err != nil {
+			p.pos = start
+			return nil, p.errorf("invalid number %q", p.data[start:p.pos])
+		}
+		node.Kind, node.Value = jsonNumber, n
+	default:
+		for _, lit := range []struct {
+			text  string
+			kind  int
+			value interface{}
+		}{{"true", jsonBool, true}, {"false", jsonBool, false}, {"null", jsonNull, nil}} {
+			if strings.HasPrefix(string(p.data[p.pos:]), lit.text) {
+				p.pos += len(lit.text)
+				node.Kind, node.Value = lit.kind, lit.value
+				node.End = p.pos
+				return node, nil
+			}
+		}
+		return nil, p.errorf("unexpected character %q", c)
+	}
+	node.End = p.pos
+	return node, nil
+}
+
+func (p *jsoncParser) object(node *jsonNode) error {
+	p.pos++ // {
+	for {
+		p.skip()
+		if p.pos >= len(p.data) {
+			return p.errorf("unterminated object")
+		}
+		if p.data[p.pos] == '}' {
+			p.pos++
+			return nil
+		}
+		if len(node.Children) > 0 {
+			if p.data[p.pos] != ',' {
+				return p.errorf("expected ',' or '}' after object member")
+			}
+			p.pos++
+			p.skip()
+			if p.pos < len(p.data) && p.data[p.pos] == '}' {
+				p.pos++
+				return nil
+			}
+		}
+		if p.pos >= len(p.data) || p.data[p.pos] != '"' {
+			return p.errorf("expected string for object key")
+		}
+		keyStart := p.pos
+		key, err := p.str()
+		if err != nil {
+			return err
+		}
+		p.skip()
+		if p.pos >= len(p.data) || p.data[p.pos] != ':' {
+			return p.errorf("expected ':' after object key")
+		}
+		p.pos++
+		p.skip()
+		member, err := p.value()
+		if err != nil {
+			return err
+		}
+		member.Key, member.KeyStart = key, keyStart
+		node.Children = append(node.Children, member)
+	}
+}
+
+func (p *jsoncParser) array(node *jsonNode) error {
+	p.pos++ // [
+	for {
+		p.skip()
+		if p.pos >= len(p.data) {
+			return p.errorf("unterminated array")
+		}
+		if p.data[p.pos] == ']' {
+			p.pos++
+			return nil
+		}
+		if len(node.Children) > 0 {
+			if p.data[p.pos] != ',' {
+				return p.errorf("expected ',' or ']' after array element")
+			}
+			p.pos++
+			p.skip()
+			if p.pos < len(p.data) && p.data[p.pos] == ']' {
+				p.pos++
+				return nil
+			}
+		}
+		elem, err := p.value()
+		if err != nil {
+			return err
+		}
+		node.Children = append(node.Children, elem)
+	}
+}
+
+func (p *jsoncParser) str() (string, error) {
+	start := p.pos
+	for p.pos++; p.pos < len(p.data); p.pos++ {
+		switch p.data[p.pos] {
+		case '\\':
+			p.pos++
+		case '\n':
+			return "", p.errorf("newline in string")
+		case '"':
+			p.pos++
+			var s string
+			if err := json.Unmarshal(p.data[start:p.pos], &s); err != nil {
+				p.pos = start
+				return "", p.errorf("invalid string: %v", err)
+			}
+			return s, nil
+		}
+	}
+	p.pos = start
+	return "", p.errorf("unterminated string")
+}
+
+// interfaceValue returns the node as the value encoding/json would decode
+func (n *jsonNode) interfaceValue() interface{} {
+	switch n.Kind {
+	case jsonObject:
+		m := make(map[string]interface{}, len(n.Children))
+		for _, c := range n.Children {
+			m[c.Key] = c.interfaceValue()
+		}
+		return m
+	case jsonArray:
+		list := make([]interface{}, len(n.Children))
+		for i, c := range n.Children {
+			list[i] = c.interfaceValue()
+		}
+		return list
+	default:
+		return n.Value
+	}
+}
+
+// member returns the last member of an object node with the given name
+func (n *jsonNode) member(key string) *jsonNode {
+	var found *jsonNode
+	for _, c := range n.Children {
+		if c.Key == key {
+			found = c
+		}
+	}
+	return found
+}
+
+// lookup returns the node at a JSON pointer, or the deepest existing
+// ancestor when the pointer does not resolve
+func (n *jsonNode) lookup(pointer string) *jsonNode {
+	node, _ := n.resolve(pointer)
+	return node
+}
+
+// resolve returns the node at a JSON pointer and whether it exists. When it
+// does not, the deepest existing ancestor is returned.
+func (n *jsonNode) resolve(pointer string) (*jsonNode, bool) {
+	node := n
+	for _, token := range splitPointer(pointer) {
+		next := node.child(token)
+		if next == nil {
+			return node, false
+		}
+		node = next
+	}
+	return node, true
+}
+
+// child returns the member or element a reference token refers to
+func (n *jsonNode) child(token string) *jsonNode {
+	switch n.Kind {
+	case jsonObject:
+		return n.member(token)
+	case jsonArray:
+		if i, err := strconv.Atoi(token); err == nil && i >= 0 && i < len(n.Children) {
+			return n.Children[i]
+		}
+	}
+	return nil
+}
+
+// splitPointer splits a JSON pointer into unescaped reference tokens
+func splitPointer(pointer string) []string {
+	if pointer == "" || pointer == "/" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// joinPointer appends an unescaped reference token to a JSON pointer
+func joinPointer(pointer, token string) string {
+	return pointer + "/" + strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1")
+}
+
+// lineColumn converts a byte offset to a 1-based line and column
+func lineColumn(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+	line, col := 1, 1
+	for _, c := range data[:offset] {
+		if c == '\n' {
+			line++
+			col = 1
+		} else {
+			col++
+		}
+	}
+	return line, col
+}
+
+// stripJSONC replaces comments and trailing commas with spaces so that the
+// result is plain JSON with the same byte offsets
+func stripJSONC(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+			lastComma = -1
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := i
+			for end < len(out) && out[end] != '\n' {
+				end++
+			}
+			blank(i, end)
+			i = end - 1
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := strings.Index(string(out[i+2:]), "*/")
+			if end < 0 {
+				end = len(out)
+			} else {
+				end += i + 4
+			}
+			blank(i, end)
+			i = end - 1
+		case c == ',':
+			lastComma = i
+		case c == '}' || c == ']':
+			if lastComma >= 0 {
+				out[lastComma] = ' '
+			}
+			lastComma = -1
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+		default:
+			lastComma = -1
+		}
+	}
+	return out
+}
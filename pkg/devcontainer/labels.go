@@ -0,0 +1,52 @@
+package devcontainer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Version is the version of this library, recorded on the resources it creates
+const Version = "0.1.0"
+
+// Labels stamped on every container, image and volume created by Manager, on
+// top of the identifying labels of the reference CLI
+const (
+	LabelDevContainerID = "devcontainer-go.devcontainer_id"
+	LabelConfigHash     = "devcontainer-go.config_hash"
+	LabelVersion        = "devcontainer-go.version"
+)
+
+// ResourceLabels returns the labels of the resources created for dc and a
+// local workspace folder: the IDLabels, the devcontainerId, the hash of the
+// configuration, the library version and the applied profiles.
+func ResourceLabels(dc *DevContainer, localFolder string) map[string]string {
+	labels := IDLabels(localFolder, dc.ConfigFilePath)
+	labels[LabelDevContainerID] = ComputeDevContainerID(labels)
+	labels[LabelConfigHash] = ConfigHash(dc)
+	labels[LabelVersion] = Version
+	if len(dc.Profiles) > 0 {
+		labels[LabelProfiles] = profilesLabel(dc.Profiles)
+	}
+	return labels
+}
+
+// ConfigHash returns the hex SHA-256 of the JSON form of dc, which changes
+// whenever the configuration does
+func ConfigHash(dc *DevContainer) string {
+	data, err := json.Marshal(dc)
+	if err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// labelFilter returns the engine filter for a label; an empty value matches
+// any value
+func labelFilter(key, value string) string {
+	if value == "" {
+		return key
+	}
+	return key + "=" + value
+}
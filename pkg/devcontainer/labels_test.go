@@ -0,0 +1,133 @@
+package devcontainer
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func TestResourceLabels(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	writeConfig(t, configPath, `{"image": "alpine:3.20", "remoteUser": "dev"}`)
+	dc, err := LoadDevContainer(configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	labels := ResourceLabels(dc, dir)
+	if labels[LabelLocalFolder] != dir || labels[LabelConfigFile] != configPath {
+		t.Errorf("missing identifying labels: %v", labels)
+	}
+	if labels[LabelDevContainerID] != dc.DevContainerID(dir) || labels[LabelVersion] != Version {
+		t.Errorf("unexpected labels %v", labels)
+	}
+	if _, ok := labels[LabelProfiles]; ok {
+		t.Errorf("expected no profiles label, got %v", labels)
+	}
+
+	hash := labels[LabelConfigHash]
+	if len(hash) != 64 || ConfigHash(dc) != hash {
+		t.Errorf("unexpected config hash %q", hash)
+	}
+	user := "root"
+	dc.RemoteUser = &user
+	if ConfigHash(dc) == hash {
+		t.Error("expected the config hash to change with the configuration")
+	}
+}
+
+func TestManagerList(t *testing.T) {
+	ctx := context.Background()
+	first := writeWorkspace(t, `{"image": "alpine:3.20"}`)
+	second := writeWorkspace(t, `{"image": "alpine:3.20", "remoteUser": "dev"}`)
+	mgr, engine := newFakeManager("alpine:3.20")
+	older := mustCreate(t, mgr, first)
+	newer := mustCreate(t, mgr, first)
+	other := mustCreate(t, mgr, second)
+	if err := mgr.Start(ctx, other); err != nil {
+		t.Fatal(err)
+	}
+	// Containers not created by a dev container tool are not listed
+	if _, err := engine.CreateContainer(ctx, &DockerRunConfig{Image: "alpine:3.20"}); err != nil {
+		t.Fatal(err)
+	}
+
+	all, err := mgr.List(ctx, api.ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 3 {
+		t.Fatalf("expected 3 containers, got %+v", all)
+	}
+
+	infos, err := mgr.FindByWorkspace(ctx, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 2 || infos[0].ID != newer || infos[1].ID != older {
+		t.Fatalf("expected %s and %s, got %+v", newer, older, infos)
+	}
+	info := infos[0]
+	hash, err := mgr.ConfigHash(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.WorkspaceFolder != first || info.ConfigPath != filepath.Join(first, ".devcontainer", "devcontainer.json") ||
+		info.DevContainerID != mgr.DevContainerID(first) || info.ConfigHash != hash || info.Version != Version ||
+		info.Image != "alpine:3.20" || info.Created == "" {
+		t.Errorf("unexpected info %+v", info)
+	}
+
+	running, err := mgr.List(ctx, api.ListFilter{Status: api.StatusRunning})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(running) != 1 || running[0].ID != other || running[0].ConfigHash == hash {
+		t.Errorf("expected only %s to be running, got %+v", other, running)
+	}
+
+	byID, err := mgr.List(ctx, api.ListFilter{DevContainerID: mgr.DevContainerID(second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(byID) != 1 || byID[0].ID != other {
+		t.Errorf("expected %s, got %+v", other, byID)
+	}
+}
+
+// vanishingEngine finds a container that is removed before it is inspected
+type vanishingEngine struct {
+	*FakeEngine
+}
+
+func (e vanishingEngine) FindContainers(ctx context.Context, labels map[string]string) ([]string, error) {
+	ids, err := e.FakeEngine.FindContainers(ctx, labels)
+	return append([]string{"removed"}, ids...), err
+}
+
+func TestManagerListSkipsRemovedContainers(t *testing.T) {
+	dir := writeWorkspace(t, `{"image": "alpine:3.20"}`)
+	engine := vanishingEngine{NewFakeEngine()}
+	engine.AddImage("alpine:3.20", nil)
+	mgr := NewManagerWithEngine(engine)
+	id := mustCreate(t, mgr, dir)
+
+	infos, err := mgr.List(context.Background(), api.ListFilter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].ID != id {
+		t.Errorf("expected only %s, got %+v", id, infos)
+	}
+}
+
+func TestContainerInfoOfReferenceCLIContainer(t *testing.T) {
+	labels := IDLabels("/src/app", "/src/app/.devcontainer/devcontainer.json")
+	info := containerInfo(&ContainerDetails{ID: "abc", Name: "/app", Status: "exited", Labels: labels})
+	if info.Name != "app" || info.Status != api.StatusStopped || info.DevContainerID != ComputeDevContainerID(labels) {
+		t.Errorf("unexpected info %+v", info)
+	}
+}
@@ -0,0 +1,186 @@
+package devcontainer
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// UserCommandOptions selects the lifecycle commands RunUserCommands runs.
+// By default onCreateCommand through postAttachCommand run in order.
+type UserCommandOptions struct {
+	// StartAt is the first command to run, e.g. "postStartCommand" for a
+	// restarted container; onCreateCommand if empty
+	StartAt string
+
+	// Prebuild stops after updateContentCommand, e.g. to prebuild an image
+	Prebuild bool
+
+	// SkipNonBlocking stops after the waitFor command, updateContentCommand
+	// unless the configuration says otherwise
+	SkipNonBlocking bool
+
+	// SkipPostAttach skips postAttachCommand
+	SkipPostAttach bool
+
+	// Output receives the output of the commands, if set
+	Output io.Writer
+}
+
+// RunUserCommands runs the lifecycle commands of the configuration Create
+// would use for the node in a running container. Commands run as the remote
+// user in the workspace folder with the remote environment, and stop at the
+// first failure.
+func (m *Manager) RunUserCommands(ctx context.Context, containerID, nodePath string, options UserCommandOptions, opts ...api.CreateOption) error {
+	if options.StartAt != "" && !isLifecyclePhase(options.StartAt) {
+		return fmt.Errorf("unknown lifecycle command %q", options.StartAt)
+	}
+	dc, err := m.resolveDevContainer(ctx, nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return err
+	}
+
+	waitFor := dc.WaitFor
+	if waitFor == "" {
+		waitFor = "updateContentCommand"
+	}
+	folder, _ := containerWorkspace(dc, nodePath)
+	execOpts := ExecOptions{User: remoteUser(dc), WorkingDir: folder, Env: dc.ResolvedRemoteEnv()}
+
+	started := options.StartAt == ""
+	for _, phase := range lifecyclePhases {
+		if started = started || phase.name == options.StartAt; !started {
+			continue
+		}
+		if phase.name == "postAttachCommand" && options.SkipPostAttach {
+			break
+		}
+		if commands := lifecycleCommandArgs(phase.command(dc)); len(commands) > 0 {
+			event := Event{Type: EventLifecycle, ContainerID: containerID, Phase: phase.name, Labels: IDLabels(nodePath, dc.ConfigFilePath)}
+			err := m.step(event, func() error {
+				for _, args := range commands {
+					out, err := m.docker.ExecAs(ctx, containerID, execOpts, args)
+					if options.Output != nil {
+						io.WriteString(options.Output, out)
+					}
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return fmt.Errorf("failed to run %s: %w", phase.name, err)
+			}
+		}
+		if (options.Prebuild && phase.name == "updateContentCommand") || (options.SkipNonBlocking && phase.name == waitFor) {
+			break
+		}
+	}
+	return nil
+}
+
+// RunInitializeCommand runs the initializeCommand of the configuration Create
+// would use for the node on the host, in the node path
+func (m *Manager) RunInitializeCommand(ctx context.Context, nodePath string, output io.Writer, opts ...api.CreateOption) error {
+	dc, err := m.loadDevContainer(ctx, nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return err
+	}
+	vars := GetStandardVariables(absPath(nodePath))
+	commands := lifecycleCommandArgs(dc.InitializeCommand.expand(vars))
+	if len(commands) == 0 {
+		return nil
+	}
+	event := Event{Type: EventLifecycle, Phase: "initializeCommand", Labels: IDLabels(nodePath, dc.ConfigFilePath)}
+	return m.step(event, func() error {
+		for _, args := range commands {
+			cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+			cmd.Dir = nodePath
+			var stderr bytes.Buffer
+			cmd.Stdout = output
+			cmd.Stderr = &stderr
+			if output == nil {
+				cmd.Stdout = io.Discard
+			}
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("failed to run initializeCommand: %w: %s", err, stderr.String())
+			}
+		}
+		return nil
+	})
+}
+
+// resolveDevContainer returns the configuration Create would use for the
+// node merged with its image metadata, with variables expanded
+func (m *Manager) resolveDevContainer(ctx context.Context, nodePath string, opts api.CreateOptions) (*DevContainer, error) {
+	dc, err := m.loadDevContainer(ctx, nodePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := m.imageMetadata(ctx, dc, nodePath)
+	if err != nil {
+		return nil, err
+	}
+	vars := GetStandardVariables(absPath(nodePath))
+	vars["devcontainerId"] = dc.DevContainerID(nodePath)
+	return expandedCopy(MergeImageMetadata(metadata, dc), vars), nil
+}
+
+func isLifecyclePhase(name string) bool {
+	for _, phase := range lifecyclePhases {
+		if phase.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteUser returns the user lifecycle commands and tools run as
+func remoteUser(dc *DevContainer) string {
+	if dc.RemoteUser != nil && *dc.RemoteUser != "" {
+		return *dc.RemoteUser
+	}
+	if dc.ContainerUser != nil {
+		return *dc.ContainerUser
+	}
+	return ""
+}
+
+// lifecycleCommandArgs returns the processes a lifecycle command runs: a
+// string runs in a shell, an array runs directly, and the entries of an
+// object, in name order, and the steps of a sequence run one after another
+func lifecycleCommandArgs(cmd *LifecycleCommand) [][]string {
+	if cmd == nil {
+		return nil
+	}
+	switch cmd.Type {
+	case CommandTypeString:
+		if cmd.Command == "" {
+			return nil
+		}
+		return [][]string{{"/bin/sh", "-c", cmd.Command}}
+	case CommandTypeArray:
+		if len(cmd.Args) == 0 {
+			return nil
+		}
+		return [][]string{cmd.Args}
+	case CommandTypeObject:
+		var all [][]string
+		for _, name := range sortedKeys(cmd.Commands) {
+			all = append(all, lifecycleCommandArgs(cmd.Commands[name])...)
+		}
+		return all
+	case CommandTypeSequence:
+		var all [][]string
+		for _, step := range cmd.Sequence {
+			all = append(all, lifecycleCommandArgs(step)...)
+		}
+		return all
+	}
+	return nil
+}
@@ -1,8 +1,14 @@
 package devcontainer
 
 import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
 )
 
 func TestParseLifecycleCommand(t *testing.T) {
@@ -172,14 +178,14 @@ func TestLifecycleCommandToShellCommand(t *testing.T) {
 func TestProcessLifecycleCommands(t *testing.T) {
 	dc := &DevContainer{
 		DevContainerCommon: DevContainerCommon{
-			InitializeCommand:    "git config --global init.defaultBranch main",
-			OnCreateCommand:      []interface{}{"npm", "install"},
-			UpdateContentCommand: "git pull",
-			PostCreateCommand: map[string]interface{}{
-				"build": "npm run build",
-				"test":  []interface{}{"npm", "test"},
-			},
-			PostStartCommand:  "npm start",
+			InitializeCommand:    StringCommand("git config --global init.defaultBranch main"),
+			OnCreateCommand:      ArrayCommand("npm", "install"),
+			UpdateContentCommand: StringCommand("git pull"),
+			PostCreateCommand: ObjectCommand(map[string]*LifecycleCommand{
+				"build": StringCommand("npm run build"),
+				"test":  ArrayCommand("npm", "test"),
+			}),
+			PostStartCommand:  StringCommand("npm start"),
 			PostAttachCommand: nil,
 		},
 	}
@@ -223,12 +229,12 @@ func TestProcessLifecycleCommands(t *testing.T) {
 func TestGetLifecycleScript(t *testing.T) {
 	dc := &DevContainer{
 		DevContainerCommon: DevContainerCommon{
-			InitializeCommand:    "echo 'Initializing'",
-			OnCreateCommand:      "npm install",
-			UpdateContentCommand: []interface{}{"git", "pull", "origin", "main"},
-			PostCreateCommand:    "npm run build",
-			PostStartCommand:     "npm start",
-			PostAttachCommand:    "echo 'Attached'",
+			InitializeCommand:    StringCommand("echo 'Initializing'"),
+			OnCreateCommand:      StringCommand("npm install"),
+			UpdateContentCommand: ArrayCommand("git", "pull", "origin", "main"),
+			PostCreateCommand:    StringCommand("npm run build"),
+			PostStartCommand:     StringCommand("npm start"),
+			PostAttachCommand:    StringCommand("echo 'Attached'"),
 		},
 	}
 
@@ -300,16 +306,16 @@ func TestGetLifecycleScript(t *testing.T) {
 func TestLifecycleCommandsWithVariableExpansion(t *testing.T) {
 	dc := &DevContainer{
 		DevContainerCommon: DevContainerCommon{
-			OnCreateCommand: "cd ${localWorkspaceFolder} && npm install",
-			PostCreateCommand: []interface{}{
+			OnCreateCommand: StringCommand("cd ${localWorkspaceFolder} && npm install"),
+			PostCreateCommand: ArrayCommand(
 				"mkdir",
 				"-p",
 				"${containerWorkspaceFolder}/dist",
-			},
-			PostStartCommand: map[string]interface{}{
-				"server": "cd ${containerWorkspaceFolder} && npm start",
-				"watch":  []interface{}{"npm", "run", "watch", "--prefix", "${containerWorkspaceFolder}"},
-			},
+			),
+			PostStartCommand: ObjectCommand(map[string]*LifecycleCommand{
+				"server": StringCommand("cd ${containerWorkspaceFolder} && npm start"),
+				"watch":  ArrayCommand("npm", "run", "watch", "--prefix", "${containerWorkspaceFolder}"),
+			}),
 		},
 	}
 
@@ -396,6 +402,61 @@ func TestHostRequirementsCheck(t *testing.T) {
 	}
 }
 
+func TestLifecycleCommandArgs(t *testing.T) {
+	dc, err := ParseDevContainer([]byte(`{
+		"onCreateCommand": "npm install",
+		"postCreateCommand": ["go", "mod", "download"],
+		"postStartCommand": {"server": "npm start", "watch": ["npm", "run", "watch"]}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	merged := MergeDevContainers(dc, &DevContainer{DevContainerCommon: DevContainerCommon{OnCreateCommand: &LifecycleCommand{Type: CommandTypeString, Command: "make"}}})
+
+	tests := []struct {
+		name string
+		cmd  *LifecycleCommand
+		want [][]string
+	}{
+		{"string", dc.OnCreateCommand, [][]string{{"/bin/sh", "-c", "npm install"}}},
+		{"array", dc.PostCreateCommand, [][]string{{"go", "mod", "download"}}},
+		{"object", dc.PostStartCommand, [][]string{{"/bin/sh", "-c", "npm start"}, {"npm", "run", "watch"}}},
+		{"sequence", merged.OnCreateCommand, [][]string{{"/bin/sh", "-c", "npm install"}, {"/bin/sh", "-c", "make"}}},
+		{"unset", dc.PostAttachCommand, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lifecycleCommandArgs(tt.cmd); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRunInitializeCommand(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".devcontainer.json"), `{"image": "alpine:3.19", "initializeCommand": "echo ${localWorkspaceFolderBasename} > marker"}`)
+
+	var out strings.Builder
+	mgr := &Manager{}
+	if err := mgr.RunInitializeCommand(context.Background(), dir, &out); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "marker"))
+	if err != nil || strings.TrimSpace(string(data)) != filepath.Base(dir) {
+		t.Errorf("expected initializeCommand to run in the workspace, got %q: %v", data, err)
+	}
+
+	writeConfig(t, filepath.Join(dir, ".devcontainer.json"), `{"image": "alpine:3.19", "initializeCommand": ["false"]}`)
+	if err := mgr.RunInitializeCommand(context.Background(), dir, &out); err == nil {
+		t.Error("expected failing initializeCommand to return an error")
+	}
+
+	if err := mgr.RunUserCommands(context.Background(), "id", dir, UserCommandOptions{StartAt: "postBuildCommand"}, api.WithConfigPath(".devcontainer.json")); err == nil {
+		t.Error("expected error for an unknown lifecycle command")
+	}
+}
+
 // Helper function
 func intPtr(i int) *int {
 	return &i
@@ -0,0 +1,382 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// lifecyclePhases are the lifecycle commands that run inside the container,
+// with their property names
+var lifecyclePhases = []struct {
+	name    string
+	command func(dc *DevContainer) *LifecycleCommand
+}{
+	{"onCreateCommand", func(dc *DevContainer) *LifecycleCommand { return dc.OnCreateCommand }},
+	{"updateContentCommand", func(dc *DevContainer) *LifecycleCommand { return dc.UpdateContentCommand }},
+	{"postCreateCommand", func(dc *DevContainer) *LifecycleCommand { return dc.PostCreateCommand }},
+	{"postStartCommand", func(dc *DevContainer) *LifecycleCommand { return dc.PostStartCommand }},
+	{"postAttachCommand", func(dc *DevContainer) *LifecycleCommand { return dc.PostAttachCommand }},
+}
+
+// toolProviders maps commands commonly used in lifecycle scripts to the
+// feature or image names that install them
+var toolProviders = map[string][]string{
+	"node":      {"node"},
+	"npm":       {"node"},
+	"npx":       {"node"},
+	"yarn":      {"node"},
+	"pnpm":      {"node"},
+	"python":    {"python", "anaconda", "miniconda"},
+	"python3":   {"python", "anaconda", "miniconda"},
+	"pip":       {"python", "anaconda", "miniconda"},
+	"pip3":      {"python", "anaconda", "miniconda"},
+	"conda":     {"conda", "anaconda", "miniconda"},
+	"go":        {"go", "golang"},
+	"cargo":     {"rust"},
+	"rustc":     {"rust"},
+	"rustup":    {"rust"},
+	"java":      {"java", "jdk", "openjdk"},
+	"javac":     {"java", "jdk", "openjdk"},
+	"mvn":       {"maven", "java"},
+	"gradle":    {"gradle", "java"},
+	"dotnet":    {"dotnet"},
+	"ruby":      {"ruby"},
+	"gem":       {"ruby"},
+	"bundle":    {"ruby"},
+	"php":       {"php"},
+	"composer":  {"php"},
+	"deno":      {"deno"},
+	"docker":    {"docker-in-docker", "docker-outside-of-docker", "docker"},
+	"kubectl":   {"kubectl-helm-minikube", "kubectl"},
+	"helm":      {"kubectl-helm-minikube", "helm"},
+	"minikube":  {"kubectl-helm-minikube", "minikube"},
+	"az":        {"azure-cli"},
+	"aws":       {"aws-cli"},
+	"gh":        {"github-cli"},
+	"terraform": {"terraform"},
+}
+
+// portableHostPaths are absolute host paths that exist on every Docker host
+var portableHostPaths = map[string]bool{
+	"/var/run/docker.sock": true,
+	"/run/docker.sock":     true,
+}
+
+var (
+	commandSeparatorRe = regexp.MustCompile(`&&|\|\||[;|\n&]`)
+	envAssignmentRe    = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*=`)
+	windowsPathRe      = regexp.MustCompile(`^[A-Za-z]:[\\/]`)
+	imageNameSepRe     = regexp.MustCompile(`[/:._-]+`)
+)
+
+// Lint reports problems in a configuration that are valid according to the
+// schema but likely to cause trouble: deprecated properties, privileged
+// containers, non-portable mounts, unpinned images and lifecycle commands
+// that use tools nothing installs. Schema violations from Validate and
+// invalid host requirements from HostRequirementsCheck are included. When
+// the configuration was loaded from a file, diagnostics carry the position
+// of the offending value in that file.
+func Lint(dc *DevContainer) (Diagnostics, error) {
+	data, err := json.Marshal(dc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode devcontainer.json: %w", err)
+	}
+	diags, err := Validate(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := HostRequirementsCheck(dc.HostRequirements); err != nil && !hasDiagnosticUnder(diags, "/hostRequirements") {
+		diags = append(diags, Diagnostic{Pointer: "/hostRequirements", Severity: SeverityError, Message: err.Error()})
+	}
+
+	var source []byte
+	var root *jsonNode
+	if dc.ConfigFilePath != "" {
+		if source, err = os.ReadFile(dc.ConfigFilePath); err == nil {
+			root, _ = parseJSONC(source)
+		}
+	}
+
+	diags = append(diags, lintDeprecated(dc)...)
+	diags = append(diags, lintPrivileged(dc, source, root)...)
+	diags = append(diags, lintMounts(dc)...)
+	diags = append(diags, lintImageTag(dc)...)
+	diags = append(diags, lintLifecycleTools(dc)...)
+
+	// Positions from Validate refer to the encoded configuration, so place
+	// every diagnostic in the source file instead, if there is one
+	for i := range diags {
+		diags[i].Line, diags[i].Column = 0, 0
+		if root == nil {
+			continue
+		}
+		if node, ok := root.resolve(diags[i].Pointer); ok {
+			diags[i].Line, diags[i].Column = lineColumn(source, node.Start)
+		}
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+	return diags, nil
+}
+
+// hasDiagnosticUnder reports whether a diagnostic points at or below pointer
+func hasDiagnosticUnder(diags Diagnostics, pointer string) bool {
+	for _, d := range diags {
+		if d.Pointer == pointer || strings.HasPrefix(d.Pointer, pointer+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// lintDeprecated flags top-level properties replaced by build.* and
+// forwardPorts
+func lintDeprecated(dc *DevContainer) []Diagnostic {
+	var diags []Diagnostic
+	if dc.DockerFile != "" || dc.DockerfileContainer != "" {
+		diags = append(diags, Diagnostic{Pointer: "/dockerFile", Severity: SeverityWarning,
+			Message: `"dockerFile" is deprecated, use "build.dockerfile"`, Suggestion: "build.dockerfile"})
+	}
+	if dc.Context != "" {
+		diags = append(diags, Diagnostic{Pointer: "/context", Severity: SeverityWarning,
+			Message: `"context" is deprecated, use "build.context"`, Suggestion: "build.context"})
+	}
+	if len(dc.AppPort) > 0 || (dc.NonComposeBase != nil && len(dc.NonComposeBase.AppPort) > 0) {
+		diags = append(diags, Diagnostic{Pointer: "/appPort", Severity: SeverityWarning,
+			Message: `"appPort" is deprecated, use "forwardPorts"`, Suggestion: "forwardPorts"})
+	}
+	return diags
+}
+
+// lintPrivileged flags privileged containers unless the property is
+// explained by a comment on the same or the preceding line
+func lintPrivileged(dc *DevContainer, source []byte, root *jsonNode) []Diagnostic {
+	if dc.Privileged == nil || !*dc.Privileged {
+		return nil
+	}
+	if root != nil {
+		if node, ok := root.resolve("/privileged"); ok && hasAdjacentComment(source, node) {
+			return nil
+		}
+	}
+	return []Diagnostic{{
+		Pointer:  "/privileged",
+		Severity: SeverityWarning,
+		Message:  "privileged containers have full access to the host; prefer capAdd or securityOpt, or add a comment explaining why it is needed",
+	}}
+}
+
+// hasAdjacentComment reports whether a comment is on the line of a member
+// or on the line above it
+func hasAdjacentComment(source []byte, node *jsonNode) bool {
+	lineStart := strings.LastIndexByte(string(source[:node.KeyStart]), '\n') + 1
+	lineEnd := len(source)
+	if i := strings.IndexByte(string(source[node.End:]), '\n'); i >= 0 {
+		lineEnd = node.End + i
+	}
+	if strings.Contains(string(source[node.End:lineEnd]), "//") || strings.Contains(string(source[node.End:lineEnd]), "/*") {
+		return true
+	}
+	if lineStart == 0 {
+		return false
+	}
+	prevStart := strings.LastIndexByte(string(source[:lineStart-1]), '\n') + 1
+	prev := strings.TrimSpace(string(source[prevStart : lineStart-1]))
+	return strings.HasPrefix(prev, "//") || strings.HasSuffix(prev, "*/")
+}
+
+// lintMounts flags bind mounts of absolute host paths, which only exist on
+// the machine the configuration was written on
+func lintMounts(dc *DevContainer) []Diagnostic {
+	var diags []Diagnostic
+	for i, m := range dc.Mounts {
+		typ, source := mountTypeAndSource(m)
+		if typ != MountTypeBind || portableHostPaths[source] {
+			continue
+		}
+		if strings.HasPrefix(source, "/") || windowsPathRe.MatchString(source) {
+			diags = append(diags, Diagnostic{
+				Pointer:  fmt.Sprintf("/mounts/%d", i),
+				Severity: SeverityWarning,
+				Message:  fmt.Sprintf("bind mount source %q is an absolute host path; use ${localWorkspaceFolder} or ${localEnv:HOME} so the mount works on other machines", source),
+			})
+		}
+	}
+	return diags
+}
+
+// mountTypeAndSource returns the type and source of a mount entry
+func mountTypeAndSource(m MountEntry) (string, string) {
+	if m.Object != nil {
+		return m.Object.Type, m.Object.Source
+	}
+	var typ, source string
+	for _, part := range strings.Split(m.Value, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "type":
+			typ = kv[1]
+		case "source", "src":
+			source = kv[1]
+		}
+	}
+	return typ, source
+}
+
+// lintImageTag flags images that are not pinned to a tag or digest
+func lintImageTag(dc *DevContainer) []Diagnostic {
+	image := dc.imageName()
+	if image == "" || strings.Contains(image, "@") || strings.Contains(image, "${") {
+		return nil
+	}
+	var msg string
+	name := image[strings.LastIndex(image, "/")+1:]
+	if i := strings.LastIndex(name, ":"); i < 0 {
+		msg = fmt.Sprintf("image %q has no tag and resolves to latest", image)
+	} else if name[i+1:] == "latest" {
+		msg = fmt.Sprintf("image %q uses the latest tag", image)
+	} else {
+		return nil
+	}
+	return []Diagnostic{{
+		Pointer:  "/image",
+		Severity: SeverityWarning,
+		Message:  msg + "; pin a version so rebuilds are reproducible",
+	}}
+}
+
+// lintLifecycleTools flags lifecycle commands whose program is provided by
+// neither a declared feature nor the image. Configurations built from a
+// Dockerfile or Compose are skipped since those may install anything.
+func lintLifecycleTools(dc *DevContainer) []Diagnostic {
+	image := dc.imageName()
+	if image == "" || dc.dockerfilePath() != "" {
+		return nil
+	}
+
+	provided := map[string]bool{}
+	for _, token := range imageNameSepRe.Split(strings.ToLower(image), -1) {
+		provided[token] = true
+	}
+	if provided["universal"] {
+		return nil
+	}
+	for _, id := range featureIDs(dc.Features) {
+		provided[id] = true
+	}
+
+	var diags []Diagnostic
+	for _, phase := range lifecyclePhases {
+		for _, use := range commandPrograms(phase.command(dc), "/"+phase.name) {
+			providers, known := toolProviders[use.program]
+			if !known || anyProvided(providers, provided) {
+				continue
+			}
+			diags = append(diags, Diagnostic{
+				Pointer:  use.pointer,
+				Severity: SeverityInfo,
+				Message:  fmt.Sprintf("%s runs %q, which is not installed by the image or any declared feature; consider adding the %q feature", phase.name, use.program, providers[0]),
+			})
+		}
+	}
+	return diags
+}
+
+func anyProvided(providers []string, provided map[string]bool) bool {
+	for _, p := range providers {
+		if provided[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// featureIDs returns the short names of the declared features, e.g. "node"
+// for "ghcr.io/devcontainers/features/node:1"
+func featureIDs(f *DevContainerCommonFeatures) []string {
+	if f == nil {
+		return nil
+	}
+	var ids []string
+	for name, version := range map[string]string{"fish": f.Fish, "gradle": f.Gradle, "maven": f.Maven} {
+		if version != "" {
+			ids = append(ids, name)
+		}
+	}
+	for ref := range f.AdditionalProperties {
+		name := path.Base(ref)
+		if i := strings.IndexAny(name, ":@"); i >= 0 {
+			name = name[:i]
+		}
+		ids = append(ids, strings.ToLower(name))
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// programUse is a program started by a lifecycle command
+type programUse struct {
+	program string
+	pointer string
+}
+
+// commandPrograms returns the programs a lifecycle command starts
+func commandPrograms(lc *LifecycleCommand, pointer string) []programUse {
+	if lc == nil {
+		return nil
+	}
+	var uses []programUse
+	switch lc.Type {
+	case CommandTypeString:
+		for _, segment := range commandSeparatorRe.Split(lc.Command, -1) {
+			if program := segmentProgram(strings.Fields(segment)); program != "" {
+				uses = append(uses, programUse{program, pointer})
+			}
+		}
+	case CommandTypeArray:
+		if program := segmentProgram(lc.Args); program != "" {
+			uses = append(uses, programUse{program, pointer})
+		}
+	case CommandTypeObject:
+		for _, name := range sortedKeys(lc.Commands) {
+			uses = append(uses, commandPrograms(lc.Commands[name], joinPointer(pointer, name))...)
+		}
+	case CommandTypeSequence:
+		for _, cmd := range lc.Sequence {
+			uses = append(uses, commandPrograms(cmd, pointer)...)
+		}
+	}
+	return uses
+}
+
+// segmentProgram returns the program of a simple command, skipping
+// environment assignments and wrappers such as sudo
+func segmentProgram(words []string) string {
+	for _, w := range words {
+		switch {
+		case envAssignmentRe.MatchString(w):
+			continue
+		case w == "sudo" || w == "exec" || w == "time" || w == "env" || w == "(" || w == "{":
+			continue
+		case strings.HasPrefix(w, "-"):
+			// Options of a wrapper, e.g. sudo -E
+			continue
+		}
+		return filepath.Base(strings.TrimLeft(w, "({"))
+	}
+	return ""
+}
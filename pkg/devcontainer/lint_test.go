@@ -0,0 +1,96 @@
+package devcontainer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	source := `{
+  "image": "node",
+  "appPort": 3000,
+  "context": "..",
+  "privileged": true,
+  "mounts": [
+    "source=/Users/me/.ssh,target=/root/.ssh,type=bind",
+    "source=/var/run/docker.sock,target=/var/run/docker.sock,type=bind",
+    {"type": "bind", "source": "${localEnv:HOME}/.aws", "target": "/root/.aws"}
+  ],
+  "postCreateCommand": "npm ci && sudo -E pip install -r requirements.txt",
+  "postStartCommand": {"lint": ["golangci-lint", "run"], "api": "go run ./cmd/api"},
+  "features": {"ghcr.io/devcontainers/features/python:1": {}},
+  "hostRequirements": {"cpus": 0}
+}`
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dc, err := LoadDevContainer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	diags, err := Lint(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		`2:12: warning: /image: image "node" has no tag and resolves to latest; pin a version so rebuilds are reproducible`,
+		`3:14: warning: /appPort: "appPort" is deprecated, use "forwardPorts" (did you mean "forwardPorts"?)`,
+		`4:14: warning: /context: "context" is deprecated, use "build.context" (did you mean "build.context"?)`,
+		`5:17: warning: /privileged: privileged containers have full access to the host; prefer capAdd or securityOpt, or add a comment explaining why it is needed`,
+		`7:5: warning: /mounts/0: bind mount source "/Users/me/.ssh" is an absolute host path; use ${localWorkspaceFolder} or ${localEnv:HOME} so the mount works on other machines`,
+		`12:65: info: /postStartCommand/api: postStartCommand runs "go", which is not installed by the image or any declared feature; consider adding the "go" feature`,
+		`14:32: error: /hostRequirements/cpus: must be >= 1 but found 0`,
+	}
+	var got []string
+	for _, d := range diags {
+		got = append(got, d.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("unexpected diagnostics\nwant:\n%s\ngot:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestLintPrivilegedWithReason(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	source := `{
+  "image": "mcr.microsoft.com/devcontainers/base:ubuntu-22.04",
+  // Needed to run nested containers with systemd
+  "privileged": true
+}`
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	dc, err := LoadDevContainer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags, err := Lint(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 0 {
+		t.Errorf("expected no diagnostics, got %v", diags)
+	}
+}
+
+func TestLintWithoutSource(t *testing.T) {
+	dc := &DevContainer{
+		ImageContainer: &ImageContainer{Image: "golang:latest"},
+		DevContainerCommon: DevContainerCommon{
+			DockerFile:        "Dockerfile",
+			PostCreateCommand: StringCommand("go mod download"),
+		},
+	}
+	diags, err := Lint(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 2 || diags[0].Pointer != "/dockerFile" || diags[1].Pointer != "/image" || diags[0].Line != 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
@@ -0,0 +1,52 @@
+package devcontainer
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+// LogOptions selects the output Logs streams
+type LogOptions struct {
+	Follow     bool      // Keep streaming until the container stops or ctx is done
+	Since      time.Time // Only output after Since, if set
+	Until      time.Time // Only output before Until, if set
+	Timestamps bool      // Prefix lines with their RFC 3339 timestamp
+	Tail       int       // Only the last Tail lines; all if zero or negative
+}
+
+// Logs streams the output of a container to stdout and stderr. Containers
+// with a TTY, like those Create makes, have a single stream: all of it goes
+// to stdout. A nil writer discards its stream. Following ends without error
+// when ctx is done.
+func (m *Manager) Logs(ctx context.Context, containerID string, opts LogOptions, stdout, stderr io.Writer) error {
+	if stdout == nil {
+		stdout = io.Discard
+	}
+	if stderr == nil {
+		stderr = io.Discard
+	}
+	return m.docker.ContainerLogs(ctx, containerID, opts, stdout, stderr)
+}
+
+// copyLogs copies the log stream of the Docker API to stdout and stderr. A
+// TTY stream is raw output; otherwise it is multiplexed in frames, which
+// may hold several lines or part of one.
+func copyLogs(r io.Reader, tty bool, stdout, stderr io.Writer) error {
+	if tty {
+		_, err := io.Copy(stdout, r)
+		return err
+	}
+	_, err := stdcopy.StdCopy(stdout, stderr, r)
+	return err
+}
+
+// logTime formats a LogOptions time for the engine, "" if unset
+func logTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339Nano)
+}
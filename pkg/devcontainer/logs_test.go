@@ -0,0 +1,101 @@
+package devcontainer
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/pkg/stdcopy"
+)
+
+func TestCopyLogs(t *testing.T) {
+	// Frames do not follow lines: one holds several, another ends mid-line
+	var stream bytes.Buffer
+	stdoutFrames := stdcopy.NewStdWriter(&stream, stdcopy.Stdout)
+	stderrFrames := stdcopy.NewStdWriter(&stream, stdcopy.Stderr)
+	stdoutFrames.Write([]byte("one\ntwo\npar"))
+	stderrFrames.Write([]byte("oops\n"))
+	stdoutFrames.Write([]byte("tial\n"))
+	multiplexed := stream.Bytes()
+
+	var stdout, stderr bytes.Buffer
+	if err := copyLogs(bytes.NewReader(multiplexed), false, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "one\ntwo\npartial\n" || stderr.String() != "oops\n" {
+		t.Errorf("unexpected streams %q and %q", stdout.String(), stderr.String())
+	}
+
+	// TTY output is raw, even if it looks like a frame header
+	stdout.Reset()
+	if err := copyLogs(bytes.NewReader(multiplexed), true, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(stdout.Bytes(), multiplexed) {
+		t.Errorf("expected raw output, got %q", stdout.String())
+	}
+}
+
+func TestManagerLogs(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine, id := newFakeContainer(t, `{"image": "alpine:3.20"}`, "alpine:3.20")
+	if err := engine.SetLogs(id, "one\ntwo\nthree\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout bytes.Buffer
+	if err := mgr.Logs(ctx, id, LogOptions{Tail: 1, Timestamps: true}, &stdout, nil); err != nil {
+		t.Fatal(err)
+	}
+	if want := "2024-01-01T00:00:00Z three\n"; stdout.String() != want {
+		t.Errorf("expected %q, got %q", want, stdout.String())
+	}
+
+	// Following a running container ends when it stops
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- mgr.Logs(ctx, id, LogOptions{Follow: true}, nil, nil)
+	}()
+	select {
+	case err := <-done:
+		t.Fatalf("expected to follow the running container, got %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	if err := mgr.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected following to end when the container stopped")
+	}
+}
+
+func TestCLIEngineContainerLogs(t *testing.T) {
+	ctx := context.Background()
+	engine, calls := newFakeCLI(t)
+
+	var stdout, stderr bytes.Buffer
+	opts := LogOptions{
+		Follow:     true,
+		Timestamps: true,
+		Since:      time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Tail:       10,
+	}
+	if err := engine.ContainerLogs(ctx, "c1", opts, &stdout, &stderr); err != nil {
+		t.Fatal(err)
+	}
+	if stdout.String() != "out\n" || stderr.String() != "err\n" {
+		t.Errorf("expected separate streams, got %q and %q", stdout.String(), stderr.String())
+	}
+	if got, want := calls()[0], "logs --follow --timestamps --since 2024-01-01T00:00:00Z --tail 10 c1"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
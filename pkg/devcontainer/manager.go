@@ -2,20 +2,25 @@ package devcontainer
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/colony-2/devcontainer-go/pkg/api"
-	"path/filepath"
+	"os"
 	"strings"
+	"time"
 )
 
 // Manager implements the container.Manager interface using devcontainers
 type Manager struct {
-	docker       *DockerClient
+	docker       Engine
 	devContainer *DevContainer // Optional pre-configured devcontainer
-	dockerClient *DockerClient // Alias for consistency with terminal.go
+	dockerClient *DockerClient // Set when the engine is a DockerClient, for terminal.go
 	customMounts []api.Mount   // Custom mount configurations
+	events       eventBus      // Events of operations, see Events
 }
 
+var _ api.Manager = (*Manager)(nil)
+
 // NewManager creates a new devcontainer manager
 func NewManager() (*Manager, error) {
 	docker, err := NewDockerClient()
@@ -29,37 +34,39 @@ func NewManager() (*Manager, error) {
 	}, nil
 }
 
+// NewManagerWithEngine creates a devcontainer manager driving engine, e.g. a
+// FakeEngine in tests
+func NewManagerWithEngine(engine Engine) *Manager {
+	docker, _ := engine.(*DockerClient)
+	return &Manager{
+		docker:       engine,
+		dockerClient: docker,
+	}
+}
+
 // SetDevContainer sets a pre-configured devcontainer for the manager
 func (m *Manager) SetDevContainer(dc *DevContainer) {
 	m.devContainer = dc
 }
 
-// Create creates a new container for the specified node
-func (m *Manager) Create(ctx context.Context, nodePath string) (string, error) {
-	var dc *DevContainer
-
-	// Use pre-configured devcontainer if available
-	if m.devContainer != nil {
-		dc = m.devContainer
-	} else {
-		// Look for devcontainer.json in the node path
-		devcontainerPath := filepath.Join(nodePath, ".devcontainer", "devcontainer.json")
-
-		// Load devcontainer configuration
-		var err error
-		dc, err = LoadDevContainer(devcontainerPath)
-		if err != nil {
-			// If no devcontainer.json, use a default configuration
-			dc = &DevContainer{
-				ImageContainer: &ImageContainer{
-					Image: "alpine:latest",
-				},
-				DevContainerCommon: DevContainerCommon{
-					WorkspaceFolder: "/workspace",
-				},
-			}
-		}
+// Create creates a new container for the specified node. Without
+// api.WithConfigPath the default configuration of the node path is used (see
+// FindDevContainerFile); use DiscoverDevContainers to list the alternatives.
+// api.WithProfiles applies named profiles on top of it (see ApplyProfiles).
+func (m *Manager) Create(ctx context.Context, nodePath string, opts ...api.CreateOption) (string, error) {
+	options := api.ApplyCreateOptions(opts...)
+	dc, err := m.loadDevContainer(ctx, nodePath, options)
+	if err != nil {
+		return "", err
+	}
+	// Labels are computed before the image metadata is merged, so that the
+	// config hash only depends on the configuration loaded
+	labels := ResourceLabels(dc, nodePath)
 
+	// Validate or build the image and merge its metadata under the config
+	dc, err = m.prepareImage(ctx, dc, nodePath)
+	if err != nil {
+		return "", err
 	}
 
 	// Apply custom mounts if configured
@@ -74,21 +81,290 @@ func (m *Manager) Create(ctx context.Context, nodePath string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to build docker config: %w", err)
 	}
+	config.Labels = mergeMaps(config.Labels, labels)
 
-	// Validate the image exists
-	if err := m.docker.ValidateImage(ctx, config.Image); err != nil {
-		return "", fmt.Errorf("invalid image: %w", err)
+	// Like the reference CLI, record the merged metadata on the container so
+	// its remote user is known without the configuration
+	metadata, err := ImageMetadataLabel([]*DevContainer{dc})
+	if err != nil {
+		return "", err
+	}
+	config.Labels[LabelMetadata] = metadata
+
+	// A remote daemon cannot bind mount the workspace, it is copied into a
+	// volume once the container exists
+	remote := m.remoteWorkspace(options) && hasDefaultWorkspaceMount(dc)
+	volume, createdVolume := "", false
+	if remote {
+		volume = WorkspaceVolumeName(dc, nodePath)
+		exists, err := m.docker.VolumeExists(ctx, volume)
+		if err != nil {
+			return "", err
+		}
+		if err := m.docker.CreateLabeledVolume(ctx, volume, labels); err != nil {
+			return "", err
+		}
+		createdVolume = !exists
+		config.WorkspaceMount = fmt.Sprintf("type=volume,source=%s,target=%s", volume, config.WorkspaceFolder)
+		config.Labels[LabelWorkspaceVolume] = volume
 	}
 
 	// Create the container
-	containerID, err := m.docker.CreateContainer(ctx, config)
+	var containerID string
+	event := Event{Type: EventCreate, Image: config.Image, Labels: IDLabels(nodePath, dc.ConfigFilePath)}
+	event.Action = ActionStarted
+	m.emit(event)
+	containerID, err = m.docker.CreateContainer(ctx, config)
 	if err != nil {
+		event.Action, event.Err = ActionFailed, err
+		m.emit(event)
 		return "", fmt.Errorf("failed to create container: %w", err)
 	}
+	event.Action, event.ContainerID = ActionFinished, containerID
+	m.emit(event)
+
+	if remote {
+		if _, err := m.uploadWorkspace(ctx, containerID, dc, nodePath); err != nil {
+			// Leave nothing half set up behind, keeping a volume that
+			// existed before
+			m.docker.RemoveContainer(ctx, containerID)
+			if createdVolume {
+				m.docker.RemoveVolume(ctx, volume)
+			}
+			return "", err
+		}
+	}
 
 	return containerID, nil
 }
 
+// Build prepares the image Create would use for the specified node, building
+// its Dockerfile if there is one, and returns the image name
+func (m *Manager) Build(ctx context.Context, nodePath string, opts ...api.CreateOption) (string, error) {
+	dc, err := m.loadDevContainer(ctx, nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return "", err
+	}
+	dc, err = m.prepareImage(ctx, dc, nodePath)
+	if err != nil {
+		return "", err
+	}
+	if dc.imageName() == "" {
+		return "", fmt.Errorf("no image specified")
+	}
+	return dc.imageName(), nil
+}
+
+// FindContainer returns the most recent container created for the
+// configuration Create would use for the specified node, or "" if there is
+// none. Containers are identified by their IDLabels.
+func (m *Manager) FindContainer(ctx context.Context, nodePath string, opts ...api.CreateOption) (string, error) {
+	dc, err := m.loadDevContainer(ctx, nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return "", err
+	}
+	ids, err := m.docker.FindContainers(ctx, IDLabels(nodePath, dc.ConfigFilePath))
+	if err != nil || len(ids) == 0 {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// DevContainerID returns the stable ${devcontainerId} for the configuration
+// that Create would use for the specified node, or "" if the configuration
+// cannot be loaded
+func (m *Manager) DevContainerID(nodePath string, opts ...api.CreateOption) string {
+	dc, err := m.loadDevContainer(context.Background(), nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return ""
+	}
+	return dc.DevContainerID(nodePath)
+}
+
+// ConfigHash returns the hash of the configuration Create would use for the
+// specified node, to compare with the LabelConfigHash of its containers
+func (m *Manager) ConfigHash(nodePath string, opts ...api.CreateOption) (string, error) {
+	dc, err := m.loadDevContainer(context.Background(), nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return "", err
+	}
+	return ConfigHash(dc), nil
+}
+
+// ReadConfiguration returns the resolved configuration Create would use for
+// the specified node (see ReadConfiguration). Without a Docker client the
+// image metadata is left out of the merged configuration.
+func (m *Manager) ReadConfiguration(ctx context.Context, nodePath string, opts ...api.CreateOption) (*ReadConfigurationResult, error) {
+	dc, err := m.loadDevContainer(ctx, nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+
+	var metadata []*DevContainer
+	if m.docker != nil {
+		if metadata, err = m.imageMetadata(ctx, dc, nodePath); err != nil {
+			return nil, err
+		}
+	}
+	return ReadConfiguration(dc, nodePath, metadata)
+}
+
+// imageMetadata returns the devcontainer.metadata of the image of dc, or of
+// the base image of its Dockerfile, pulling the image if needed
+func (m *Manager) imageMetadata(ctx context.Context, dc *DevContainer, nodePath string) ([]*DevContainer, error) {
+	image := dc.imageName()
+	if image == "" && dc.dockerfilePath() != "" {
+		configDir := dc.configDir(nodePath)
+		content, err := os.ReadFile(resolveConfigPath(configDir, dc.dockerfilePath()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read Dockerfile: %w", err)
+		}
+		image = DockerfileBaseImage(string(content), dc.Build.Target, dc.Build.Args)
+	}
+	if image == "" {
+		return nil, nil
+	}
+	if err := m.docker.ValidateImage(ctx, image); err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+	return m.docker.GetImageMetadata(ctx, image)
+}
+
+// loadDevContainer returns the configuration to use for the specified node,
+// with the selected profiles applied
+func (m *Manager) loadDevContainer(ctx context.Context, nodePath string, opts api.CreateOptions) (*DevContainer, error) {
+	dc, err := m.loadBaseDevContainer(ctx, nodePath, opts)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyProfiles(dc, opts.Profiles...)
+}
+
+// loadBaseDevContainer returns the configuration selected for the node
+func (m *Manager) loadBaseDevContainer(ctx context.Context, nodePath string, opts api.CreateOptions) (*DevContainer, error) {
+	// Use pre-configured devcontainer if available
+	if m.devContainer != nil && opts.ConfigPath == "" {
+		return m.devContainer, nil
+	}
+
+	devcontainerPath := opts.ConfigPath
+	if devcontainerPath != "" {
+		devcontainerPath = resolveConfigPath(nodePath, devcontainerPath)
+	} else {
+		path, err := FindDevContainerFile(nodePath)
+		if err != nil {
+			return nil, err
+		}
+		devcontainerPath = path
+	}
+
+	if devcontainerPath == "" {
+		// If no devcontainer.json, use a default configuration
+		return &DevContainer{
+			ImageContainer: &ImageContainer{
+				Image: "alpine:latest",
+			},
+			DevContainerCommon: DevContainerCommon{
+				WorkspaceFolder: "/workspace",
+			},
+		}, nil
+	}
+
+	dc, err := LoadDevContainerWithExtendsContext(ctx, devcontainerPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	return dc, nil
+}
+
+// prepareImage makes sure the image for dc is available, building it from a
+// Dockerfile when needed, and merges the image's devcontainer.metadata label
+// under the local configuration
+func (m *Manager) prepareImage(ctx context.Context, dc *DevContainer, nodePath string) (*DevContainer, error) {
+	image := dc.imageName()
+	if image == "" && dc.dockerfilePath() != "" {
+		return m.buildImage(ctx, dc, nodePath)
+	}
+	if image == "" {
+		// Leave it to BuildDockerRunCommand to report the missing image
+		return dc, nil
+	}
+
+	// Validate the image exists
+	if err := m.pullImage(ctx, image, IDLabels(nodePath, dc.ConfigFilePath)); err != nil {
+		return nil, fmt.Errorf("invalid image: %w", err)
+	}
+
+	metadata, err := m.docker.GetImageMetadata(ctx, image)
+	if err != nil {
+		return nil, err
+	}
+	return MergeImageMetadata(metadata, dc), nil
+}
+
+// buildImage builds the Dockerfile of dc and returns the merged configuration
+// pointing at the built image. The image is labeled with the metadata of its
+// base image followed by the local configuration.
+func (m *Manager) buildImage(ctx context.Context, dc *DevContainer, nodePath string) (*DevContainer, error) {
+	configDir := dc.configDir(nodePath)
+	dockerfile := resolveConfigPath(configDir, dc.dockerfilePath())
+	contextDir := configDir
+	if buildContext := lastString(dc.Context, dc.Build.Context); buildContext != "" {
+		contextDir = resolveConfigPath(configDir, buildContext)
+	}
+
+	content, err := os.ReadFile(dockerfile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Dockerfile: %w", err)
+	}
+
+	var metadata []*DevContainer
+	if base := DockerfileBaseImage(string(content), dc.Build.Target, dc.Build.Args); base != "" {
+		if err := m.pullImage(ctx, base, IDLabels(nodePath, dc.ConfigFilePath)); err != nil {
+			return nil, fmt.Errorf("invalid base image: %w", err)
+		}
+		if metadata, err = m.docker.GetImageMetadata(ctx, base); err != nil {
+			return nil, err
+		}
+	}
+
+	label, err := ImageMetadataLabel(append(append([]*DevContainer{}, metadata...), dc))
+	if err != nil {
+		return nil, err
+	}
+
+	labels := ResourceLabels(dc, nodePath)
+	labels[LabelMetadata] = label
+
+	tag := "devcontainer-go-" + dc.DevContainerID(nodePath)
+	err = m.step(Event{Type: EventBuild, Image: tag, Labels: IDLabels(nodePath, dc.ConfigFilePath)}, func() error {
+		return m.docker.BuildImage(ctx, &ImageBuildConfig{
+			ContextDir: contextDir,
+			Dockerfile: dockerfile,
+			Tag:        tag,
+			Target:     dc.Build.Target,
+			Args:       dc.Build.Args,
+			CacheFrom:  dc.Build.CacheFrom,
+			Labels:     labels,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	merged := MergeImageMetadata(metadata, dc)
+	merged.Image = tag
+	merged.ImageContainer = &ImageContainer{Image: tag}
+	return merged, nil
+}
+
+// pullImage makes sure an image is present, pulling it if needed
+func (m *Manager) pullImage(ctx context.Context, image string, labels map[string]string) error {
+	return m.step(Event{Type: EventPull, Image: image, Labels: labels}, func() error {
+		return m.docker.ValidateImage(ctx, image)
+	})
+}
+
 // Start starts an existing container
 func (m *Manager) Start(ctx context.Context, containerID string) error {
 	return m.docker.StartContainer(ctx, containerID)
@@ -114,30 +390,127 @@ func (m *Manager) Remove(ctx context.Context, containerID string) error {
 
 // GetInfo returns information about a container
 func (m *Manager) GetInfo(ctx context.Context, containerID string) (*api.Info, error) {
-	status, err := m.docker.GetContainerStatus(ctx, containerID)
+	details, err := m.docker.InspectContainer(ctx, containerID)
 	if err != nil {
 		return nil, err
 	}
+	return m.containerInfo(ctx, details), nil
+}
 
-	return &api.Info{
-		ID:     containerID,
-		Status: mapDockerStatus(status),
-	}, nil
+// List returns the containers created by the manager that match filter, most
+// recent first. Containers are recognized by their LabelLocalFolder.
+func (m *Manager) List(ctx context.Context, filter api.ListFilter) ([]api.Info, error) {
+	labels := mergeMaps(filter.Labels, nil)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	labels[LabelLocalFolder] = ""
+	if filter.WorkspaceFolder != "" {
+		labels[LabelLocalFolder] = absPath(filter.WorkspaceFolder)
+	}
+	if filter.ConfigPath != "" {
+		labels[LabelConfigFile] = absPath(filter.ConfigPath)
+	}
+
+	ids, err := m.docker.FindContainers(ctx, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos []api.Info
+	for _, id := range ids {
+		details, err := m.docker.InspectContainer(ctx, id)
+		if errors.Is(err, ErrNoSuchContainer) {
+			continue // Removed since it was found
+		}
+		if err != nil {
+			return nil, err
+		}
+		info := m.containerInfo(ctx, details)
+		if filter.DevContainerID != "" && info.DevContainerID != filter.DevContainerID {
+			continue
+		}
+		if filter.Status != "" && info.Status != filter.Status {
+			continue
+		}
+		infos = append(infos, *info)
+	}
+	return infos, nil
+}
+
+// FindByWorkspace returns the containers created for a local workspace
+// folder, most recent first
+func (m *Manager) FindByWorkspace(ctx context.Context, workspaceFolder string) ([]api.Info, error) {
+	return m.List(ctx, api.ListFilter{WorkspaceFolder: workspaceFolder})
+}
+
+// containerInfo returns the api.Info of an inspected container with its
+// remote user. The remote user falls back to the user of the container when
+// its configuration cannot be resolved anymore.
+func (m *Manager) containerInfo(ctx context.Context, details *ContainerDetails) *api.Info {
+	info := containerInfo(details)
+	if user, err := m.detailsRemoteUser(ctx, details); err == nil && user != "" {
+		info.RemoteUser = user
+	}
+	return info
+}
+
+// containerInfo returns the api.Info of an inspected container
+func containerInfo(details *ContainerDetails) *api.Info {
+	info := &api.Info{
+		ID:              details.ID,
+		Name:            strings.TrimPrefix(details.Name, "/"),
+		Status:          mapDockerStatus(details.Status),
+		Image:           details.Image,
+		Ports:           details.Ports,
+		Labels:          details.Labels,
+		ExitCode:        details.ExitCode,
+		OOMKilled:       details.OOMKilled,
+		Health:          details.Health,
+		RemoteUser:      details.User,
+		WorkspaceFolder: details.Labels[LabelLocalFolder],
+		ConfigPath:      details.Labels[LabelConfigFile],
+		DevContainerID:  details.Labels[LabelDevContainerID],
+		ConfigHash:      details.Labels[LabelConfigHash],
+		Version:         details.Labels[LabelVersion],
+	}
+	info.Created = formatTime(details.Created)
+	info.StartedAt = formatTime(details.StartedAt)
+	info.FinishedAt = formatTime(details.FinishedAt)
+	for _, mount := range details.Mounts {
+		info.Mounts = append(info.Mounts, api.Mount{
+			Type:     mount.Type,
+			Source:   mount.Source,
+			Target:   mount.Target,
+			ReadOnly: mount.ReadOnly,
+		})
+	}
+	// Containers of the reference CLI only have the identifying labels
+	if info.DevContainerID == "" && info.WorkspaceFolder != "" {
+		info.DevContainerID = ComputeDevContainerID(IDLabels(info.WorkspaceFolder, info.ConfigPath))
+	}
+	return info
 }
 
 // GetStatus returns the current status of a container
 func (m *Manager) GetStatus(ctx context.Context, containerID string) (api.Status, error) {
-	status, err := m.docker.GetContainerStatus(ctx, containerID)
+	details, err := m.docker.InspectContainer(ctx, containerID)
 	if err != nil {
 		return api.StatusNone, err
 	}
 
-	return mapDockerStatus(status), nil
+	return mapDockerStatus(details.Status), nil
 }
 
 // Exec executes a command in a running container
 func (m *Manager) Exec(ctx context.Context, containerID string, command []string) (string, error) {
-	return m.docker.ExecInContainer(ctx, containerID, command)
+	return m.docker.ExecAs(ctx, containerID, ExecOptions{}, command)
+}
+
+// ExecWithOptions executes a command in a running container as a user, in
+// a working directory or with extra environment variables
+func (m *Manager) ExecWithOptions(ctx context.Context, containerID string, opts ExecOptions, command []string) (string, error) {
+	return m.docker.ExecAs(ctx, containerID, opts, command)
 }
 
 // AttachWebSocket attaches a WebSocket for terminal access
@@ -156,40 +529,34 @@ func (m *Manager) ConfigureMounts(mounts []api.Mount) error {
 // applyCustomMounts applies custom mount configurations to a DevContainer
 func (m *Manager) applyCustomMounts(dc *DevContainer) error {
 	// Build custom mounts in devcontainer object format (object style)
-	var custom []interface{}
+	var custom []MountEntry
 	for _, mount := range m.customMounts {
-		custom = append(custom, map[string]interface{}{
-			"type":     mount.Type,
-			"source":   mount.Source,
-			"target":   mount.Target,
-			"readonly": mount.ReadOnly,
-		})
+		custom = append(custom, MountObject(Mount{
+			Type:     mount.Type,
+			Source:   mount.Source,
+			Target:   mount.Target,
+			ReadOnly: mount.ReadOnly,
+		}))
 	}
 
 	// Merge: preserve mounts declared in devcontainer.json and append custom mounts.
 	// If there are duplicate object-style targets, prefer custom by removing earlier duplicates.
 	// Note: string-style mounts are kept as-is (cannot safely de-dup without parsing).
-	var merged []interface{}
+	var merged []MountEntry
 
 	// Track targets we will override to avoid duplicates
 	targets := map[string]bool{}
 	for _, cm := range custom {
-		if m, ok := cm.(map[string]interface{}); ok {
-			if tgt, ok := m["target"].(string); ok && tgt != "" {
-				targets[tgt] = true
-			}
+		if cm.Object.Target != "" {
+			targets[cm.Object.Target] = true
 		}
 	}
 
 	// First, copy existing mounts that are not overridden by a custom mount (object-style)
 	for _, em := range dc.Mounts {
-		if mobj, ok := em.(map[string]interface{}); ok {
-			if tgt, ok := mobj["target"].(string); ok && tgt != "" {
-				if targets[tgt] {
-					// Skip, will be provided by custom
-					continue
-				}
-			}
+		if em.Object != nil && targets[em.Object.Target] {
+			// Skip, will be provided by custom
+			continue
 		}
 		merged = append(merged, em)
 	}
@@ -218,11 +585,25 @@ func (m *Manager) Close() error {
 	return nil
 }
 
+// formatTime formats t as RFC 3339 in UTC, or "" for the zero time
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
 // mapDockerStatus maps Docker status to container.Status
 func mapDockerStatus(dockerStatus string) api.Status {
 	switch strings.ToLower(dockerStatus) {
+	case "created", "configured": // Podman reports configured before init
+		return api.StatusCreated
 	case "running":
 		return api.StatusRunning
+	case "paused":
+		return api.StatusPaused
+	case "restarting":
+		return api.StatusRestarting
 	case "exited", "stopped":
 		return api.StatusStopped
 	case "error", "dead":
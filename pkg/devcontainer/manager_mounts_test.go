@@ -12,9 +12,9 @@ func TestApplyCustomMountsMergesExisting(t *testing.T) {
 	dc := &DevContainer{}
 
 	// Existing devcontainer mounts (object + string)
-	dc.Mounts = []interface{}{
-		map[string]interface{}{"type": "bind", "source": "/host/a", "target": "/container/a", "readonly": true},
-		"type=volume,source=vol1,target=/container/vol1",
+	dc.Mounts = []MountEntry{
+		MountObject(Mount{Type: "bind", Source: "/host/a", Target: "/container/a", ReadOnly: true}),
+		MountString("type=volume,source=vol1,target=/container/vol1"),
 	}
 
 	// Custom mounts include one overriding /container/a and one new
@@ -30,22 +30,21 @@ func TestApplyCustomMountsMergesExisting(t *testing.T) {
 	foundA := false
 	foundB := false
 	for _, m := range dc.Mounts {
-		switch v := m.(type) {
-		case string:
-			if v == "type=volume,source=vol1,target=/container/vol1" {
+		if m.Object == nil {
+			if m.Value == "type=volume,source=vol1,target=/container/vol1" {
 				foundString = true
 			}
-		case map[string]interface{}:
-			if tgt, _ := v["target"].(string); tgt == "/container/a" {
-				// Should be overridden by custom
-				if src, _ := v["source"].(string); src == "/host/a-new" {
-					foundA = true
-				}
-			}
-			if tgt, _ := v["target"].(string); tgt == "/container/b" {
-				foundB = true
+			continue
+		}
+		if m.Object.Target == "/container/a" {
+			// Should be overridden by custom
+			if m.Object.Source == "/host/a-new" {
+				foundA = true
 			}
 		}
+		if m.Object.Target == "/container/b" {
+			foundB = true
+		}
 	}
 
 	require.True(t, foundString, "existing string mount should be preserved")
@@ -0,0 +1,595 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// mergeConfigs merges override on top of base using the devcontainer spec's
+// merge rules: scalars are last-wins, capAdd/securityOpt/forwardPorts are
+// unioned, init/privileged are true if any source sets them, mounts are
+// de-duplicated by target, lifecycle commands accumulate in order and
+// hostRequirements take the larger value. Neither input is modified.
+func mergeConfigs(base, override *DevContainer) *DevContainer {
+	result := *base
+
+	// Container type fields
+	result.ImageContainer = lastPtr(base.ImageContainer, override.ImageContainer)
+	result.DockerfileContainer = lastString(base.DockerfileContainer, override.DockerfileContainer)
+	result.ComposeContainer = lastPtr(base.ComposeContainer, override.ComposeContainer)
+	if override.DockerComposeFile != nil {
+		result.DockerComposeFile = override.DockerComposeFile
+	}
+	result.Service = lastString(base.Service, override.Service)
+	if override.RunServices != nil {
+		result.RunServices = override.RunServices
+	}
+	result.ConfigFilePath = lastString(base.ConfigFilePath, override.ConfigFilePath)
+
+	// Basic container configuration
+	result.Image = lastString(base.Image, override.Image)
+	result.DockerFile = lastString(base.DockerFile, override.DockerFile)
+	result.Build = mergeBuild(base.Build, override.Build)
+	result.Context = lastString(base.Context, override.Context)
+	result.WorkspaceFolder = lastString(base.WorkspaceFolder, override.WorkspaceFolder)
+	result.WorkspaceMount = lastString(base.WorkspaceMount, override.WorkspaceMount)
+
+	// Environment is merged per variable
+	result.ContainerEnv = mergeMaps(base.ContainerEnv, override.ContainerEnv)
+	result.RemoteEnv = mergeRemoteEnv(base.RemoteEnv, override.RemoteEnv)
+
+	// User configuration
+	result.ContainerUser = lastPtr(base.ContainerUser, override.ContainerUser)
+	result.RemoteUser = lastPtr(base.RemoteUser, override.RemoteUser)
+
+	// Ports
+	result.ForwardPorts = unionPorts(base.ForwardPorts, override.ForwardPorts)
+	if override.AppPort != nil {
+		result.AppPort = override.AppPort
+	}
+	result.PortsAttributes = mergeMaps(base.PortsAttributes, override.PortsAttributes)
+	result.OtherPortsAttributes = lastPtr(base.OtherPortsAttributes, override.OtherPortsAttributes)
+
+	// Lifecycle commands accumulate
+	result.InitializeCommand = appendLifecycle(base.InitializeCommand, override.InitializeCommand)
+	result.OnCreateCommand = appendLifecycle(base.OnCreateCommand, override.OnCreateCommand)
+	result.UpdateContentCommand = appendLifecycle(base.UpdateContentCommand, override.UpdateContentCommand)
+	result.PostCreateCommand = appendLifecycle(base.PostCreateCommand, override.PostCreateCommand)
+	result.PostStartCommand = appendLifecycle(base.PostStartCommand, override.PostStartCommand)
+	result.PostAttachCommand = appendLifecycle(base.PostAttachCommand, override.PostAttachCommand)
+	result.WaitFor = lastString(base.WaitFor, override.WaitFor)
+
+	// Mounts
+	result.Mounts = mergeMounts(base.Mounts, override.Mounts)
+
+	// Security
+	result.CapAdd = unionStrings(base.CapAdd, override.CapAdd)
+	result.SecurityOpt = unionStrings(base.SecurityOpt, override.SecurityOpt)
+	result.Init = anyTrue(base.Init, override.Init)
+	result.Privileged = anyTrue(base.Privileged, override.Privileged)
+
+	// Features and customizations
+	if base.Features != nil || override.Features != nil {
+		result.Features = mergeFeatures(base.Features, override.Features)
+	}
+	if override.OverrideFeatureInstallOrder != nil {
+		result.OverrideFeatureInstallOrder = override.OverrideFeatureInstallOrder
+	}
+	result.Customizations = mergeCustomizations(base.Customizations, override.Customizations)
+
+	// Host requirements and secrets
+	result.HostRequirements = mergeHostRequirements(base.HostRequirements, override.HostRequirements)
+	result.Secrets = mergeMaps(base.Secrets, override.Secrets)
+
+	// Other settings
+	result.Name = lastPtr(base.Name, override.Name)
+	result.UpdateRemoteUserUID = lastPtr(base.UpdateRemoteUserUID, override.UpdateRemoteUserUID)
+	result.UserEnvProbe = lastString(base.UserEnvProbe, override.UserEnvProbe)
+	result.OverrideCommand = lastPtr(base.OverrideCommand, override.OverrideCommand)
+	result.ShutdownAction = lastString(base.ShutdownAction, override.ShutdownAction)
+
+	// NonComposeBase
+	result.NonComposeBase = mergeNonComposeBase(base.NonComposeBase, override.NonComposeBase)
+
+	// Unknown properties are last-wins per property
+	result.AdditionalProperties = mergeMaps(base.AdditionalProperties, override.AdditionalProperties)
+
+	return &result
+}
+
+// lastPtr returns override if set, otherwise base
+func lastPtr[T any](base, override *T) *T {
+	if override != nil {
+		return override
+	}
+	return base
+}
+
+// lastString returns override if non-empty, otherwise base
+func lastString(base, override string) string {
+	if override != "" {
+		return override
+	}
+	return base
+}
+
+// anyTrue returns true if either value is true, otherwise the last value set
+func anyTrue(base, override *bool) *bool {
+	if (base != nil && *base) || (override != nil && *override) {
+		t := true
+		return &t
+	}
+	return lastPtr(base, override)
+}
+
+// mergeMaps merges two maps into a new map, override values win
+func mergeMaps[V any](base, override map[string]V) map[string]V {
+	if base == nil && override == nil {
+		return nil
+	}
+	result := make(map[string]V, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = v
+	}
+	return result
+}
+
+// unionStrings returns the values of both slices without duplicates, in order
+func unionStrings(base, override []string) []string {
+	if base == nil && override == nil {
+		return nil
+	}
+	return uniqueStrings(append(append([]string{}, base...), override...))
+}
+
+// unionPorts returns the union of two forwardPorts lists without duplicates
+func unionPorts(base, override []Port) []Port {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+
+	var result []Port
+	seen := map[string]bool{}
+	for _, port := range append(append([]Port{}, base...), override...) {
+		if seen[port.String()] {
+			continue
+		}
+		seen[port.String()] = true
+		result = append(result, port)
+	}
+	return result
+}
+
+// portKey returns a comparable key for a decoded JSON port value
+func portKey(port interface{}) string {
+	switch p := port.(type) {
+	case float64:
+		return fmt.Sprintf("%d", int(p))
+	case int:
+		return fmt.Sprintf("%d", p)
+	default:
+		return fmt.Sprintf("%v", p)
+	}
+}
+
+// appendLifecycle accumulates lifecycle commands from base and override
+// into a sequence
+func appendLifecycle(base, override *LifecycleCommand) *LifecycleCommand {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+
+	seq := &LifecycleCommand{Type: CommandTypeSequence}
+	for _, cmd := range []*LifecycleCommand{base, override} {
+		if cmd.Type == CommandTypeSequence {
+			seq.Sequence = append(seq.Sequence, cmd.Sequence...)
+		} else {
+			seq.Sequence = append(seq.Sequence, cmd)
+		}
+	}
+	return seq
+}
+
+// mergeMounts collects mounts from base and override. When several mounts
+// share a target the last one wins, keeping its position in the list.
+func mergeMounts(base, override []MountEntry) []MountEntry {
+	if base == nil && override == nil {
+		return nil
+	}
+	all := append(append([]MountEntry{}, base...), override...)
+
+	seen := map[string]bool{}
+	var reversed []MountEntry
+	for i := len(all) - 1; i >= 0; i-- {
+		if target := all[i].Target(); target != "" {
+			if seen[target] {
+				continue
+			}
+			seen[target] = true
+		}
+		reversed = append(reversed, all[i])
+	}
+
+	result := make([]MountEntry, 0, len(reversed))
+	for i := len(reversed) - 1; i >= 0; i-- {
+		result = append(result, reversed[i])
+	}
+	return result
+}
+
+// mergeHostRequirements takes the larger of each requirement
+func mergeHostRequirements(base, override *DevContainerCommonHostRequirements) *DevContainerCommonHostRequirements {
+	if base == nil {
+		return override
+	}
+	if override == nil {
+		return base
+	}
+	result := *base
+	if cpus(override.CPUs) > cpus(base.CPUs) {
+		result.CPUs = override.CPUs
+		result.cpusString = override.cpusString
+	}
+	if parseSize(override.Memory) > parseSize(base.Memory) {
+		result.Memory = override.Memory
+	}
+	if parseSize(override.Storage) > parseSize(base.Storage) {
+		result.Storage = override.Storage
+	}
+	if gpuRank(override) > gpuRank(base) {
+		result.Gpu = override.Gpu
+		result.GpuDetails = override.GpuDetails
+	}
+	result.AdditionalProperties = mergeMaps(base.AdditionalProperties, override.AdditionalProperties)
+	return &result
+}
+
+// cpus parses a cpus requirement, 0 if unset
+func cpus(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// gpuRank orders gpu requirements: none, optional, required
+func gpuRank(r *DevContainerCommonHostRequirements) int {
+	switch {
+	case r.GpuDetails != nil || r.Gpu == "true":
+		return 2
+	case r.Gpu == "optional":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// parseSize parses a size such as "4gb" or "512mb" into bytes, 0 if invalid
+func parseSize(s string) int64 {
+	s = strings.ToLower(strings.TrimSpace(s))
+	units := []struct {
+		suffix string
+		factor int64
+	}{{"tb", 1 << 40}, {"gb", 1 << 30}, {"mb", 1 << 20}, {"kb", 1 << 10}, {"b", 1}}
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(s, u.suffix), 64)
+			if err != nil {
+				return 0
+			}
+			return int64(n * float64(u.factor))
+		}
+	}
+	n, _ := strconv.ParseInt(s, 10, 64)
+	return n
+}
+
+// mergeCustomizations deep-merges tool customizations. Nested objects are
+// merged, arrays are unioned and other values are last-wins.
+func mergeCustomizations(base, override map[string]interface{}) map[string]interface{} {
+	if base == nil && override == nil {
+		return nil
+	}
+	result := make(map[string]interface{}, len(base)+len(override))
+	for k, v := range base {
+		result[k] = v
+	}
+	for k, v := range override {
+		result[k] = mergeCustomizationValue(result[k], v)
+	}
+	return result
+}
+
+func mergeCustomizationValue(base, override interface{}) interface{} {
+	switch o := override.(type) {
+	case map[string]interface{}:
+		if b, ok := base.(map[string]interface{}); ok {
+			return mergeCustomizations(b, o)
+		}
+	case []interface{}:
+		if b, ok := base.([]interface{}); ok {
+			result := append([]interface{}{}, b...)
+			for _, item := range o {
+				if !containsValue(result, item) {
+					result = append(result, item)
+				}
+			}
+			return result
+		}
+	}
+	return override
+}
+
+// containsValue reports whether list contains a value with the same JSON form
+func containsValue(list []interface{}, item interface{}) bool {
+	for _, v := range list {
+		if fmt.Sprintf("%#v", v) == fmt.Sprintf("%#v", item) {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeBuild merges build configurations field by field
+func mergeBuild(base, override Build) Build {
+	result := base
+	result.Dockerfile = lastString(base.Dockerfile, override.Dockerfile)
+	result.Context = lastString(base.Context, override.Context)
+	result.Args = mergeMaps(base.Args, override.Args)
+	result.Target = lastString(base.Target, override.Target)
+	if override.CacheFrom != nil {
+		result.CacheFrom = override.CacheFrom
+		result.cacheFromScalar = override.cacheFromScalar
+	}
+	if override.Options != nil {
+		result.Options = override.Options
+	}
+	result.AdditionalProperties = mergeMaps(base.AdditionalProperties, override.AdditionalProperties)
+	return result
+}
+
+// mergeNonComposeBase merges non-compose settings; runArgs are replaced
+func mergeNonComposeBase(base, override *NonComposeBase) *NonComposeBase {
+	if override == nil {
+		return base
+	}
+	if base == nil {
+		return override
+	}
+	result := *base
+	result.WorkspaceFolder = lastPtr(base.WorkspaceFolder, override.WorkspaceFolder)
+	result.WorkspaceMount = lastPtr(base.WorkspaceMount, override.WorkspaceMount)
+	if override.AppPort != nil {
+		result.AppPort = override.AppPort
+	}
+	result.RunArgs = override.RunArgs
+	return &result
+}
+
+// MergeLayer is a named configuration source
+type MergeLayer struct {
+	Source string        // Name of the source, e.g. a file path
+	Config *DevContainer // Configuration contributed by the source
+}
+
+// MergeTraceEntry records the source of a resolved value
+type MergeTraceEntry struct {
+	Property string      `json:"property"` // Property path, e.g. "containerEnv.FOO" or "capAdd[SYS_PTRACE]"
+	Source   string      `json:"source"`   // Source that contributed the value
+	Value    interface{} `json:"value"`    // Contributed value
+}
+
+// MergeTrace records which source contributed each resolved value
+type MergeTrace struct {
+	Entries []MergeTraceEntry `json:"entries"`
+	index   map[string]int
+}
+
+// Lookup returns the trace entry for a property path
+func (t *MergeTrace) Lookup(property string) (MergeTraceEntry, bool) {
+	if i, ok := t.index[property]; ok {
+		return t.Entries[i], true
+	}
+	return MergeTraceEntry{}, false
+}
+
+// String renders the trace one resolved value per line
+func (t *MergeTrace) String() string {
+	var b strings.Builder
+	for _, e := range t.Entries {
+		value, _ := json.Marshal(e.Value)
+		fmt.Fprintf(&b, "%s = %s (from %s)\n", e.Property, value, e.Source)
+	}
+	return b.String()
+}
+
+// MergeDevContainersWithTrace merges layers in order, later layers taking
+// precedence, and returns the result with a trace of where each resolved
+// value came from. The merge rules are those of the devcontainer spec:
+//   - scalars and objects such as image, name or remoteUser are last-wins
+//   - containerEnv, remoteEnv and build.args are merged per variable, and a
+//     null remoteEnv value unsets the variable
+//   - capAdd, securityOpt and forwardPorts are unioned
+//   - init and privileged are true if any layer sets them
+//   - mounts are collected and de-duplicated by target, last wins
+//   - lifecycle commands accumulate and run in layer order
+func MergeDevContainersWithTrace(layers ...MergeLayer) (*DevContainer, *MergeTrace) {
+	trace := &MergeTrace{index: map[string]int{}}
+	var result *DevContainer
+	for _, layer := range layers {
+		if layer.Config == nil {
+			continue
+		}
+		if result == nil {
+			result = mergeConfigs(&DevContainer{}, layer.Config)
+		} else {
+			result = mergeConfigs(result, layer.Config)
+		}
+		trace.record(layer.Source, layer.Config, result)
+	}
+	return result, trace
+}
+
+// Properties whose array values are unioned or accumulated
+var (
+	unionProperties = map[string]bool{
+		"capAdd":       true,
+		"securityOpt":  true,
+		"forwardPorts": true,
+	}
+	lifecycleProperties = map[string]bool{
+		"initializeCommand":    true,
+		"onCreateCommand":      true,
+		"updateContentCommand": true,
+		"postCreateCommand":    true,
+		"postStartCommand":     true,
+		"postAttachCommand":    true,
+	}
+	perKeyProperties = map[string]bool{
+		"containerEnv":    true,
+		"remoteEnv":       true,
+		"features":        true,
+		"customizations":  true,
+		"portsAttributes": true,
+		"secrets":         true,
+	}
+)
+
+// set records a last-wins contribution
+func (t *MergeTrace) set(property, source string, value interface{}) {
+	entry := MergeTraceEntry{Property: property, Source: source, Value: value}
+	if i, ok := t.index[property]; ok {
+		t.Entries[i] = entry
+		return
+	}
+	t.index[property] = len(t.Entries)
+	t.Entries = append(t.Entries, entry)
+}
+
+// add records a contribution unless the property already has one
+func (t *MergeTrace) add(property, source string, value interface{}) {
+	if _, ok := t.index[property]; !ok {
+		t.set(property, source, value)
+	}
+}
+
+// record attributes the values contributed by a layer
+func (t *MergeTrace) record(source string, layer, merged *DevContainer) {
+	raw := map[string]interface{}{}
+	if data, err := json.Marshal(layer); err == nil {
+		_ = json.Unmarshal(data, &raw)
+	}
+
+	// Sequences marshal like array commands, keep their steps apart
+	for property, cmd := range map[string]*LifecycleCommand{
+		"initializeCommand":    layer.InitializeCommand,
+		"onCreateCommand":      layer.OnCreateCommand,
+		"updateContentCommand": layer.UpdateContentCommand,
+		"postCreateCommand":    layer.PostCreateCommand,
+		"postStartCommand":     layer.PostStartCommand,
+		"postAttachCommand":    layer.PostAttachCommand,
+	} {
+		if cmd != nil {
+			raw[property] = cmd
+		}
+	}
+
+	for _, property := range sortedKeys(raw) {
+		value := raw[property]
+		switch {
+		case perKeyProperties[property]:
+			if m, ok := value.(map[string]interface{}); ok {
+				for _, k := range sortedKeys(m) {
+					t.set(property+"."+k, source, m[k])
+				}
+			}
+		case property == "build":
+			if m, ok := value.(map[string]interface{}); ok {
+				for _, k := range sortedKeys(m) {
+					if args, ok := m[k].(map[string]interface{}); ok && k == "args" {
+						for _, name := range sortedKeys(args) {
+							t.set("build.args."+name, source, args[name])
+						}
+						continue
+					}
+					t.set("build."+k, source, m[k])
+				}
+			}
+		case unionProperties[property]:
+			list, ok := value.([]interface{})
+			if !ok {
+				list = []interface{}{value}
+			}
+			for _, item := range list {
+				t.add(fmt.Sprintf("%s[%s]", property, portKey(item)), source, item)
+			}
+		case lifecycleProperties[property]:
+			cmd := value.(*LifecycleCommand)
+			steps := []*LifecycleCommand{cmd}
+			if cmd.Type == CommandTypeSequence {
+				steps = cmd.Sequence
+			}
+			for _, step := range steps {
+				t.appendStep(property, source, jsonValue(step))
+			}
+		case property == "mounts":
+			for _, mount := range layer.Mounts {
+				t.set(fmt.Sprintf("mounts[%s]", mount.Target()), source, jsonValue(mount))
+			}
+		case property == "init" || property == "privileged":
+			if b, ok := value.(bool); ok && !b {
+				// false never overrides an earlier true
+				if merged != nil && ((property == "init" && merged.Init != nil && *merged.Init) ||
+					(property == "privileged" && merged.Privileged != nil && *merged.Privileged)) {
+					continue
+				}
+			}
+			t.set(property, source, value)
+		default:
+			t.set(property, source, value)
+		}
+	}
+}
+
+// appendStep records the next accumulated lifecycle command of a property
+func (t *MergeTrace) appendStep(property, source string, step interface{}) {
+	n := 0
+	for {
+		if _, ok := t.index[fmt.Sprintf("%s[%d]", property, n)]; !ok {
+			break
+		}
+		n++
+	}
+	t.set(fmt.Sprintf("%s[%d]", property, n), source, step)
+}
+
+// jsonValue returns the decoded JSON form of v
+func jsonValue(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	var out interface{}
+	_ = json.Unmarshal(data, &out)
+	return out
+}
+
+// sortedKeys returns the keys of a map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -90,31 +91,99 @@ func TestMergeDevContainers(t *testing.T) {
 			},
 		},
 		{
-			name: "merge arrays (override replaces)",
+			name: "merge arrays (union)",
 			base: &DevContainer{
 				DevContainerCommon: DevContainerCommon{
 					CapAdd:       []string{"SYS_PTRACE"},
-					ForwardPorts: []interface{}{float64(8080)},
+					ForwardPorts: []Port{PortNumber(8080)},
 				},
 			},
 			override: &DevContainer{
 				DevContainerCommon: DevContainerCommon{
-					CapAdd:       []string{"NET_ADMIN", "SYS_TIME"},
-					ForwardPorts: []interface{}{float64(3000), "5000:5000"},
+					CapAdd:       []string{"NET_ADMIN", "SYS_TIME", "SYS_PTRACE"},
+					ForwardPorts: []Port{PortNumber(3000), PortString("5000:5000"), PortNumber(8080)},
 				},
 			},
 			validate: func(t *testing.T, result *DevContainer) {
-				expectedCaps := []string{"NET_ADMIN", "SYS_TIME"}
+				expectedCaps := []string{"SYS_PTRACE", "NET_ADMIN", "SYS_TIME"}
 				if !reflect.DeepEqual(result.CapAdd, expectedCaps) {
 					t.Errorf("expected caps %v, got %v", expectedCaps, result.CapAdd)
 				}
 				
-				expectedPorts := []interface{}{float64(3000), "5000:5000"}
+				expectedPorts := []Port{PortNumber(8080), PortNumber(3000), PortString("5000:5000")}
 				if !reflect.DeepEqual(result.ForwardPorts, expectedPorts) {
 					t.Errorf("expected ports %v, got %v", expectedPorts, result.ForwardPorts)
 				}
 			},
 		},
+		{
+			name: "merge mounts by target",
+			base: &DevContainer{
+				DevContainerCommon: DevContainerCommon{
+					Mounts: []MountEntry{
+						MountString("type=volume,source=base-cache,target=/cache"),
+						MountObject(Mount{Type: "bind", Source: "/host/data", Target: "/data"}),
+					},
+				},
+			},
+			override: &DevContainer{
+				DevContainerCommon: DevContainerCommon{
+					Mounts: []MountEntry{
+						MountObject(Mount{Type: "volume", Source: "override-cache", Target: "/cache"}),
+					},
+				},
+			},
+			validate: func(t *testing.T, result *DevContainer) {
+				expected := []MountEntry{
+					MountObject(Mount{Type: "bind", Source: "/host/data", Target: "/data"}),
+					MountObject(Mount{Type: "volume", Source: "override-cache", Target: "/cache"}),
+				}
+				if !reflect.DeepEqual(result.Mounts, expected) {
+					t.Errorf("expected mounts %v, got %v", expected, result.Mounts)
+				}
+			},
+		},
+		{
+			name: "merge remoteEnv with null unset",
+			base: &DevContainer{
+				DevContainerCommon: DevContainerCommon{
+					RemoteEnv: map[string]*string{"KEEP": strPtr("base"), "DROP": strPtr("base")},
+				},
+			},
+			override: &DevContainer{
+				DevContainerCommon: DevContainerCommon{
+					RemoteEnv: map[string]*string{"DROP": nil, "NEW": strPtr("override")},
+				},
+			},
+			validate: func(t *testing.T, result *DevContainer) {
+				expected := map[string]string{"KEEP": "base", "NEW": "override"}
+				if !reflect.DeepEqual(result.ResolvedRemoteEnv(), expected) {
+					t.Errorf("expected remote env %v, got %v", expected, result.ResolvedRemoteEnv())
+				}
+			},
+		},
+		{
+			name: "privileged is true if any source sets it",
+			base: &DevContainer{
+				DevContainerCommon: DevContainerCommon{
+					Privileged: boolPtr(true),
+				},
+			},
+			override: &DevContainer{
+				DevContainerCommon: DevContainerCommon{
+					Privileged: boolPtr(false),
+					Init:       boolPtr(false),
+				},
+			},
+			validate: func(t *testing.T, result *DevContainer) {
+				if result.Privileged == nil || !*result.Privileged {
+					t.Error("expected privileged to stay true")
+				}
+				if result.Init == nil || *result.Init {
+					t.Error("expected init to be false")
+				}
+			},
+		},
 		{
 			name: "merge NonComposeBase fields",
 			base: &DevContainer{
@@ -126,7 +195,7 @@ func TestMergeDevContainers(t *testing.T) {
 			override: &DevContainer{
 				NonComposeBase: &NonComposeBase{
 					WorkspaceFolder: strPtr("/app"),
-					AppPort:         []interface{}{float64(8080)},
+					AppPort: PortList{PortNumber(8080)},
 				},
 			},
 			validate: func(t *testing.T, result *DevContainer) {
@@ -149,25 +218,30 @@ func TestMergeDevContainers(t *testing.T) {
 			name: "merge lifecycle commands",
 			base: &DevContainer{
 				DevContainerCommon: DevContainerCommon{
-					OnCreateCommand:   "echo 'base'",
-					PostCreateCommand: []interface{}{"npm", "install"},
+					OnCreateCommand:   StringCommand("echo 'base'"),
+					PostCreateCommand: ArrayCommand("npm", "install"),
 				},
 			},
 			override: &DevContainer{
 				DevContainerCommon: DevContainerCommon{
-					OnCreateCommand:  "echo 'override'",
-					PostStartCommand: "npm start",
+					OnCreateCommand:  StringCommand("echo 'override'"),
+					PostStartCommand: StringCommand("npm start"),
 				},
 			},
 			validate: func(t *testing.T, result *DevContainer) {
-				if result.OnCreateCommand != "echo 'override'" {
-					t.Error("expected onCreate command to be overridden")
+				// Commands from both sources accumulate, base first
+				expected := &LifecycleCommand{
+					Type:     CommandTypeSequence,
+					Sequence: []*LifecycleCommand{StringCommand("echo 'base'"), StringCommand("echo 'override'")},
+				}
+				if !reflect.DeepEqual(result.OnCreateCommand, expected) {
+					t.Errorf("expected onCreate commands %v, got %v", expected, result.OnCreateCommand)
 				}
 				// PostCreateCommand should remain from base
 				if result.PostCreateCommand == nil {
 					t.Error("expected postCreate command to be preserved")
 				}
-				if result.PostStartCommand != "npm start" {
+				if result.PostStartCommand.ToShellCommand() != "npm start" {
 					t.Error("expected postStart command to be set")
 				}
 			},
@@ -243,15 +317,15 @@ func TestExpandVariables(t *testing.T) {
 			name: "expand lifecycle commands",
 			dc: &DevContainer{
 				DevContainerCommon: DevContainerCommon{
-					OnCreateCommand: "cd ${localWorkspaceFolder} && npm install",
-					PostCreateCommand: []interface{}{
+					OnCreateCommand: StringCommand("cd ${localWorkspaceFolder} && npm install"),
+					PostCreateCommand: ArrayCommand(
 						"echo",
 						"Working in ${containerWorkspaceFolder}",
-					},
-					PostStartCommand: map[string]interface{}{
-						"server": "cd ${containerWorkspaceFolder} && npm start",
-						"watch":  []interface{}{"npm", "run", "watch", "--prefix", "${containerWorkspaceFolder}"},
-					},
+					),
+					PostStartCommand: ObjectCommand(map[string]*LifecycleCommand{
+						"server": StringCommand("cd ${containerWorkspaceFolder} && npm start"),
+						"watch":  ArrayCommand("npm", "run", "watch", "--prefix", "${containerWorkspaceFolder}"),
+					}),
 				},
 			},
 			variables: map[string]string{
@@ -260,22 +334,18 @@ func TestExpandVariables(t *testing.T) {
 			},
 			validate: func(t *testing.T, dc *DevContainer) {
 				expectedOnCreate := "cd /home/user/app && npm install"
-				if dc.OnCreateCommand != expectedOnCreate {
+				if dc.OnCreateCommand.Command != expectedOnCreate {
 					t.Errorf("expected onCreate %s, got %v", expectedOnCreate, dc.OnCreateCommand)
 				}
 				
-				if cmd, ok := dc.PostCreateCommand.([]interface{}); ok {
-					if len(cmd) != 2 || cmd[1] != "Working in /workspace/app" {
-						t.Errorf("unexpected postCreate command: %v", cmd)
-					}
+				if cmd := dc.PostCreateCommand.Args; len(cmd) != 2 || cmd[1] != "Working in /workspace/app" {
+					t.Errorf("unexpected postCreate command: %v", cmd)
 				}
 				
-				if cmds, ok := dc.PostStartCommand.(map[string]interface{}); ok {
-					if server, ok := cmds["server"].(string); ok {
-						expected := "cd /workspace/app && npm start"
-						if server != expected {
-							t.Errorf("expected server command %s, got %s", expected, server)
-						}
+				if server, ok := dc.PostStartCommand.Commands["server"]; ok {
+					expected := "cd /workspace/app && npm start"
+					if server.Command != expected {
+						t.Errorf("expected server command %s, got %s", expected, server.Command)
 					}
 				}
 			},
@@ -284,17 +354,17 @@ func TestExpandVariables(t *testing.T) {
 			name: "expand mount sources",
 			dc: &DevContainer{
 				DevContainerCommon: DevContainerCommon{
-					Mounts: []interface{}{
-						map[string]interface{}{
-							"type":   "bind",
-							"source": "${localWorkspaceFolder}/data",
-							"target": "/data",
-						},
-						map[string]interface{}{
-							"type":   "volume",
-							"source": "${containerWorkspaceFolderBasename}-cache",
-							"target": "/cache",
-						},
+					Mounts: []MountEntry{
+						MountObject(Mount{
+							Type:   "bind",
+							Source: "${localWorkspaceFolder}/data",
+							Target: "/data",
+						}),
+						MountObject(Mount{
+							Type:   "volume",
+							Source: "${containerWorkspaceFolderBasename}-cache",
+							Target: "/cache",
+						}),
 					},
 				},
 			},
@@ -308,21 +378,21 @@ func TestExpandVariables(t *testing.T) {
 				}
 				
 				// Check first mount (bind)
-				mount0, ok := dc.Mounts[0].(map[string]interface{})
-				if !ok {
-					t.Fatalf("expected first mount to be a map")
+				mount0 := dc.Mounts[0].Object
+				if mount0 == nil {
+					t.Fatalf("expected first mount to be an object")
 				}
-				if mount0["source"] != "/projects/app/data" {
-					t.Errorf("expected first mount source to be expanded, got %v", mount0["source"])
+				if mount0.Source != "/projects/app/data" {
+					t.Errorf("expected first mount source to be expanded, got %v", mount0.Source)
 				}
 				
 				// Check second mount (volume)
-				mount1, ok := dc.Mounts[1].(map[string]interface{})
-				if !ok {
-					t.Fatalf("expected second mount to be a map")
+				mount1 := dc.Mounts[1].Object
+				if mount1 == nil {
+					t.Fatalf("expected second mount to be an object")
 				}
-				if mount1["source"] != "app-cache" {
-					t.Errorf("expected second mount source to be expanded, got %v", mount1["source"])
+				if mount1.Source != "app-cache" {
+					t.Errorf("expected second mount source to be expanded, got %v", mount1.Source)
 				}
 			},
 		},
@@ -394,8 +464,8 @@ func TestLoadDevContainerWithExtends(t *testing.T) {
 			t.Errorf("expected env %v, got %v", expectedEnv, dc.ContainerEnv)
 		}
 		
-		// Check arrays are replaced
-		expectedPorts := []interface{}{float64(3000), float64(5000)}
+		// Check arrays are unioned
+		expectedPorts := []Port{PortNumber(8080), PortNumber(3000), PortNumber(5000)}
 		if !reflect.DeepEqual(dc.ForwardPorts, expectedPorts) {
 			t.Errorf("expected ports %v, got %v", expectedPorts, dc.ForwardPorts)
 		}
@@ -577,4 +647,56 @@ func TestMergeRemoteEnv(t *testing.T) {
 	if result["VAR3"] != nil {
 		t.Error("expected VAR3 to be nil")
 	}
-}
\ No newline at end of file
+}
+
+func TestMergeDevContainersWithTrace(t *testing.T) {
+	base := &DevContainer{
+		ImageContainer: &ImageContainer{Image: "ubuntu:22.04"},
+		DevContainerCommon: DevContainerCommon{
+			ContainerEnv:      map[string]string{"A": "base", "B": "base"},
+			CapAdd:            []string{"SYS_PTRACE"},
+			PostCreateCommand: StringCommand("echo base"),
+		},
+	}
+	override := &DevContainer{
+		DevContainerCommon: DevContainerCommon{
+			ContainerEnv:      map[string]string{"A": "override"},
+			CapAdd:            []string{"SYS_PTRACE", "NET_ADMIN"},
+			PostCreateCommand: StringCommand("echo override"),
+			Name:              strPtr("traced"),
+		},
+	}
+
+	result, trace := MergeDevContainersWithTrace(
+		MergeLayer{Source: "base.json", Config: base},
+		MergeLayer{Source: "override.json", Config: override},
+	)
+	if result.ImageContainer == nil || result.ImageContainer.Image != "ubuntu:22.04" {
+		t.Fatal("expected merged image")
+	}
+
+	expected := map[string]string{
+		"image":                "base.json",
+		"name":                 "override.json",
+		"containerEnv.A":       "override.json",
+		"containerEnv.B":       "base.json",
+		"capAdd[SYS_PTRACE]":   "base.json",
+		"capAdd[NET_ADMIN]":    "override.json",
+		"postCreateCommand[0]": "base.json",
+		"postCreateCommand[1]": "override.json",
+	}
+	for property, source := range expected {
+		entry, ok := trace.Lookup(property)
+		if !ok {
+			t.Errorf("expected trace entry for %s", property)
+			continue
+		}
+		if entry.Source != source {
+			t.Errorf("expected %s from %s, got %s", property, source, entry.Source)
+		}
+	}
+
+	if out := trace.String(); !strings.Contains(out, `containerEnv.A = "override" (from override.json)`) {
+		t.Errorf("unexpected trace output:\n%s", out)
+	}
+}
@@ -0,0 +1,131 @@
+package devcontainer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LabelMetadata is the image label carrying devcontainer configuration fragments
+const LabelMetadata = "devcontainer.metadata"
+
+// metadataProperties lists the devcontainer.json properties that are stored
+// in the devcontainer.metadata label
+var metadataProperties = []string{
+	"id",
+	"init",
+	"privileged",
+	"capAdd",
+	"securityOpt",
+	"entrypoint",
+	"mounts",
+	"customizations",
+	"onCreateCommand",
+	"updateContentCommand",
+	"postCreateCommand",
+	"postStartCommand",
+	"postAttachCommand",
+	"waitFor",
+	"remoteUser",
+	"containerUser",
+	"userEnvProbe",
+	"remoteEnv",
+	"containerEnv",
+	"overrideCommand",
+	"portsAttributes",
+	"otherPortsAttributes",
+	"forwardPorts",
+	"shutdownAction",
+	"updateRemoteUserUID",
+	"hostRequirements",
+}
+
+// ParseImageMetadata parses the value of a devcontainer.metadata label.
+// The label holds either a JSON array of config fragments or a single object.
+func ParseImageMetadata(label string) ([]*DevContainer, error) {
+	label = strings.TrimSpace(label)
+	if label == "" {
+		return nil, nil
+	}
+
+	var entries []json.RawMessage
+	if strings.HasPrefix(label, "[") {
+		if err := json.Unmarshal([]byte(label), &entries); err != nil {
+			return nil, fmt.Errorf("failed to parse %s label: %w", LabelMetadata, err)
+		}
+	} else {
+		entries = []json.RawMessage{json.RawMessage(label)}
+	}
+
+	result := make([]*DevContainer, 0, len(entries))
+	for i, entry := range entries {
+		dc, err := ParseDevContainer(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s entry %d: %w", LabelMetadata, i, err)
+		}
+		result = append(result, dc)
+	}
+	return result, nil
+}
+
+// MergeImageMetadata merges image metadata entries under the local
+// configuration. Entries are applied in order and dc is applied last, so the
+// local configuration wins wherever the spec uses last-wins semantics.
+// The inputs are not modified.
+func MergeImageMetadata(metadata []*DevContainer, dc *DevContainer) *DevContainer {
+	result := &DevContainer{}
+	for _, entry := range metadata {
+		if entry != nil {
+			result = mergeConfigs(result, entry)
+		}
+	}
+	if dc != nil {
+		result = mergeConfigs(result, dc)
+	}
+	return result
+}
+
+// ImageMetadataLabel serializes config fragments into a devcontainer.metadata
+// label value. Only the properties the spec stores in image metadata are kept.
+func ImageMetadataLabel(entries []*DevContainer) (string, error) {
+	fragments := make([]map[string]interface{}, 0, len(entries))
+	for _, entry := range entries {
+		if entry == nil {
+			continue
+		}
+		fragment, err := metadataFragment(entry)
+		if err != nil {
+			return "", err
+		}
+		fragments = append(fragments, fragment)
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(fragments); err != nil {
+		return "", fmt.Errorf("failed to encode %s label: %w", LabelMetadata, err)
+	}
+	return strings.TrimSuffix(buf.String(), "\n"), nil
+}
+
+// metadataFragment returns the metadata properties of a configuration
+func metadataFragment(dc *DevContainer) (map[string]interface{}, error) {
+	data, err := json.Marshal(dc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode devcontainer config: %w", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to decode devcontainer config: %w", err)
+	}
+
+	fragment := make(map[string]interface{})
+	for _, name := range metadataProperties {
+		if v, ok := raw[name]; ok {
+			fragment[name] = v
+		}
+	}
+	return fragment, nil
+}
@@ -0,0 +1,231 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseImageMetadata(t *testing.T) {
+	label := `[
+		{"remoteUser": "vscode", "capAdd": ["SYS_PTRACE"], "postCreateCommand": "echo image"},
+		{"mounts": [{"type": "volume", "source": "cache", "target": "/cache"}], "customizations": {"vscode": {"extensions": ["golang.go"]}}}
+	]`
+
+	entries, err := ParseImageMetadata(label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+	if entries[0].RemoteUser == nil || *entries[0].RemoteUser != "vscode" {
+		t.Error("expected remoteUser from first entry")
+	}
+	if len(entries[1].Mounts) != 1 {
+		t.Error("expected mount from second entry")
+	}
+
+	// A single object is accepted as well
+	entries, err = ParseImageMetadata(`{"remoteUser": "node"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || *entries[0].RemoteUser != "node" {
+		t.Error("expected single object label to be parsed")
+	}
+
+	if _, err := ParseImageMetadata(`[{"remoteUser": `); err == nil {
+		t.Error("expected error for malformed label")
+	}
+}
+
+func TestMergeImageMetadata(t *testing.T) {
+	metadata, err := ParseImageMetadata(`[
+		{"remoteUser": "vscode", "containerEnv": {"A": "image", "B": "image"}, "capAdd": ["SYS_PTRACE"],
+		 "privileged": true, "postCreateCommand": "echo image",
+		 "mounts": ["type=volume,source=image-cache,target=/cache", "type=volume,source=data,target=/data"],
+		 "forwardPorts": [8080],
+		 "customizations": {"vscode": {"extensions": ["golang.go"], "settings": {"a": 1}}}}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	local, err := ParseDevContainer([]byte(`{
+		"image": "example:1",
+		"containerEnv": {"A": "local"},
+		"capAdd": ["NET_ADMIN", "SYS_PTRACE"],
+		"privileged": false,
+		"postCreateCommand": "echo local",
+		"mounts": [{"type": "volume", "source": "local-cache", "target": "/cache"}],
+		"forwardPorts": [8080, 3000],
+		"customizations": {"vscode": {"extensions": ["ms-python.python"], "settings": {"a": 2}}}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	merged := MergeImageMetadata(metadata, local)
+
+	if merged.RemoteUser == nil || *merged.RemoteUser != "vscode" {
+		t.Error("expected remoteUser from image metadata")
+	}
+	if merged.imageName() != "example:1" {
+		t.Errorf("expected local image, got %s", merged.imageName())
+	}
+	expectedEnv := map[string]string{"A": "local", "B": "image"}
+	if !reflect.DeepEqual(merged.ContainerEnv, expectedEnv) {
+		t.Errorf("expected env %v, got %v", expectedEnv, merged.ContainerEnv)
+	}
+	expectedCaps := []string{"SYS_PTRACE", "NET_ADMIN"}
+	if !reflect.DeepEqual(merged.CapAdd, expectedCaps) {
+		t.Errorf("expected caps %v, got %v", expectedCaps, merged.CapAdd)
+	}
+	if merged.Privileged == nil || !*merged.Privileged {
+		t.Error("expected privileged when any source sets it")
+	}
+	if len(merged.ForwardPorts) != 2 {
+		t.Errorf("expected unioned ports, got %v", merged.ForwardPorts)
+	}
+
+	// Lifecycle commands accumulate, image first
+	seq := merged.PostCreateCommand
+	if seq.Type != CommandTypeSequence || len(seq.Sequence) != 2 ||
+		seq.Sequence[0].Command != "echo image" || seq.Sequence[1].Command != "echo local" {
+		t.Errorf("expected accumulated postCreateCommand, got %#v", merged.PostCreateCommand)
+	}
+	script, err := GetLifecycleScript(merged, "create")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(script, "echo image\necho local") {
+		t.Errorf("expected both commands in script, got %q", script)
+	}
+
+	// Mounts with the same target resolve to the last source
+	if len(merged.Mounts) != 2 {
+		t.Fatalf("expected 2 mounts, got %v", merged.Mounts)
+	}
+	if merged.Mounts[0].Target() != "/data" {
+		t.Errorf("expected /data mount first, got %v", merged.Mounts[0])
+	}
+	if m := merged.Mounts[1].Object; m == nil || m.Source != "local-cache" {
+		t.Errorf("expected local /cache mount to win, got %v", merged.Mounts[1])
+	}
+
+	vscode := merged.Customizations["vscode"].(map[string]interface{})
+	if !reflect.DeepEqual(vscode["extensions"], []interface{}{"golang.go", "ms-python.python"}) {
+		t.Errorf("expected unioned extensions, got %v", vscode["extensions"])
+	}
+	if vscode["settings"].(map[string]interface{})["a"] != float64(2) {
+		t.Error("expected local settings to win")
+	}
+
+	// Inputs are left untouched
+	if local.RemoteUser != nil || len(local.ContainerEnv) != 1 {
+		t.Error("expected local config to be unmodified")
+	}
+}
+
+func TestImageMetadataLabel(t *testing.T) {
+	dc, err := ParseDevContainer([]byte(`{
+		"image": "example:1",
+		"name": "not metadata",
+		"remoteUser": "vscode",
+		"postStartCommand": "echo start"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	base, err := ParseImageMetadata(`{"capAdd": ["SYS_PTRACE"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	label, err := ImageMetadataLabel(append(base, dc))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var fragments []map[string]interface{}
+	if err := json.Unmarshal([]byte(label), &fragments); err != nil {
+		t.Fatalf("expected JSON array label, got %s: %v", label, err)
+	}
+	if len(fragments) != 2 {
+		t.Fatalf("expected 2 fragments, got %d", len(fragments))
+	}
+	if _, ok := fragments[1]["image"]; ok {
+		t.Error("expected image to be excluded from metadata")
+	}
+	if _, ok := fragments[1]["name"]; ok {
+		t.Error("expected name to be excluded from metadata")
+	}
+	if fragments[1]["remoteUser"] != "vscode" || fragments[1]["postStartCommand"] != "echo start" {
+		t.Errorf("unexpected fragment: %v", fragments[1])
+	}
+
+	// The label round-trips through ParseImageMetadata
+	entries, err := ParseImageMetadata(label)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 || !reflect.DeepEqual(entries[0].CapAdd, []string{"SYS_PTRACE"}) {
+		t.Error("expected label to round-trip")
+	}
+}
+
+func TestDockerfileBaseImage(t *testing.T) {
+	tests := []struct {
+		name      string
+		content   string
+		target    string
+		buildArgs map[string]string
+		expected  string
+	}{
+		{
+			name:     "single stage",
+			content:  "FROM mcr.microsoft.com/devcontainers/go:1.21\nRUN echo hi\n",
+			expected: "mcr.microsoft.com/devcontainers/go:1.21",
+		},
+		{
+			name: "global arg with default",
+			content: `ARG VARIANT=3.11
+# comment
+FROM --platform=linux/amd64 python:${VARIANT}`,
+			expected: "python:3.11",
+		},
+		{
+			name:      "build arg override",
+			content:   "ARG VARIANT=3.11\nFROM python:$VARIANT\n",
+			buildArgs: map[string]string{"VARIANT": "3.12"},
+			expected:  "python:3.12",
+		},
+		{
+			name:     "stage reference",
+			content:  "FROM node:18 AS base\nFROM base AS dev\nRUN npm i\n",
+			expected: "node:18",
+		},
+		{
+			name:     "target stage",
+			content:  "FROM node:18 AS base\nFROM golang:1.22 AS tools\nFROM base\n",
+			target:   "tools",
+			expected: "golang:1.22",
+		},
+		{
+			name:     "scratch",
+			content:  "FROM scratch\n",
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DockerfileBaseImage(tt.content, tt.target, tt.buildArgs)
+			if got != tt.expected {
+				t.Errorf("expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
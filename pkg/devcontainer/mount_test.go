@@ -79,21 +79,21 @@ func TestMountHandlingInDockerCommand(t *testing.T) {
 					Image: "alpine:latest",
 				},
 				DevContainerCommon: DevContainerCommon{
-					Mounts: []interface{}{
-						map[string]interface{}{
-							"type":   "bind",
-							"source": "/host/code",
-							"target": "/code",
-						},
-						map[string]interface{}{
-							"type":   "volume",
-							"source": "cache-vol",
-							"target": "/cache",
-						},
-						map[string]interface{}{
-							"type":   "tmpfs",
-							"target": "/tmp/scratch",
-						},
+					Mounts: []MountEntry{
+						MountObject(Mount{
+							Type:   "bind",
+							Source: "/host/code",
+							Target: "/code",
+						}),
+						MountObject(Mount{
+							Type:   "volume",
+							Source: "cache-vol",
+							Target: "/cache",
+						}),
+						MountObject(Mount{
+							Type:   "tmpfs",
+							Target: "/tmp/scratch",
+						}),
 					},
 				},
 			},
@@ -126,12 +126,12 @@ func TestMountHandlingInDockerCommand(t *testing.T) {
 					WorkspaceMount: strPtr("type=bind,source=/projects/app,target=/workspace"),
 				},
 				DevContainerCommon: DevContainerCommon{
-					Mounts: []interface{}{
-						map[string]interface{}{
-							"type":   "volume",
-							"source": "node_modules",
-							"target": "/workspace/node_modules",
-						},
+					Mounts: []MountEntry{
+						MountObject(Mount{
+							Type:   "volume",
+							Source: "node_modules",
+							Target: "/workspace/node_modules",
+						}),
 					},
 				},
 			},
@@ -160,10 +160,10 @@ func TestMountHandlingInDockerCommand(t *testing.T) {
 					Image: "ubuntu:22.04",
 				},
 				DevContainerCommon: DevContainerCommon{
-					Mounts: []interface{}{
-						map[string]interface{}{"type": "volume", "source": "first", "target": "/1"},
-						map[string]interface{}{"type": "volume", "source": "second", "target": "/2"},
-						map[string]interface{}{"type": "volume", "source": "third", "target": "/3"},
+					Mounts: []MountEntry{
+						MountObject(Mount{Type: "volume", Source: "first", Target: "/1"}),
+						MountObject(Mount{Type: "volume", Source: "second", Target: "/2"}),
+						MountObject(Mount{Type: "volume", Source: "third", Target: "/3"}),
 					},
 				},
 			},
@@ -290,17 +290,17 @@ func TestMountValidation(t *testing.T) {
 func TestMountExpansion(t *testing.T) {
 	dc := &DevContainer{
 		DevContainerCommon: DevContainerCommon{
-			Mounts: []interface{}{
-				map[string]interface{}{
-					"type":   "bind",
-					"source": "${localWorkspaceFolder}/data",
-					"target": "/data",
-				},
-				map[string]interface{}{
-					"type":   "volume",
-					"source": "${containerWorkspaceFolderBasename}-cache",
-					"target": "${containerWorkspaceFolder}/cache",
-				},
+			Mounts: []MountEntry{
+				MountObject(Mount{
+					Type:   "bind",
+					Source: "${localWorkspaceFolder}/data",
+					Target: "/data",
+				}),
+				MountObject(Mount{
+					Type:   "volume",
+					Source: "${containerWorkspaceFolderBasename}-cache",
+					Target: "${containerWorkspaceFolder}/cache",
+				}),
 			},
 		},
 		NonComposeBase: &NonComposeBase{
@@ -318,23 +318,23 @@ func TestMountExpansion(t *testing.T) {
 	ExpandVariables(dc, variables)
 
 	// Check mount expansion
-	mount0, ok := dc.Mounts[0].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected first mount to be a map")
+	mount0 := dc.Mounts[0].Object
+	if mount0 == nil {
+		t.Fatalf("expected first mount to be an object")
 	}
-	if mount0["source"] != "/home/user/myproject/data" {
-		t.Errorf("expected first mount source to be expanded, got %v", mount0["source"])
+	if mount0.Source != "/home/user/myproject/data" {
+		t.Errorf("expected first mount source to be expanded, got %v", mount0.Source)
 	}
 	
-	mount1, ok := dc.Mounts[1].(map[string]interface{})
-	if !ok {
-		t.Fatalf("expected second mount to be a map")
+	mount1 := dc.Mounts[1].Object
+	if mount1 == nil {
+		t.Fatalf("expected second mount to be an object")
 	}
-	if mount1["source"] != "myproject-cache" {
-		t.Errorf("expected second mount source to be expanded, got %v", mount1["source"])
+	if mount1.Source != "myproject-cache" {
+		t.Errorf("expected second mount source to be expanded, got %v", mount1.Source)
 	}
-	if mount1["target"] != "/workspace/myproject/cache" {
-		t.Errorf("expected second mount target to be expanded, got %v", mount1["target"])
+	if mount1.Target != "/workspace/myproject/cache" {
+		t.Errorf("expected second mount target to be expanded, got %v", mount1.Target)
 	}
 
 	// Check workspace mount expansion
@@ -94,13 +94,11 @@ func TestMountsExpansion(t *testing.T) {
 
 	// Check expansion worked
 	assert.Equal(t, 2, len(dc.Mounts))
-	mount0, ok := dc.Mounts[0].(string)
-	assert.True(t, ok)
-	assert.Contains(t, mount0, "/my/workspace/data")
+	assert.Nil(t, dc.Mounts[0].Object)
+	assert.Contains(t, dc.Mounts[0].Value, "/my/workspace/data")
 
-	mount1, ok := dc.Mounts[1].(string)
-	assert.True(t, ok)
-	assert.Contains(t, mount1, "/home/user/.ssh")
+	assert.Nil(t, dc.Mounts[1].Object)
+	assert.Contains(t, dc.Mounts[1].Value, "/home/user/.ssh")
 }
 
 func TestRealWorldDevcontainer(t *testing.T) {
@@ -0,0 +1,116 @@
+package devcontainer
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+	"github.com/docker/docker/client"
+)
+
+// EngineType identifies the daemon behind a Docker API socket
+type EngineType string
+
+const (
+	EngineDocker EngineType = "docker"
+	EnginePodman EngineType = "podman"
+)
+
+// EngineType returns the engine the client is connected to
+func (c *DockerClient) EngineType() EngineType {
+	if c.engine == "" {
+		return EngineDocker
+	}
+	return c.engine
+}
+
+// EngineType returns the engine type of the manager's engine, or "" if the
+// engine does not report one
+func (m *Manager) EngineType() EngineType {
+	if m.dockerClient == nil {
+		return ""
+	}
+	return m.dockerClient.EngineType()
+}
+
+// podmanSocketAttempts probe the rootless and rootful Podman API sockets
+func podmanSocketAttempts() []func() (*client.Client, error) {
+	var sockets []string
+	if dir := os.Getenv("XDG_RUNTIME_DIR"); dir != "" {
+		sockets = append(sockets, dir+"/podman/podman.sock")
+	}
+	sockets = append(sockets, "/run/podman/podman.sock")
+
+	attempts := make([]func() (*client.Client, error), len(sockets))
+	for i, socket := range sockets {
+		attempts[i] = func() (*client.Client, error) {
+			return client.NewClientWithOpts(
+				client.WithHost("unix://"+socket),
+				client.WithAPIVersionNegotiation(),
+			)
+		}
+	}
+	return attempts
+}
+
+// detectEngine asks the daemon which engine it is and whether it runs
+// rootless. Docker is assumed if it cannot tell.
+func (c *DockerClient) detectEngine(ctx context.Context) {
+	c.engine = EngineDocker
+	if version, err := c.client.ServerVersion(ctx); err == nil {
+		c.engine = engineTypeFromVersion(version)
+	}
+	if info, err := c.client.Info(ctx); err == nil {
+		for _, opt := range info.SecurityOptions {
+			if strings.Contains(opt, "name=rootless") {
+				c.rootless = true
+			}
+		}
+	}
+}
+
+// engineTypeFromVersion identifies the engine from its /version response
+func engineTypeFromVersion(version types.Version) EngineType {
+	if strings.Contains(strings.ToLower(version.Platform.Name), "podman") {
+		return EnginePodman
+	}
+	for _, component := range version.Components {
+		if strings.Contains(strings.ToLower(component.Name), "podman") {
+			return EnginePodman
+		}
+	}
+	return EngineDocker
+}
+
+// adaptHostConfigForPodman adjusts a host configuration for Podman's known
+// differences from Docker:
+//   - rootless containers keep the host user's ID (--userns=keep-id) so bind
+//     mounted files stay owned by the user, instead of rewriting the UID of
+//     the remote user
+//   - bind mounts are relabelled for SELinux (:Z), which the mount API
+//     cannot express, so they are passed as binds
+//   - Init is dropped; Podman needs a separate init binary (catatonit) that
+//     is often not installed
+func adaptHostConfigForPodman(hostConfig *container.HostConfig, rootless bool) {
+	if rootless && hostConfig.UsernsMode == "" {
+		hostConfig.UsernsMode = "keep-id"
+	}
+	hostConfig.Init = nil
+
+	mounts := hostConfig.Mounts[:0]
+	for _, m := range hostConfig.Mounts {
+		if m.Type != mount.TypeBind {
+			mounts = append(mounts, m)
+			continue
+		}
+		options := "Z"
+		if m.ReadOnly {
+			options = "ro,Z"
+		}
+		hostConfig.Binds = append(hostConfig.Binds, m.Source+":"+m.Target+":"+options)
+	}
+	hostConfig.Mounts = mounts
+}
@@ -0,0 +1,64 @@
+package devcontainer
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/api/types"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/docker/api/types/mount"
+)
+
+func TestEngineTypeFromVersion(t *testing.T) {
+	var docker types.Version
+	docker.Platform.Name = "Docker Engine - Community"
+	docker.Components = []types.ComponentVersion{{Name: "Engine"}, {Name: "containerd"}}
+	if got := engineTypeFromVersion(docker); got != EngineDocker {
+		t.Errorf("expected docker, got %s", got)
+	}
+
+	podman := types.Version{Components: []types.ComponentVersion{{Name: "Podman Engine", Version: "4.9.3"}}}
+	if got := engineTypeFromVersion(podman); got != EnginePodman {
+		t.Errorf("expected podman, got %s", got)
+	}
+
+	if got := (&DockerClient{}).EngineType(); got != EngineDocker {
+		t.Errorf("expected docker before detection, got %s", got)
+	}
+}
+
+func TestAdaptHostConfigForPodman(t *testing.T) {
+	init := true
+	newHostConfig := func() *container.HostConfig {
+		return &container.HostConfig{
+			Init: &init,
+			Mounts: []mount.Mount{
+				{Type: mount.TypeBind, Source: "/src", Target: "/workspace"},
+				{Type: mount.TypeVolume, Source: "cache", Target: "/cache"},
+				{Type: mount.TypeBind, Source: "/etc/ssl", Target: "/ssl", ReadOnly: true},
+			},
+		}
+	}
+
+	hostConfig := newHostConfig()
+	adaptHostConfigForPodman(hostConfig, true)
+	if hostConfig.Init != nil {
+		t.Error("expected init to be dropped")
+	}
+	if hostConfig.UsernsMode != "keep-id" {
+		t.Errorf("expected keep-id, got %q", hostConfig.UsernsMode)
+	}
+	if want := []string{"/src:/workspace:Z", "/etc/ssl:/ssl:ro,Z"}; !reflect.DeepEqual(hostConfig.Binds, want) {
+		t.Errorf("expected binds %v, got %v", want, hostConfig.Binds)
+	}
+	if len(hostConfig.Mounts) != 1 || hostConfig.Mounts[0].Source != "cache" {
+		t.Errorf("expected only the volume to stay a mount, got %+v", hostConfig.Mounts)
+	}
+
+	// keep-id is only valid for rootless Podman
+	hostConfig = newHostConfig()
+	adaptHostConfigForPodman(hostConfig, false)
+	if hostConfig.UsernsMode != "" {
+		t.Errorf("expected no user namespace for rootful podman, got %q", hostConfig.UsernsMode)
+	}
+}
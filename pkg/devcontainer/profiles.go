@@ -0,0 +1,160 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// LabelProfiles is the container label listing the applied profiles,
+// comma-separated in the order they were applied
+const LabelProfiles = "devcontainer-go.profiles"
+
+// profilesCustomization is the customizations namespace holding inline
+// profiles: "customizations": {"devcontainer-go": {"profiles": {...}}}
+const profilesCustomization = "devcontainer-go"
+
+var profileNameRe = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// ApplyProfiles merges named profiles on top of dc, in order, using the
+// same rules as MergeDevContainers. A profile is a partial configuration
+// defined either inline under customizations["devcontainer-go"].profiles or
+// in a file next to the configuration named after it, e.g.
+// devcontainer.ci.json for profile "ci" of devcontainer.json. When both
+// exist the inline profile is applied first. The applied names are
+// appended to Profiles and end up in the LabelProfiles container label.
+// dc is not modified.
+func ApplyProfiles(dc *DevContainer, names ...string) (*DevContainer, error) {
+	if len(names) == 0 {
+		return dc, nil
+	}
+
+	layers := []MergeLayer{{Source: "base", Config: dc}}
+	for _, name := range names {
+		overlays, err := profileOverlays(dc, name)
+		if err != nil {
+			return nil, err
+		}
+		if len(overlays) == 0 {
+			return nil, fmt.Errorf("unknown profile %q", name)
+		}
+		for _, overlay := range overlays {
+			layers = append(layers, MergeLayer{Source: "profile " + name, Config: overlay})
+		}
+	}
+
+	merged, _ := MergeDevContainersWithTrace(layers...)
+	merged.Profiles = append(append([]string{}, dc.Profiles...), names...)
+	return merged, nil
+}
+
+// LoadDevContainerWithProfiles loads a devcontainer.json and applies the
+// named profiles (see ApplyProfiles)
+func LoadDevContainerWithProfiles(path string, profiles ...string) (*DevContainer, error) {
+	dc, err := LoadDevContainer(path)
+	if err != nil {
+		return nil, err
+	}
+	return ApplyProfiles(dc, profiles...)
+}
+
+// AvailableProfiles returns the names of the profiles defined for dc, sorted
+func AvailableProfiles(dc *DevContainer) ([]string, error) {
+	seen := map[string]bool{}
+	inline, err := inlineProfiles(dc)
+	if err != nil {
+		return nil, err
+	}
+	for name := range inline {
+		seen[name] = true
+	}
+
+	if dc.ConfigFilePath != "" {
+		ext := filepath.Ext(dc.ConfigFilePath)
+		prefix := strings.TrimSuffix(filepath.Base(dc.ConfigFilePath), ext) + "."
+		entries, err := os.ReadDir(filepath.Dir(dc.ConfigFilePath))
+		if err != nil {
+			return nil, fmt.Errorf("failed to list profiles: %w", err)
+		}
+		for _, entry := range entries {
+			name, ok := strings.CutPrefix(entry.Name(), prefix)
+			if name, found := strings.CutSuffix(name, ext); ok && found && !entry.IsDir() && profileNameRe.MatchString(name) {
+				seen[name] = true
+			}
+		}
+	}
+	return sortedKeys(seen), nil
+}
+
+// profileOverlays returns the configurations defining profile name
+func profileOverlays(dc *DevContainer, name string) ([]*DevContainer, error) {
+	if !profileNameRe.MatchString(name) {
+		return nil, fmt.Errorf("invalid profile name %q", name)
+	}
+
+	var overlays []*DevContainer
+	inline, err := inlineProfiles(dc)
+	if err != nil {
+		return nil, err
+	}
+	if raw, ok := inline[name]; ok {
+		overlay, err := ParseDevContainer(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid profile %q: %w", name, err)
+		}
+		overlays = append(overlays, overlay)
+	}
+
+	if path := profilePath(dc.ConfigFilePath, name); path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read profile %q: %w", name, err)
+		}
+		if err == nil {
+			overlay, err := ParseDevContainer(data)
+			if err != nil {
+				return nil, fmt.Errorf("invalid profile %q: %s: %w", name, path, err)
+			}
+			overlays = append(overlays, overlay)
+		}
+	}
+	return overlays, nil
+}
+
+// inlineProfiles returns the profiles defined in the customizations of dc
+func inlineProfiles(dc *DevContainer) (map[string]json.RawMessage, error) {
+	ns, ok := dc.Customizations[profilesCustomization]
+	if !ok {
+		return nil, nil
+	}
+	data, err := json.Marshal(ns)
+	if err != nil {
+		return nil, err
+	}
+	var custom struct {
+		Profiles map[string]json.RawMessage `json:"profiles"`
+	}
+	if err := json.Unmarshal(data, &custom); err != nil {
+		return nil, fmt.Errorf("invalid %s customizations: %w", profilesCustomization, err)
+	}
+	return custom.Profiles, nil
+}
+
+// profilePath returns the overlay file of a profile for a configuration
+// file, or "" if the configuration was not loaded from a file
+func profilePath(configPath, name string) string {
+	if configPath == "" {
+		return ""
+	}
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + "." + name + ext
+}
+
+// profilesLabel returns the value of LabelProfiles for applied profiles,
+// kept in application order since it determines precedence
+func profilesLabel(profiles []string) string {
+	return strings.Join(profiles, ",")
+}
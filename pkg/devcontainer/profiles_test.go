@@ -0,0 +1,103 @@
+package devcontainer
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func TestApplyProfiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	writeConfig(t, path, `{
+		"image": "golang:1.22",
+		"containerEnv": {"MODE": "dev", "GOFLAGS": "-mod=mod"},
+		"forwardPorts": [8080],
+		"customizations": {
+			"devcontainer-go": {
+				"profiles": {
+					"ci": {"containerEnv": {"MODE": "ci"}, "postCreateCommand": "make deps"},
+					"gpu": {"runArgs": ["--gpus=all"]}
+				}
+			}
+		}
+	}`)
+	writeConfig(t, filepath.Join(dir, ".devcontainer", "devcontainer.ci.json"), `{
+		// Applied after the inline ci profile
+		"containerEnv": {"CI": "true"},
+		"forwardPorts": [9090]
+	}`)
+	writeConfig(t, filepath.Join(dir, ".devcontainer", "devcontainer.slim.json"), `{"image": "golang:1.22-alpine"}`)
+
+	base, err := LoadDevContainer(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	profiles, err := AvailableProfiles(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(profiles, ",") != "ci,gpu,slim" {
+		t.Errorf("unexpected profiles %v", profiles)
+	}
+
+	dc, err := ApplyProfiles(base, "ci", "slim")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEnv := map[string]string{"MODE": "ci", "GOFLAGS": "-mod=mod", "CI": "true"}
+	if !reflect.DeepEqual(dc.ContainerEnv, wantEnv) {
+		t.Errorf("unexpected containerEnv %v", dc.ContainerEnv)
+	}
+	if dc.imageName() != "golang:1.22-alpine" {
+		t.Errorf("expected slim image, got %s", dc.imageName())
+	}
+	if len(dc.ForwardPorts) != 2 {
+		t.Errorf("expected forwardPorts to be unioned, got %v", dc.ForwardPorts)
+	}
+	if dc.ConfigFilePath != base.ConfigFilePath {
+		t.Errorf("expected config path to be kept, got %s", dc.ConfigFilePath)
+	}
+	if base.ContainerEnv["MODE"] != "dev" || base.Profiles != nil {
+		t.Error("expected base config to be unchanged")
+	}
+
+	config, err := BuildDockerRunCommand(dc, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Labels[LabelProfiles] != "ci,slim" {
+		t.Errorf("unexpected labels %v", config.Labels)
+	}
+	if !strings.Contains(strings.Join(config.ToDockerRunArgs(), " "), "--label "+LabelProfiles+"=ci,slim") {
+		t.Errorf("expected profiles label in run args, got %v", config.ToDockerRunArgs())
+	}
+
+	for _, name := range []string{"missing", "../ci", ""} {
+		if _, err := ApplyProfiles(base, name); err == nil {
+			t.Errorf("expected error for profile %q", name)
+		}
+	}
+}
+
+func TestManagerProfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeConfig(t, filepath.Join(dir, ".devcontainer.json"), `{"image": "alpine:3.19"}`)
+	writeConfig(t, filepath.Join(dir, ".devcontainer.debug.json"), `{"privileged": true}`)
+
+	mgr := &Manager{}
+	dc, err := mgr.loadDevContainer(context.Background(), dir, api.ApplyCreateOptions(api.WithProfiles("debug")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.Privileged == nil || !*dc.Privileged || !reflect.DeepEqual(dc.Profiles, []string{"debug"}) {
+		t.Errorf("expected debug profile to be applied, got %+v", dc)
+	}
+	if _, err := mgr.loadDevContainer(context.Background(), dir, api.ApplyCreateOptions(api.WithProfiles("release"))); err == nil {
+		t.Error("expected error for an unknown profile")
+	}
+}
@@ -0,0 +1,215 @@
+package devcontainer
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ReadConfigurationResult is the resolved configuration of a workspace in
+// the shape printed by `devcontainer read-configuration
+// --include-merged-configuration` of the reference CLI
+type ReadConfigurationResult struct {
+	// Configuration is the devcontainer.json with extends, profiles and
+	// local variables applied
+	Configuration map[string]interface{} `json:"configuration"`
+
+	// Workspace is where the workspace is mounted in the container
+	Workspace WorkspaceConfiguration `json:"workspace"`
+
+	// MergedConfiguration combines the image metadata with Configuration.
+	// Properties collected from every source use plural names, e.g.
+	// postCreateCommands lists the postCreateCommand of each source in order.
+	MergedConfiguration map[string]interface{} `json:"mergedConfiguration,omitempty"`
+}
+
+// WorkspaceConfiguration is the workspace of a ReadConfigurationResult
+type WorkspaceConfiguration struct {
+	WorkspaceFolder string `json:"workspaceFolder"`
+	WorkspaceMount  string `json:"workspaceMount,omitempty"`
+}
+
+// ConfigFileURI is the URI form the reference CLI uses for configFilePath
+type ConfigFileURI struct {
+	Mid    int    `json:"$mid"`
+	FsPath string `json:"fsPath"`
+	Path   string `json:"path"`
+	Scheme string `json:"scheme"`
+}
+
+// replacedProperties are the properties mergedConfiguration replaces with
+// their collected (plural) form
+var replacedProperties = map[string]bool{
+	"customizations":       true,
+	"entrypoint":           true,
+	"onCreateCommand":      true,
+	"updateContentCommand": true,
+	"postCreateCommand":    true,
+	"postStartCommand":     true,
+	"postAttachCommand":    true,
+	"shutdownAction":       true,
+}
+
+// lastWinsMetadataProperties are taken from the last source setting them
+var lastWinsMetadataProperties = []string{
+	"waitFor",
+	"remoteUser",
+	"containerUser",
+	"userEnvProbe",
+	"overrideCommand",
+	"otherPortsAttributes",
+	"shutdownAction",
+	"updateRemoteUserUID",
+}
+
+// ReadConfiguration resolves dc for the local workspace folder. Variables
+// such as ${localWorkspaceFolder} and ${devcontainerId} are substituted and
+// metadata, the devcontainer.metadata entries of the image, is merged under
+// the configuration the way the reference CLI does. Features are reported
+// as declared; their own metadata is not fetched. dc is not modified.
+func ReadConfiguration(dc *DevContainer, workspaceFolder string, metadata []*DevContainer) (*ReadConfigurationResult, error) {
+	vars := GetStandardVariables(absPath(workspaceFolder))
+	vars["devcontainerId"] = dc.DevContainerID(workspaceFolder)
+	resolved := expandedCopy(dc, vars)
+
+	configuration, ok := jsonValue(resolved).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to encode devcontainer config")
+	}
+	if dc.ConfigFilePath != "" {
+		configuration["configFilePath"] = fileURI(dc.ConfigFilePath)
+	}
+
+	folder, mount := containerWorkspace(resolved, workspaceFolder)
+	entries := append(append([]*DevContainer{}, metadata...), resolved)
+	return &ReadConfigurationResult{
+		Configuration:       configuration,
+		Workspace:           WorkspaceConfiguration{WorkspaceFolder: folder, WorkspaceMount: mount},
+		MergedConfiguration: mergedConfiguration(configuration, entries),
+	}, nil
+}
+
+// expandedCopy returns a copy of dc with variables expanded, leaving the
+// maps and slices of dc untouched
+func expandedCopy(dc *DevContainer, vars map[string]string) *DevContainer {
+	clone := *dc
+	clone.Mounts = append([]MountEntry(nil), dc.Mounts...)
+	clone.ContainerEnv = mergeMaps(dc.ContainerEnv, nil)
+	if dc.NonComposeBase != nil {
+		ncb := *dc.NonComposeBase
+		clone.NonComposeBase = &ncb
+	}
+	ExpandVariables(&clone, vars)
+	return &clone
+}
+
+// mergedConfiguration merges metadata entries, the configuration last, into
+// the mergedConfiguration of the reference CLI
+func mergedConfiguration(configuration map[string]interface{}, entries []*DevContainer) map[string]interface{} {
+	merged := make(map[string]interface{}, len(configuration))
+	for k, v := range configuration {
+		if !replacedProperties[k] {
+			merged[k] = v
+		}
+	}
+
+	var (
+		init, privileged bool
+		capAdd, secOpt   []string
+		ports            []Port
+		mounts           []MountEntry
+		host             *DevContainerCommonHostRequirements
+		entrypoints      []interface{}
+		customizations   = map[string][]interface{}{}
+		commands         = map[string][]interface{}{}
+		objects          = map[string]map[string]interface{}{
+			"containerEnv":    {},
+			"remoteEnv":       {},
+			"portsAttributes": {},
+		}
+	)
+	for _, entry := range entries {
+		raw, _ := jsonValue(entry).(map[string]interface{})
+
+		init = init || (entry.Init != nil && *entry.Init)
+		privileged = privileged || (entry.Privileged != nil && *entry.Privileged)
+		capAdd = unionStrings(capAdd, entry.CapAdd)
+		secOpt = unionStrings(secOpt, entry.SecurityOpt)
+		ports = unionPorts(ports, entry.ForwardPorts)
+		mounts = mergeMounts(mounts, entry.Mounts)
+		host = mergeHostRequirements(host, entry.HostRequirements)
+		if v, ok := raw["entrypoint"]; ok {
+			entrypoints = append(entrypoints, v)
+		}
+		for _, k := range sortedKeys(entry.Customizations) {
+			customizations[k] = append(customizations[k], entry.Customizations[k])
+		}
+		for _, phase := range lifecyclePhases {
+			commands[phase.name] = append(commands[phase.name], lifecycleSteps(phase.command(entry))...)
+		}
+		for name, values := range objects {
+			if m, ok := raw[name].(map[string]interface{}); ok {
+				for k, v := range m {
+					values[k] = v
+				}
+			}
+		}
+		for _, name := range lastWinsMetadataProperties {
+			if v, ok := raw[name]; ok {
+				merged[name] = v
+			}
+		}
+	}
+
+	merged["init"] = init
+	merged["privileged"] = privileged
+	for name, values := range objects {
+		merged[name] = values
+	}
+	setOrDelete(merged, "capAdd", capAdd, len(capAdd) > 0)
+	setOrDelete(merged, "securityOpt", secOpt, len(secOpt) > 0)
+	setOrDelete(merged, "forwardPorts", jsonValue(ports), len(ports) > 0)
+	setOrDelete(merged, "mounts", jsonValue(mounts), len(mounts) > 0)
+	setOrDelete(merged, "hostRequirements", jsonValue(host), host != nil)
+	setOrDelete(merged, "entrypoints", entrypoints, len(entrypoints) > 0)
+	setOrDelete(merged, "customizations", customizations, len(customizations) > 0)
+	for _, phase := range lifecyclePhases {
+		steps := commands[phase.name]
+		setOrDelete(merged, strings.TrimSuffix(phase.name, "Command")+"Commands", steps, len(steps) > 0)
+	}
+	return merged
+}
+
+// lifecycleSteps returns the JSON form of each command of an accumulated
+// lifecycle command
+func lifecycleSteps(cmd *LifecycleCommand) []interface{} {
+	if cmd == nil {
+		return nil
+	}
+	if cmd.Type != CommandTypeSequence {
+		return []interface{}{jsonValue(cmd)}
+	}
+	var steps []interface{}
+	for _, step := range cmd.Sequence {
+		steps = append(steps, lifecycleSteps(step)...)
+	}
+	return steps
+}
+
+func setOrDelete(m map[string]interface{}, key string, value interface{}, set bool) {
+	if set {
+		m[key] = value
+	} else {
+		delete(m, key)
+	}
+}
+
+// fileURI returns the file URI of a local path
+func fileURI(path string) ConfigFileURI {
+	path = absPath(path)
+	uriPath := filepath.ToSlash(path)
+	if !strings.HasPrefix(uriPath, "/") {
+		uriPath = "/" + uriPath
+	}
+	return ConfigFileURI{Mid: 1, FsPath: path, Path: uriPath, Scheme: "file"}
+}
@@ -0,0 +1,94 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadConfiguration(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, ".devcontainer", "devcontainer.json")
+	writeConfig(t, filepath.Join(dir, ".devcontainer", "base.json"), `{"postCreateCommand": "make setup", "capAdd": ["SYS_PTRACE"]}`)
+	writeConfig(t, path, `{
+		"extends": "./base.json",
+		"image": "golang:1.22",
+		"containerEnv": {"SRC": "${localWorkspaceFolder}/src"},
+		"postCreateCommand": ["go", "mod", "download"],
+		"customizations": {"vscode": {"extensions": ["golang.go"]}},
+		"shutdownAction": "none"
+	}`)
+	metadata, err := ParseImageMetadata(`[
+		{"remoteUser": "vscode", "postCreateCommand": "echo image", "containerEnv": {"GOPATH": "/go"}, "customizations": {"vscode": {"extensions": ["ms-vscode.go"]}}, "init": true},
+		{"entrypoint": "/usr/local/share/docker-init.sh", "capAdd": ["NET_ADMIN"]}
+	]`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dc, err := LoadDevContainerWithExtends(path, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := ReadConfiguration(dc, dir, metadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dc.ContainerEnv["SRC"] != "${localWorkspaceFolder}/src" {
+		t.Error("expected the input config to be unchanged")
+	}
+
+	// Round-trip through JSON to compare with what a consumer sees
+	data, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out struct {
+		Configuration       map[string]interface{} `json:"configuration"`
+		Workspace           map[string]string      `json:"workspace"`
+		MergedConfiguration map[string]interface{} `json:"mergedConfiguration"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	configFile := out.Configuration["configFilePath"].(map[string]interface{})
+	if configFile["fsPath"] != path || configFile["scheme"] != "file" || configFile["$mid"] != float64(1) {
+		t.Errorf("unexpected configFilePath %v", configFile)
+	}
+	if env := out.Configuration["containerEnv"].(map[string]interface{}); env["SRC"] != dir+"/src" {
+		t.Errorf("expected variables to be substituted, got %v", env)
+	}
+	if out.Workspace["workspaceFolder"] != "/workspaces/"+filepath.Base(dir) {
+		t.Errorf("unexpected workspace %v", out.Workspace)
+	}
+
+	merged := out.MergedConfiguration
+	tests := []struct {
+		property string
+		want     interface{}
+	}{
+		{"postCreateCommands", []interface{}{"echo image", "make setup", []interface{}{"go", "mod", "download"}}},
+		{"entrypoints", []interface{}{"/usr/local/share/docker-init.sh"}},
+		{"capAdd", []interface{}{"NET_ADMIN", "SYS_PTRACE"}},
+		{"customizations", map[string]interface{}{"vscode": []interface{}{
+			map[string]interface{}{"extensions": []interface{}{"ms-vscode.go"}},
+			map[string]interface{}{"extensions": []interface{}{"golang.go"}},
+		}}},
+		{"containerEnv", map[string]interface{}{"GOPATH": "/go", "SRC": dir + "/src"}},
+		{"remoteEnv", map[string]interface{}{}},
+		{"remoteUser", "vscode"},
+		{"shutdownAction", "none"},
+		{"init", true},
+		{"privileged", false},
+		{"image", "golang:1.22"},
+		{"postCreateCommand", nil},
+		{"onCreateCommands", nil},
+	}
+	for _, tt := range tests {
+		if got := merged[tt.property]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: expected %#v, got %#v", tt.property, tt.want, got)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// ProbeType is what a Probe checks
+type ProbeType string
+
+const (
+	ProbeStatus ProbeType = "status"      // The container reaches a status
+	ProbeHealth ProbeType = "healthcheck" // Docker HEALTHCHECK reports healthy
+	ProbeTCP    ProbeType = "tcp"         // A port listens inside the container
+	ProbeHTTP   ProbeType = "http"        // A GET inside the container answers 2xx
+	ProbeExec   ProbeType = "exec"        // A command exits 0 in the container
+)
+
+// Default probe settings
+const (
+	DefaultProbeTimeout   = time.Minute
+	defaultBackoffInitial = 100 * time.Millisecond
+	defaultBackoffMax     = 5 * time.Second
+	defaultBackoffFactor  = 2
+)
+
+// Probe is a readiness check of a container, retried with backoff until it
+// succeeds or its timeout expires
+type Probe struct {
+	Type    ProbeType
+	Status  api.Status // Status of status probes
+	Port    int        // Port of TCP and HTTP probes
+	Path    string     // Path of HTTP probes, "/" if empty
+	Command []string   // Command of exec probes
+
+	Timeout time.Duration // Time for the probe to succeed; DefaultProbeTimeout if zero
+	Backoff Backoff
+}
+
+// Backoff is the delay between the attempts of a probe, growing from Initial
+// by Factor up to Max
+type Backoff struct {
+	Initial time.Duration // 100ms if zero
+	Max     time.Duration // 5s if zero
+	Factor  float64       // 2 if zero
+}
+
+// StatusProbe waits for the container to reach status, e.g. api.StatusStopped
+func StatusProbe(status api.Status) Probe {
+	return Probe{Type: ProbeStatus, Status: status}
+}
+
+// HealthProbe waits for the Docker HEALTHCHECK of the container to report
+// healthy
+func HealthProbe() Probe {
+	return Probe{Type: ProbeHealth}
+}
+
+// TCPProbe waits for a port to listen inside the container, as read from
+// /proc/net/tcp
+func TCPProbe(port int) Probe {
+	return Probe{Type: ProbeTCP, Port: port}
+}
+
+// HTTPProbe waits for a GET of path on a port inside the container to
+// answer 2xx. It runs curl, or wget, in the container.
+func HTTPProbe(port int, path string) Probe {
+	return Probe{Type: ProbeHTTP, Port: port, Path: path}
+}
+
+// ExecProbe waits for command to exit 0 in the container
+func ExecProbe(command ...string) Probe {
+	return Probe{Type: ProbeExec, Command: command}
+}
+
+func (p Probe) String() string {
+	switch p.Type {
+	case ProbeStatus:
+		return fmt.Sprintf("status %s", p.Status)
+	case ProbeTCP:
+		return fmt.Sprintf("tcp port %d", p.Port)
+	case ProbeHTTP:
+		return fmt.Sprintf("http port %d %s", p.Port, p.path())
+	case ProbeExec:
+		return fmt.Sprintf("exec %q", p.Command)
+	}
+	return string(p.Type)
+}
+
+func (p Probe) path() string {
+	if p.Path == "" {
+		return "/"
+	}
+	if !strings.HasPrefix(p.Path, "/") {
+		return "/" + p.Path
+	}
+	return p.Path
+}
+
+// delay returns the wait after the given attempt, counting from 0
+func (b Backoff) delay(attempt int) time.Duration {
+	initial, max, factor := b.Initial, b.Max, b.Factor
+	if initial <= 0 {
+		initial = defaultBackoffInitial
+	}
+	if max <= 0 {
+		max = defaultBackoffMax
+	}
+	if factor < 1 {
+		factor = defaultBackoffFactor
+	}
+	d := float64(initial)
+	for i := 0; i < attempt && d < float64(max); i++ {
+		d *= factor
+	}
+	if d > float64(max) {
+		return max
+	}
+	return time.Duration(d)
+}
+
+// stopProbing is a probe failure that retrying cannot fix
+type stopProbing struct{ error }
+
+// WaitReady waits until all probes succeed in a container. The probes run
+// concurrently, each with its own timeout and backoff. Except for
+// StatusProbe, attempts fail while the container is created, paused or
+// restarting; a stopped or removed container fails the probes at once, as
+// does a missing healthcheck for HealthProbe.
+func (m *Manager) WaitReady(ctx context.Context, containerID string, probes ...Probe) error {
+	errs := make([]error, len(probes))
+	var wg sync.WaitGroup
+	for i, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = m.waitProbe(ctx, containerID, probe, nil)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+// waitProbe retries a probe until it succeeds, the container stops or the
+// timeout of the probe expires. An event on wake, e.g. a container event,
+// retries at once; a failed event ends the wait.
+func (m *Manager) waitProbe(ctx context.Context, containerID string, probe Probe, wake <-chan Event) error {
+	timeout := probe.Timeout
+	if timeout <= 0 {
+		timeout = DefaultProbeTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for attempt := 0; ; attempt++ {
+		err := m.probeOnce(ctx, containerID, probe)
+		if err == nil {
+			return nil
+		}
+		var stop stopProbing
+		if errors.As(err, &stop) {
+			return fmt.Errorf("%s failed: %w", probe, stop.error)
+		}
+		select {
+		case <-time.After(probe.Backoff.delay(attempt)):
+		case e, ok := <-wake:
+			if !ok {
+				wake = nil
+			} else if e.Err != nil {
+				return e.Err
+			}
+		case <-ctx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%s not ready after %s: %w", probe, timeout, err)
+			}
+			return ctx.Err()
+		}
+	}
+}
+
+// probeOnce makes a single attempt of a probe
+func (m *Manager) probeOnce(ctx context.Context, containerID string, probe Probe) error {
+	details, err := m.docker.InspectContainer(ctx, containerID)
+	if errors.Is(err, ErrNoSuchContainer) {
+		if probe.Type == ProbeStatus && probe.Status == api.StatusNone {
+			return nil
+		}
+		return stopProbing{err}
+	}
+	if err != nil {
+		return err
+	}
+	if probe.Type == ProbeStatus {
+		if status := mapDockerStatus(details.Status); status != probe.Status {
+			return fmt.Errorf("container is %s", status)
+		}
+		return nil
+	}
+	switch status := mapDockerStatus(details.Status); status {
+	case api.StatusRunning:
+	case api.StatusCreated, api.StatusRestarting, api.StatusPaused:
+		return fmt.Errorf("container is %s", status)
+	default:
+		return stopProbing{fmt.Errorf("container is %s", details.Status)}
+	}
+
+	switch probe.Type {
+	case ProbeHealth:
+		switch details.Health {
+		case "healthy":
+			return nil
+		case "":
+			return stopProbing{errors.New("container has no healthcheck")}
+		}
+		return fmt.Errorf("container is %s", details.Health)
+	case ProbeTCP:
+		out, err := m.docker.ExecAs(ctx, containerID, ExecOptions{}, tcpProbeCommand())
+		if err != nil {
+			return err
+		}
+		if !listening(out, probe.Port) {
+			return fmt.Errorf("port %d is not listening", probe.Port)
+		}
+		return nil
+	case ProbeHTTP:
+		out, err := m.docker.ExecAs(ctx, containerID, ExecOptions{}, httpProbeCommand(probe))
+		var execErr *ExecError
+		if errors.As(err, &execErr) && execErr.ExitCode == noHTTPClient {
+			return stopProbing{errors.New("container has neither curl nor wget")}
+		}
+		if err != nil {
+			return err
+		}
+		code, _ := strconv.Atoi(strings.TrimSpace(out))
+		if code < 200 || code > 299 {
+			return fmt.Errorf("http status %q", strings.TrimSpace(out))
+		}
+		return nil
+	case ProbeExec:
+		_, err := m.docker.ExecAs(ctx, containerID, ExecOptions{}, probe.Command)
+		return err
+	}
+	return stopProbing{fmt.Errorf("unknown probe type %q", probe.Type)}
+}
+
+// tcpProbeCommand returns the command printing the sockets of the container.
+// Kernels without IPv6 have no /proc/net/tcp6, which is skipped.
+func tcpProbeCommand() []string {
+	return []string{"sh", "-c", `cat "$@" 2>/dev/null; true`, "probe", "/proc/net/tcp", "/proc/net/tcp6"}
+}
+
+// noHTTPClient is the exit code of httpProbeCommand in a container having
+// neither curl nor wget
+const noHTTPClient = 127
+
+// httpProbeCommand returns the command printing the status code of a GET
+func httpProbeCommand(probe Probe) []string {
+	url := fmt.Sprintf("http://127.0.0.1:%d%s", probe.Port, probe.path())
+	script := `if command -v curl >/dev/null 2>&1; then curl -s -o /dev/null -w '%{http_code}' "$1"; ` +
+		`elif command -v wget >/dev/null 2>&1; then wget -q -S -O /dev/null "$1" 2>&1 | awk '/HTTP\//{code=$2} END{print code}'; ` +
+		`else echo "neither curl nor wget found" >&2; exit ` + strconv.Itoa(noHTTPClient) + `; fi`
+	return []string{"sh", "-c", script, "probe", url}
+}
+
+// listening reports whether /proc/net/tcp output has a socket listening on
+// port
+func listening(procNetTCP string, port int) bool {
+	for _, line := range strings.Split(procNetTCP, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 4 || fields[3] != "0A" { // TCP_LISTEN
+			continue
+		}
+		i := strings.LastIndex(fields[1], ":")
+		if i < 0 {
+			continue
+		}
+		if p, err := strconv.ParseUint(fields[1][i+1:], 16, 16); err == nil && int(p) == port {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package devcontainer
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// procNetTCP has a socket listening on port 5432 (0x1538) and a connection
+// from port 8080 (0x1F90)
+const procNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:1538 00000000:0000 0A 00000000:00000000 00:00000000 00000000   999        0 1234 1 0000000000000000 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000  1000        0 5678 1 0000000000000000 20 4 30 10 -1
+`
+
+func TestListening(t *testing.T) {
+	if !listening(procNetTCP, 5432) {
+		t.Error("expected port 5432 to listen")
+	}
+	if listening(procNetTCP, 8080) {
+		t.Error("expected a connected port not to listen")
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: time.Second, Max: 5 * time.Second, Factor: 2}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := b.delay(attempt); got != want {
+			t.Errorf("delay(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+	if got := (Backoff{}).delay(1); got != 200*time.Millisecond {
+		t.Errorf("expected default backoff to double 100ms, got %s", got)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine, id := newFakeContainer(t, `{"image": "postgres:16"}`, "postgres:16")
+	fast := Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+	// The services come up after a few attempts
+	var mu sync.Mutex
+	attempts := map[string]int{}
+	engine.ExecFunc = func(containerID string, opts ExecOptions, command []string) (string, int) {
+		mu.Lock()
+		defer mu.Unlock()
+		probe := command[0]
+		if probe == "sh" && command[len(command)-1] == "/proc/net/tcp6" {
+			probe = "tcp"
+		}
+		attempts[probe]++
+		ready := attempts[probe] > 2
+		switch probe {
+		case "tcp":
+			if ready {
+				return procNetTCP, 0
+			}
+			return "", 0
+		case "sh":
+			if !strings.HasSuffix(command[len(command)-1], ":8080/health") {
+				t.Errorf("unexpected http probe %q", command)
+			}
+			if ready {
+				return "204", 0
+			}
+			return "000", 0
+		case "pg_isready":
+			if ready {
+				return "", 0
+			}
+			return "", 2
+		}
+		return "", 127
+	}
+
+	probes := []Probe{TCPProbe(5432), HTTPProbe(8080, "health"), ExecProbe("pg_isready"), HealthProbe()}
+	for i := range probes {
+		probes[i].Backoff = fast
+		probes[i].Timeout = 5 * time.Second
+	}
+
+	// The container is started and becomes healthy while the probes wait;
+	// like Docker, it reports starting until the first healthcheck
+	engine.UpdateContainer(id, func(c *FakeContainer) { c.Health = "starting" })
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		mgr.Start(ctx, id)
+		time.Sleep(10 * time.Millisecond)
+		engine.UpdateContainer(id, func(c *FakeContainer) { c.Health = "healthy" })
+	}()
+	if err := mgr.WaitReady(ctx, id, probes...); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWaitReadyFailures(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine, id := newFakeContainer(t, `{"image": "postgres:16"}`, "postgres:16")
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	engine.ExecFunc = func(containerID string, opts ExecOptions, command []string) (string, int) {
+		return "", 1
+	}
+
+	// Probes time out with the last failure
+	probe := ExecProbe("false")
+	probe.Timeout = 30 * time.Millisecond
+	probe.Backoff = Backoff{Initial: time.Millisecond}
+	err := mgr.WaitReady(ctx, id, probe)
+	if err == nil || !strings.Contains(err.Error(), `exec ["false"] not ready after 30ms`) || !strings.Contains(err.Error(), "exit code 1") {
+		t.Errorf("expected a timeout, got %v", err)
+	}
+
+	// Without a healthcheck there is nothing to wait for
+	start := time.Now()
+	if err := mgr.WaitReady(ctx, id, HealthProbe()); err == nil || !strings.Contains(err.Error(), "no healthcheck") {
+		t.Errorf("expected a missing healthcheck to fail, got %v", err)
+	}
+
+	// Nor without an HTTP client
+	engine.ExecFunc = func(containerID string, opts ExecOptions, command []string) (string, int) {
+		return "", noHTTPClient
+	}
+	if err := mgr.WaitReady(ctx, id, HTTPProbe(8080, "/")); err == nil || !strings.Contains(err.Error(), "neither curl nor wget") {
+		t.Errorf("expected a missing HTTP client to fail, got %v", err)
+	}
+
+	// Nor in a stopped container
+	if err := mgr.Stop(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if err := mgr.WaitReady(ctx, id, TCPProbe(5432)); err == nil || !strings.Contains(err.Error(), "container is exited") {
+		t.Errorf("expected a stopped container to fail, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("expected permanent failures not to be retried, took %s", elapsed)
+	}
+}
+
+func TestProbeCommands(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("probe commands are shell scripts")
+	}
+
+	// Without /proc/net/tcp6 the sockets of /proc/net/tcp are still listed
+	dir := t.TempDir()
+	tcp := filepath.Join(dir, "tcp")
+	writeFile(t, tcp, procNetTCP)
+	command := tcpProbeCommand()
+	command = append(command[:len(command)-2], tcp, filepath.Join(dir, "tcp6"))
+	out, err := exec.Command(command[0], command[1:]...).Output()
+	if err != nil || !listening(string(out), 5432) {
+		t.Errorf("expected port 5432 to listen, got %q: %v", out, err)
+	}
+
+	// Without curl and wget the HTTP probe reports it cannot run
+	command = httpProbeCommand(HTTPProbe(8080, "/"))
+	cmd := exec.Command(command[0], command[1:]...)
+	cmd.Env = []string{"PATH=" + dir}
+	var exitErr *exec.ExitError
+	if err := cmd.Run(); !errors.As(err, &exitErr) || exitErr.ExitCode() != noHTTPClient {
+		t.Errorf("expected exit code %d, got %v", noHTTPClient, err)
+	}
+}
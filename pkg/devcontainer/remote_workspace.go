@@ -0,0 +1,563 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+// LabelWorkspaceVolume is set on containers whose workspace was copied into
+// a volume, to the name of the volume
+const LabelWorkspaceVolume = "devcontainer-go.workspace_volume"
+
+// workspaceManifestDir and workspaceManifestFile locate the record of the
+// last workspace transfer inside a container
+const (
+	workspaceManifestDir  = "/var/tmp/devcontainer-go"
+	workspaceManifestFile = "workspace.json"
+)
+
+// WorkspaceSyncResult lists the workspace paths a transfer touched,
+// slash-separated and relative to the workspace folder
+type WorkspaceSyncResult struct {
+	Uploaded   []string `json:"uploaded,omitempty"`
+	Deleted    []string `json:"deleted,omitempty"`
+	Downloaded []string `json:"downloaded,omitempty"`
+	Conflicts  []string `json:"conflicts,omitempty"` // Changed on both sides and left alone
+}
+
+// workspaceEntry is the state of a workspace path at the last transfer
+type workspaceEntry struct {
+	Mode    fs.FileMode `json:"mode"`
+	Size    int64       `json:"size,omitempty"`
+	ModTime int64       `json:"modTime,omitempty"` // Unix seconds, the precision tar keeps
+	Link    string      `json:"link,omitempty"`
+}
+
+// same reports whether two entries describe the same content. Directories
+// only compare by type, their times change with their contents.
+func (e workspaceEntry) same(other workspaceEntry) bool {
+	if e.Mode.IsDir() || other.Mode.IsDir() {
+		return e.Mode.IsDir() && other.Mode.IsDir()
+	}
+	return e.Mode == other.Mode && e.Size == other.Size && e.ModTime == other.ModTime && e.Link == other.Link
+}
+
+// IsRemoteDockerHost reports whether a daemon address is on another machine,
+// whose containers cannot bind mount local paths
+func IsRemoteDockerHost(host string) bool {
+	u, err := url.Parse(host)
+	if err != nil {
+		return false
+	}
+	switch u.Scheme {
+	case "tcp", "http", "https", "ssh":
+	default:
+		return false // unix and npipe sockets are local
+	}
+	hostname := u.Hostname()
+	if hostname == "localhost" {
+		return false
+	}
+	ip := net.ParseIP(hostname)
+	return ip == nil || !ip.IsLoopback()
+}
+
+// WorkspaceVolumeName returns the volume a remote workspace is copied into
+func WorkspaceVolumeName(dc *DevContainer, localFolder string) string {
+	return "devcontainer-" + dc.DevContainerID(localFolder) + "-workspace"
+}
+
+// remoteWorkspace reports whether Create copies the workspace into a volume
+func (m *Manager) remoteWorkspace(opts api.CreateOptions) bool {
+	if opts.RemoteWorkspace {
+		return true
+	}
+	return m.dockerClient != nil && IsRemoteDockerHost(m.dockerClient.Endpoint().Host)
+}
+
+// hasDefaultWorkspaceMount reports whether the workspace would be bind
+// mounted from the local folder, as opposed to a configured workspaceMount
+func hasDefaultWorkspaceMount(dc *DevContainer) bool {
+	return dc.WorkspaceMount == "" && (dc.NonComposeBase == nil || dc.NonComposeBase.WorkspaceMount == nil)
+}
+
+// SyncWorkspace uploads the local changes made to the workspace since the
+// last transfer to a container with a remote workspace. Files deleted
+// locally are removed with rm, which needs the container to be running.
+func (m *Manager) SyncWorkspace(ctx context.Context, containerID, nodePath string, opts ...api.CreateOption) (*WorkspaceSyncResult, error) {
+	dc, err := m.resolveDevContainer(ctx, nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	return m.uploadWorkspace(ctx, containerID, dc, nodePath)
+}
+
+// DownloadWorkspace copies the files changed in the workspace of a container
+// since the last transfer back to the local folder. Files changed on both
+// sides are reported as conflicts and not downloaded; files deleted in the
+// container are kept locally.
+func (m *Manager) DownloadWorkspace(ctx context.Context, containerID, nodePath string, opts ...api.CreateOption) (*WorkspaceSyncResult, error) {
+	dc, err := m.resolveDevContainer(ctx, nodePath, api.ApplyCreateOptions(opts...))
+	if err != nil {
+		return nil, err
+	}
+	return m.downloadWorkspace(ctx, containerID, dc, nodePath)
+}
+
+// uploadWorkspace copies the local workspace paths that changed since the
+// last transfer into the container, owned by the remote user
+func (m *Manager) uploadWorkspace(ctx context.Context, containerID string, dc *DevContainer, localFolder string) (*WorkspaceSyncResult, error) {
+	folder, _ := containerWorkspace(dc, localFolder)
+	local, _, err := scanWorkspace(localFolder)
+	if err != nil {
+		return nil, err
+	}
+	last := m.readWorkspaceManifest(ctx, containerID)
+
+	result := &WorkspaceSyncResult{}
+	var deleted []string
+	for _, name := range sortedKeys(last) {
+		entry, ok := local[name]
+		if !ok || entry.Mode.Type() != last[name].Mode.Type() {
+			deleted = append(deleted, name)
+		}
+	}
+	for _, name := range sortedKeys(local) {
+		if entry, ok := last[name]; !ok || !entry.same(local[name]) {
+			result.Uploaded = append(result.Uploaded, name)
+		}
+	}
+
+	if len(deleted) > 0 {
+		command := []string{"rm", "-rf", "--"}
+		for _, name := range deleted {
+			command = append(command, path.Join(folder, name))
+		}
+		if _, err := m.docker.ExecAs(ctx, containerID, ExecOptions{User: "root"}, command); err != nil {
+			return nil, fmt.Errorf("failed to delete workspace files: %w", err)
+		}
+		for _, name := range deleted {
+			if _, ok := local[name]; !ok {
+				result.Deleted = append(result.Deleted, name)
+			}
+		}
+	}
+
+	if len(result.Uploaded) > 0 {
+		owner, err := lookupContainerUser(ctx, m.docker, containerID, remoteUser(dc))
+		if err != nil {
+			return nil, err
+		}
+		pr, pw := io.Pipe()
+		go func() {
+			tw := tar.NewWriter(pw)
+			err := archiveWorkspace(tw, localFolder, result.Uploaded, local, owner)
+			if err == nil {
+				err = tw.Close()
+			}
+			pw.CloseWithError(err)
+		}()
+		err = m.docker.CopyToContainer(ctx, containerID, folder, pr)
+		pr.CloseWithError(err) // Stops the writer if the copy failed early
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload workspace: %w", err)
+		}
+	}
+
+	if err := m.writeWorkspaceManifest(ctx, containerID, local); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// downloadWorkspace writes the container's workspace paths that changed
+// since the last transfer to the local folder
+func (m *Manager) downloadWorkspace(ctx context.Context, containerID string, dc *DevContainer, localFolder string) (*WorkspaceSyncResult, error) {
+	folder, _ := containerWorkspace(dc, localFolder)
+	local, ignore, err := scanWorkspace(localFolder)
+	if err != nil {
+		return nil, err
+	}
+	root, err := filepath.EvalSymlinks(absPath(localFolder))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve workspace folder: %w", err)
+	}
+	last := m.readWorkspaceManifest(ctx, containerID)
+	manifest := copyWorkspaceEntries(last)
+
+	reader, err := m.docker.CopyFromContainer(ctx, containerID, folder)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	result := &WorkspaceSyncResult{}
+	skipped := ""
+	tr := tar.NewReader(reader)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to download workspace: %w", err)
+		}
+		// Entries are rooted at the base name of the folder
+		_, name, _ := strings.Cut(strings.TrimSuffix(hdr.Name, "/"), "/")
+		name = path.Clean(name)
+		if name == "." || name == ".." || strings.HasPrefix(name, "../") {
+			continue
+		}
+		if skipped != "" && strings.HasPrefix(name, skipped+"/") {
+			continue
+		}
+		remote := tarEntry(hdr)
+		if ignore.ignored(name, remote.Mode.IsDir()) {
+			if remote.Mode.IsDir() {
+				skipped = name
+			}
+			continue
+		}
+
+		previous, known := last[name]
+		if known && previous.same(remote) {
+			continue // Unchanged in the container
+		}
+		current, exists := local[name]
+		if exists && current.same(remote) {
+			manifest[name] = remote
+			continue
+		}
+		if exists && (!known || !current.same(previous)) {
+			result.Conflicts = append(result.Conflicts, name)
+			if remote.Mode.IsDir() {
+				skipped = name
+			}
+			continue
+		}
+
+		target := filepath.Join(root, filepath.FromSlash(name))
+		if err := checkInside(root, filepath.Dir(target)); err != nil {
+			return nil, err
+		}
+		if err := writeArchiveEntry(target, hdr, tr); err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", name, err)
+		}
+		manifest[name] = remote
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if _, ok := manifest[dir]; !ok {
+				manifest[dir] = workspaceEntry{Mode: fs.ModeDir | 0755} // Created for the entry
+			}
+		}
+		if !remote.Mode.IsDir() || !exists {
+			result.Downloaded = append(result.Downloaded, name)
+		}
+	}
+
+	if err := m.writeWorkspaceManifest(ctx, containerID, manifest); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// scanWorkspace returns the entries of the workspace at dir that are not
+// ignored, by slash-separated relative path, and the ignore rules it found
+func scanWorkspace(dir string) (map[string]workspaceEntry, *ignoreMatcher, error) {
+	fsys := os.DirFS(dir)
+	ignore := &ignoreMatcher{}
+	entries := map[string]workspaceEntry{}
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if name != "." {
+			if ignore.ignored(name, d.IsDir()) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			entry := workspaceEntry{Mode: info.Mode() & (fs.ModeType | fs.ModePerm)}
+			if entry.Mode.IsRegular() {
+				entry.Size = info.Size()
+				entry.ModTime = info.ModTime().Unix()
+			} else if entry.Mode&fs.ModeSymlink != 0 {
+				if entry.Link, err = os.Readlink(filepath.Join(dir, filepath.FromSlash(name))); err != nil {
+					return err
+				}
+			} else if !entry.Mode.IsDir() {
+				return nil // Devices, sockets and pipes are not copied
+			}
+			entries[name] = entry
+		}
+		if d.IsDir() {
+			return ignore.load(fsys, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to scan workspace: %w", err)
+	}
+	return entries, ignore, nil
+}
+
+// archiveWorkspace writes the given workspace paths to tw, owned by owner
+func archiveWorkspace(tw *tar.Writer, dir string, names []string, entries map[string]workspaceEntry, owner containerOwner) error {
+	for _, name := range names {
+		entry := entries[name]
+		hdr := &tar.Header{
+			Name:    name,
+			Mode:    int64(entry.Mode.Perm()),
+			ModTime: time.Unix(entry.ModTime, 0),
+			Uid:     owner.UID,
+			Gid:     owner.GID,
+		}
+		switch {
+		case entry.Mode.IsDir():
+			hdr.Typeflag = tar.TypeDir
+			hdr.Name += "/"
+			hdr.ModTime = time.Now()
+		case entry.Mode&fs.ModeSymlink != 0:
+			hdr.Typeflag = tar.TypeSymlink
+			hdr.Linkname = entry.Link
+		default:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = entry.Size
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		f, err := os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+		_, err = io.CopyN(tw, f, entry.Size)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("failed to archive %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// tarEntry returns the workspace entry of an archived path
+func tarEntry(hdr *tar.Header) workspaceEntry {
+	info := hdr.FileInfo()
+	entry := workspaceEntry{Mode: info.Mode() & (fs.ModeType | fs.ModePerm)}
+	switch {
+	case entry.Mode.IsRegular():
+		entry.Size = hdr.Size
+		entry.ModTime = hdr.ModTime.Unix()
+	case entry.Mode&fs.ModeSymlink != 0:
+		entry.Link = hdr.Linkname
+	}
+	return entry
+}
+
+// writeArchiveEntry writes an archived path to target, keeping the
+// modification time so the next sync sees it as unchanged
+func writeArchiveEntry(target string, hdr *tar.Header, content io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return err
+	}
+	switch hdr.Typeflag {
+	case tar.TypeDir:
+		return os.MkdirAll(target, 0755)
+	case tar.TypeSymlink:
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		return os.Symlink(hdr.Linkname, target)
+	case tar.TypeReg:
+		if info, err := os.Lstat(target); err == nil && !info.Mode().IsRegular() {
+			if err := os.RemoveAll(target); err != nil {
+				return err
+			}
+		}
+		f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, hdr.FileInfo().Mode().Perm())
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, content); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		if err := os.Chmod(target, hdr.FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+		return os.Chtimes(target, hdr.ModTime, hdr.ModTime)
+	}
+	return nil // Other types are not copied
+}
+
+// checkInside fails unless dir, or its closest existing parent, is inside
+// root with symlinks resolved
+func checkInside(root, dir string) error {
+	resolved, err := filepath.EvalSymlinks(dir)
+	for os.IsNotExist(err) && filepath.Dir(dir) != dir {
+		dir = filepath.Dir(dir)
+		resolved, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return err
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to write outside of the workspace: %s", dir)
+	}
+	return nil
+}
+
+// readWorkspaceManifest returns the entries of the last transfer, none if
+// the container has no record of one
+func (m *Manager) readWorkspaceManifest(ctx context.Context, containerID string) map[string]workspaceEntry {
+	entries := map[string]workspaceEntry{}
+	reader, err := m.docker.CopyFromContainer(ctx, containerID, path.Join(workspaceManifestDir, workspaceManifestFile))
+	if err != nil {
+		return entries
+	}
+	defer reader.Close()
+	tr := tar.NewReader(reader)
+	if _, err := tr.Next(); err != nil {
+		return entries
+	}
+	if err := json.NewDecoder(tr).Decode(&entries); err != nil {
+		return map[string]workspaceEntry{}
+	}
+	return entries
+}
+
+// writeWorkspaceManifest records the entries of a transfer in the container
+func (m *Manager) writeWorkspaceManifest(ctx context.Context, containerID string, entries map[string]workspaceEntry) error {
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return fmt.Errorf("failed to encode workspace manifest: %w", err)
+	}
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	base := path.Base(workspaceManifestDir)
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeDir, Name: base + "/", Mode: 0700, ModTime: time.Now()}); err != nil {
+		return fmt.Errorf("failed to encode workspace manifest: %w", err)
+	}
+	if err := tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: base + "/" + workspaceManifestFile, Mode: 0600, Size: int64(len(data)), ModTime: time.Now()}); err != nil {
+		return fmt.Errorf("failed to encode workspace manifest: %w", err)
+	}
+	if _, err := tw.Write(data); err != nil {
+		return fmt.Errorf("failed to encode workspace manifest: %w", err)
+	}
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to encode workspace manifest: %w", err)
+	}
+	if err := m.docker.CopyToContainer(ctx, containerID, path.Dir(workspaceManifestDir), &buf); err != nil {
+		return fmt.Errorf("failed to write workspace manifest: %w", err)
+	}
+	return nil
+}
+
+// copyWorkspaceEntries returns a copy of entries
+func copyWorkspaceEntries(entries map[string]workspaceEntry) map[string]workspaceEntry {
+	result := make(map[string]workspaceEntry, len(entries))
+	for k, v := range entries {
+		result[k] = v
+	}
+	return result
+}
+
+// containerOwner is a numeric user and group in a container
+type containerOwner struct {
+	UID int
+	GID int
+}
+
+// lookupContainerUser resolves a user[:group] specification to numeric IDs
+// using the container's /etc/passwd and /etc/group. An empty user is root.
+func lookupContainerUser(ctx context.Context, engine Engine, containerID, spec string) (containerOwner, error) {
+	user, group, hasGroup := strings.Cut(spec, ":")
+	if user == "" || user == "root" {
+		user = "0"
+	}
+
+	owner := containerOwner{}
+	uid, err := strconv.Atoi(user)
+	if err == nil {
+		owner.UID, owner.GID = uid, uid
+		if fields, err := lookupContainerDatabase(ctx, engine, containerID, "/etc/passwd", 2, user); err == nil && fields != nil {
+			owner.GID, _ = strconv.Atoi(fields[3])
+		}
+	} else {
+		fields, err := lookupContainerDatabase(ctx, engine, containerID, "/etc/passwd", 0, user)
+		if err != nil {
+			return owner, err
+		}
+		if fields == nil {
+			return owner, fmt.Errorf("user %s not found in container", user)
+		}
+		owner.UID, _ = strconv.Atoi(fields[2])
+		owner.GID, _ = strconv.Atoi(fields[3])
+	}
+
+	if hasGroup && group != "" {
+		if gid, err := strconv.Atoi(group); err == nil {
+			owner.GID = gid
+			return owner, nil
+		}
+		fields, err := lookupContainerDatabase(ctx, engine, containerID, "/etc/group", 0, group)
+		if err != nil {
+			return owner, err
+		}
+		if fields == nil {
+			return owner, fmt.Errorf("group %s not found in container", group)
+		}
+		owner.GID, _ = strconv.Atoi(fields[2])
+	}
+	return owner, nil
+}
+
+// lookupContainerDatabase returns the fields of the first line of a
+// colon-separated database like /etc/passwd whose field key equals value,
+// or nil if there is none
+func lookupContainerDatabase(ctx context.Context, engine Engine, containerID, file string, key int, value string) ([]string, error) {
+	reader, err := engine.CopyFromContainer(ctx, containerID, file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	defer reader.Close()
+	tr := tar.NewReader(reader)
+	if _, err := tr.Next(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	scanner := bufio.NewScanner(tr)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ":")
+		if len(fields) >= 4 && fields[key] == value {
+			return fields, nil
+		}
+	}
+	return nil, scanner.Err()
+}
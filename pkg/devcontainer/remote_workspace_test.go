@@ -0,0 +1,230 @@
+package devcontainer
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+)
+
+func TestIsRemoteDockerHost(t *testing.T) {
+	tests := map[string]bool{
+		"unix:///var/run/docker.sock":  false,
+		"npipe:////./pipe/docker":      false,
+		"tcp://localhost:2375":         false,
+		"tcp://127.0.0.1:2375":         false,
+		"tcp://[::1]:2375":             false,
+		"tcp://10.0.0.2:2376":          true,
+		"tcp://build.example.com:2376": true,
+		"ssh://user@build":             true,
+	}
+	for host, want := range tests {
+		if got := IsRemoteDockerHost(host); got != want {
+			t.Errorf("IsRemoteDockerHost(%q) = %v, want %v", host, got, want)
+		}
+	}
+}
+
+func writeWorkspaceFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	writeFile(t, filepath.Join(dir, filepath.FromSlash(name)), content)
+}
+
+func TestRemoteWorkspace(t *testing.T) {
+	ctx := context.Background()
+	dir := writeWorkspace(t, `{
+		"image": "golang:1.22",
+		"remoteUser": "dev",
+		"workspaceFolder": "/workspaces/app"
+	}`)
+	writeWorkspaceFile(t, dir, ".gitignore", "*.log\nnode_modules/\n")
+	writeWorkspaceFile(t, dir, "main.go", "package main\n")
+	writeWorkspaceFile(t, dir, "pkg/lib.go", "package pkg\n")
+	writeWorkspaceFile(t, dir, "debug.log", "noise\n")
+	writeWorkspaceFile(t, dir, "node_modules/dep/index.js", "module.exports = 1\n")
+	if err := os.Symlink("main.go", filepath.Join(dir, "link.go")); err != nil {
+		t.Fatal(err)
+	}
+
+	mgr, engine := newFakeManager("golang:1.22")
+	engine.AddImageFile("golang:1.22", "/etc/passwd", []byte("root:x:0:0:root:/root:/bin/sh\ndev:x:1000:1001::/home/dev:/bin/sh\n"))
+	engine.ExecFunc = func(containerID string, opts ExecOptions, command []string) (string, int) {
+		if command[0] == "rm" {
+			for _, name := range command[3:] {
+				engine.RemoveFile(containerID, name)
+			}
+		}
+		return "", 0
+	}
+	id := mustCreate(t, mgr, dir, api.WithRemoteWorkspace())
+	c := engine.Container(id)
+	volume := WorkspaceVolumeName(mustLoad(t, dir), dir)
+	if want := "type=volume,source=" + volume + ",target=/workspaces/app"; c.Config.WorkspaceMount != want {
+		t.Errorf("expected workspace mount %q, got %q", want, c.Config.WorkspaceMount)
+	}
+	if c.Labels[LabelWorkspaceVolume] != volume || !reflect.DeepEqual(engine.Volumes(), []string{volume}) {
+		t.Errorf("expected volume %s, got label %q and volumes %v", volume, c.Labels[LabelWorkspaceVolume], engine.Volumes())
+	}
+	if labels := engine.VolumeLabels(volume); labels[LabelLocalFolder] != dir || labels[LabelVersion] != Version {
+		t.Errorf("expected the volume to be labeled for %s, got %v", dir, labels)
+	}
+
+	main := engine.File(id, "/workspaces/app/main.go")
+	if main == nil || string(main.Content) != "package main\n" || main.Header.Uid != 1000 || main.Header.Gid != 1001 {
+		t.Fatalf("expected main.go owned by dev, got %+v", main)
+	}
+	if link := engine.File(id, "/workspaces/app/link.go"); link == nil || link.Header.Linkname != "main.go" {
+		t.Errorf("expected symlink to be uploaded, got %+v", link)
+	}
+	for _, name := range []string{"debug.log", "node_modules/dep/index.js", ".devcontainer/devcontainer.json"} {
+		if file := engine.File(id, "/workspaces/app/"+name); (file == nil) != (name != ".devcontainer/devcontainer.json") {
+			t.Errorf("unexpected upload state of %s: %+v", name, file)
+		}
+	}
+
+	// Nothing changed: nothing to upload
+	if err := mgr.Start(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	result, err := mgr.SyncWorkspace(ctx, id, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Uploaded) != 0 || len(result.Deleted) != 0 {
+		t.Errorf("expected no changes, got %+v", result)
+	}
+
+	// Incremental sync uploads changes and deletes removed files
+	later := time.Now().Add(time.Hour)
+	writeWorkspaceFile(t, dir, "main.go", "package main // changed\n")
+	os.Chtimes(filepath.Join(dir, "main.go"), later, later)
+	writeWorkspaceFile(t, dir, "pkg/new.go", "package pkg\n")
+	os.Remove(filepath.Join(dir, "pkg", "lib.go"))
+	result, err = mgr.SyncWorkspace(ctx, id, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Uploaded, []string{"main.go", "pkg/new.go"}) || !reflect.DeepEqual(result.Deleted, []string{"pkg/lib.go"}) {
+		t.Errorf("unexpected sync result %+v", result)
+	}
+	if got := string(engine.File(id, "/workspaces/app/main.go").Content); got != "package main // changed\n" {
+		t.Errorf("expected updated main.go, got %q", got)
+	}
+	execs := engine.Execs()
+	if len(execs) != 1 || !reflect.DeepEqual(execs[0].Command, []string{"rm", "-rf", "--", "/workspaces/app/pkg/lib.go"}) {
+		t.Errorf("expected deletion in the container, got %+v", execs)
+	}
+
+	// Changes made in the container are downloaded, unless they conflict
+	writeWorkspaceFile(t, dir, "pkg/new.go", "package pkg // local\n")
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range map[string]string{"gen/out.txt": "generated\n", "pkg/new.go": "package pkg // remote\n", "app.log": "log\n"} {
+		tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: name, Mode: 0644, Size: int64(len(content)), ModTime: later.Add(time.Hour)})
+		tw.Write([]byte(content))
+	}
+	tw.Close()
+	if err := engine.CopyToContainer(ctx, id, "/workspaces/app", &buf); err != nil {
+		t.Fatal(err)
+	}
+	result, err = mgr.DownloadWorkspace(ctx, id, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Downloaded, []string{"gen/out.txt"}) || !reflect.DeepEqual(result.Conflicts, []string{"pkg/new.go"}) {
+		t.Errorf("unexpected download result %+v", result)
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "gen", "out.txt")); err != nil || string(data) != "generated\n" {
+		t.Errorf("expected downloaded file, got %q: %v", data, err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "pkg", "new.go")); string(data) != "package pkg // local\n" {
+		t.Errorf("expected conflicting local file to be kept, got %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "app.log")); err == nil {
+		t.Error("expected ignored file not to be downloaded")
+	}
+
+	// The downloaded file is in sync, the local edit still needs uploading
+	result, err = mgr.SyncWorkspace(ctx, id, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(result.Uploaded, []string{"pkg/new.go"}) {
+		t.Errorf("expected only the local edit to upload, got %+v", result)
+	}
+}
+
+func TestRemoteWorkspaceUploadFailure(t *testing.T) {
+	ctx := context.Background()
+	// The image has no such user, so the upload fails
+	dir := writeWorkspace(t, `{"image": "alpine:3.20", "remoteUser": "ghost"}`)
+	writeWorkspaceFile(t, dir, "main.go", "package main\n")
+	mgr, engine := newFakeManager("alpine:3.20")
+	engine.AddImageFile("alpine:3.20", "/etc/passwd", []byte("root:x:0:0:root:/root:/bin/sh\n"))
+
+	if _, err := mgr.Create(ctx, dir, api.WithRemoteWorkspace()); err == nil || !strings.Contains(err.Error(), "user ghost not found") {
+		t.Fatalf("expected the upload to fail, got %v", err)
+	}
+	if ids, _ := engine.FindContainers(ctx, nil); len(ids) != 0 || len(engine.Volumes()) != 0 {
+		t.Errorf("expected the container and volume to be removed, got %v and %v", ids, engine.Volumes())
+	}
+
+	// A volume that existed before is kept
+	volume := WorkspaceVolumeName(mustLoad(t, dir), dir)
+	if err := engine.CreateVolume(ctx, volume); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mgr.Create(ctx, dir, api.WithRemoteWorkspace()); err == nil {
+		t.Fatal("expected the upload to fail")
+	}
+	if ids, _ := engine.FindContainers(ctx, nil); len(ids) != 0 || !reflect.DeepEqual(engine.Volumes(), []string{volume}) {
+		t.Errorf("expected only the volume to be kept, got %v and %v", ids, engine.Volumes())
+	}
+}
+
+func TestLookupContainerUser(t *testing.T) {
+	ctx := context.Background()
+	engine := NewFakeEngine()
+	engine.AddImage("img", nil)
+	engine.AddImageFile("img", "/etc/passwd", []byte("root:x:0:0::/root:/bin/sh\nvscode:x:1000:1000::/home/vscode:/bin/bash\n"))
+	engine.AddImageFile("img", "/etc/group", []byte("root:x:0:\nvscode:x:1000:\ndocker:x:999:vscode\n"))
+	id, err := engine.CreateContainer(ctx, &DockerRunConfig{Image: "img"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]containerOwner{
+		"":              {0, 0},
+		"root":          {0, 0},
+		"vscode":        {1000, 1000},
+		"1000":          {1000, 1000},
+		"4242":          {4242, 4242},
+		"vscode:docker": {1000, 999},
+		"vscode:50":     {1000, 50},
+	}
+	for spec, want := range tests {
+		got, err := lookupContainerUser(ctx, engine, id, spec)
+		if err != nil || got != want {
+			t.Errorf("lookupContainerUser(%q) = %+v, %v, want %+v", spec, got, err, want)
+		}
+	}
+	if _, err := lookupContainerUser(ctx, engine, id, "nobody"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected unknown user error, got %v", err)
+	}
+}
+
+func mustLoad(t *testing.T, dir string) *DevContainer {
+	t.Helper()
+	dc, err := LoadDevContainer(filepath.Join(dir, ".devcontainer", "devcontainer.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dc
+}
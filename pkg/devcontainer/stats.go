@@ -0,0 +1,124 @@
+package devcontainer
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+	"github.com/docker/docker/api/types/container"
+	"github.com/docker/go-units"
+)
+
+// Stats returns a resource usage reading of a running container
+func (m *Manager) Stats(ctx context.Context, containerID string) (*api.Stats, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	readings, errs := m.docker.ContainerStats(ctx, containerID, false)
+	if stats, ok := <-readings; ok {
+		return &stats, nil
+	}
+	if err := <-errs; err != nil {
+		return nil, err
+	}
+	return nil, fmt.Errorf("failed to get container stats: no reading")
+}
+
+// StatsStream sends a resource usage reading of a container about every
+// second until ctx is done
+func (m *Manager) StatsStream(ctx context.Context, containerID string) (<-chan api.Stats, <-chan error) {
+	return m.docker.ContainerStats(ctx, containerID, true)
+}
+
+// statsFromResponse computes a reading from the stats of the Docker API the
+// way docker stats does on Linux
+func statsFromResponse(resp *container.StatsResponse) api.Stats {
+	stats := api.Stats{
+		Time:        resp.Read,
+		MemoryLimit: resp.MemoryStats.Limit,
+		PIDs:        resp.PidsStats.Current,
+	}
+
+	// CPU time of the container over CPU time of the host since the
+	// previous reading, scaled to the online CPUs
+	cpuDelta := float64(resp.CPUStats.CPUUsage.TotalUsage) - float64(resp.PreCPUStats.CPUUsage.TotalUsage)
+	systemDelta := float64(resp.CPUStats.SystemUsage) - float64(resp.PreCPUStats.SystemUsage)
+	onlineCPUs := float64(resp.CPUStats.OnlineCPUs)
+	if onlineCPUs == 0 {
+		onlineCPUs = float64(len(resp.CPUStats.CPUUsage.PercpuUsage))
+	}
+	if cpuDelta > 0 && systemDelta > 0 {
+		stats.CPUPercent = cpuDelta / systemDelta * onlineCPUs * 100
+	}
+
+	// The inactive page cache can be reclaimed, so it does not count:
+	// total_inactive_file on cgroup v1, inactive_file on v2
+	stats.MemoryUsage = resp.MemoryStats.Usage
+	inactive, ok := resp.MemoryStats.Stats["total_inactive_file"]
+	if !ok {
+		inactive = resp.MemoryStats.Stats["inactive_file"]
+	}
+	if inactive < stats.MemoryUsage {
+		stats.MemoryUsage -= inactive
+	}
+	if stats.MemoryLimit != 0 {
+		stats.MemoryPercent = float64(stats.MemoryUsage) / float64(stats.MemoryLimit) * 100
+	}
+
+	for _, network := range resp.Networks {
+		stats.NetworkRx += network.RxBytes
+		stats.NetworkTx += network.TxBytes
+	}
+	for _, entry := range resp.BlkioStats.IoServiceBytesRecursive {
+		switch strings.ToLower(entry.Op) {
+		case "read":
+			stats.BlockRead += entry.Value
+		case "write":
+			stats.BlockWrite += entry.Value
+		}
+	}
+	return stats
+}
+
+// cliStats is a reading printed by `stats --format {{json .}}`, with the
+// values docker stats displays
+type cliStats struct {
+	CPUPerc  string `json:"CPUPerc"`  // "0.50%"
+	MemUsage string `json:"MemUsage"` // "1.5MiB / 7.6GiB"
+	MemPerc  string `json:"MemPerc"`
+	NetIO    string `json:"NetIO"`   // "1.2kB / 648B"
+	BlockIO  string `json:"BlockIO"` // "0B / 4.1kB"
+	PIDs     string `json:"PIDs"`
+}
+
+// stats parses the displayed values; memory sizes are binary, I/O sizes
+// decimal
+func (s cliStats) stats() api.Stats {
+	var stats api.Stats
+	stats.CPUPercent = parsePercent(s.CPUPerc)
+	stats.MemoryPercent = parsePercent(s.MemPerc)
+	stats.MemoryUsage, stats.MemoryLimit = parseSizePair(s.MemUsage, units.RAMInBytes)
+	stats.NetworkRx, stats.NetworkTx = parseSizePair(s.NetIO, units.FromHumanSize)
+	stats.BlockRead, stats.BlockWrite = parseSizePair(s.BlockIO, units.FromHumanSize)
+	stats.PIDs, _ = strconv.ParseUint(strings.TrimSpace(s.PIDs), 10, 64)
+	return stats
+}
+
+func parsePercent(s string) float64 {
+	f, _ := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(s), "%"), 64)
+	return f
+}
+
+// parseSizePair parses "<size> / <size>"; unparsable sizes are 0
+func parseSizePair(s string, parse func(string) (int64, error)) (uint64, uint64) {
+	first, second, _ := strings.Cut(s, "/")
+	size := func(s string) uint64 {
+		n, err := parse(strings.TrimSpace(s))
+		if err != nil || n < 0 {
+			return 0
+		}
+		return uint64(n)
+	}
+	return size(first), size(second)
+}
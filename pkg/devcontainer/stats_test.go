@@ -0,0 +1,124 @@
+package devcontainer
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/colony-2/devcontainer-go/pkg/api"
+	"github.com/docker/docker/api/types/container"
+)
+
+func TestStatsFromResponse(t *testing.T) {
+	tests := []struct {
+		name string
+		resp string
+		want api.Stats
+	}{
+		{
+			// CPUs are counted from the per-CPU usage when online_cpus is
+			// missing
+			name: "cgroup v1",
+			resp: `{
+				"pids_stats": {"current": 7},
+				"cpu_stats": {"cpu_usage": {"total_usage": 400, "percpu_usage": [250, 150]}, "system_cpu_usage": 2000},
+				"precpu_stats": {"cpu_usage": {"total_usage": 200}, "system_cpu_usage": 1000},
+				"memory_stats": {"usage": 1000, "limit": 4000, "stats": {"total_inactive_file": 200, "inactive_file": 100}},
+				"networks": {"eth0": {"rx_bytes": 10, "tx_bytes": 20}, "eth1": {"rx_bytes": 1, "tx_bytes": 2}},
+				"blkio_stats": {"io_service_bytes_recursive": [
+					{"op": "Read", "value": 100}, {"op": "Write", "value": 50}, {"op": "Total", "value": 150}
+				]}
+			}`,
+			want: api.Stats{
+				CPUPercent: 40, MemoryUsage: 800, MemoryLimit: 4000, MemoryPercent: 20,
+				NetworkRx: 11, NetworkTx: 22, BlockRead: 100, BlockWrite: 50, PIDs: 7,
+			},
+		},
+		{
+			name: "cgroup v2",
+			resp: `{
+				"cpu_stats": {"cpu_usage": {"total_usage": 300}, "system_cpu_usage": 3000, "online_cpus": 4},
+				"precpu_stats": {"cpu_usage": {"total_usage": 200}, "system_cpu_usage": 2000},
+				"memory_stats": {"usage": 1000, "stats": {"inactive_file": 600}},
+				"blkio_stats": {"io_service_bytes_recursive": [{"op": "read", "value": 8}, {"op": "write", "value": 4}]}
+			}`,
+			want: api.Stats{CPUPercent: 40, MemoryUsage: 400, BlockRead: 8, BlockWrite: 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp container.StatsResponse
+			if err := json.Unmarshal([]byte(tt.resp), &resp); err != nil {
+				t.Fatal(err)
+			}
+			if got := statsFromResponse(&resp); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestManagerStats(t *testing.T) {
+	ctx := context.Background()
+	mgr, engine, id := newFakeContainer(t, `{"image": "alpine:3.20"}`, "alpine:3.20")
+	reading := api.Stats{CPUPercent: 12.5, MemoryUsage: 1 << 20, PIDs: 3}
+	engine.UpdateContainer(id, func(c *FakeContainer) { c.Stats = reading })
+
+	stats, err := mgr.Stats(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *stats != reading {
+		t.Errorf("expected %+v, got %+v", reading, *stats)
+	}
+	if _, err := mgr.Stats(ctx, "missing"); err == nil || !strings.Contains(err.Error(), "no such container") {
+		t.Errorf("expected a missing container to fail, got %v", err)
+	}
+
+	// Streams end when ctx is done
+	streamCtx, cancel := context.WithCancel(ctx)
+	readings, errs := mgr.StatsStream(streamCtx, id)
+	for i := 0; i < 2; i++ {
+		if got := <-readings; got != reading {
+			t.Errorf("expected %+v, got %+v", reading, got)
+		}
+	}
+	cancel()
+	for range readings {
+	}
+	if err := <-errs; err != nil {
+		t.Errorf("expected a canceled stream to end without error, got %v", err)
+	}
+}
+
+func TestCLIEngineContainerStats(t *testing.T) {
+	ctx := context.Background()
+	engine, calls := newFakeCLI(t)
+
+	readings, errs := engine.ContainerStats(ctx, "c1", false)
+	var got []api.Stats
+	for stats := range readings {
+		got = append(got, stats)
+	}
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("expected 1 reading, got %+v", got)
+	}
+	if got[0].Time.IsZero() {
+		t.Error("expected the reading to be timed")
+	}
+	got[0].Time = api.Stats{}.Time
+	want := api.Stats{
+		CPUPercent: 12.5, MemoryUsage: 150 << 20, MemoryLimit: 15 << 29, MemoryPercent: 1.95,
+		NetworkRx: 1200, NetworkTx: 648, BlockRead: 0, BlockWrite: 4100, PIDs: 7,
+	}
+	if got[0] != want {
+		t.Errorf("expected %+v, got %+v", want, got[0])
+	}
+	if c := calls(); len(c) != 1 || c[0] != "stats --format {{json .}} --no-stream c1" {
+		t.Errorf("unexpected calls %v", c)
+	}
+}
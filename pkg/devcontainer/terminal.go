@@ -23,11 +23,22 @@ type TerminalAttachment struct {
 
 // AttachInteractive attaches an interactive terminal to a container
 func (m *Manager) AttachInteractive(ctx context.Context, containerID string) error {
+	if m.dockerClient == nil {
+		return fmt.Errorf("interactive attach requires a Docker engine")
+	}
 	attachment := &TerminalAttachment{
 		client:      m.dockerClient.client,
 		containerID: containerID,
 	}
-	return attachment.Start(ctx)
+	
+	// Attach events carry the labels of the container, for workspace filters
+	event := Event{Type: EventAttach, ContainerID: containerID}
+	if details, err := m.docker.InspectContainer(ctx, containerID); err == nil {
+		event.Labels = details.Labels
+	}
+	return m.step(event, func() error {
+		return attachment.Start(ctx)
+	})
 }
 
 // Start begins an interactive terminal session
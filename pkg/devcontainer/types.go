@@ -0,0 +1,696 @@
+package devcontainer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Port is a port in forwardPorts or appPort: either a port number or a
+// string such as "3000:3001" or "db:5432"
+type Port struct {
+	Number int    // Set for numeric ports
+	Value  string // Set for string ports
+}
+
+// PortNumber returns a numeric Port
+func PortNumber(n int) Port {
+	return Port{Number: n}
+}
+
+// PortString returns a string Port
+func PortString(s string) Port {
+	return Port{Value: s}
+}
+
+// IsNumber reports whether the port was given as a number
+func (p Port) IsNumber() bool {
+	return p.Value == ""
+}
+
+// String returns the port as written in devcontainer.json
+func (p Port) String() string {
+	if p.IsNumber() {
+		return strconv.Itoa(p.Number)
+	}
+	return p.Value
+}
+
+// MarshalJSON implements json.Marshaler
+func (p Port) MarshalJSON() ([]byte, error) {
+	if p.IsNumber() {
+		return json.Marshal(p.Number)
+	}
+	return json.Marshal(p.Value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (p *Port) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	switch val := v.(type) {
+	case float64:
+		if val != float64(int(val)) {
+			return fmt.Errorf("port must be an integer, got %v", val)
+		}
+		*p = Port{Number: int(val)}
+	case string:
+		if val == "" {
+			return fmt.Errorf("port must not be empty")
+		}
+		*p = Port{Value: val}
+	default:
+		return fmt.Errorf("port must be a number or a string, got %s", jsonTypeName(v))
+	}
+	return nil
+}
+
+// PortList is the appPort property. A single port is accepted on input and
+// written back as a one-element list.
+type PortList []Port
+
+// UnmarshalJSON implements json.Unmarshaler
+func (l *PortList) UnmarshalJSON(data []byte) error {
+	if string(bytes.TrimSpace(data)) == "null" {
+		*l = nil
+		return nil
+	}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		var ports []Port
+		if err := json.Unmarshal(data, &ports); err != nil {
+			return err
+		}
+		*l = ports
+		return nil
+	}
+	var port Port
+	if err := json.Unmarshal(data, &port); err != nil {
+		return err
+	}
+	*l = PortList{port}
+	return nil
+}
+
+// StringList is a property that accepts a string or an array of strings
+type StringList []string
+
+// UnmarshalJSON implements json.Unmarshaler
+func (l *StringList) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*l = StringList{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("expected a string or an array of strings")
+	}
+	*l = list
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. A cacheFrom written as a string is
+// written back as a string.
+func (b Build) MarshalJSON() ([]byte, error) {
+	type Alias Build
+	if b.cacheFromScalar && len(b.CacheFrom) == 1 {
+		return marshalWithExtra(struct {
+			Alias
+			CacheFrom string `json:"cacheFrom"`
+		}{Alias(b), b.CacheFrom[0]}, b.AdditionalProperties)
+	}
+	return marshalWithExtra(Alias(b), b.AdditionalProperties)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (b *Build) UnmarshalJSON(data []byte) error {
+	type Alias Build
+	*b = Build{}
+	raw, err := unmarshalWithRaw(data, (*Alias)(b))
+	if err != nil {
+		return err
+	}
+	if rawCacheFrom, ok := raw["cacheFrom"]; ok {
+		trimmed := bytes.TrimSpace(rawCacheFrom)
+		b.cacheFromScalar = len(trimmed) > 0 && trimmed[0] == '"'
+	}
+	b.AdditionalProperties = extraProperties(raw, reflect.TypeOf(*b))
+	return nil
+}
+
+// MountEntry is an element of the mounts property: either a Docker --mount
+// string or a mount object
+type MountEntry struct {
+	Value  string // String form, e.g. "source=cache,target=/cache,type=volume"
+	Object *Mount // Object form
+}
+
+// MountString returns a string MountEntry
+func MountString(s string) MountEntry {
+	return MountEntry{Value: s}
+}
+
+// MountObject returns an object MountEntry
+func MountObject(m Mount) MountEntry {
+	return MountEntry{Object: &m}
+}
+
+// Target returns the container path of the mount
+func (e MountEntry) Target() string {
+	if e.Object != nil {
+		return e.Object.Target
+	}
+	for _, part := range strings.Split(e.Value, ",") {
+		kv := strings.SplitN(part, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		switch kv[0] {
+		case "target", "dst", "destination":
+			return kv[1]
+		}
+	}
+	return ""
+}
+
+// MountArg returns the mount in Docker --mount syntax
+func (e MountEntry) MountArg() string {
+	if e.Object != nil {
+		return buildMountStringFromMount(*e.Object)
+	}
+	return e.Value
+}
+
+// MarshalJSON implements json.Marshaler
+func (e MountEntry) MarshalJSON() ([]byte, error) {
+	if e.Object != nil {
+		return json.Marshal(e.Object)
+	}
+	return json.Marshal(e.Value)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *MountEntry) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*e = MountEntry{Value: s}
+		return nil
+	}
+	var m Mount
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+	*e = MountEntry{Object: &m}
+	return nil
+}
+
+// Lifecycle command types
+const (
+	CommandTypeString   = "string"
+	CommandTypeArray    = "array"
+	CommandTypeObject   = "object"
+	CommandTypeSequence = "sequence"
+)
+
+// StringCommand returns a lifecycle command run through a shell
+func StringCommand(command string) *LifecycleCommand {
+	return &LifecycleCommand{Type: CommandTypeString, Command: command}
+}
+
+// ArrayCommand returns a lifecycle command run without a shell
+func ArrayCommand(args ...string) *LifecycleCommand {
+	return &LifecycleCommand{Type: CommandTypeArray, Args: args}
+}
+
+// ObjectCommand returns a lifecycle command made of named commands that run
+// in parallel
+func ObjectCommand(commands map[string]*LifecycleCommand) *LifecycleCommand {
+	return &LifecycleCommand{Type: CommandTypeObject, Commands: commands}
+}
+
+// MarshalJSON implements json.Marshaler. A sequence is written as an array
+// of its commands.
+func (lc LifecycleCommand) MarshalJSON() ([]byte, error) {
+	switch lc.Type {
+	case CommandTypeString:
+		return json.Marshal(lc.Command)
+	case CommandTypeArray:
+		if lc.Args == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(lc.Args)
+	case CommandTypeObject:
+		if lc.Commands == nil {
+			return []byte("{}"), nil
+		}
+		return json.Marshal(lc.Commands)
+	case CommandTypeSequence:
+		return json.Marshal(lc.Sequence)
+	default:
+		return nil, fmt.Errorf("unknown lifecycle command type %q", lc.Type)
+	}
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (lc *LifecycleCommand) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	parsed, err := ParseLifecycleCommand(v)
+	if err != nil {
+		return err
+	}
+	if parsed == nil {
+		return fmt.Errorf("lifecycle command must not be null")
+	}
+	*lc = *parsed
+	return nil
+}
+
+// PortAttributes describes a port in portsAttributes or otherPortsAttributes
+type PortAttributes struct {
+	Label            string `json:"label,omitempty"`
+	Protocol         string `json:"protocol,omitempty"`
+	OnAutoForward    string `json:"onAutoForward,omitempty"`
+	RequireLocalPort *bool  `json:"requireLocalPort,omitempty"`
+	ElevateIfNeeded  *bool  `json:"elevateIfNeeded,omitempty"`
+
+	// AdditionalProperties holds properties not modeled above
+	AdditionalProperties map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (a PortAttributes) MarshalJSON() ([]byte, error) {
+	type alias PortAttributes
+	return marshalWithExtra(alias(a), a.AdditionalProperties)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (a *PortAttributes) UnmarshalJSON(data []byte) error {
+	type alias PortAttributes
+	raw, err := unmarshalWithRaw(data, (*alias)(a))
+	if err != nil {
+		return err
+	}
+	a.AdditionalProperties = extraProperties(raw, reflect.TypeOf(*a))
+	return nil
+}
+
+// Secret describes a recommended secret in the secrets property
+type Secret struct {
+	Description          string                     `json:"description,omitempty"`
+	DocumentationURL     string                     `json:"documentationUrl,omitempty"`
+	AdditionalProperties map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (s Secret) MarshalJSON() ([]byte, error) {
+	type alias Secret
+	return marshalWithExtra(alias(s), s.AdditionalProperties)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	type alias Secret
+	raw, err := unmarshalWithRaw(data, (*alias)(s))
+	if err != nil {
+		return err
+	}
+	s.AdditionalProperties = extraProperties(raw, reflect.TypeOf(*s))
+	return nil
+}
+
+// HostGPURequirements is the object form of hostRequirements.gpu
+type HostGPURequirements struct {
+	Cores                int                        `json:"cores,omitempty"`
+	Memory               string                     `json:"memory,omitempty"`
+	AdditionalProperties map[string]json.RawMessage `json:"-"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (g HostGPURequirements) MarshalJSON() ([]byte, error) {
+	type alias HostGPURequirements
+	return marshalWithExtra(alias(g), g.AdditionalProperties)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (g *HostGPURequirements) UnmarshalJSON(data []byte) error {
+	type alias HostGPURequirements
+	raw, err := unmarshalWithRaw(data, (*alias)(g))
+	if err != nil {
+		return err
+	}
+	g.AdditionalProperties = extraProperties(raw, reflect.TypeOf(*g))
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. cpus is written as a number unless
+// it was parsed from a string, and gpu as a boolean, "optional" or an
+// object, as in devcontainer.json.
+func (r DevContainerCommonHostRequirements) MarshalJSON() ([]byte, error) {
+	out := map[string]interface{}{}
+	for k, v := range r.AdditionalProperties {
+		out[k] = v
+	}
+	if r.CPUs != "" {
+		if n, err := strconv.Atoi(r.CPUs); err == nil && !r.cpusString {
+			out["cpus"] = n
+		} else {
+			out["cpus"] = r.CPUs
+		}
+	}
+	if r.Memory != "" {
+		out["memory"] = r.Memory
+	}
+	if r.Storage != "" {
+		out["storage"] = r.Storage
+	}
+	switch {
+	case r.GpuDetails != nil:
+		out["gpu"] = r.GpuDetails
+	case r.Gpu == "true" || r.Gpu == "false":
+		out["gpu"] = r.Gpu == "true"
+	case r.Gpu != "":
+		out["gpu"] = r.Gpu
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (r *DevContainerCommonHostRequirements) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		CPUs    interface{}     `json:"cpus"`
+		Memory  string          `json:"memory"`
+		Storage string          `json:"storage"`
+		Gpu     json.RawMessage `json:"gpu"`
+	}
+	all, err := unmarshalWithRaw(data, &raw)
+	if err != nil {
+		return err
+	}
+
+	*r = DevContainerCommonHostRequirements{Memory: raw.Memory, Storage: raw.Storage}
+	r.AdditionalProperties = extraProperties(all, reflect.TypeOf(raw))
+	switch v := raw.CPUs.(type) {
+	case nil:
+	case float64:
+		r.CPUs = strconv.Itoa(int(v))
+	case string:
+		r.CPUs = v
+		r.cpusString = true
+	default:
+		return fmt.Errorf("hostRequirements.cpus must be a number, got %s", jsonTypeName(v))
+	}
+
+	if len(raw.Gpu) > 0 {
+		var gpu interface{}
+		if err := json.Unmarshal(raw.Gpu, &gpu); err != nil {
+			return err
+		}
+		switch v := gpu.(type) {
+		case nil:
+		case bool:
+			r.Gpu = strconv.FormatBool(v)
+		case string:
+			r.Gpu = v
+		case map[string]interface{}:
+			r.GpuDetails = &HostGPURequirements{}
+			if err := json.Unmarshal(raw.Gpu, r.GpuDetails); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("hostRequirements.gpu must be a boolean, string or object, got %s", jsonTypeName(v))
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler
+func (f DevContainerCommonFeatures) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(f.AdditionalProperties)+3)
+	for k, v := range f.AdditionalProperties {
+		out[k] = v
+	}
+	for k, v := range map[string]string{"fish": f.Fish, "gradle": f.Gradle, "maven": f.Maven} {
+		if v != "" {
+			out[k] = v
+		}
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (f *DevContainerCommonFeatures) UnmarshalJSON(data []byte) error {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	*f = DevContainerCommonFeatures{}
+	for k, v := range raw {
+		// Short feature ids with a version string map to the named fields
+		s, isString := v.(string)
+		switch {
+		case k == "fish" && isString:
+			f.Fish = s
+		case k == "gradle" && isString:
+			f.Gradle = s
+		case k == "maven" && isString:
+			f.Maven = s
+		default:
+			if f.AdditionalProperties == nil {
+				f.AdditionalProperties = make(map[string]interface{})
+			}
+			f.AdditionalProperties[k] = v
+		}
+	}
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler. Fields held outside of
+// DevContainerCommon (runArgs, the image of ImageContainer, ...) and
+// AdditionalProperties are written alongside the common properties.
+func (dc DevContainer) MarshalJSON() ([]byte, error) {
+	type alias DevContainer
+	data, err := json.Marshal(alias(dc))
+	if err != nil {
+		return nil, err
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+
+	setIfAbsent := func(key string, v interface{}) error {
+		if _, ok := out[key]; ok {
+			return nil
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		out[key] = raw
+		return nil
+	}
+
+	if dc.ImageContainer != nil && dc.ImageContainer.Image != "" {
+		if err := setIfAbsent("image", dc.ImageContainer.Image); err != nil {
+			return nil, err
+		}
+	}
+	if dc.ComposeContainer != nil {
+		if dc.ComposeContainer.DockerComposeFile != nil {
+			if err := setIfAbsent("dockerComposeFile", dc.ComposeContainer.DockerComposeFile); err != nil {
+				return nil, err
+			}
+		}
+		if dc.ComposeContainer.Service != "" {
+			if err := setIfAbsent("service", dc.ComposeContainer.Service); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if ncb := dc.NonComposeBase; ncb != nil {
+		if ncb.RunArgs != nil {
+			if err := setIfAbsent("runArgs", ncb.RunArgs); err != nil {
+				return nil, err
+			}
+		}
+		if ncb.WorkspaceFolder != nil {
+			if err := setIfAbsent("workspaceFolder", *ncb.WorkspaceFolder); err != nil {
+				return nil, err
+			}
+		}
+		if ncb.WorkspaceMount != nil {
+			if err := setIfAbsent("workspaceMount", *ncb.WorkspaceMount); err != nil {
+				return nil, err
+			}
+		}
+		if ncb.AppPort != nil {
+			if err := setIfAbsent("appPort", ncb.AppPort); err != nil {
+				return nil, err
+			}
+		}
+	}
+	for k, v := range dc.AdditionalProperties {
+		if _, ok := out[k]; !ok {
+			out[k] = v
+		}
+	}
+
+	// Keep the shape of the source document
+	if reflect.ValueOf(dc.Build).IsZero() {
+		delete(out, "build")
+	}
+	if dc.appPortScalar && len(dc.AppPort) == 1 {
+		raw, err := json.Marshal(dc.AppPort[0])
+		if err != nil {
+			return nil, err
+		}
+		out["appPort"] = raw
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (dc *DevContainer) UnmarshalJSON(data []byte) error {
+	type alias DevContainer
+	*dc = DevContainer{}
+	raw, err := unmarshalWithRaw(data, (*alias)(dc))
+	if err != nil {
+		return err
+	}
+
+	if rawArgs, ok := raw["runArgs"]; ok {
+		var runArgs []string
+		if err := json.Unmarshal(rawArgs, &runArgs); err != nil {
+			return fmt.Errorf("runArgs: %w", err)
+		}
+		dc.NonComposeBase = &NonComposeBase{RunArgs: runArgs}
+	}
+
+	// Set container type based on what's present
+	if dc.Image != "" {
+		dc.ImageContainer = &ImageContainer{
+			Image: dc.Image,
+		}
+	}
+	if dc.DockerComposeFile != nil {
+		dc.ComposeContainer = &ComposeContainer{
+			DockerComposeFile: dc.DockerComposeFile,
+			Service:           dc.Service,
+		}
+	}
+
+	if rawPort, ok := raw["appPort"]; ok {
+		trimmed := bytes.TrimSpace(rawPort)
+		dc.appPortScalar = len(trimmed) > 0 && trimmed[0] != '['
+	}
+
+	extra := extraProperties(raw, reflect.TypeOf(*dc))
+	delete(extra, "runArgs")
+	dc.AdditionalProperties = extra
+	return nil
+}
+
+// unmarshalWithRaw decodes data into v and also returns the raw properties
+func unmarshalWithRaw(data []byte, v interface{}) (map[string]json.RawMessage, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
+
+// marshalWithExtra encodes v and adds the extra properties it doesn't declare
+func marshalWithExtra(v interface{}, extra map[string]json.RawMessage) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+	var out map[string]json.RawMessage
+	if err := json.Unmarshal(data, &out); err != nil {
+		return nil, err
+	}
+	for k, raw := range extra {
+		if _, ok := out[k]; !ok {
+			out[k] = raw
+		}
+	}
+	return json.Marshal(out)
+}
+
+// extraProperties returns the raw properties that t has no JSON field for,
+// or nil if there are none
+func extraProperties(raw map[string]json.RawMessage, t reflect.Type) map[string]json.RawMessage {
+	known := jsonFieldNames(t)
+	var extra map[string]json.RawMessage
+	for k, v := range raw {
+		if known[k] {
+			continue
+		}
+		if extra == nil {
+			extra = make(map[string]json.RawMessage)
+		}
+		extra[k] = v
+	}
+	return extra
+}
+
+// jsonFieldNames returns the JSON property names of a struct type,
+// including those of embedded structs
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	names := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			for k := range jsonFieldNames(field.Type) {
+				names[k] = true
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		names[name] = true
+	}
+	return names
+}
+
+// jsonTypeName returns the JSON type name of a decoded value
+func jsonTypeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
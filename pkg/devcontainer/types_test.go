@@ -0,0 +1,167 @@
+package devcontainer
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestTypedModelRoundTrip(t *testing.T) {
+	input := `{
+		"$schema": "https://raw.githubusercontent.com/devcontainers/spec/main/schemas/devContainer.schema.json",
+		"name": "typed",
+		"image": "mcr.microsoft.com/devcontainers/go:1.22",
+		"extends": "../base/devcontainer.json",
+		"runArgs": ["--cap-add=SYS_PTRACE"],
+		"forwardPorts": [3000, "db:5432"],
+		"appPort": 8080,
+		"portsAttributes": {
+			"3000": {"label": "web", "onAutoForward": "notify", "requireLocalPort": true, "x-custom": 1}
+		},
+		"otherPortsAttributes": {"onAutoForward": "silent"},
+		"mounts": [
+			"source=cache,target=/cache,type=volume",
+			{"type": "bind", "source": "/host", "target": "/data", "readOnly": true, "consistency": "cached"}
+		],
+		"onCreateCommand": "make setup",
+		"postCreateCommand": ["npm", "install"],
+		"postStartCommand": {"server": "npm start", "watch": ["npm", "run", "watch"]},
+		"waitFor": "postCreateCommand",
+		"hostRequirements": {"cpus": 4, "memory": "8gb", "gpu": {"cores": 2, "memory": "4gb"}},
+		"secrets": {"TOKEN": {"description": "API token", "documentationUrl": "https://example.com"}},
+		"features": {"ghcr.io/devcontainers/features/go:1": {"version": "1.22"}, "fish": "latest"},
+		"overrideFeatureInstallOrder": ["ghcr.io/devcontainers/features/go"],
+		"remoteEnv": {"UNSET": null, "SET": "value"}
+	}`
+
+	dc, err := ParseDevContainer([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(dc.ForwardPorts, []Port{PortNumber(3000), PortString("db:5432")}) {
+		t.Errorf("unexpected forwardPorts %v", dc.ForwardPorts)
+	}
+	if !reflect.DeepEqual(dc.AppPort, PortList{PortNumber(8080)}) {
+		t.Errorf("unexpected appPort %v", dc.AppPort)
+	}
+	if dc.Mounts[0].Target() != "/cache" || dc.Mounts[1].Object == nil || !dc.Mounts[1].Object.ReadOnly {
+		t.Errorf("unexpected mounts %v", dc.Mounts)
+	}
+	if dc.PostStartCommand.Type != CommandTypeObject || dc.PostStartCommand.Commands["watch"].Type != CommandTypeArray {
+		t.Errorf("unexpected postStartCommand %+v", dc.PostStartCommand)
+	}
+	if dc.HostRequirements == nil || dc.HostRequirements.CPUs != "4" || dc.HostRequirements.GpuDetails.Cores != 2 {
+		t.Errorf("unexpected hostRequirements %+v", dc.HostRequirements)
+	}
+	if dc.PortsAttributes["3000"].RequireLocalPort == nil || dc.WaitFor != "postCreateCommand" {
+		t.Error("expected portsAttributes and waitFor to be parsed")
+	}
+	if _, ok := dc.AdditionalProperties["extends"]; !ok {
+		t.Error("expected unknown property to be preserved")
+	}
+
+	output, err := json.Marshal(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got interface{}
+	if err := json.Unmarshal([]byte(input), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round-trip mismatch\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+func TestTypedModelKeepsNestedUnknownProperties(t *testing.T) {
+	input := `{
+		"build": {"dockerfile": "Dockerfile", "cacheFrom": "app:cache", "x-build": {"platform": "linux/arm64"}},
+		"hostRequirements": {"cpus": 2, "x-host": true, "gpu": {"cores": 1, "x-gpu": "a100"}},
+		"secrets": {"TOKEN": {"description": "API token", "x-secret": 1}}
+	}`
+
+	dc, err := ParseDevContainer([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := dc.Build.AdditionalProperties["x-build"]; !ok {
+		t.Errorf("expected unknown build property to be preserved, got %v", dc.Build.AdditionalProperties)
+	}
+
+	output, err := json.Marshal(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got interface{}
+	if err := json.Unmarshal([]byte(input), &want); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(output, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(want, got) {
+		t.Errorf("round-trip mismatch\nwant: %v\ngot:  %v", want, got)
+	}
+}
+
+func TestTypedModelKeepsScalarForms(t *testing.T) {
+	tests := []string{
+		`{"build": {"dockerfile": "Dockerfile", "cacheFrom": "img:1"}, "hostRequirements": {"cpus": "2"}}`,
+		`{"build": {"dockerfile": "Dockerfile", "cacheFrom": ["img:1"]}, "hostRequirements": {"cpus": 2}}`,
+	}
+	for _, input := range tests {
+		dc, err := ParseDevContainer([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		output, err := json.Marshal(dc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want, got interface{}
+		if err := json.Unmarshal([]byte(input), &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(output, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("round-trip mismatch\nwant: %v\ngot:  %v", want, got)
+		}
+	}
+
+	// Merged values keep the form of the layer they come from
+	base, _ := ParseDevContainer([]byte(tests[1]))
+	override, _ := ParseDevContainer([]byte(`{"build": {"cacheFrom": "img:2"}, "hostRequirements": {"cpus": "8"}}`))
+	output, err := json.Marshal(MergeDevContainers(base, override))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`"cacheFrom":"img:2"`, `"cpus":"8"`} {
+		if !strings.Contains(string(output), want) {
+			t.Errorf("expected %s in %s", want, output)
+		}
+	}
+}
+
+func TestTypedModelRejectsInvalidUnions(t *testing.T) {
+	tests := []string{
+		`{"forwardPorts": [{"port": 3000}]}`,
+		`{"appPort": true}`,
+		`{"mounts": [42]}`,
+		`{"mounts": [{"source": "x", "target": "/x"}]}`,
+		`{"postCreateCommand": 42}`,
+		`{"postCreateCommand": ["npm", 1]}`,
+		`{"hostRequirements": {"gpu": 1}}`,
+	}
+	for _, input := range tests {
+		if _, err := ParseDevContainer([]byte(input)); err == nil {
+			t.Errorf("expected error for %s", input)
+		}
+	}
+}
@@ -0,0 +1,492 @@
+package devcontainer
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+// devContainerSchema is the devcontainer.json schema published by the
+// devcontainers spec (schemas/devContainer.base.schema.json)
+//
+//go:embed schema/devContainer.base.schema.json
+var devContainerSchema []byte
+
+const devContainerSchemaURL = "https://raw.githubusercontent.com/devcontainers/spec/main/schemas/devContainer.base.schema.json"
+
+// extensionProperties are top-level properties supported by this package
+// that are not part of the base schema
+var extensionProperties = map[string]bool{
+	"extends": true,
+}
+
+// Severity is the severity of a Diagnostic
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+// Diagnostic describes a problem found in a devcontainer.json
+type Diagnostic struct {
+	Pointer    string   `json:"pointer"`          // JSON pointer to the offending value, "" for the document
+	Line       int      `json:"line,omitempty"`   // 1-based line in the source, 0 if unknown
+	Column     int      `json:"column,omitempty"` // 1-based column in the source, 0 if unknown
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"` // Likely intended value, e.g. a property name for a typo
+}
+
+func (d Diagnostic) String() string {
+	var b strings.Builder
+	if d.Line > 0 {
+		fmt.Fprintf(&b, "%d:%d: ", d.Line, d.Column)
+	}
+	fmt.Fprintf(&b, "%s: ", d.Severity)
+	if d.Pointer != "" {
+		fmt.Fprintf(&b, "%s: ", d.Pointer)
+	}
+	b.WriteString(d.Message)
+	if d.Suggestion != "" {
+		fmt.Fprintf(&b, " (did you mean %q?)", d.Suggestion)
+	}
+	return b.String()
+}
+
+// Diagnostics is a list of problems, in document order
+type Diagnostics []Diagnostic
+
+// HasErrors reports whether any diagnostic is an error
+func (d Diagnostics) HasErrors() bool {
+	for _, diag := range d {
+		if diag.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Err returns the diagnostics as an error if any of them is an error
+func (d Diagnostics) Err() error {
+	if !d.HasErrors() {
+		return nil
+	}
+	return &ValidationError{Diagnostics: d}
+}
+
+// ValidationError is returned for configurations with error diagnostics
+type ValidationError struct {
+	Diagnostics Diagnostics
+}
+
+func (e *ValidationError) Error() string {
+	var lines []string
+	for _, d := range e.Diagnostics {
+		if d.Severity == SeverityError {
+			lines = append(lines, d.String())
+		}
+	}
+	return "invalid devcontainer.json:\n  " + strings.Join(lines, "\n  ")
+}
+
+var (
+	compiledSchema     *jsonschema.Schema
+	compiledSchemaErr  error
+	compileSchemaOnce  sync.Once
+	schemaPropertyList []string
+	schemaDocument     map[string]interface{}
+)
+
+// loadSchema compiles the embedded schema once
+func loadSchema() (*jsonschema.Schema, error) {
+	compileSchemaOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		compiler.Draft = jsonschema.Draft2019
+		if err := compiler.AddResource(devContainerSchemaURL, bytes.NewReader(devContainerSchema)); err != nil {
+			compiledSchemaErr = fmt.Errorf("failed to load devcontainer schema: %w", err)
+			return
+		}
+		compiledSchema, compiledSchemaErr = compiler.Compile(devContainerSchemaURL)
+		if compiledSchemaErr != nil {
+			compiledSchemaErr = fmt.Errorf("failed to compile devcontainer schema: %w", compiledSchemaErr)
+			return
+		}
+		if err := json.Unmarshal(devContainerSchema, &schemaDocument); err != nil {
+			compiledSchemaErr = fmt.Errorf("failed to parse devcontainer schema: %w", err)
+			return
+		}
+		schemaPropertyList = collectSchemaProperties(schemaDocument)
+	})
+	return compiledSchema, compiledSchemaErr
+}
+
+// Validate checks a devcontainer.json document against the devcontainer
+// schema and returns every problem found. Comments and trailing commas are
+// accepted. Each diagnostic carries the JSON pointer and source position of
+// the offending value; unknown properties are reported as warnings with the
+// closest known property name as a suggestion.
+func Validate(data []byte) (Diagnostics, error) {
+	schema, err := loadSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseJSONC(data)
+	if err != nil {
+		var syntaxErr *JSONSyntaxError
+		if !errors.As(err, &syntaxErr) {
+			return nil, err
+		}
+		line, col := lineColumn(data, syntaxErr.Offset)
+		return Diagnostics{{Line: line, Column: col, Severity: SeverityError, Message: syntaxErr.Msg}}, nil
+	}
+
+	var diags Diagnostics
+	if err := schema.Validate(root.interfaceValue()); err != nil {
+		var verr *jsonschema.ValidationError
+		if !errors.As(err, &verr) {
+			return nil, fmt.Errorf("failed to validate devcontainer.json: %w", err)
+		}
+		for _, leaf := range schemaLeafErrors(verr, root) {
+			diags = append(diags, toDiagnostics(leaf, root)...)
+		}
+	}
+
+	diags = dedupeDiagnostics(diags)
+	for i := range diags {
+		node := root.lookup(diags[i].Pointer)
+		offset := node.Start
+		if node.KeyStart >= 0 && diags[i].Severity == SeverityWarning {
+			// Point unknown properties at their name
+			offset = node.KeyStart
+		}
+		diags[i].Line, diags[i].Column = lineColumn(data, offset)
+	}
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Line != diags[j].Line {
+			return diags[i].Line < diags[j].Line
+		}
+		return diags[i].Column < diags[j].Column
+	})
+	return diags, nil
+}
+
+// ValidateFile validates the devcontainer.json at path
+func ValidateFile(path string) (Diagnostics, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read devcontainer.json: %w", err)
+	}
+	return Validate(data)
+}
+
+// schemaLeafErrors flattens a validation error tree into the errors that
+// describe actual problems. For oneOf/anyOf only the alternative that came
+// closest to matching is kept, so a typo in one property does not report
+// every alternative container type. Alternatives of the right shape, with
+// their required properties present and no undeclared ones, come first, then
+// those failing deepest in the document.
+func schemaLeafErrors(err *jsonschema.ValidationError, root *jsonNode) []*jsonschema.ValidationError {
+	if len(err.Causes) == 0 {
+		return []*jsonschema.ValidationError{err}
+	}
+	keyword := err.KeywordLocation[strings.LastIndex(err.KeywordLocation, "/")+1:]
+	if (keyword == "oneOf" || keyword == "anyOf") && len(err.Causes) > 1 {
+		if merged := mergeTypeAlternatives(err); merged != nil {
+			return []*jsonschema.ValidationError{merged}
+		}
+		var best []*jsonschema.ValidationError
+		var bestRank alternativeRank
+		for i, cause := range err.Causes {
+			leaves := schemaLeafErrors(cause, root)
+			rank := alternativeRank{
+				shape: allShapeErrors(leaves),
+				depth: maxInstanceDepth(leaves),
+				score: len(leaves) * 2,
+			}
+			if allTypeErrorsAt(leaves, err.InstanceLocation) {
+				// The value is not even of the alternative's type
+				rank.score++
+			}
+			rank.relevance = declaredProperties(leaves, root)
+			if i == 0 || rank.better(bestRank) {
+				best, bestRank = leaves, rank
+			}
+		}
+		return best
+	}
+	var leaves []*jsonschema.ValidationError
+	for _, cause := range err.Causes {
+		leaves = append(leaves, schemaLeafErrors(cause, root)...)
+	}
+	return leaves
+}
+
+// alternativeRank orders the alternatives of a oneOf/anyOf by how close they
+// came to matching
+type alternativeRank struct {
+	shape     bool // Only fails on missing or undeclared properties
+	depth     int  // Depth in the document of the deepest error
+	score     int  // Number of errors, doubled, plus one for a wrong type
+	relevance int  // Properties declared by the schemas missing properties
+}
+
+func (r alternativeRank) better(other alternativeRank) bool {
+	switch {
+	case r.shape != other.shape:
+		return !r.shape
+	case r.depth != other.depth:
+		return r.depth > other.depth
+	case r.score != other.score:
+		return r.score < other.score
+	}
+	return r.relevance > other.relevance
+}
+
+// allShapeErrors reports whether all errors are missing required properties
+// or properties the schema does not declare
+func allShapeErrors(leaves []*jsonschema.ValidationError) bool {
+	for _, leaf := range leaves {
+		if !strings.HasSuffix(leaf.AbsoluteKeywordLocation, "/required") &&
+			!strings.HasSuffix(leaf.AbsoluteKeywordLocation, "/additionalProperties") {
+			return false
+		}
+	}
+	return len(leaves) > 0
+}
+
+// maxInstanceDepth returns the depth of the deepest instance location
+func maxInstanceDepth(leaves []*jsonschema.ValidationError) int {
+	depth := 0
+	for _, leaf := range leaves {
+		if d := len(splitPointer(normalizePointer(leaf.InstanceLocation))); d > depth {
+			depth = d
+		}
+	}
+	return depth
+}
+
+var expectedTypeRe = regexp.MustCompile(`^expected (.+), but got (\S+)$`)
+
+// mergeTypeAlternatives combines alternatives that all fail on the type of
+// the same value into a single "expected a or b" error
+func mergeTypeAlternatives(err *jsonschema.ValidationError) *jsonschema.ValidationError {
+	var expected []string
+	var got string
+	for _, cause := range err.Causes {
+		leaves := schemaLeafErrors(cause, nil)
+		if len(leaves) != 1 || leaves[0].InstanceLocation != err.InstanceLocation {
+			return nil
+		}
+		m := expectedTypeRe.FindStringSubmatch(leaves[0].Message)
+		if m == nil {
+			return nil
+		}
+		expected = append(expected, strings.Split(m[1], " or ")...)
+		got = m[2]
+	}
+	merged := *err
+	merged.Causes = nil
+	merged.Message = fmt.Sprintf("expected %s, but got %s", strings.Join(uniqueStrings(expected), " or "), got)
+	return &merged
+}
+
+// declaredProperties counts the instance properties declared by the schemas
+// whose required properties are missing. It breaks ties between alternatives
+// that each miss a property, e.g. an image config missing "image" versus a
+// compose config missing "service".
+func declaredProperties(leaves []*jsonschema.ValidationError, root *jsonNode) int {
+	count := 0
+	for _, leaf := range leaves {
+		if root == nil || !strings.HasSuffix(leaf.AbsoluteKeywordLocation, "/required") {
+			continue
+		}
+		fragment := leaf.AbsoluteKeywordLocation[strings.Index(leaf.AbsoluteKeywordLocation, "#")+1:]
+		var schema interface{} = schemaDocument
+		for _, token := range splitPointer(strings.TrimSuffix(fragment, "/required")) {
+			m, _ := schema.(map[string]interface{})
+			schema = m[token]
+		}
+		m, _ := schema.(map[string]interface{})
+		props, _ := m["properties"].(map[string]interface{})
+		for _, member := range root.lookup(normalizePointer(leaf.InstanceLocation)).Children {
+			if _, ok := props[member.Key]; ok && member.KeyStart >= 0 {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+func allTypeErrorsAt(leaves []*jsonschema.ValidationError, location string) bool {
+	for _, leaf := range leaves {
+		if leaf.InstanceLocation != location || !expectedTypeRe.MatchString(leaf.Message) {
+			return false
+		}
+	}
+	return len(leaves) > 0
+}
+
+var (
+	quotedNameRe = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+	enumValueRe  = regexp.MustCompile(`"((?:[^"\\]|\\.)*)"`)
+)
+
+// toDiagnostics converts a leaf schema error into diagnostics
+func toDiagnostics(err *jsonschema.ValidationError, root *jsonNode) []Diagnostic {
+	pointer := normalizePointer(err.InstanceLocation)
+	keyword := err.KeywordLocation[strings.LastIndex(err.KeywordLocation, "/")+1:]
+
+	switch {
+	case keyword == "additionalProperties" && strings.HasPrefix(err.Message, "additionalProperties "):
+		// One diagnostic per property, pointing at the property itself
+		var diags []Diagnostic
+		for _, m := range quotedNameRe.FindAllStringSubmatch(err.Message, -1) {
+			if d, ok := unknownPropertyDiagnostic(joinPointer(pointer, m[1])); ok {
+				diags = append(diags, d)
+			}
+		}
+		return diags
+	case keyword == "unevaluatedProperties":
+		// Properties evaluated by a failing alternative are reported as
+		// unevaluated too, so only flag names the schema does not know
+		tokens := splitPointer(pointer)
+		if len(tokens) > 0 && slices.Contains(schemaPropertyList, tokens[len(tokens)-1]) {
+			return nil
+		}
+		if d, ok := unknownPropertyDiagnostic(pointer); ok {
+			return []Diagnostic{d}
+		}
+		return nil
+	case keyword == "enum":
+		d := Diagnostic{Pointer: pointer, Severity: SeverityError, Message: err.Message}
+		if s, ok := root.lookup(pointer).Value.(string); ok {
+			var values []string
+			for _, m := range enumValueRe.FindAllStringSubmatch(err.Message, -1) {
+				values = append(values, m[1])
+			}
+			d.Suggestion = closestMatch(s, values)
+		}
+		return []Diagnostic{d}
+	}
+	return []Diagnostic{{Pointer: pointer, Severity: SeverityError, Message: err.Message}}
+}
+
+// unknownPropertyDiagnostic reports a property the schema does not allow
+func unknownPropertyDiagnostic(pointer string) (Diagnostic, bool) {
+	tokens := splitPointer(pointer)
+	if len(tokens) == 0 {
+		return Diagnostic{}, false
+	}
+	name := tokens[len(tokens)-1]
+	if len(tokens) == 1 && extensionProperties[name] {
+		return Diagnostic{}, false
+	}
+	return Diagnostic{
+		Pointer:    pointer,
+		Severity:   SeverityWarning,
+		Message:    fmt.Sprintf("property %q is not allowed", name),
+		Suggestion: closestMatch(name, schemaPropertyList),
+	}, true
+}
+
+// normalizePointer turns a schema instance location into a JSON pointer
+func normalizePointer(location string) string {
+	if location == "" || strings.HasPrefix(location, "/") {
+		return location
+	}
+	return "/" + location
+}
+
+// dedupeDiagnostics drops repeated diagnostics, keeping the first
+func dedupeDiagnostics(diags Diagnostics) Diagnostics {
+	seen := map[string]bool{}
+	var result Diagnostics
+	for _, d := range diags {
+		key := d.Pointer + "\x00" + d.Message
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		result = append(result, d)
+	}
+	return result
+}
+
+// collectSchemaProperties returns every property name declared in a schema
+func collectSchemaProperties(schema map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var walk func(v interface{})
+	walk = func(v interface{}) {
+		switch val := v.(type) {
+		case map[string]interface{}:
+			if props, ok := val["properties"].(map[string]interface{}); ok {
+				for name := range props {
+					seen[name] = true
+				}
+			}
+			for _, child := range val {
+				walk(child)
+			}
+		case []interface{}:
+			for _, child := range val {
+				walk(child)
+			}
+		}
+	}
+	walk(schema)
+	return sortedKeys(seen)
+}
+
+// closestMatch returns the candidate most similar to s, or "" if none is
+// close enough to be a likely typo
+func closestMatch(s string, candidates []string) string {
+	best, bestDist := "", -1
+	for _, c := range candidates {
+		if c == s {
+			continue
+		}
+		d := editDistance(strings.ToLower(s), strings.ToLower(c))
+		if bestDist < 0 || d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	// Allow roughly one edit per four characters
+	if bestDist < 0 || bestDist > 1+len(s)/4 {
+		return ""
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
@@ -0,0 +1,126 @@
+package devcontainer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateReportsAllProblems(t *testing.T) {
+	input := `{
+  // Comments and trailing commas are allowed
+  "image": "mcr.microsoft.com/devcontainers/base:ubuntu",
+  "forwardPorts": "abc",
+  "postCreateComand": "make",
+  "mounts": [{"type": "bnd", "target": "/data"}],
+  "extends": "../base/devcontainer.json",
+}`
+
+	diags, err := Validate([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Diagnostic{
+		{Pointer: "/forwardPorts", Line: 4, Column: 19, Severity: SeverityError, Message: "expected array, but got string"},
+		{Pointer: "/postCreateComand", Line: 5, Column: 3, Severity: SeverityWarning, Message: `property "postCreateComand" is not allowed`, Suggestion: "postCreateCommand"},
+		{Pointer: "/mounts/0/type", Line: 6, Column: 23, Severity: SeverityError, Message: `value must be one of "bind", "volume"`, Suggestion: "bind"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %d: %v", len(want), len(diags), diags)
+	}
+	for i := range want {
+		if diags[i] != want[i] {
+			t.Errorf("diagnostic %d:\nwant: %v\ngot:  %v", i, want[i], diags[i])
+		}
+	}
+
+	if !diags.HasErrors() {
+		t.Error("expected HasErrors to be true")
+	}
+	var verr *ValidationError
+	if err := diags.Err(); !errors.As(err, &verr) || strings.Contains(err.Error(), "postCreateComand") {
+		t.Errorf("expected a ValidationError listing only errors, got %v", err)
+	}
+}
+
+func TestValidateValidConfigs(t *testing.T) {
+	tests := map[string]string{
+		"image":      `{"image": "alpine", "forwardPorts": [3000, "db:5432"], "customizations": {"vscode": {}}}`,
+		"dockerfile": `{"build": {"dockerfile": "Dockerfile", "args": {"A": "b"}}, "hostRequirements": {"cpus": 2, "memory": "4gb"}}`,
+		"compose":    `{"dockerComposeFile": ["a.yml"], "service": "app", "workspaceFolder": "/workspace"}`,
+		"lifecycle":  `{"image": "alpine", "postCreateCommand": {"a": "make", "b": ["npm", "ci"]}, "mounts": ["source=x,target=/x,type=volume"]}`,
+	}
+	for name, input := range tests {
+		t.Run(name, func(t *testing.T) {
+			diags, err := Validate([]byte(input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(diags) != 0 {
+				t.Errorf("expected no diagnostics, got %v", diags)
+			}
+		})
+	}
+}
+
+func TestValidatePicksClosestAlternative(t *testing.T) {
+	diags, err := Validate([]byte(`{"dockerComposeFile": "a.yml", "service": "app"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || !strings.Contains(diags[0].Message, "workspaceFolder") {
+		t.Errorf("expected missing workspaceFolder, got %v", diags)
+	}
+}
+
+func TestValidatePrefersDeepestAlternative(t *testing.T) {
+	tests := []struct {
+		input   string
+		pointer string
+		line    int
+		column  int
+	}{
+		{`{"image": 1}`, "/image", 1, 11},
+		{"{\n  \"build\": {\"dockerfile\": 1}\n}", "/build/dockerfile", 2, 27},
+	}
+	for _, tt := range tests {
+		diags, err := Validate([]byte(tt.input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(diags) != 1 || diags[0].Pointer != tt.pointer || !strings.Contains(diags[0].Message, "expected string") ||
+			diags[0].Line != tt.line || diags[0].Column != tt.column {
+			t.Errorf("%s: expected a type error at %s, got %v", tt.input, tt.pointer, diags)
+		}
+	}
+}
+
+func TestValidateSyntaxError(t *testing.T) {
+	diags, err := Validate([]byte("{\n  \"image\": \n}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Severity != SeverityError || diags[0].Line != 3 || diags[0].Column != 1 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "devcontainer.json")
+	if err := os.WriteFile(path, []byte(`{"image": "alpine", "remoteUsr": "vscode"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	diags, err := ValidateFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags) != 1 || diags[0].Suggestion != "remoteUser" || diags.HasErrors() {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+	if got := diags[0].String(); got != `1:21: warning: /remoteUsr: property "remoteUsr" is not allowed (did you mean "remoteUser"?)` {
+		t.Errorf("unexpected String() %q", got)
+	}
+}
@@ -193,13 +193,13 @@ func TestValidationWithRealDevContainer(t *testing.T) {
 			ContainerEnv: map[string]string{
 				"GOPROXY": "https://proxy.golang.org",
 			},
-			ForwardPorts: []interface{}{float64(8080)},
-			Mounts: []interface{}{
-				map[string]interface{}{
-					"type":   "volume",
-					"source": "go-mod-cache",
-					"target": "/go/pkg/mod",
-				},
+			ForwardPorts: []Port{PortNumber(8080)},
+			Mounts: []MountEntry{
+				MountObject(Mount{
+					Type:   "volume",
+					Source: "go-mod-cache",
+					Target: "/go/pkg/mod",
+				}),
 			},
 			Init:       boolPtr(true),
 			Privileged: boolPtr(false),